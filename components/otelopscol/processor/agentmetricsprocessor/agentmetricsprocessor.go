@@ -41,6 +41,8 @@ type agentMetricsProcessor struct {
 	logger *zap.Logger
 	cfg    *Config
 
+	blankLabelMetrics metricNameMatcher
+
 	mutex             sync.Mutex
 	prevCPUTimeValues map[string]float64
 	prevOp            map[opKey]opData
@@ -48,9 +50,10 @@ type agentMetricsProcessor struct {
 
 func newAgentMetricsProcessor(logger *zap.Logger, cfg *Config) *agentMetricsProcessor {
 	return &agentMetricsProcessor{
-		logger: logger,
-		cfg:    cfg,
-		prevOp: make(map[opKey]opData),
+		logger:            logger,
+		cfg:               cfg,
+		blankLabelMetrics: newMetricNameMatcher(cfg.BlankLabelMetrics),
+		prevOp:            make(map[opKey]opData),
 	}
 }
 
@@ -80,11 +83,29 @@ func (mtp *agentMetricsProcessor) ProcessMetrics(_ context.Context, metrics pmet
 		errors = append(errors, err)
 	}
 
+	// Aggregate label values after utilizations have been computed from the
+	// individual series.
+	if len(mtp.cfg.AggregateLabelValues) > 0 {
+		mtp.aggregateLabelValues(metrics.ResourceMetrics())
+	}
+
+	// Drop all-zero series only after the other steps, since some of them
+	// need zero points, e.g. to compute utilizations.
+	if mtp.cfg.DropAllZeroSeries {
+		dropAllZeroSeries(metrics.ResourceMetrics())
+	}
+
 	// Add blank labels last so they can also be applied to metrics added by agentmetricsprocessor.
 	if err := mtp.addBlankLabel(metrics.ResourceMetrics()); err != nil {
 		errors = append(errors, err)
 	}
 
+	// Rename metrics after everything else, so that the other steps keep
+	// seeing the upstream names.
+	if len(mtp.cfg.RenameMetrics) > 0 {
+		renameMetrics(metrics.ResourceMetrics(), mtp.cfg.RenameMetrics)
+	}
+
 	if len(errors) > 0 {
 		return metrics, multierr.Combine(errors...)
 	}
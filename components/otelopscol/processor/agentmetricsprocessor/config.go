@@ -14,9 +14,89 @@
 
 package agentmetricsprocessor
 
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
 // Config defines configuration for Resource processor.
 type Config struct {
 	// BlankLabelMetrics is a list of metrics that need a label called
-	// "blank" with an empty value.
+	// "blank" with an empty value. Entries may be glob patterns as accepted
+	// by path.Match, e.g. "system.cpu.*"; entries without wildcards match
+	// the metric name exactly.
 	BlankLabelMetrics []string `mapstructure:"blank_label_metrics"`
+
+	// DropAllZeroSeries removes gauge and sum time series whose data points
+	// are all zero within a batch, to save ingestion quota.
+	DropAllZeroSeries bool `mapstructure:"drop_all_zero_series"`
+
+	// RenameMetrics maps metric names to the names they should be renamed
+	// to, e.g. to match a legacy dashboard. Metrics whose names are not keys
+	// are left untouched. The other options refer to metrics by their
+	// original names, since renaming happens last.
+	RenameMetrics map[string]string `mapstructure:"rename_metrics"`
+
+	// AggregateLabelValues lists metrics whose series should be merged across
+	// the values of one label, e.g. to turn per-CPU series into a single
+	// series for the whole machine.
+	AggregateLabelValues []AggregationRule `mapstructure:"aggregate_label_values"`
+}
+
+// Supported values of AggregationRule.Aggregation.
+const (
+	aggregationSum = "sum"
+	aggregationAvg = "avg"
+)
+
+// AggregationRule collapses one label of a metric: series that differ only
+// in that label are merged into one series without it, by aggregating their
+// points at matching timestamps.
+type AggregationRule struct {
+	// MetricName is the name of the metric to aggregate.
+	MetricName string `mapstructure:"metric_name"`
+	// Label is the label to collapse, e.g. "cpu".
+	Label string `mapstructure:"label"`
+	// Aggregation is how values are combined, either "sum" or "avg".
+	Aggregation string `mapstructure:"aggregation"`
+}
+
+// Validate checks that all BlankLabelMetrics patterns are well-formed, that
+// RenameMetrics does not map two metrics to the same name, and that all
+// AggregateLabelValues rules are complete.
+func (cfg *Config) Validate() error {
+	for _, name := range cfg.BlankLabelMetrics {
+		if _, err := path.Match(name, ""); err != nil {
+			return fmt.Errorf("invalid blank_label_metrics pattern %q: %w", name, err)
+		}
+	}
+
+	// Iterate in a fixed order so that the reported collision is stable.
+	sources := make([]string, 0, len(cfg.RenameMetrics))
+	for from := range cfg.RenameMetrics {
+		sources = append(sources, from)
+	}
+	slices.Sort(sources)
+	renamedFrom := make(map[string]string)
+	for _, from := range sources {
+		to := cfg.RenameMetrics[from]
+		if to == "" {
+			return fmt.Errorf("rename_metrics entry for %q has an empty target name", from)
+		}
+		if other, ok := renamedFrom[to]; ok {
+			return fmt.Errorf("rename_metrics maps both %q and %q to %q", other, from, to)
+		}
+		renamedFrom[to] = from
+	}
+
+	for i, rule := range cfg.AggregateLabelValues {
+		if rule.MetricName == "" || rule.Label == "" {
+			return fmt.Errorf("aggregate_label_values[%d] must set both metric_name and label", i)
+		}
+		if rule.Aggregation != aggregationSum && rule.Aggregation != aggregationAvg {
+			return fmt.Errorf("aggregate_label_values[%d] has unsupported aggregation %q, want %q or %q", i, rule.Aggregation, aggregationSum, aggregationAvg)
+		}
+	}
+	return nil
 }
@@ -41,3 +41,11 @@ func TestLoadConfig(t *testing.T) {
 
 	assert.Equal(t, want, p1)
 }
+
+func TestValidate(t *testing.T) {
+	cfg := &Config{BlankLabelMetrics: []string{"system.cpu.time", "system.cpu.*", "system.disk.[rw]*"}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg = &Config{BlankLabelMetrics: []string{"system.cpu.["}}
+	assert.ErrorContains(t, cfg.Validate(), `invalid blank_label_metrics pattern "system.cpu.["`)
+}
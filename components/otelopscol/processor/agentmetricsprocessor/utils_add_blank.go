@@ -15,11 +15,48 @@
 package agentmetricsprocessor
 
 import (
+	"path"
+	"strings"
+
 	"go.opentelemetry.io/collector/pdata/pmetric"
 )
 
 const labelName = "blank"
 
+// metricNameMatcher matches metric names against a list of exact names and
+// glob patterns.
+type metricNameMatcher struct {
+	names    map[string]bool
+	patterns []string
+}
+
+// newMetricNameMatcher sorts the given entries into exact names and glob
+// patterns, so that entries without wildcards keep exact-match semantics.
+// The patterns are assumed to have been checked by Config.Validate.
+func newMetricNameMatcher(entries []string) metricNameMatcher {
+	m := metricNameMatcher{names: make(map[string]bool)}
+	for _, entry := range entries {
+		if strings.ContainsAny(entry, `*?[\`) {
+			m.patterns = append(m.patterns, entry)
+		} else {
+			m.names[entry] = true
+		}
+	}
+	return m
+}
+
+func (m metricNameMatcher) matches(name string) bool {
+	if m.names[name] {
+		return true
+	}
+	for _, pattern := range m.patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
 func (mtp *agentMetricsProcessor) addBlankLabel(rms pmetric.ResourceMetricsSlice) error {
 	for i := 0; i < rms.Len(); i++ {
 		ilms := rms.At(i).ScopeMetrics()
@@ -27,13 +64,7 @@ func (mtp *agentMetricsProcessor) addBlankLabel(rms pmetric.ResourceMetricsSlice
 			metrics := ilms.At(j).Metrics()
 			for k := 0; k < metrics.Len(); k++ {
 				metric := metrics.At(k)
-				var found bool
-				for _, name := range mtp.cfg.BlankLabelMetrics {
-					if name == metric.Name() {
-						found = true
-					}
-				}
-				if !found {
+				if !mtp.blankLabelMetrics.matches(metric.Name()) {
 					continue
 				}
 				if err := forEachPoint(metric, addBlankLabel); err != nil {
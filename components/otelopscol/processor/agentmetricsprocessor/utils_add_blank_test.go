@@ -0,0 +1,62 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentmetricsprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+func TestAddBlankLabelPatterns(t *testing.T) {
+	amp := newAgentMetricsProcessor(zap.NewExample(), &Config{
+		BlankLabelMetrics: []string{"system.cpu.*", "system.memory.usage"},
+	})
+
+	rmb := newResourceMetricsBuilder()
+	b := rmb.addResourceMetrics(nil)
+	b.addMetric("system.cpu.time", pmetric.MetricTypeSum, true).addDoubleDataPoint(1, nil)
+	b.addMetric("system.cpu.utilization", pmetric.MetricTypeGauge, false).addDoubleDataPoint(0.5, nil)
+	b.addMetric("system.memory.usage", pmetric.MetricTypeGauge, false).addIntDataPoint(10, nil)
+	b.addMetric("system.memory.usage.total", pmetric.MetricTypeGauge, false).addIntDataPoint(10, nil)
+	b.addMetric("system.cpu", pmetric.MetricTypeGauge, false).addIntDataPoint(1, nil)
+	rms := rmb.Build()
+
+	require.NoError(t, amp.addBlankLabel(rms))
+
+	want := map[string]bool{
+		"system.cpu.time":           true,
+		"system.cpu.utilization":    true,
+		"system.memory.usage":       true,
+		"system.memory.usage.total": false,
+		"system.cpu":                false,
+	}
+	metrics := rms.At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		metric := metrics.At(i)
+		var attrs map[string]any
+		switch metric.Type() {
+		case pmetric.MetricTypeSum:
+			attrs = metric.Sum().DataPoints().At(0).Attributes().AsRaw()
+		case pmetric.MetricTypeGauge:
+			attrs = metric.Gauge().DataPoints().At(0).Attributes().AsRaw()
+		}
+		_, hasBlank := attrs[labelName]
+		assert.Equalf(t, want[metric.Name()], hasBlank, "Metric %s", metric.Name())
+	}
+}
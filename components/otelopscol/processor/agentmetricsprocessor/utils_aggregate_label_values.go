@@ -0,0 +1,161 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentmetricsprocessor
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// aggregationGroup holds the points of all series of a metric that differ
+// only in the label being collapsed.
+type aggregationGroup struct {
+	// A point of the group, used as a template for the aggregated points.
+	first       pmetric.NumberDataPoint
+	labelValues map[string]bool
+	points      map[pcommon.Timestamp][]pmetric.NumberDataPoint
+	// The label values of the points at each timestamp.
+	pointLabelValues map[pcommon.Timestamp]map[string]bool
+	// The timestamps in points, in the order they were first seen.
+	timestamps []pcommon.Timestamp
+}
+
+func (mtp *agentMetricsProcessor) aggregateLabelValues(rms pmetric.ResourceMetricsSlice) {
+	rules := make(map[string][]AggregationRule)
+	for _, rule := range mtp.cfg.AggregateLabelValues {
+		rules[rule.MetricName] = append(rules[rule.MetricName], rule)
+	}
+
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).ScopeMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			metrics := ilms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				var ndps pmetric.NumberDataPointSlice
+				switch metric.Type() {
+				case pmetric.MetricTypeSum:
+					ndps = metric.Sum().DataPoints()
+				case pmetric.MetricTypeGauge:
+					ndps = metric.Gauge().DataPoints()
+				default:
+					continue
+				}
+				for _, rule := range rules[metric.Name()] {
+					mtp.aggregateNumberDataPoints(metric.Name(), ndps, rule)
+				}
+			}
+		}
+	}
+}
+
+// aggregateNumberDataPoints replaces the points of ndps that have the rule's
+// label with their aggregates, leaving other points untouched. Points are
+// only aggregated if every series of their group has a point at the same
+// timestamp; unaligned points are dropped.
+func (mtp *agentMetricsProcessor) aggregateNumberDataPoints(metricName string, ndps pmetric.NumberDataPointSlice, rule AggregationRule) {
+	out := pmetric.NewNumberDataPointSlice()
+	groups := make(map[string]*aggregationGroup)
+	// The keys of groups, in the order they were first seen.
+	var keys []string
+	for i := 0; i < ndps.Len(); i++ {
+		ndp := ndps.At(i)
+		labelValue, ok := ndp.Attributes().Get(rule.Label)
+		if !ok {
+			ndp.CopyTo(out.AppendEmpty())
+			continue
+		}
+		key, _ := otherLabelsAsKey(ndp.Attributes(), rule.Label)
+		group, ok := groups[key]
+		if !ok {
+			group = &aggregationGroup{
+				first:            ndp,
+				labelValues:      make(map[string]bool),
+				points:           make(map[pcommon.Timestamp][]pmetric.NumberDataPoint),
+				pointLabelValues: make(map[pcommon.Timestamp]map[string]bool),
+			}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		value := labelValue.AsString()
+		group.labelValues[value] = true
+		ts := ndp.Timestamp()
+		if _, ok := group.points[ts]; !ok {
+			group.timestamps = append(group.timestamps, ts)
+			group.pointLabelValues[ts] = make(map[string]bool)
+		}
+		// A duplicate point would be counted twice when checking alignment,
+		// and could stand in for another label value's missing point.
+		if group.pointLabelValues[ts][value] {
+			mtp.logger.Debug("Dropping duplicate point",
+				zap.String("metric", metricName),
+				zap.String("label", rule.Label),
+				zap.String("label_value", value))
+			continue
+		}
+		group.pointLabelValues[ts][value] = true
+		group.points[ts] = append(group.points[ts], ndp)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		for _, ts := range group.timestamps {
+			points := group.points[ts]
+			if len(points) != len(group.labelValues) {
+				mtp.logger.Debug("Dropping points that are not aligned across all label values",
+					zap.String("metric", metricName),
+					zap.String("label", rule.Label),
+					zap.Int("points", len(points)),
+					zap.Int("label_values", len(group.labelValues)))
+				continue
+			}
+			aggregated := out.AppendEmpty()
+			group.first.Attributes().CopyTo(aggregated.Attributes())
+			aggregated.Attributes().Remove(rule.Label)
+			aggregated.SetStartTimestamp(points[0].StartTimestamp())
+			aggregated.SetTimestamp(ts)
+			setAggregatedValue(aggregated, points, rule.Aggregation)
+		}
+	}
+	out.CopyTo(ndps)
+}
+
+// setAggregatedValue sets the value of ndp to the aggregate of the values of
+// points. Sums of integer points stay integers; everything else is a double.
+func setAggregatedValue(ndp pmetric.NumberDataPoint, points []pmetric.NumberDataPoint, aggregation string) {
+	allInts := true
+	var intSum int64
+	var doubleSum float64
+	for _, point := range points {
+		switch point.ValueType() {
+		case pmetric.NumberDataPointValueTypeInt:
+			intSum += point.IntValue()
+			doubleSum += float64(point.IntValue())
+		case pmetric.NumberDataPointValueTypeDouble:
+			allInts = false
+			doubleSum += point.DoubleValue()
+		}
+	}
+
+	switch {
+	case aggregation == aggregationAvg:
+		ndp.SetDoubleValue(doubleSum / float64(len(points)))
+	case allInts:
+		ndp.SetIntValue(intSum)
+	default:
+		ndp.SetDoubleValue(doubleSum)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentmetricsprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+func TestAggregateLabelValues(t *testing.T) {
+	tests := []struct {
+		name        string
+		aggregation string
+		want        float64
+	}{
+		{name: "sum", aggregation: aggregationSum, want: 10},
+		{name: "avg", aggregation: aggregationAvg, want: 2.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amp := newAgentMetricsProcessor(zap.NewExample(), &Config{
+				AggregateLabelValues: []AggregationRule{{MetricName: "system.cpu.time", Label: "cpu", Aggregation: tt.aggregation}},
+			})
+
+			rmb := newResourceMetricsBuilder()
+			b := rmb.addResourceMetrics(nil)
+			b.addMetric("system.cpu.time", pmetric.MetricTypeSum, true).
+				addDoubleDataPoint(1, map[string]string{"cpu": "0", "state": "user"}).
+				addDoubleDataPoint(2, map[string]string{"cpu": "1", "state": "user"}).
+				addDoubleDataPoint(3, map[string]string{"cpu": "2", "state": "user"}).
+				addDoubleDataPoint(4, map[string]string{"cpu": "3", "state": "user"})
+			b.addMetric("system.memory.usage", pmetric.MetricTypeGauge, false).
+				addIntDataPoint(5, map[string]string{"cpu": "0"}).
+				addIntDataPoint(6, map[string]string{"cpu": "1"})
+			rms := rmb.Build()
+
+			amp.aggregateLabelValues(rms)
+
+			metrics := rms.At(0).ScopeMetrics().At(0).Metrics()
+			dps := metrics.At(0).Sum().DataPoints()
+			require.Equal(t, 1, dps.Len())
+			assert.Equal(t, tt.want, dps.At(0).DoubleValue())
+			assert.Equal(t, map[string]any{"state": "user"}, dps.At(0).Attributes().AsRaw())
+
+			assert.Equal(t, 2, metrics.At(1).Gauge().DataPoints().Len(), "metrics without a rule should be left untouched")
+		})
+	}
+}
+
+func TestAggregateLabelValuesDropsUnalignedPoints(t *testing.T) {
+	amp := newAgentMetricsProcessor(zap.NewExample(), &Config{
+		AggregateLabelValues: []AggregationRule{{MetricName: "system.cpu.time", Label: "cpu", Aggregation: aggregationSum}},
+	})
+
+	rmb := newResourceMetricsBuilder()
+	b := rmb.addResourceMetrics(nil)
+	b.addMetric("system.cpu.time", pmetric.MetricTypeSum, true).
+		addIntDataPoint(1, map[string]string{"cpu": "0"}).
+		addIntDataPoint(2, map[string]string{"cpu": "1"}).
+		addIntDataPoint(3, map[string]string{"cpu": "0"}).
+		addIntDataPoint(4, map[string]string{"cpu": "1"}).
+		addIntDataPoint(5, map[string]string{"cpu": "0"})
+	rms := rmb.Build()
+	dps := rms.At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints()
+	// Both CPUs have points at ts1 and ts2, but only cpu 0 has one at ts3.
+	ts1, ts2, ts3 := pcommon.Timestamp(1), pcommon.Timestamp(2), pcommon.Timestamp(3)
+	for i, ts := range []pcommon.Timestamp{ts1, ts1, ts2, ts2, ts3} {
+		dps.At(i).SetTimestamp(ts)
+	}
+
+	amp.aggregateLabelValues(rms)
+
+	require.Equal(t, 2, dps.Len())
+	assert.Equal(t, ts1, dps.At(0).Timestamp())
+	assert.Equal(t, int64(3), dps.At(0).IntValue())
+	assert.Equal(t, ts2, dps.At(1).Timestamp())
+	assert.Equal(t, int64(7), dps.At(1).IntValue())
+}
+
+func TestAggregateLabelValuesIgnoresDuplicatePoints(t *testing.T) {
+	amp := newAgentMetricsProcessor(zap.NewExample(), &Config{
+		AggregateLabelValues: []AggregationRule{{MetricName: "system.cpu.time", Label: "cpu", Aggregation: aggregationSum}},
+	})
+
+	rmb := newResourceMetricsBuilder()
+	b := rmb.addResourceMetrics(nil)
+	b.addMetric("system.cpu.time", pmetric.MetricTypeSum, true).
+		addIntDataPoint(1, map[string]string{"cpu": "0"}).
+		addIntDataPoint(2, map[string]string{"cpu": "0"}).
+		addIntDataPoint(3, map[string]string{"cpu": "0"}).
+		addIntDataPoint(4, map[string]string{"cpu": "1"})
+	rms := rmb.Build()
+	dps := rms.At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints()
+	// cpu 0 has two points at ts1 but cpu 1 has none, so ts1 is not aligned.
+	ts1, ts2 := pcommon.Timestamp(1), pcommon.Timestamp(2)
+	for i, ts := range []pcommon.Timestamp{ts1, ts1, ts2, ts2} {
+		dps.At(i).SetTimestamp(ts)
+	}
+
+	amp.aggregateLabelValues(rms)
+
+	require.Equal(t, 1, dps.Len())
+	assert.Equal(t, ts2, dps.At(0).Timestamp())
+	assert.Equal(t, int64(7), dps.At(0).IntValue())
+}
+
+func TestValidateAggregateLabelValues(t *testing.T) {
+	cfg := &Config{AggregateLabelValues: []AggregationRule{{MetricName: "system.cpu.time", Label: "cpu", Aggregation: "sum"}}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg = &Config{AggregateLabelValues: []AggregationRule{{MetricName: "system.cpu.time", Label: "cpu", Aggregation: "max"}}}
+	assert.ErrorContains(t, cfg.Validate(), `unsupported aggregation "max"`)
+
+	cfg = &Config{AggregateLabelValues: []AggregationRule{{MetricName: "system.cpu.time", Aggregation: "sum"}}}
+	assert.ErrorContains(t, cfg.Validate(), "must set both metric_name and label")
+}
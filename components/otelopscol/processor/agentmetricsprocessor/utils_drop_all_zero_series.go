@@ -0,0 +1,67 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentmetricsprocessor
+
+import (
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// dropAllZeroSeries removes every gauge and sum time series whose data points
+// in this batch are all zero. A time series is identified by its metric and
+// the attributes of its data points. Metrics left without any data points are
+// removed as well.
+func dropAllZeroSeries(rms pmetric.ResourceMetricsSlice) {
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).ScopeMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			ilms.At(j).Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				var dps pmetric.NumberDataPointSlice
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					dps = metric.Gauge().DataPoints()
+				case pmetric.MetricTypeSum:
+					dps = metric.Sum().DataPoints()
+				default:
+					return false
+				}
+				if dps.Len() == 0 {
+					// Leave metrics that were already empty alone.
+					return false
+				}
+
+				nonZeroSeries := make(map[string]bool)
+				for l := 0; l < dps.Len(); l++ {
+					if dp := dps.At(l); !isZero(dp) {
+						nonZeroSeries[labelsAsKey(dp.Attributes())] = true
+					}
+				}
+				dps.RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+					return !nonZeroSeries[labelsAsKey(dp.Attributes())]
+				})
+				return dps.Len() == 0
+			})
+		}
+	}
+}
+
+func isZero(dp pmetric.NumberDataPoint) bool {
+	switch dp.ValueType() {
+	case pmetric.NumberDataPointValueTypeInt:
+		return dp.IntValue() == 0
+	case pmetric.NumberDataPointValueTypeDouble:
+		return dp.DoubleValue() == 0
+	}
+	return false
+}
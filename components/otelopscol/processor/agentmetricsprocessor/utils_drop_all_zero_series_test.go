@@ -0,0 +1,63 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentmetricsprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestDropAllZeroSeries(t *testing.T) {
+	rmb := newResourceMetricsBuilder()
+	b := rmb.addResourceMetrics(nil)
+
+	b.addMetric("int.sum", pmetric.MetricTypeSum, true).
+		addIntDataPoint(0, map[string]string{"series": "zero"}).
+		addIntDataPoint(0, map[string]string{"series": "zero"}).
+		addIntDataPoint(0, map[string]string{"series": "one-nonzero"}).
+		addIntDataPoint(5, map[string]string{"series": "one-nonzero"})
+	b.addMetric("double.gauge", pmetric.MetricTypeGauge, false).
+		addDoubleDataPoint(0, map[string]string{"series": "zero"}).
+		addDoubleDataPoint(1.5, map[string]string{"series": "nonzero"})
+	b.addMetric("all.zero.int.gauge", pmetric.MetricTypeGauge, false).
+		addIntDataPoint(0, map[string]string{"series": "a"}).
+		addIntDataPoint(0, map[string]string{"series": "b"})
+	b.addMetric("all.zero.double.sum", pmetric.MetricTypeSum, true).
+		addDoubleDataPoint(0, nil)
+	rms := rmb.Build()
+
+	dropAllZeroSeries(rms)
+
+	metrics := rms.At(0).ScopeMetrics().At(0).Metrics()
+	require.Equal(t, 2, metrics.Len(), "fully-zero metrics should be removed")
+
+	intSum := metrics.At(0)
+	assert.Equal(t, "int.sum", intSum.Name())
+	dps := intSum.Sum().DataPoints()
+	require.Equal(t, 2, dps.Len(), "a series with a single nonzero point should be kept whole")
+	for i := 0; i < dps.Len(); i++ {
+		series, _ := dps.At(i).Attributes().Get("series")
+		assert.Equal(t, "one-nonzero", series.Str())
+	}
+
+	doubleGauge := metrics.At(1)
+	assert.Equal(t, "double.gauge", doubleGauge.Name())
+	dps = doubleGauge.Gauge().DataPoints()
+	require.Equal(t, 1, dps.Len())
+	assert.Equal(t, 1.5, dps.At(0).DoubleValue())
+}
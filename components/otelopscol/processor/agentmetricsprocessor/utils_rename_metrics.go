@@ -0,0 +1,36 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentmetricsprocessor
+
+import (
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// renameMetrics renames every metric whose name is a key of renames to the
+// corresponding value. Data points, units and descriptions are unchanged.
+func renameMetrics(rms pmetric.ResourceMetricsSlice, renames map[string]string) {
+	for i := 0; i < rms.Len(); i++ {
+		ilms := rms.At(i).ScopeMetrics()
+		for j := 0; j < ilms.Len(); j++ {
+			metrics := ilms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+				if newName, ok := renames[metric.Name()]; ok {
+					metric.SetName(newName)
+				}
+			}
+		}
+	}
+}
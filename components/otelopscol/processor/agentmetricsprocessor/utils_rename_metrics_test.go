@@ -0,0 +1,63 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentmetricsprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestRenameMetrics(t *testing.T) {
+	rmb := newResourceMetricsBuilder()
+	b := rmb.addResourceMetrics(nil)
+
+	b.addMetric("system.cpu.time", pmetric.MetricTypeSum, true).
+		addDoubleDataPoint(1.5, map[string]string{"state": "user"}).
+		addDoubleDataPoint(2.5, map[string]string{"state": "system"})
+	b.addMetric("system.memory.usage", pmetric.MetricTypeGauge, false).
+		addIntDataPoint(42, map[string]string{"state": "used"})
+	rms := rmb.Build()
+	metrics := rms.At(0).ScopeMetrics().At(0).Metrics()
+	metrics.At(0).SetUnit("s")
+	want := pmetric.NewMetricSlice()
+	metrics.CopyTo(want)
+	want.At(0).SetName("legacy.cpu.time")
+
+	renameMetrics(rms, map[string]string{
+		"system.cpu.time":  "legacy.cpu.time",
+		"not.in.the.batch": "also.not.in.the.batch",
+	})
+
+	require.Equal(t, 2, metrics.Len())
+	assert.Equal(t, "legacy.cpu.time", metrics.At(0).Name())
+	assert.Equal(t, "system.memory.usage", metrics.At(1).Name(), "metrics without a mapping should keep their names")
+	// Apart from the name, the renamed metric should be unchanged, including
+	// its unit, data point values and attributes.
+	assert.Equal(t, want, metrics)
+}
+
+func TestValidateRenameMetrics(t *testing.T) {
+	cfg := &Config{RenameMetrics: map[string]string{"a": "x", "b": "y"}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg = &Config{RenameMetrics: map[string]string{"a": "x", "b": "x"}}
+	assert.ErrorContains(t, cfg.Validate(), `rename_metrics maps both "a" and "b" to "x"`)
+
+	cfg = &Config{RenameMetrics: map[string]string{"a": ""}}
+	assert.ErrorContains(t, cfg.Validate(), `rename_metrics entry for "a" has an empty target name`)
+}
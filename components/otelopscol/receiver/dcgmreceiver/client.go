@@ -18,9 +18,11 @@
 package dcgmreceiver
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
+	"math/rand/v2"
 	"time"
 
 	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
@@ -33,17 +35,21 @@ const dcgmProfilingFieldsStart = dcgm.Short(1000)
 
 var ErrDcgmInitialization = errors.New("error initializing DCGM")
 
+var errPollTimeout = errors.New("timed out polling DCGM daemon for field values")
+
 type dcgmClientSettings struct {
 	endpoint         string
 	pollingInterval  time.Duration
 	retryBlankValues bool
 	maxRetries       int
+	scrapeTimeout    time.Duration
 	fields           []string
 }
 
 type deviceMetrics struct {
 	ModelName string
 	UUID      string
+	PCIBusID  string
 	Metrics   MetricsMap
 }
 
@@ -53,6 +59,9 @@ type dcgmClient struct {
 	enabledFieldIDs   []dcgm.Short
 	enabledFieldGroup dcgm.FieldHandle
 	deviceGroup       dcgm.GroupHandle
+	// unavailableFieldIDs are the requested fields that the GPUs don't
+	// support, and that are therefore not watched.
+	unavailableFieldIDs []dcgm.Short
 
 	devices            map[uint]deviceMetrics
 	lastSuccessfulPoll time.Time
@@ -61,6 +70,17 @@ type dcgmClient struct {
 	pollingInterval                time.Duration
 	retryBlankValues               bool
 	maxRetries                     int
+	scrapeTimeout                  time.Duration
+	// pendingPoll receives the result of a poll of DCGM that timed out and
+	// is still running. It is nil if no such poll exists.
+	pendingPoll chan valuesSinceResult
+}
+
+// valuesSinceResult holds the values returned by dcgmGetValuesSince.
+type valuesSinceResult struct {
+	fieldValues []dcgm.FieldValue_v2
+	pollTime    time.Time
+	err         error
 }
 
 // Can't pass argument dcgm.mode because it is unexported
@@ -70,6 +90,12 @@ var dcgmInit = func(args ...string) (func(), error) {
 
 var dcgmGetValuesSince = dcgm.GetValuesSince
 
+var dcgmFieldGroupDestroy = dcgm.FieldGroupDestroy
+
+var dcgmDestroyGroup = dcgm.DestroyGroup
+
+var dcgmGetSupportedMetricGroups = dcgm.GetSupportedMetricGroups
+
 func newClient(settings *dcgmClientSettings, logger *zap.Logger) (*dcgmClient, error) {
 	dcgmCleanup, err := initializeDcgm(settings.endpoint, logger)
 	if err != nil {
@@ -83,25 +109,28 @@ func newClient(settings *dcgmClientSettings, logger *zap.Logger) (*dcgmClient, e
 		// receiver collect basic metrics: (GPU utilization, used/free memory).
 		logger.Sugar().Warnf("Error querying supported profiling fields on '%w'. GPU profiling metrics will not be collected.", err)
 	}
+	// Unsupported fields are logged by the scraper, once rather than on every
+	// reconnect.
 	enabledFields, unavailableFields := filterSupportedFields(requestedFieldIDs, supportedProfilingFieldIDs)
-	for _, f := range unavailableFields {
-		logger.Sugar().Warnf("Field '%s' is not supported", dcgmIDToName[f])
-	}
 	var deviceGroup dcgm.GroupHandle
 	if len(enabledFields) != 0 {
 		supportedDeviceIndices, err := dcgm.GetSupportedDevices()
 		if err != nil {
+			dcgmCleanup()
 			return nil, fmt.Errorf("Unable to discover supported GPUs on %w", err)
 		}
 		logger.Sugar().Infof("Discovered %d supported GPU devices", len(supportedDeviceIndices))
 
 		deviceGroup, err = createDeviceGroup(logger, supportedDeviceIndices)
 		if err != nil {
+			dcgmCleanup()
 			return nil, err
 		}
 		enabledFieldGroup, err = setWatchesOnEnabledFields(settings.pollingInterval, logger, deviceGroup, enabledFields)
 		if err != nil {
-			_ = dcgm.FieldGroupDestroy(enabledFieldGroup)
+			_ = dcgmFieldGroupDestroy(enabledFieldGroup)
+			_ = dcgmDestroyGroup(deviceGroup)
+			dcgmCleanup()
 			return nil, fmt.Errorf("Unable to set field watches on %w", err)
 		}
 	}
@@ -109,6 +138,7 @@ func newClient(settings *dcgmClientSettings, logger *zap.Logger) (*dcgmClient, e
 		logger:                         logger.Sugar(),
 		handleCleanup:                  dcgmCleanup,
 		enabledFieldIDs:                enabledFields,
+		unavailableFieldIDs:            unavailableFields,
 		enabledFieldGroup:              enabledFieldGroup,
 		deviceGroup:                    deviceGroup,
 		devices:                        map[uint]deviceMetrics{},
@@ -117,14 +147,15 @@ func newClient(settings *dcgmClientSettings, logger *zap.Logger) (*dcgmClient, e
 		pollingInterval:                settings.pollingInterval,
 		retryBlankValues:               settings.retryBlankValues,
 		maxRetries:                     settings.maxRetries,
+		scrapeTimeout:                  settings.scrapeTimeout,
 	}, nil
 }
 
 // initializeDcgm tries to initialize a DCGM connection; returns a cleanup func
 // only if the connection is initialized successfully without error
 func initializeDcgm(endpoint string, logger *zap.Logger) (func(), error) {
-	isSocket := "0"
-	dcgmCleanup, err := dcgmInit(endpoint, isSocket)
+	address, isSocket := hostengineAddress(endpoint)
+	dcgmCleanup, err := dcgmInit(address, isSocket)
 	if err != nil {
 		msg := fmt.Sprintf("Unable to connect to DCGM daemon at %s on %v; Is the DCGM daemon running?", endpoint, err)
 		logger.Sugar().Warn(msg)
@@ -147,9 +178,10 @@ func newDeviceMetrics(logger *zap.SugaredLogger, gpuIndex uint) (deviceMetrics,
 	device := deviceMetrics{
 		ModelName: deviceInfo.Identifiers.Model,
 		UUID:      deviceInfo.UUID,
+		PCIBusID:  deviceInfo.PCI.BusID,
 		Metrics:   MetricsMap{},
 	}
-	logger.Infof("Discovered NVIDIA device %s with UUID %s (DCGM GPU ID %d)", device.ModelName, device.UUID, gpuIndex)
+	logger.Infof("Discovered NVIDIA device %s with UUID %s at PCI bus ID %s (DCGM GPU ID %d)", device.ModelName, device.UUID, device.PCIBusID, gpuIndex)
 	return device, nil
 }
 
@@ -163,6 +195,7 @@ func createDeviceGroup(logger *zap.Logger, deviceIndices []uint) (dcgm.GroupHand
 	for _, gpuIndex := range deviceIndices {
 		err = dcgm.AddToGroup(deviceGroup, gpuIndex)
 		if err != nil {
+			_ = dcgmDestroyGroup(deviceGroup)
 			return dcgm.GroupHandle{}, fmt.Errorf("Unable add NVIDIA device %d to GPU group '%s' on %w", gpuIndex, deviceGroupName, err)
 		}
 	}
@@ -171,6 +204,17 @@ func createDeviceGroup(logger *zap.Logger, deviceIndices []uint) (dcgm.GroupHand
 	return deviceGroup, nil
 }
 
+// checkFieldsKnown returns an error naming the first of the given fields that
+// this version of DCGM does not know about.
+func checkFieldsKnown(fields []string) error {
+	for _, f := range fields {
+		if _, ok := dcgm.DCGM_FI[f]; !ok {
+			return fmt.Errorf("unsupported DCGM field %q", f)
+		}
+	}
+	return nil
+}
+
 func toFieldIDs(fields []string) []dcgm.Short {
 	requestedFieldIDs := make([]dcgm.Short, len(fields))
 	for i, f := range fields {
@@ -187,7 +231,7 @@ func getSupportedProfilingFields() ([]dcgm.Short, error) {
 	// group handle; here we pass 0 to query supported fields for group 0, which
 	// is the default DCGM group that is **supposed** to include all GPUs of the
 	// host.
-	fieldGroups, err := dcgm.GetSupportedMetricGroups(0)
+	fieldGroups, err := dcgmGetSupportedMetricGroups(0)
 	if err != nil {
 		var dcgmErr *dcgm.DcgmError
 		if errors.As(err, &dcgmErr) {
@@ -275,7 +319,7 @@ const maxKeepSamples = 100 // TODO: Is this enough?
 func setWatchesOnEnabledFields(pollingInterval time.Duration, logger *zap.Logger, deviceGroup dcgm.GroupHandle, enabledFieldIDs []dcgm.Short) (dcgm.FieldHandle, error) {
 	return setWatchesOnFields(logger, deviceGroup, enabledFieldIDs, dcgmWatchParams{
 		// Note: Add random suffix to avoid conflict amongnst any parallel collectors
-		fieldGroupName: fmt.Sprintf("google-cloud-ops-agent-metrics-%d", randSource.Intn(10000)),
+		fieldGroupName: fmt.Sprintf("google-cloud-ops-agent-metrics-%d", rand.IntN(10000)),
 		// Note: DCGM retained samples = Max(maxKeepSamples, maxKeepTime/updateFreq)
 		updateFreqUs:   int64(pollingInterval / time.Microsecond),
 		maxKeepTime:    600.0, /* 10 min */
@@ -283,25 +327,64 @@ func setWatchesOnEnabledFields(pollingInterval time.Duration, logger *zap.Logger
 	})
 }
 
+// cleanup releases the DCGM groups and connection held by the client. It is
+// safe to call more than once; only the first call releases anything.
 func (client *dcgmClient) cleanup() {
-	_ = dcgm.FieldGroupDestroy(client.enabledFieldGroup)
-	_ = dcgm.DestroyGroup(client.deviceGroup)
-	if client.handleCleanup != nil {
-		client.handleCleanup()
+	if client.handleCleanup == nil {
+		return
+	}
+	if client.pendingPoll != nil {
+		// Avoid destroying the field group out from under a poll that is
+		// still using it, unless DCGM is stuck for good.
+		select {
+		case <-client.pendingPoll:
+		case <-time.After(client.scrapeTimeout):
+			client.logger.Warnf("DCGM daemon is still being polled after %s; shutting down anyway", client.scrapeTimeout)
+		}
+		client.pendingPoll = nil
 	}
+	_ = dcgmFieldGroupDestroy(client.enabledFieldGroup)
+	_ = dcgmDestroyGroup(client.deviceGroup)
+	client.handleCleanup()
+	client.handleCleanup = nil
 
 	client.logger.Info("Shutdown DCGM")
 }
 
+// getValuesSince polls DCGM for the field values reported since the last
+// successful poll, giving up when ctx is done. A poll that gives up keeps
+// running in the background and the next call waits for its result instead
+// of starting another one, so that the field group is never queried
+// concurrently and no values are lost.
+func (client *dcgmClient) getValuesSince(ctx context.Context) ([]dcgm.FieldValue_v2, time.Time, error) {
+	if client.pendingPoll == nil {
+		resultCh := make(chan valuesSinceResult, 1)
+		deviceGroup, fieldGroup, since := client.deviceGroup, client.enabledFieldGroup, client.lastSuccessfulPoll
+		go func() {
+			fieldValues, pollTime, err := dcgmGetValuesSince(deviceGroup, fieldGroup, since)
+			resultCh <- valuesSinceResult{fieldValues, pollTime, err}
+		}()
+		client.pendingPoll = resultCh
+	}
+	select {
+	case result := <-client.pendingPoll:
+		client.pendingPoll = nil
+		return result.fieldValues, result.pollTime, result.err
+	case <-ctx.Done():
+		return nil, time.Time{}, fmt.Errorf("%w: %w", errPollTimeout, ctx.Err())
+	}
+}
+
 // collect will poll dcgm for any new metrics, updating client.devices as appropriate
-// It returns the estimated polling interval.
-func (client *dcgmClient) collect() (time.Duration, error) {
+// It returns the estimated polling interval. It gives up on the poll with an
+// error wrapping errPollTimeout when ctx is done.
+func (client *dcgmClient) collect(ctx context.Context) (time.Duration, error) {
 	client.logger.Debugf("Polling DCGM daemon for field values")
 	if len(client.enabledFieldIDs) == 0 {
 		// Make sure we don't try to scrape without a device group (since we don't construct one when there are no enabled fields).
 		return 0, nil
 	}
-	fieldValues, pollTime, err := dcgmGetValuesSince(client.deviceGroup, client.enabledFieldGroup, client.lastSuccessfulPoll)
+	fieldValues, pollTime, err := client.getValuesSince(ctx)
 	if err != nil {
 		msg := fmt.Sprintf("Unable to poll DCGM daemon for metrics: %s", err)
 		client.issueWarningForFailedQueryUptoThreshold("all-profiling-metrics", maxWarningsForFailedDeviceMetricQuery, msg)
@@ -20,6 +20,7 @@
 package dcgmreceiver
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -57,6 +58,7 @@ func defaultClientSettings() *dcgmClientSettings {
 		pollingInterval:  1 * time.Second,
 		retryBlankValues: true,
 		maxRetries:       5,
+		scrapeTimeout:    defaultScrapeTimeout,
 		fields:           requestedFields,
 	}
 }
@@ -91,7 +93,7 @@ func TestSupportedProfilingFieldsWithGolden(t *testing.T) {
 	}
 	sort.Strings(enabledFieldsString)
 	sort.Strings(unavailableFieldsString)
-	_, err = client.collect()
+	_, err = client.collect(context.Background())
 	require.Nil(t, err)
 	require.NotEmpty(t, client.devices)
 	gpuModel := client.devices[0].ModelName
@@ -152,7 +154,7 @@ func TestCollectGpuProfilingMetrics(t *testing.T) {
 	var before, after int64
 	for {
 		before = time.Now().UnixMicro() - maxCollectionInterval.Microseconds()
-		duration, err := client.collect()
+		duration, err := client.collect(context.Background())
 		after = time.Now().UnixMicro()
 		assert.Greater(t, duration, time.Duration(0))
 		assert.Nil(t, err)
@@ -23,6 +23,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -50,3 +51,35 @@ func TestNewDcgmClientOnInitializationError(t *testing.T) {
 	assert.Regexp(t, ".*Unable to connect.*", err)
 	assert.Nil(t, client)
 }
+
+func TestCheckFieldsKnown(t *testing.T) {
+	assert.NoError(t, checkFieldsKnown(discoverRequestedFields(createDefaultConfig().(*Config))))
+
+	err := checkFieldsKnown([]string{"DCGM_FI_DEV_GPU_UTIL", "DCGM_FI_NOT_A_FIELD"})
+	assert.ErrorContains(t, err, `unsupported DCGM field "DCGM_FI_NOT_A_FIELD"`)
+}
+
+func TestCleanupReleasesResourcesOnce(t *testing.T) {
+	realDcgmFieldGroupDestroy, realDcgmDestroyGroup := dcgmFieldGroupDestroy, dcgmDestroyGroup
+	defer func() { dcgmFieldGroupDestroy, dcgmDestroyGroup = realDcgmFieldGroupDestroy, realDcgmDestroyGroup }()
+	var fieldGroupDestroyed, groupDestroyed, handleClosed int
+	dcgmFieldGroupDestroy = func(dcgm.FieldHandle) error {
+		fieldGroupDestroyed++
+		return nil
+	}
+	dcgmDestroyGroup = func(dcgm.GroupHandle) error {
+		groupDestroyed++
+		return nil
+	}
+
+	client := &dcgmClient{
+		logger:        zaptest.NewLogger(t).Sugar(),
+		handleCleanup: func() { handleClosed++ },
+	}
+	client.cleanup()
+	client.cleanup()
+
+	assert.Equal(t, 1, fieldGroupDestroyed, "field group destroyed")
+	assert.Equal(t, 1, groupDestroyed, "device group destroyed")
+	assert.Equal(t, 1, handleClosed, "DCGM handle closed")
+}
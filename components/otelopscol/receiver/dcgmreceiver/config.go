@@ -15,19 +15,93 @@
 package dcgmreceiver
 
 import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/collector/config/confignet"
 	"go.opentelemetry.io/collector/scraper/scraperhelper"
 
 	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/dcgmreceiver/internal/metadata"
+	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/internal/scrapeschedule"
 )
 
 const defaultEndpoint = "localhost:5555"
 const defaultCollectionInterval = 20 * time.Second
+const defaultScrapeTimeout = 5 * time.Second
+
+// unixSocketPrefix marks an endpoint as the path of a Unix domain socket that
+// the DCGM hostengine listens on (nv-hostengine --domain-socket), instead of
+// a TCP host:port.
+const unixSocketPrefix = "unix://"
 
 type Config struct {
 	scraperhelper.ControllerConfig `mapstructure:",squash"`
 	confignet.TCPAddrConfig        `mapstructure:",squash"`
-	Metrics                        metadata.MetricsConfig `mapstructure:"metrics"`
+	Metrics                        metadata.MetricsConfig            `mapstructure:"metrics"`
+	ResourceAttributes             metadata.ResourceAttributesConfig `mapstructure:"resource_attributes"`
+	// ScrapeTimeout bounds how long a single poll of DCGM for field values
+	// may take, so that a hung DCGM daemon or driver can't stall the
+	// collector.
+	ScrapeTimeout time.Duration `mapstructure:"scrape_timeout"`
+	// InitRetryWindow is how long starting the receiver keeps retrying to
+	// connect to DCGM, which can take a while to come up after a driver load
+	// or host boot, before failing. If zero, the receiver starts right away
+	// and keeps trying to connect in the background.
+	InitRetryWindow time.Duration `mapstructure:"init_retry_window"`
+	// InitialDelayJitter is the upper bound of a random delay added to
+	// initial_delay before the first scrape, so that collectors started at
+	// the same time don't all query DCGM at once.
+	InitialDelayJitter time.Duration `mapstructure:"initial_delay_jitter"`
+	// IntervalJitter is the upper bound of a random delay before each
+	// scrape, so that collectors scraping at the same interval drift apart
+	// instead of staying in sync. It must be shorter than
+	// collection_interval.
+	IntervalJitter time.Duration `mapstructure:"interval_jitter"`
+}
+
+// Validate checks that the endpoint is a valid TCP address or Unix domain
+// socket path, so that a typo fails collector startup instead of silently
+// producing no data.
+func (c *Config) Validate() error {
+	if err := validateEndpoint(c.TCPAddrConfig.Endpoint); err != nil {
+		return err
+	}
+	if c.ScrapeTimeout <= 0 {
+		return fmt.Errorf("invalid scrape_timeout %v: must be positive", c.ScrapeTimeout)
+	}
+	if c.InitRetryWindow < 0 {
+		return fmt.Errorf("invalid init_retry_window %v: must not be negative", c.InitRetryWindow)
+	}
+	return scrapeschedule.ValidateJitter(c.InitialDelayJitter, c.IntervalJitter, c.CollectionInterval)
+}
+
+func validateEndpoint(endpoint string) error {
+	if path, ok := strings.CutPrefix(endpoint, unixSocketPrefix); ok {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("invalid endpoint %q: socket path must be absolute", endpoint)
+		}
+		return nil
+	}
+	_, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("invalid endpoint %q: port must be a number between 0 and 65535", endpoint)
+	}
+	return nil
+}
+
+// hostengineAddress returns the address of the DCGM hostengine to pass to
+// dcgm.Init for the given endpoint, and whether it is a Unix domain socket
+// ("1") or a TCP address ("0").
+func hostengineAddress(endpoint string) (address string, isSocket string) {
+	if path, ok := strings.CutPrefix(endpoint, unixSocketPrefix); ok {
+		return path, "1"
+	}
+	return endpoint, "0"
 }
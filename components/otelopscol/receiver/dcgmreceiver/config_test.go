@@ -0,0 +1,136 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dcgmreceiver
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap/confmaptest"
+
+	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/dcgmreceiver/internal/metadata"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		endpoint    string
+		expectedErr string
+	}{
+		{
+			desc:     "default endpoint",
+			endpoint: defaultEndpoint,
+		},
+		{
+			desc:     "ip endpoint",
+			endpoint: "127.0.0.1:5555",
+		},
+		{
+			desc:        "missing port",
+			endpoint:    "localhost",
+			expectedErr: `invalid endpoint "localhost"`,
+		},
+		{
+			desc:        "non-numeric port",
+			endpoint:    "localhost:dcgm",
+			expectedErr: "port must be a number",
+		},
+		{
+			desc:        "port out of range",
+			endpoint:    "localhost:70000",
+			expectedErr: "port must be a number",
+		},
+		{
+			desc:     "unix socket endpoint",
+			endpoint: "unix:///run/nvidia/nv-hostengine.sock",
+		},
+		{
+			desc:        "relative unix socket path",
+			endpoint:    "unix://nv-hostengine.sock",
+			expectedErr: "socket path must be absolute",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.TCPAddrConfig.Endpoint = tc.endpoint
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestValidateScrapeTimeout(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	require.Equal(t, defaultScrapeTimeout, cfg.ScrapeTimeout)
+	require.NoError(t, cfg.Validate())
+
+	cfg.ScrapeTimeout = 0
+	require.ErrorContains(t, cfg.Validate(), "invalid scrape_timeout")
+}
+
+func TestValidateInitRetryWindow(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	require.Zero(t, cfg.InitRetryWindow)
+
+	cfg.InitRetryWindow = time.Minute
+	require.NoError(t, cfg.Validate())
+
+	cfg.InitRetryWindow = -time.Minute
+	require.ErrorContains(t, cfg.Validate(), "invalid init_retry_window")
+}
+
+func TestLoadConfig(t *testing.T) {
+	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "config.yaml"))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		id           component.ID
+		wantAddress  string
+		wantIsSocket string
+	}{
+		{
+			id:           component.NewID(metadata.Type),
+			wantAddress:  "localhost:5555",
+			wantIsSocket: "0",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "socket"),
+			wantAddress:  "/run/nvidia/nv-hostengine.sock",
+			wantIsSocket: "1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.id.String(), func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			sub, err := cm.Sub(tc.id.String())
+			require.NoError(t, err)
+			require.NoError(t, sub.Unmarshal(cfg))
+			require.NoError(t, cfg.Validate())
+
+			address, isSocket := hostengineAddress(cfg.TCPAddrConfig.Endpoint)
+			require.Equal(t, tc.wantAddress, address)
+			require.Equal(t, tc.wantIsSocket, isSocket)
+		})
+	}
+}
@@ -20,9 +20,7 @@ package dcgmreceiver
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	"strings"
-	"time"
 
 	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 	"go.opentelemetry.io/collector/component"
@@ -32,10 +30,10 @@ import (
 	"go.opentelemetry.io/collector/scraper/scraperhelper"
 
 	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/dcgmreceiver/internal/metadata"
+	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/internal/scrapeschedule"
 )
 
 var dcgmIDToName map[dcgm.Short]string
-var randSource = rand.New(rand.NewSource(time.Now().UnixMicro()))
 
 func init() {
 	dcgmIDToName = make(map[dcgm.Short]string, len(dcgm.DCGM_FI))
@@ -57,18 +55,39 @@ func createMetricsReceiver(
 	if !ok {
 		return nil, fmt.Errorf("Unable to cast receiver configuration to dcgm.Config")
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid dcgm receiver configuration: %w", err)
+	}
 
 	ns := newDcgmScraper(cfg, params)
+	// Keep collectors that scrape at the same interval from staying in sync.
+	// The jitter is applied to the ticks between scrapes, so that it doesn't
+	// count against the scrape timeout.
+	ticker := scrapeschedule.NewTicker(cfg.CollectionInterval, cfg.IntervalJitter)
 	scp, err := scraper.NewMetrics(
 		ns.scrape,
-		scraper.WithStart(ns.start),
-		scraper.WithShutdown(ns.stop))
+		scraper.WithStart(func(ctx context.Context, host component.Host) error {
+			if err := ns.start(ctx, host); err != nil {
+				return err
+			}
+			ticker.Start()
+			return nil
+		}),
+		scraper.WithShutdown(func(ctx context.Context) error {
+			ticker.Stop()
+			return ns.stop(ctx)
+		}))
 	if err != nil {
 		return nil, err
 	}
 
+	// Extend the initial delay so that collectors started together don't all
+	// scrape at once.
+	controllerConfig := cfg.ControllerConfig
+	controllerConfig.InitialDelay += scrapeschedule.Jitter(cfg.InitialDelayJitter)
 	return scraperhelper.NewMetricsController(
-		&cfg.ControllerConfig, params, consumer,
+		&controllerConfig, params, consumer,
 		scraperhelper.AddScraper(metadata.Type, scp),
+		scraperhelper.WithTickerChannel(ticker.C),
 	)
 }
@@ -20,9 +20,11 @@ package dcgmreceiver
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/dcgmreceiver/internal/metadata"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/receiver/receivertest"
 )
@@ -42,3 +44,45 @@ func TestCreateMetricsReceiverOnLinux(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, receiver, "failed to create metrics receiver")
 }
+
+func TestCreateMetricsReceiverWithBadEndpoint(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig()
+	receiverConfig := cfg.(*Config)
+	receiverConfig.TCPAddrConfig.Endpoint = "localhost"
+
+	receiver, err := factory.CreateMetrics(
+		context.Background(),
+		receivertest.NewNopSettings(metadata.Type),
+		receiverConfig,
+		consumertest.NewNop(),
+	)
+
+	require.ErrorContains(t, err, `invalid dcgm receiver configuration: invalid endpoint "localhost"`)
+	require.Nil(t, receiver)
+}
+
+// The collector recreates the receiver when its configuration is reloaded,
+// so a changed collection_interval applies to the receiver created from it.
+func TestCreateMetricsReceiverCollectionInterval(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.InitialDelay = 0
+	cfg.CollectionInterval = 20 * time.Millisecond
+	sink := new(consumertest.MetricsSink)
+	rcvr, err := factory.CreateMetrics(
+		context.Background(),
+		receivertest.NewNopSettings(metadata.Type),
+		cfg,
+		sink,
+	)
+	require.NoError(t, err)
+	require.NoError(t, rcvr.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, rcvr.Shutdown(context.Background()))
+	}()
+
+	// The default interval is several seconds long, so this many scrapes can
+	// only come from the configured interval.
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) >= 5 }, 10*time.Second, 10*time.Millisecond)
+}
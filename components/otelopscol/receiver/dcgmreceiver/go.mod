@@ -3,7 +3,9 @@ module github.com/GoogleCloudPlatform/opentelemetry-operations-collector/compone
 go 1.25.0
 
 require (
+	github.com/GoogleCloudPlatform/opentelemetry-operations-collector v0.156.0
 	github.com/NVIDIA/go-dcgm v0.0.0-20240910155525-85ceb314ca65
+	github.com/cenkalti/backoff/v4 v4.3.0
 	github.com/google/go-cmp v0.7.0
 	github.com/stretchr/testify v1.11.1
 	go.opentelemetry.io/collector/component v1.62.0
@@ -18,13 +20,14 @@ require (
 	go.opentelemetry.io/collector/receiver/receivertest v0.156.0
 	go.opentelemetry.io/collector/scraper v0.156.0
 	go.opentelemetry.io/collector/scraper/scraperhelper v0.156.0
+	go.opentelemetry.io/otel v1.44.0
 	go.opentelemetry.io/otel/metric v1.44.0
 	go.opentelemetry.io/otel/trace v1.44.0
 	go.uber.org/goleak v1.3.0
 	go.uber.org/zap v1.28.0
 	golang.org/x/sync v0.20.0
 	gopkg.in/yaml.v3 v3.0.1
-	gotest.tools/v3 v3.5.1
+	gotest.tools/v3 v3.5.2
 )
 
 require (
@@ -58,7 +61,6 @@ require (
 	go.opentelemetry.io/collector/pipeline/xpipeline v0.156.0 // indirect
 	go.opentelemetry.io/collector/receiver/receiverhelper v0.156.0 // indirect
 	go.opentelemetry.io/collector/receiver/xreceiver v0.156.0 // indirect
-	go.opentelemetry.io/otel v1.44.0 // indirect
 	go.opentelemetry.io/otel/sdk v1.44.0 // indirect
 	go.opentelemetry.io/otel/sdk/metric v1.44.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
@@ -68,3 +70,5 @@ require (
 	google.golang.org/grpc v1.82.0 // indirect
 	google.golang.org/protobuf v1.36.11 // indirect
 )
+
+replace github.com/GoogleCloudPlatform/opentelemetry-operations-collector => ../../../../
@@ -640,9 +640,10 @@ func (rac *ResourceAttributeConfig) Unmarshal(parser *confmap.Conf) error {
 
 // ResourceAttributesConfig provides config for dcgm resource attributes.
 type ResourceAttributesConfig struct {
-	GpuModel  ResourceAttributeConfig `mapstructure:"gpu.model"`
-	GpuNumber ResourceAttributeConfig `mapstructure:"gpu.number"`
-	GpuUUID   ResourceAttributeConfig `mapstructure:"gpu.uuid"`
+	GpuModel    ResourceAttributeConfig `mapstructure:"gpu.model"`
+	GpuNumber   ResourceAttributeConfig `mapstructure:"gpu.number"`
+	GpuPciBusID ResourceAttributeConfig `mapstructure:"gpu.pci_bus_id"`
+	GpuUUID     ResourceAttributeConfig `mapstructure:"gpu.uuid"`
 }
 
 func DefaultResourceAttributesConfig() ResourceAttributesConfig {
@@ -653,6 +654,9 @@ func DefaultResourceAttributesConfig() ResourceAttributesConfig {
 		GpuNumber: ResourceAttributeConfig{
 			Enabled: true,
 		},
+		GpuPciBusID: ResourceAttributeConfig{
+			Enabled: true,
+		},
 		GpuUUID: ResourceAttributeConfig{
 			Enabled: true,
 		},
@@ -90,9 +90,10 @@ func TestMetricsBuilderConfig(t *testing.T) {
 					},
 				},
 				ResourceAttributes: ResourceAttributesConfig{
-					GpuModel:  ResourceAttributeConfig{Enabled: true},
-					GpuNumber: ResourceAttributeConfig{Enabled: true},
-					GpuUUID:   ResourceAttributeConfig{Enabled: true},
+					GpuModel:    ResourceAttributeConfig{Enabled: true},
+					GpuNumber:   ResourceAttributeConfig{Enabled: true},
+					GpuPciBusID: ResourceAttributeConfig{Enabled: true},
+					GpuUUID:     ResourceAttributeConfig{Enabled: true},
 				},
 			},
 		},
@@ -164,9 +165,10 @@ func TestMetricsBuilderConfig(t *testing.T) {
 					},
 				},
 				ResourceAttributes: ResourceAttributesConfig{
-					GpuModel:  ResourceAttributeConfig{Enabled: false},
-					GpuNumber: ResourceAttributeConfig{Enabled: false},
-					GpuUUID:   ResourceAttributeConfig{Enabled: false},
+					GpuModel:    ResourceAttributeConfig{Enabled: false},
+					GpuNumber:   ResourceAttributeConfig{Enabled: false},
+					GpuPciBusID: ResourceAttributeConfig{Enabled: false},
+					GpuUUID:     ResourceAttributeConfig{Enabled: false},
 				},
 			},
 		},
@@ -286,17 +288,19 @@ func TestResourceAttributesConfig(t *testing.T) {
 		{
 			name: "all_set",
 			want: ResourceAttributesConfig{
-				GpuModel:  ResourceAttributeConfig{Enabled: true},
-				GpuNumber: ResourceAttributeConfig{Enabled: true},
-				GpuUUID:   ResourceAttributeConfig{Enabled: true},
+				GpuModel:    ResourceAttributeConfig{Enabled: true},
+				GpuNumber:   ResourceAttributeConfig{Enabled: true},
+				GpuPciBusID: ResourceAttributeConfig{Enabled: true},
+				GpuUUID:     ResourceAttributeConfig{Enabled: true},
 			},
 		},
 		{
 			name: "none_set",
 			want: ResourceAttributesConfig{
-				GpuModel:  ResourceAttributeConfig{Enabled: false},
-				GpuNumber: ResourceAttributeConfig{Enabled: false},
-				GpuUUID:   ResourceAttributeConfig{Enabled: false},
+				GpuModel:    ResourceAttributeConfig{Enabled: false},
+				GpuNumber:   ResourceAttributeConfig{Enabled: false},
+				GpuPciBusID: ResourceAttributeConfig{Enabled: false},
+				GpuUUID:     ResourceAttributeConfig{Enabled: false},
 			},
 		},
 	}
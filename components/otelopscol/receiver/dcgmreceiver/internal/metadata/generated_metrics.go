@@ -1434,6 +1434,12 @@ func NewMetricsBuilder(mbc MetricsBuilderConfig, settings receiver.Settings, opt
 	if mbc.ResourceAttributes.GpuNumber.MetricsExclude != nil {
 		mb.resourceAttributeExcludeFilter["gpu.number"] = filter.CreateFilter(mbc.ResourceAttributes.GpuNumber.MetricsExclude)
 	}
+	if mbc.ResourceAttributes.GpuPciBusID.MetricsInclude != nil {
+		mb.resourceAttributeIncludeFilter["gpu.pci_bus_id"] = filter.CreateFilter(mbc.ResourceAttributes.GpuPciBusID.MetricsInclude)
+	}
+	if mbc.ResourceAttributes.GpuPciBusID.MetricsExclude != nil {
+		mb.resourceAttributeExcludeFilter["gpu.pci_bus_id"] = filter.CreateFilter(mbc.ResourceAttributes.GpuPciBusID.MetricsExclude)
+	}
 	if mbc.ResourceAttributes.GpuUUID.MetricsInclude != nil {
 		mb.resourceAttributeIncludeFilter["gpu.uuid"] = filter.CreateFilter(mbc.ResourceAttributes.GpuUUID.MetricsInclude)
 	}
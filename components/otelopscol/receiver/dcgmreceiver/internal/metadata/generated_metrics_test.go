@@ -155,6 +155,7 @@ func TestMetricsBuilder(t *testing.T) {
 			rb := mb.NewResourceBuilder()
 			rb.SetGpuModel("gpu.model-val")
 			rb.SetGpuNumber("gpu.number-val")
+			rb.SetGpuPciBusID("gpu.pci_bus_id-val")
 			rb.SetGpuUUID("gpu.uuid-val")
 			res := rb.Emit()
 			metrics := mb.Emit(WithResource(res))
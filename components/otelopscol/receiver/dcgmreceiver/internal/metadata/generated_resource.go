@@ -35,6 +35,13 @@ func (rb *ResourceBuilder) SetGpuNumber(val string) {
 	}
 }
 
+// SetGpuPciBusID sets provided value as "gpu.pci_bus_id" attribute.
+func (rb *ResourceBuilder) SetGpuPciBusID(val string) {
+	if rb.config.GpuPciBusID.Enabled {
+		rb.res.Attributes().PutStr("gpu.pci_bus_id", val)
+	}
+}
+
 // SetGpuUUID sets provided value as "gpu.uuid" attribute.
 func (rb *ResourceBuilder) SetGpuUUID(val string) {
 	if rb.config.GpuUUID.Enabled {
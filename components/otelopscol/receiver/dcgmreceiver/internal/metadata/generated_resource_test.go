@@ -15,6 +15,7 @@ func TestResourceBuilder(t *testing.T) {
 			rb := NewResourceBuilder(cfg)
 			rb.SetGpuModel("gpu.model-val")
 			rb.SetGpuNumber("gpu.number-val")
+			rb.SetGpuPciBusID("gpu.pci_bus_id-val")
 			rb.SetGpuUUID("gpu.uuid-val")
 
 			res := rb.Emit()
@@ -22,9 +23,9 @@ func TestResourceBuilder(t *testing.T) {
 
 			switch tt {
 			case "default":
-				assert.Equal(t, 3, res.Attributes().Len())
+				assert.Equal(t, 4, res.Attributes().Len())
 			case "all_set":
-				assert.Equal(t, 3, res.Attributes().Len())
+				assert.Equal(t, 4, res.Attributes().Len())
 			case "none_set":
 				assert.Equal(t, 0, res.Attributes().Len())
 				return
@@ -41,6 +42,11 @@ func TestResourceBuilder(t *testing.T) {
 			if ok {
 				assert.Equal(t, "gpu.number-val", gpuNumberAttrVal.Str())
 			}
+			gpuPciBusIDAttrVal, ok := res.Attributes().Get("gpu.pci_bus_id")
+			assert.True(t, ok)
+			if ok {
+				assert.Equal(t, "gpu.pci_bus_id-val", gpuPciBusIDAttrVal.Str())
+			}
 			gpuUUIDAttrVal, ok := res.Attributes().Get("gpu.uuid")
 			assert.True(t, ok)
 			if ok {
@@ -21,12 +21,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"sync"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/receiver"
+	"go.opentelemetry.io/collector/scraper/scrapererror"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/dcgmreceiver/internal/metadata"
@@ -40,10 +46,30 @@ type dcgmScraper struct {
 	collectTriggerCh chan<- struct{}
 	metricsCh        <-chan map[uint]deviceMetrics
 	cancel           func()
+	// initBackoffInterval is the first delay between attempts to connect to
+	// DCGM during start; later delays grow exponentially.
+	initBackoffInterval time.Duration
+	// pollErr is set when the most recent poll of DCGM timed out, and is
+	// reported by scrape alongside the metrics collected before that.
+	pollErrMu sync.Mutex
+	pollErr   error
+	// fieldSupport records whether the GPUs support each requested DCGM
+	// field, as of the most recent connection to DCGM. Unsupported fields are
+	// not watched. It is reported through the receiver's internal telemetry.
+	fieldSupportMu sync.Mutex
+	fieldSupport   map[string]bool
+	// fieldSupportRegistration unregisters the telemetry callback that
+	// reports fieldSupport. It is nil if there is no such callback.
+	fieldSupportRegistration metric.Registration
 }
 
 func newDcgmScraper(config *Config, settings receiver.Settings) *dcgmScraper {
-	return &dcgmScraper{config: config, settings: settings, initRetryDelay: 10 * time.Second}
+	return &dcgmScraper{
+		config:              config,
+		settings:            settings,
+		initRetryDelay:      10 * time.Second,
+		initBackoffInterval: time.Second,
+	}
 }
 
 const scrapePollingInterval = 100 * time.Millisecond // TODO: Choose an appropriate value
@@ -58,6 +84,7 @@ func (s *dcgmScraper) initClient() (*dcgmClient, error) {
 		fields:           discoverRequestedFields(s.config),
 		retryBlankValues: true,
 		maxRetries:       5,
+		scrapeTimeout:    s.config.ScrapeTimeout,
 	}
 	client, err := newClient(clientSettings, s.settings.Logger)
 	if err != nil {
@@ -69,16 +96,121 @@ func (s *dcgmScraper) initClient() (*dcgmClient, error) {
 		}
 		return nil, err
 	}
+	s.recordFieldSupport(client)
+	return client, nil
+}
+
+// recordFieldSupport records which of the requested fields the given client
+// watches and which it dropped because the GPUs don't support them, and logs
+// the dropped ones if they differ from the last connection's.
+func (s *dcgmScraper) recordFieldSupport(client *dcgmClient) {
+	fieldSupport := make(map[string]bool)
+	for _, f := range client.enabledFieldIDs {
+		fieldSupport[dcgmIDToName[f]] = true
+	}
+	var dropped []string
+	for _, f := range client.unavailableFieldIDs {
+		fieldSupport[dcgmIDToName[f]] = false
+		dropped = append(dropped, dcgmIDToName[f])
+	}
+
+	s.fieldSupportMu.Lock()
+	defer s.fieldSupportMu.Unlock()
+	if len(dropped) > 0 && !maps.Equal(fieldSupport, s.fieldSupport) {
+		s.settings.Logger.Sugar().Warnf("Not collecting DCGM fields that the GPUs don't support: %v", dropped)
+	}
+	s.fieldSupport = fieldSupport
+}
+
+// getFieldSupport returns a copy of fieldSupport.
+func (s *dcgmScraper) getFieldSupport() map[string]bool {
+	s.fieldSupportMu.Lock()
+	defer s.fieldSupportMu.Unlock()
+	return maps.Clone(s.fieldSupport)
+}
+
+// registerFieldSupportTelemetry reports which of the requested fields the
+// GPUs support through the receiver's internal telemetry, to help debug
+// missing metrics.
+func (s *dcgmScraper) registerFieldSupportTelemetry() error {
+	meter := metadata.Meter(s.settings.TelemetrySettings)
+	gauge, err := meter.Int64ObservableGauge(
+		"otelcol_receiver_dcgm_field_supported",
+		metric.WithDescription("Whether the GPUs support a requested DCGM field (1) or not (0). Unsupported fields are not collected."),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+	s.fieldSupportRegistration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for field, supported := range s.getFieldSupport() {
+			var value int64
+			if supported {
+				value = 1
+			}
+			o.ObserveInt64(gauge, value, metric.WithAttributes(attribute.String("field", field)))
+		}
+		return nil
+	}, gauge)
+	return err
+}
+
+// connectWithRetry tries to connect to DCGM, backing off exponentially between
+// attempts, until config.InitRetryWindow has passed.
+func (s *dcgmScraper) connectWithRetry(ctx context.Context) (*dcgmClient, error) {
+	var client *dcgmClient
+	tryConnect := func() error {
+		var err error
+		client, err = s.initClient()
+		if err != nil {
+			// DCGM is available but the client couldn't be set up;
+			// retrying won't help.
+			return backoff.Permanent(err)
+		}
+		if client == nil {
+			// Returning a non-permanent error triggers retries.
+			return ErrDcgmInitialization
+		}
+		return nil
+	}
+	backoffPolicy := backoff.NewExponentialBackOff()
+	backoffPolicy.InitialInterval = s.initBackoffInterval
+	backoffPolicy.MaxElapsedTime = s.config.InitRetryWindow
+	if err := backoff.Retry(tryConnect, backoff.WithContext(backoffPolicy, ctx)); err != nil {
+		return nil, fmt.Errorf("unable to connect to DCGM within %s: %w", s.config.InitRetryWindow, err)
+	}
 	return client, nil
 }
 
 func (s *dcgmScraper) start(ctx context.Context, _ component.Host) error {
+	// Unknown fields can never be collected, so fail startup instead of
+	// retrying forever in the connect loop.
+	if err := checkFieldsKnown(discoverRequestedFields(s.config)); err != nil {
+		return fmt.Errorf("unable to start dcgm receiver: %w", err)
+	}
+
+	var client *dcgmClient
+	if s.config.InitRetryWindow > 0 {
+		var err error
+		client, err = s.connectWithRetry(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to start dcgm receiver: %w", err)
+		}
+	}
+
 	startTime := pcommon.NewTimestampFromTime(time.Now())
 	mbConfig := metadata.DefaultMetricsBuilderConfig()
 	mbConfig.Metrics = s.config.Metrics
+	mbConfig.ResourceAttributes = s.config.ResourceAttributes
 	s.mb = metadata.NewMetricsBuilder(
 		mbConfig, s.settings, metadata.WithStartTime(startTime))
 
+	if s.settings.TelemetrySettings.MeterProvider != nil {
+		if err := s.registerFieldSupportTelemetry(); err != nil {
+			return fmt.Errorf("unable to start dcgm receiver: %w", err)
+		}
+	}
+
 	scrapeCtx, scrapeCancel := context.WithCancel(context.WithoutCancel(ctx))
 	g, scrapeCtx := errgroup.WithContext(scrapeCtx)
 
@@ -93,7 +225,7 @@ func (s *dcgmScraper) start(ctx context.Context, _ component.Host) error {
 	s.collectTriggerCh = collectTriggerCh
 
 	g.Go(func() error {
-		return s.runConnectLoop(scrapeCtx, metricsCh, collectTriggerCh)
+		return s.runConnectLoop(scrapeCtx, client, metricsCh, collectTriggerCh)
 	})
 
 	return nil
@@ -104,6 +236,11 @@ func (s *dcgmScraper) stop(_ context.Context) error {
 		s.cancel()
 		s.cancel = nil
 	}
+	if s.fieldSupportRegistration != nil {
+		err := s.fieldSupportRegistration.Unregister()
+		s.fieldSupportRegistration = nil
+		return err
+	}
 	return nil
 }
 
@@ -180,13 +317,18 @@ func discoverRequestedFields(config *Config) []string {
 	return requestedFields
 }
 
-func (s *dcgmScraper) runConnectLoop(ctx context.Context, metricsCh chan<- map[uint]deviceMetrics, collectTriggerCh <-chan struct{}) error {
+// runConnectLoop polls DCGM through client, reconnecting whenever the
+// connection is lost. If client is nil, it connects first.
+func (s *dcgmScraper) runConnectLoop(ctx context.Context, client *dcgmClient, metricsCh chan<- map[uint]deviceMetrics, collectTriggerCh <-chan struct{}) error {
 	defer close(metricsCh)
 	for {
-		client, _ := s.initClient()
-		// Ignore the error; it's logged in initClient.
+		if client == nil {
+			client, _ = s.initClient()
+			// Ignore the error; it's logged in initClient.
+		}
 		if client != nil {
 			s.pollClient(ctx, client, metricsCh, collectTriggerCh)
+			client = nil
 		}
 		select {
 		case <-ctx.Done():
@@ -201,8 +343,11 @@ func (s *dcgmScraper) runConnectLoop(ctx context.Context, metricsCh chan<- map[u
 func (s *dcgmScraper) pollClient(ctx context.Context, client *dcgmClient, metricsCh chan<- map[uint]deviceMetrics, collectTriggerCh <-chan struct{}) {
 	defer client.cleanup()
 	for {
-		waitTime, err := client.collect()
-		// Ignore the error; it's logged in collect()
+		collectCtx, collectCancel := context.WithTimeout(ctx, s.config.ScrapeTimeout)
+		waitTime, err := client.collect(collectCtx)
+		collectCancel()
+		// Other than timeouts, ignore the error; it's logged in collect()
+		s.setPollErr(err)
 		if err != nil {
 			waitTime = 10 * time.Second
 		}
@@ -232,6 +377,22 @@ func (s *dcgmScraper) pollClient(ctx context.Context, client *dcgmClient, metric
 	}
 }
 
+// setPollErr records the result of the most recent poll of DCGM.
+func (s *dcgmScraper) setPollErr(err error) {
+	if !errors.Is(err, errPollTimeout) {
+		err = nil
+	}
+	s.pollErrMu.Lock()
+	defer s.pollErrMu.Unlock()
+	s.pollErr = err
+}
+
+func (s *dcgmScraper) getPollErr() error {
+	s.pollErrMu.Lock()
+	defer s.pollErrMu.Unlock()
+	return s.pollErr
+}
+
 func (s *dcgmScraper) scrape(ctx context.Context) (pmetric.Metrics, error) {
 	var deviceMetrics map[uint]deviceMetrics
 	// Trigger a collection cycle to make sure we have fresh metrics.
@@ -255,6 +416,7 @@ func (s *dcgmScraper) scrape(ctx context.Context) (pmetric.Metrics, error) {
 		rb.SetGpuNumber(fmt.Sprintf("%d", gpuIndex))
 		rb.SetGpuUUID(gpu.UUID)
 		rb.SetGpuModel(gpu.ModelName)
+		rb.SetGpuPciBusID(gpu.PCIBusID)
 		gpuResource := rb.Emit()
 
 		v, ok := gpu.Metrics.LastFloat64("DCGM_FI_PROF_GR_ENGINE_ACTIVE")
@@ -368,5 +530,10 @@ func (s *dcgmScraper) scrape(ctx context.Context) (pmetric.Metrics, error) {
 		s.mb.EmitForResource(metadata.WithResource(gpuResource))
 	}
 
+	if err := s.getPollErr(); err != nil {
+		// The metrics are whatever was collected before DCGM stopped
+		// responding.
+		return s.mb.Emit(), scrapererror.NewPartialScrapeError(err, 0)
+	}
 	return s.mb.Emit(), nil
 }
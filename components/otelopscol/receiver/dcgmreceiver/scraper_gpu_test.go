@@ -140,6 +140,41 @@ func TestScrapeWithDelayedDcgmService(t *testing.T) {
 	validateScraperResult(t, metrics)
 }
 
+func TestStartRetriesDcgmInit(t *testing.T) {
+	realDcgmInit := dcgmInit
+	defer func() { dcgmInit = realDcgmInit }()
+	failures := 2
+	dcgmInit = func(args ...string) (func(), error) {
+		if failures > 0 {
+			failures--
+			return nil, fmt.Errorf("No DCGM client library *OR* No DCGM connection")
+		}
+		return realDcgmInit(args...)
+	}
+
+	var settings receiver.Settings
+	settings.Logger = zaptest.NewLogger(t)
+
+	config := createDefaultConfig().(*Config)
+	config.InitRetryWindow = time.Minute
+	scraper := newDcgmScraper(config, settings)
+	require.NotNil(t, scraper)
+
+	scraper.initBackoffInterval = 10 * time.Millisecond
+
+	// Start only returns once DCGM is connected.
+	err := scraper.start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	assert.Equal(t, 0, failures)
+
+	metrics, err := collectScraperResult(t, context.Background(), scraper)
+	assert.NoError(t, err)
+
+	assert.NoError(t, scraper.stop(context.Background()))
+
+	validateScraperResult(t, metrics)
+}
+
 func TestScrapeWithEmptyMetricsConfig(t *testing.T) {
 	var settings receiver.Settings
 	settings.Logger = zaptest.NewLogger(t)
@@ -150,6 +185,7 @@ func TestScrapeWithEmptyMetricsConfig(t *testing.T) {
 		TCPAddrConfig: confignet.TCPAddrConfig{
 			Endpoint: defaultEndpoint,
 		},
+		ScrapeTimeout: defaultScrapeTimeout,
 		Metrics: metadata.MetricsConfig{
 			GpuDcgmClockFrequency: metadata.MetricConfig{
 				Enabled: false,
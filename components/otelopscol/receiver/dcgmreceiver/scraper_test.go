@@ -19,17 +19,24 @@ package dcgmreceiver
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/NVIDIA/go-dcgm/pkg/dcgm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/receiver"
+	"go.opentelemetry.io/collector/scraper/scrapererror"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
+
+	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/dcgmreceiver/internal/metadata"
 )
 
 func TestScraperWithoutDcgm(t *testing.T) {
@@ -69,3 +76,193 @@ func TestScraperWithoutDcgm(t *testing.T) {
 	err = scraper.stop(context.Background())
 	assert.NoError(t, err)
 }
+
+func TestScrapeTimeout(t *testing.T) {
+	// Fake a DCGM daemon that hangs until released, and track how many polls
+	// are in flight at once.
+	release := make(chan struct{})
+	var inFlight, maxInFlight atomic.Int32
+	realDcgmGetValuesSince := dcgmGetValuesSince
+	defer func() { dcgmGetValuesSince = realDcgmGetValuesSince }()
+	dcgmGetValuesSince = func(_ dcgm.GroupHandle, _ dcgm.FieldHandle, _ time.Time) ([]dcgm.FieldValue_v2, time.Time, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		if n > maxInFlight.Load() {
+			maxInFlight.Store(n)
+		}
+		<-release
+		return nil, time.Now(), nil
+	}
+
+	var settings receiver.Settings
+	settings.Logger = zaptest.NewLogger(t)
+	config := createDefaultConfig().(*Config)
+	config.ScrapeTimeout = 50 * time.Millisecond
+	scraper := newDcgmScraper(config, settings)
+	scraper.mb = metadata.NewMetricsBuilder(metadata.DefaultMetricsBuilderConfig(), settings)
+
+	metricsCh := make(chan map[uint]deviceMetrics)
+	collectTriggerCh := make(chan struct{}, 1)
+	scraper.metricsCh = metricsCh
+	scraper.collectTriggerCh = collectTriggerCh
+	client := &dcgmClient{
+		logger:                         settings.Logger.Sugar(),
+		enabledFieldIDs:                []dcgm.Short{dcgm.DCGM_FI["DCGM_FI_DEV_GPU_UTIL"]},
+		devices:                        map[uint]deviceMetrics{},
+		lastSuccessfulPoll:             time.Now(),
+		deviceMetricToFailedQueryCount: map[string]int{},
+		pollingInterval:                scrapePollingInterval,
+		scrapeTimeout:                  config.ScrapeTimeout,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scraper.pollClient(ctx, client, metricsCh, collectTriggerCh)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	start := time.Now()
+	metrics, err := scraper.scrape(context.Background())
+	assert.Less(t, time.Since(start), 10*time.Second, "scrape was not bounded by the scrape timeout")
+	assert.True(t, errors.Is(err, errPollTimeout), "got error %v, want a poll timeout", err)
+	assert.True(t, scrapererror.IsPartialScrapeError(err))
+	assert.Equal(t, 0, metrics.MetricCount())
+
+	// Once DCGM responds again, scrapes succeed without the hung poll ever
+	// having been repeated concurrently.
+	close(release)
+	assert.Eventually(t, func() bool {
+		_, err := scraper.scrape(context.Background())
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), maxInFlight.Load())
+}
+
+func TestStartGivesUpAfterInitRetryWindow(t *testing.T) {
+	realDcgmInit := dcgmInit
+	defer func() { dcgmInit = realDcgmInit }()
+	var attempts atomic.Int32
+	dcgmInit = func(...string) (func(), error) {
+		attempts.Add(1)
+		return nil, errors.New("No DCGM client library *OR* No DCGM connection")
+	}
+
+	var settings receiver.Settings
+	settings.Logger = zaptest.NewLogger(t)
+	config := createDefaultConfig().(*Config)
+	config.InitRetryWindow = 200 * time.Millisecond
+	scraper := newDcgmScraper(config, settings)
+	scraper.initBackoffInterval = 10 * time.Millisecond
+
+	err := scraper.start(context.Background(), componenttest.NewNopHost())
+	assert.ErrorIs(t, err, ErrDcgmInitialization)
+	assert.Greater(t, attempts.Load(), int32(1), "DCGM initialization was not retried")
+
+	// Stopping a scraper that never started is a no-op.
+	assert.NoError(t, scraper.stop(context.Background()))
+	assert.NoError(t, scraper.stop(context.Background()))
+}
+
+func TestScrapeResourceAttributes(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*Config)
+		want      map[string]any
+	}{
+		{
+			name:      "default",
+			configure: func(*Config) {},
+			want: map[string]any{
+				"gpu.number":     "0",
+				"gpu.model":      "Tesla T4",
+				"gpu.uuid":       "GPU-00000000-0000-0000-0000-000000000000",
+				"gpu.pci_bus_id": "00000000:00:04.0",
+			},
+		},
+		{
+			name: "pci bus id disabled",
+			configure: func(config *Config) {
+				config.ResourceAttributes.GpuPciBusID.Enabled = false
+			},
+			want: map[string]any{
+				"gpu.number": "0",
+				"gpu.model":  "Tesla T4",
+				"gpu.uuid":   "GPU-00000000-0000-0000-0000-000000000000",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var settings receiver.Settings
+			settings.Logger = zaptest.NewLogger(t)
+			config := createDefaultConfig().(*Config)
+			tc.configure(config)
+			scraper := newDcgmScraper(config, settings)
+			mbConfig := metadata.DefaultMetricsBuilderConfig()
+			mbConfig.ResourceAttributes = config.ResourceAttributes
+			scraper.mb = metadata.NewMetricsBuilder(mbConfig, settings)
+
+			temperature := fieldValueFloat64(t, 1, 50)
+			metricsCh := make(chan map[uint]deviceMetrics, 1)
+			metricsCh <- map[uint]deviceMetrics{
+				0: {
+					ModelName: "Tesla T4",
+					UUID:      "GPU-00000000-0000-0000-0000-000000000000",
+					PCIBusID:  "00000000:00:04.0",
+					Metrics: MetricsMap{
+						"DCGM_FI_DEV_GPU_TEMP": &metricStats{lastFieldValue: &temperature},
+					},
+				},
+			}
+			scraper.metricsCh = metricsCh
+			scraper.collectTriggerCh = make(chan struct{}, 1)
+
+			metrics, err := scraper.scrape(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, 1, metrics.ResourceMetrics().Len())
+			assert.Equal(t, tc.want, metrics.ResourceMetrics().At(0).Resource().Attributes().AsRaw())
+		})
+	}
+}
+
+func TestUnsupportedFieldsDropped(t *testing.T) {
+	realDcgmGetSupportedMetricGroups := dcgmGetSupportedMetricGroups
+	defer func() { dcgmGetSupportedMetricGroups = realDcgmGetSupportedMetricGroups }()
+	// Fake an older GPU that only supports one of the profiling fields.
+	dcgmGetSupportedMetricGroups = func(uint) ([]dcgm.MetricGroup, error) {
+		return []dcgm.MetricGroup{
+			{FieldIds: []uint{uint(dcgm.DCGM_FI["DCGM_FI_PROF_SM_ACTIVE"])}},
+		}, nil
+	}
+
+	supported, err := getSupportedProfilingFields()
+	require.NoError(t, err)
+	requested := toFieldIDs([]string{"DCGM_FI_DEV_GPU_UTIL", "DCGM_FI_PROF_SM_ACTIVE", "DCGM_FI_PROF_SM_OCCUPANCY"})
+	enabled, unavailable := filterSupportedFields(requested, supported)
+
+	var settings receiver.Settings
+	var droppedWarnings atomic.Int32
+	settings.Logger = zaptest.NewLogger(t, zaptest.WrapOptions(zap.Hooks(func(e zapcore.Entry) error {
+		if e.Level == zap.WarnLevel && strings.Contains(e.Message, "DCGM_FI_PROF_SM_OCCUPANCY") {
+			droppedWarnings.Add(1)
+		}
+		return nil
+	})))
+	scraper := newDcgmScraper(createDefaultConfig().(*Config), settings)
+	client := &dcgmClient{enabledFieldIDs: enabled, unavailableFieldIDs: unavailable}
+
+	// Reconnecting to DCGM doesn't log the same dropped fields again.
+	scraper.recordFieldSupport(client)
+	scraper.recordFieldSupport(client)
+	assert.Equal(t, int32(1), droppedWarnings.Load())
+	assert.Equal(t, map[string]bool{
+		"DCGM_FI_DEV_GPU_UTIL":      true,
+		"DCGM_FI_PROF_SM_ACTIVE":    true,
+		"DCGM_FI_PROF_SM_OCCUPANCY": false,
+	}, scraper.getFieldSupport())
+}
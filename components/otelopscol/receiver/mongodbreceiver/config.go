@@ -40,6 +40,14 @@ type Config struct {
 	Password   string                 `mapstructure:"password"`
 	ReplicaSet string                 `mapstructure:"replica_set,omitempty"`
 	Timeout    time.Duration          `mapstructure:"timeout"`
+	// CollectIndexStats enables the per-collection $indexStats aggregation
+	// that produces mongodb.index.access.count. It is off by default because
+	// it runs one aggregation per collection on every scrape.
+	CollectIndexStats bool `mapstructure:"collect_index_stats"`
+	// Databases limits per-database metrics (dbStats, and collection and
+	// index stats) to the named databases. If empty, all databases are
+	// scraped.
+	Databases []string `mapstructure:"databases"`
 }
 
 func (c *Config) Validate() error {
@@ -54,6 +62,13 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for _, db := range c.Databases {
+		if db == "" {
+			err = multierr.Append(err, errors.New("empty database name in databases"))
+			break
+		}
+	}
+
 	if c.Username != "" && c.Password == "" {
 		err = multierr.Append(err, errors.New("username provided without password"))
 	} else if c.Username == "" && c.Password != "" {
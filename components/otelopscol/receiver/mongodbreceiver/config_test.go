@@ -15,7 +15,17 @@
 package mongodbreceiver // import "github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/mongodbreceiver"
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
+	"math/big"
+	"net"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -35,6 +45,7 @@ func TestValidate(t *testing.T) {
 		desc      string
 		username  string
 		password  string
+		databases []string
 		expected  error
 	}{
 		{
@@ -82,6 +93,18 @@ func TestValidate(t *testing.T) {
 			endpoints: []string{""},
 			expected:  errors.New("no endpoint specified for one of the hosts"),
 		},
+		{
+			desc:      "databases",
+			endpoints: []string{"localhost:27107"},
+			databases: []string{"orders", "inventory"},
+			expected:  nil,
+		},
+		{
+			desc:      "empty database name",
+			endpoints: []string{"localhost:27107"},
+			databases: []string{"orders", ""},
+			expected:  errors.New("empty database name in databases"),
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.desc, func(t *testing.T) {
@@ -94,9 +117,10 @@ func TestValidate(t *testing.T) {
 			}
 
 			cfg := Config{
-				Username: tc.username,
-				Password: tc.password,
-				Hosts:    hosts,
+				Username:  tc.username,
+				Password:  tc.password,
+				Hosts:     hosts,
+				Databases: tc.databases,
 			}
 			err := cfg.Validate()
 			if tc.expected == nil {
@@ -126,6 +150,35 @@ func TestBadTLSConfigs(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			desc: "client cert without key",
+			tlsConfig: configtls.ClientConfig{
+				Config: configtls.Config{
+					CertFile: filepath.Join("testdata", "certs", "client.crt"),
+				},
+			},
+			expectError: true,
+		},
+		{
+			desc: "client key without cert",
+			tlsConfig: configtls.ClientConfig{
+				Config: configtls.Config{
+					KeyFile: filepath.Join("testdata", "certs", "client.key"),
+				},
+			},
+			expectError: true,
+		},
+		{
+			desc: "client cert and key",
+			tlsConfig: configtls.ClientConfig{
+				Config: configtls.Config{
+					CAFile:   filepath.Join("testdata", "certs", "ca.crt"),
+					CertFile: filepath.Join("testdata", "certs", "client.crt"),
+					KeyFile:  filepath.Join("testdata", "certs", "client.key"),
+				},
+			},
+			expectError: false,
+		},
 		{
 			desc: "no issues",
 			tlsConfig: configtls.ClientConfig{
@@ -202,6 +255,142 @@ func TestOptionsTLS(t *testing.T) {
 	require.NotNil(t, opts.TLSConfig)
 }
 
+func TestOptionsTLSClientCertificate(t *testing.T) {
+	// client.crt is a self-signed certificate with its matching key
+	cfg := &Config{
+		Hosts: []confignet.AddrConfig{
+			{
+				Endpoint: "localhost:27017",
+			},
+		},
+		ClientConfig: configtls.ClientConfig{
+			InsecureSkipVerify: true,
+			Config: configtls.Config{
+				CAFile:   filepath.Join("testdata", "certs", "ca.crt"),
+				CertFile: filepath.Join("testdata", "certs", "client.crt"),
+				KeyFile:  filepath.Join("testdata", "certs", "client.key"),
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	opts := cfg.ClientOptions()
+	require.NotNil(t, opts.TLSConfig)
+	require.True(t, opts.TLSConfig.InsecureSkipVerify)
+	require.NotNil(t, opts.TLSConfig.RootCAs)
+	require.NotNil(t, opts.TLSConfig.GetClientCertificate)
+
+	cert, err := opts.TLSConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+	require.NoError(t, err)
+	require.NotEmpty(t, cert.Certificate)
+}
+
+func TestOptionsTLSHandshake(t *testing.T) {
+	caCert, caKey := generateTestCert(t, nil, nil, "test-ca")
+	serverCert, serverKey := generateTestCert(t, caCert, caKey, "test-server")
+	clientCert, clientKey := generateTestCert(t, caCert, caKey, "test-client")
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(caCert)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    caPool,
+	})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// The server reports the client certificate it verified, or the error
+	// that failed the handshake.
+	type handshakeResult struct {
+		clientName string
+		err        error
+	}
+	results := make(chan handshakeResult, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			results <- handshakeResult{err: err}
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		if err := tlsConn.Handshake(); err != nil {
+			results <- handshakeResult{err: err}
+			return
+		}
+		results <- handshakeResult{clientName: tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName}
+	}()
+
+	dir := t.TempDir()
+	cfg := &Config{
+		Hosts: []confignet.AddrConfig{
+			{
+				Endpoint: listener.Addr().String(),
+			},
+		},
+		ClientConfig: configtls.ClientConfig{
+			Config: configtls.Config{
+				CAFile:   writeTestPEM(t, filepath.Join(dir, "ca.crt"), "CERTIFICATE", caCert.Raw),
+				CertFile: writeTestPEM(t, filepath.Join(dir, "client.crt"), "CERTIFICATE", clientCert.Raw),
+				KeyFile:  writeTestPEM(t, filepath.Join(dir, "client.key"), "PRIVATE KEY", marshalTestKey(t, clientKey)),
+			},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+	opts := cfg.ClientOptions()
+	require.NotNil(t, opts.TLSConfig)
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), opts.TLSConfig)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	result := <-results
+	require.NoError(t, result.err)
+	require.Equal(t, "test-client", result.clientName)
+}
+
+// generateTestCert returns a new certificate for 127.0.0.1 named commonName,
+// and its key. The certificate is signed by parent, or is a self-signed CA if
+// parent is nil.
+func generateTestCert(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func marshalTestKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return der
+}
+
+// writeTestPEM writes der to path as a PEM block of the given type, and
+// returns path.
+func writeTestPEM(t *testing.T, path string, blockType string, der []byte) string {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
 func TestLoadConfig(t *testing.T) {
 	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "config.yaml"))
 	require.NoError(t, err)
@@ -154,6 +154,54 @@ func (ms *MongodbConnectionCountMetricConfig) Validate() error {
 	return nil
 }
 
+// MongodbConnectionCreatedMetricAttributeKey specifies the key of an attribute for the mongodb.connection.created metric.
+type MongodbConnectionCreatedMetricAttributeKey string
+
+const (
+	MongodbConnectionCreatedMetricAttributeKeyDatabase MongodbConnectionCreatedMetricAttributeKey = "database"
+)
+
+// MongodbConnectionCreatedMetricConfig provides config for the mongodb.connection.created metric.
+type MongodbConnectionCreatedMetricConfig struct {
+	Enabled          bool `mapstructure:"enabled"`
+	enabledSetByUser bool
+
+	AggregationStrategy string                                       `mapstructure:"aggregation_strategy"`
+	EnabledAttributes   []MongodbConnectionCreatedMetricAttributeKey `mapstructure:"attributes"`
+}
+
+func (ms *MongodbConnectionCreatedMetricConfig) Unmarshal(parser *confmap.Conf) error {
+	if parser == nil {
+		return nil
+	}
+
+	err := parser.Unmarshal(ms)
+	if err != nil {
+		return err
+	}
+
+	ms.enabledSetByUser = parser.IsSet("enabled")
+	return nil
+}
+
+func (ms *MongodbConnectionCreatedMetricConfig) Validate() error {
+	for _, val := range ms.EnabledAttributes {
+		switch val {
+		case MongodbConnectionCreatedMetricAttributeKeyDatabase:
+		default:
+			return fmt.Errorf("metric mongodb.connection.created doesn't have an attribute %v, valid attributes: [database]", val)
+		}
+	}
+
+	switch ms.AggregationStrategy {
+	case AggregationStrategySum, AggregationStrategyAvg, AggregationStrategyMin, AggregationStrategyMax:
+	default:
+		return fmt.Errorf("invalid aggregation strategy %q, valid strategies: [%s, %s, %s, %s]", ms.AggregationStrategy, AggregationStrategySum, AggregationStrategyAvg, AggregationStrategyMin, AggregationStrategyMax)
+	}
+
+	return nil
+}
+
 // MongodbCursorCountMetricConfig provides config for the mongodb.cursor.count metric.
 type MongodbCursorCountMetricConfig struct {
 	Enabled          bool `mapstructure:"enabled"`
@@ -385,6 +433,7 @@ type MongodbIndexAccessCountMetricAttributeKey string
 const (
 	MongodbIndexAccessCountMetricAttributeKeyDatabase   MongodbIndexAccessCountMetricAttributeKey = "database"
 	MongodbIndexAccessCountMetricAttributeKeyCollection MongodbIndexAccessCountMetricAttributeKey = "collection"
+	MongodbIndexAccessCountMetricAttributeKeyIndex      MongodbIndexAccessCountMetricAttributeKey = "index"
 )
 
 // MongodbIndexAccessCountMetricConfig provides config for the mongodb.index.access.count metric.
@@ -413,9 +462,9 @@ func (ms *MongodbIndexAccessCountMetricConfig) Unmarshal(parser *confmap.Conf) e
 func (ms *MongodbIndexAccessCountMetricConfig) Validate() error {
 	for _, val := range ms.EnabledAttributes {
 		switch val {
-		case MongodbIndexAccessCountMetricAttributeKeyDatabase, MongodbIndexAccessCountMetricAttributeKeyCollection:
+		case MongodbIndexAccessCountMetricAttributeKeyDatabase, MongodbIndexAccessCountMetricAttributeKeyCollection, MongodbIndexAccessCountMetricAttributeKeyIndex:
 		default:
-			return fmt.Errorf("metric mongodb.index.access.count doesn't have an attribute %v, valid attributes: [database, collection]", val)
+			return fmt.Errorf("metric mongodb.index.access.count doesn't have an attribute %v, valid attributes: [database, collection, index]", val)
 		}
 	}
 
@@ -929,6 +978,102 @@ func (ms *MongodbOperationCountMetricConfig) Validate() error {
 	return nil
 }
 
+// MongodbOperationLatencyCountMetricAttributeKey specifies the key of an attribute for the mongodb.operation.latency.count metric.
+type MongodbOperationLatencyCountMetricAttributeKey string
+
+const (
+	MongodbOperationLatencyCountMetricAttributeKeyOperationLatency MongodbOperationLatencyCountMetricAttributeKey = "operation"
+)
+
+// MongodbOperationLatencyCountMetricConfig provides config for the mongodb.operation.latency.count metric.
+type MongodbOperationLatencyCountMetricConfig struct {
+	Enabled          bool `mapstructure:"enabled"`
+	enabledSetByUser bool
+
+	AggregationStrategy string                                           `mapstructure:"aggregation_strategy"`
+	EnabledAttributes   []MongodbOperationLatencyCountMetricAttributeKey `mapstructure:"attributes"`
+}
+
+func (ms *MongodbOperationLatencyCountMetricConfig) Unmarshal(parser *confmap.Conf) error {
+	if parser == nil {
+		return nil
+	}
+
+	err := parser.Unmarshal(ms)
+	if err != nil {
+		return err
+	}
+
+	ms.enabledSetByUser = parser.IsSet("enabled")
+	return nil
+}
+
+func (ms *MongodbOperationLatencyCountMetricConfig) Validate() error {
+	for _, val := range ms.EnabledAttributes {
+		switch val {
+		case MongodbOperationLatencyCountMetricAttributeKeyOperationLatency:
+		default:
+			return fmt.Errorf("metric mongodb.operation.latency.count doesn't have an attribute %v, valid attributes: [operation]", val)
+		}
+	}
+
+	switch ms.AggregationStrategy {
+	case AggregationStrategySum, AggregationStrategyAvg, AggregationStrategyMin, AggregationStrategyMax:
+	default:
+		return fmt.Errorf("invalid aggregation strategy %q, valid strategies: [%s, %s, %s, %s]", ms.AggregationStrategy, AggregationStrategySum, AggregationStrategyAvg, AggregationStrategyMin, AggregationStrategyMax)
+	}
+
+	return nil
+}
+
+// MongodbOperationLatencyTimeMetricAttributeKey specifies the key of an attribute for the mongodb.operation.latency.time metric.
+type MongodbOperationLatencyTimeMetricAttributeKey string
+
+const (
+	MongodbOperationLatencyTimeMetricAttributeKeyOperationLatency MongodbOperationLatencyTimeMetricAttributeKey = "operation"
+)
+
+// MongodbOperationLatencyTimeMetricConfig provides config for the mongodb.operation.latency.time metric.
+type MongodbOperationLatencyTimeMetricConfig struct {
+	Enabled          bool `mapstructure:"enabled"`
+	enabledSetByUser bool
+
+	AggregationStrategy string                                          `mapstructure:"aggregation_strategy"`
+	EnabledAttributes   []MongodbOperationLatencyTimeMetricAttributeKey `mapstructure:"attributes"`
+}
+
+func (ms *MongodbOperationLatencyTimeMetricConfig) Unmarshal(parser *confmap.Conf) error {
+	if parser == nil {
+		return nil
+	}
+
+	err := parser.Unmarshal(ms)
+	if err != nil {
+		return err
+	}
+
+	ms.enabledSetByUser = parser.IsSet("enabled")
+	return nil
+}
+
+func (ms *MongodbOperationLatencyTimeMetricConfig) Validate() error {
+	for _, val := range ms.EnabledAttributes {
+		switch val {
+		case MongodbOperationLatencyTimeMetricAttributeKeyOperationLatency:
+		default:
+			return fmt.Errorf("metric mongodb.operation.latency.time doesn't have an attribute %v, valid attributes: [operation]", val)
+		}
+	}
+
+	switch ms.AggregationStrategy {
+	case AggregationStrategySum, AggregationStrategyAvg, AggregationStrategyMin, AggregationStrategyMax:
+	default:
+		return fmt.Errorf("invalid aggregation strategy %q, valid strategies: [%s, %s, %s, %s]", ms.AggregationStrategy, AggregationStrategySum, AggregationStrategyAvg, AggregationStrategyMin, AggregationStrategyMax)
+	}
+
+	return nil
+}
+
 // MongodbOperationTimeMetricAttributeKey specifies the key of an attribute for the mongodb.operation.time metric.
 type MongodbOperationTimeMetricAttributeKey string
 
@@ -1050,6 +1195,7 @@ type MetricsConfig struct {
 	MongodbCacheOperations        MongodbCacheOperationsMetricConfig        `mapstructure:"mongodb.cache.operations"`
 	MongodbCollectionCount        MongodbCollectionCountMetricConfig        `mapstructure:"mongodb.collection.count"`
 	MongodbConnectionCount        MongodbConnectionCountMetricConfig        `mapstructure:"mongodb.connection.count"`
+	MongodbConnectionCreated      MongodbConnectionCreatedMetricConfig      `mapstructure:"mongodb.connection.created"`
 	MongodbCursorCount            MongodbCursorCountMetricConfig            `mapstructure:"mongodb.cursor.count"`
 	MongodbCursorTimeoutCount     MongodbCursorTimeoutCountMetricConfig     `mapstructure:"mongodb.cursor.timeout.count"`
 	MongodbDataSize               MongodbDataSizeMetricConfig               `mapstructure:"mongodb.data.size"`
@@ -1070,6 +1216,8 @@ type MetricsConfig struct {
 	MongodbNetworkRequestCount    MongodbNetworkRequestCountMetricConfig    `mapstructure:"mongodb.network.request.count"`
 	MongodbObjectCount            MongodbObjectCountMetricConfig            `mapstructure:"mongodb.object.count"`
 	MongodbOperationCount         MongodbOperationCountMetricConfig         `mapstructure:"mongodb.operation.count"`
+	MongodbOperationLatencyCount  MongodbOperationLatencyCountMetricConfig  `mapstructure:"mongodb.operation.latency.count"`
+	MongodbOperationLatencyTime   MongodbOperationLatencyTimeMetricConfig   `mapstructure:"mongodb.operation.latency.time"`
 	MongodbOperationTime          MongodbOperationTimeMetricConfig          `mapstructure:"mongodb.operation.time"`
 	MongodbSessionCount           MongodbSessionCountMetricConfig           `mapstructure:"mongodb.session.count"`
 	MongodbStorageSize            MongodbStorageSizeMetricConfig            `mapstructure:"mongodb.storage.size"`
@@ -1092,6 +1240,11 @@ func DefaultMetricsConfig() MetricsConfig {
 			AggregationStrategy: AggregationStrategySum,
 			EnabledAttributes:   []MongodbConnectionCountMetricAttributeKey{MongodbConnectionCountMetricAttributeKeyDatabase, MongodbConnectionCountMetricAttributeKeyConnectionType},
 		},
+		MongodbConnectionCreated: MongodbConnectionCreatedMetricConfig{
+			Enabled:             false,
+			AggregationStrategy: AggregationStrategySum,
+			EnabledAttributes:   []MongodbConnectionCreatedMetricAttributeKey{MongodbConnectionCreatedMetricAttributeKeyDatabase},
+		},
 		MongodbCursorCount: MongodbCursorCountMetricConfig{
 			Enabled: true,
 		},
@@ -1122,7 +1275,7 @@ func DefaultMetricsConfig() MetricsConfig {
 		MongodbIndexAccessCount: MongodbIndexAccessCountMetricConfig{
 			Enabled:             true,
 			AggregationStrategy: AggregationStrategySum,
-			EnabledAttributes:   []MongodbIndexAccessCountMetricAttributeKey{MongodbIndexAccessCountMetricAttributeKeyDatabase, MongodbIndexAccessCountMetricAttributeKeyCollection},
+			EnabledAttributes:   []MongodbIndexAccessCountMetricAttributeKey{MongodbIndexAccessCountMetricAttributeKeyDatabase, MongodbIndexAccessCountMetricAttributeKeyCollection, MongodbIndexAccessCountMetricAttributeKeyIndex},
 		},
 		MongodbIndexCount: MongodbIndexCountMetricConfig{
 			Enabled:             true,
@@ -1178,6 +1331,16 @@ func DefaultMetricsConfig() MetricsConfig {
 			AggregationStrategy: AggregationStrategySum,
 			EnabledAttributes:   []MongodbOperationCountMetricAttributeKey{MongodbOperationCountMetricAttributeKeyOperation},
 		},
+		MongodbOperationLatencyCount: MongodbOperationLatencyCountMetricConfig{
+			Enabled:             true,
+			AggregationStrategy: AggregationStrategySum,
+			EnabledAttributes:   []MongodbOperationLatencyCountMetricAttributeKey{MongodbOperationLatencyCountMetricAttributeKeyOperationLatency},
+		},
+		MongodbOperationLatencyTime: MongodbOperationLatencyTimeMetricConfig{
+			Enabled:             true,
+			AggregationStrategy: AggregationStrategySum,
+			EnabledAttributes:   []MongodbOperationLatencyTimeMetricAttributeKey{MongodbOperationLatencyTimeMetricAttributeKeyOperationLatency},
+		},
 		MongodbOperationTime: MongodbOperationTimeMetricConfig{
 			Enabled:             true,
 			AggregationStrategy: AggregationStrategySum,
@@ -41,6 +41,11 @@ func TestMetricsBuilderConfig(t *testing.T) {
 						AggregationStrategy: AggregationStrategySum,
 						EnabledAttributes:   []MongodbConnectionCountMetricAttributeKey{MongodbConnectionCountMetricAttributeKeyDatabase, MongodbConnectionCountMetricAttributeKeyConnectionType},
 					},
+					MongodbConnectionCreated: MongodbConnectionCreatedMetricConfig{
+						Enabled:             true,
+						AggregationStrategy: AggregationStrategySum,
+						EnabledAttributes:   []MongodbConnectionCreatedMetricAttributeKey{MongodbConnectionCreatedMetricAttributeKeyDatabase},
+					},
 					MongodbCursorCount: MongodbCursorCountMetricConfig{
 						Enabled: true,
 					},
@@ -71,7 +76,7 @@ func TestMetricsBuilderConfig(t *testing.T) {
 					MongodbIndexAccessCount: MongodbIndexAccessCountMetricConfig{
 						Enabled:             true,
 						AggregationStrategy: AggregationStrategySum,
-						EnabledAttributes:   []MongodbIndexAccessCountMetricAttributeKey{MongodbIndexAccessCountMetricAttributeKeyDatabase, MongodbIndexAccessCountMetricAttributeKeyCollection},
+						EnabledAttributes:   []MongodbIndexAccessCountMetricAttributeKey{MongodbIndexAccessCountMetricAttributeKeyDatabase, MongodbIndexAccessCountMetricAttributeKeyCollection, MongodbIndexAccessCountMetricAttributeKeyIndex},
 					},
 					MongodbIndexCount: MongodbIndexCountMetricConfig{
 						Enabled:             true,
@@ -127,6 +132,16 @@ func TestMetricsBuilderConfig(t *testing.T) {
 						AggregationStrategy: AggregationStrategySum,
 						EnabledAttributes:   []MongodbOperationCountMetricAttributeKey{MongodbOperationCountMetricAttributeKeyOperation},
 					},
+					MongodbOperationLatencyCount: MongodbOperationLatencyCountMetricConfig{
+						Enabled:             true,
+						AggregationStrategy: AggregationStrategySum,
+						EnabledAttributes:   []MongodbOperationLatencyCountMetricAttributeKey{MongodbOperationLatencyCountMetricAttributeKeyOperationLatency},
+					},
+					MongodbOperationLatencyTime: MongodbOperationLatencyTimeMetricConfig{
+						Enabled:             true,
+						AggregationStrategy: AggregationStrategySum,
+						EnabledAttributes:   []MongodbOperationLatencyTimeMetricAttributeKey{MongodbOperationLatencyTimeMetricAttributeKeyOperationLatency},
+					},
 					MongodbOperationTime: MongodbOperationTimeMetricConfig{
 						Enabled:             true,
 						AggregationStrategy: AggregationStrategySum,
@@ -165,6 +180,11 @@ func TestMetricsBuilderConfig(t *testing.T) {
 						AggregationStrategy: AggregationStrategySum,
 						EnabledAttributes:   []MongodbConnectionCountMetricAttributeKey{MongodbConnectionCountMetricAttributeKeyDatabase, MongodbConnectionCountMetricAttributeKeyConnectionType},
 					},
+					MongodbConnectionCreated: MongodbConnectionCreatedMetricConfig{
+						Enabled:             false,
+						AggregationStrategy: AggregationStrategySum,
+						EnabledAttributes:   []MongodbConnectionCreatedMetricAttributeKey{MongodbConnectionCreatedMetricAttributeKeyDatabase},
+					},
 					MongodbCursorCount: MongodbCursorCountMetricConfig{
 						Enabled: false,
 					},
@@ -195,7 +215,7 @@ func TestMetricsBuilderConfig(t *testing.T) {
 					MongodbIndexAccessCount: MongodbIndexAccessCountMetricConfig{
 						Enabled:             false,
 						AggregationStrategy: AggregationStrategySum,
-						EnabledAttributes:   []MongodbIndexAccessCountMetricAttributeKey{MongodbIndexAccessCountMetricAttributeKeyDatabase, MongodbIndexAccessCountMetricAttributeKeyCollection},
+						EnabledAttributes:   []MongodbIndexAccessCountMetricAttributeKey{MongodbIndexAccessCountMetricAttributeKeyDatabase, MongodbIndexAccessCountMetricAttributeKeyCollection, MongodbIndexAccessCountMetricAttributeKeyIndex},
 					},
 					MongodbIndexCount: MongodbIndexCountMetricConfig{
 						Enabled:             false,
@@ -251,6 +271,16 @@ func TestMetricsBuilderConfig(t *testing.T) {
 						AggregationStrategy: AggregationStrategySum,
 						EnabledAttributes:   []MongodbOperationCountMetricAttributeKey{MongodbOperationCountMetricAttributeKeyOperation},
 					},
+					MongodbOperationLatencyCount: MongodbOperationLatencyCountMetricConfig{
+						Enabled:             false,
+						AggregationStrategy: AggregationStrategySum,
+						EnabledAttributes:   []MongodbOperationLatencyCountMetricAttributeKey{MongodbOperationLatencyCountMetricAttributeKeyOperationLatency},
+					},
+					MongodbOperationLatencyTime: MongodbOperationLatencyTimeMetricConfig{
+						Enabled:             false,
+						AggregationStrategy: AggregationStrategySum,
+						EnabledAttributes:   []MongodbOperationLatencyTimeMetricAttributeKey{MongodbOperationLatencyTimeMetricAttributeKeyOperationLatency},
+					},
 					MongodbOperationTime: MongodbOperationTimeMetricConfig{
 						Enabled:             false,
 						AggregationStrategy: AggregationStrategySum,
@@ -274,7 +304,7 @@ func TestMetricsBuilderConfig(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := loadMetricsBuilderConfig(t, tt.name)
-			diff := cmp.Diff(tt.want, cfg, cmpopts.IgnoreUnexported(MongodbCacheOperationsMetricConfig{}, MongodbCollectionCountMetricConfig{}, MongodbConnectionCountMetricConfig{}, MongodbCursorCountMetricConfig{}, MongodbCursorTimeoutCountMetricConfig{}, MongodbDataSizeMetricConfig{}, MongodbDatabaseCountMetricConfig{}, MongodbDocumentOperationCountMetricConfig{}, MongodbExtentCountMetricConfig{}, MongodbGlobalLockTimeMetricConfig{}, MongodbIndexAccessCountMetricConfig{}, MongodbIndexCountMetricConfig{}, MongodbIndexSizeMetricConfig{}, MongodbLockAcquireCountMetricConfig{}, MongodbLockAcquireTimeMetricConfig{}, MongodbLockAcquireWaitCountMetricConfig{}, MongodbLockDeadlockCountMetricConfig{}, MongodbMemoryUsageMetricConfig{}, MongodbNetworkIoReceiveMetricConfig{}, MongodbNetworkIoTransmitMetricConfig{}, MongodbNetworkRequestCountMetricConfig{}, MongodbObjectCountMetricConfig{}, MongodbOperationCountMetricConfig{}, MongodbOperationTimeMetricConfig{}, MongodbSessionCountMetricConfig{}, MongodbStorageSizeMetricConfig{}, ResourceAttributeConfig{}))
+			diff := cmp.Diff(tt.want, cfg, cmpopts.IgnoreUnexported(MongodbCacheOperationsMetricConfig{}, MongodbCollectionCountMetricConfig{}, MongodbConnectionCountMetricConfig{}, MongodbConnectionCreatedMetricConfig{}, MongodbCursorCountMetricConfig{}, MongodbCursorTimeoutCountMetricConfig{}, MongodbDataSizeMetricConfig{}, MongodbDatabaseCountMetricConfig{}, MongodbDocumentOperationCountMetricConfig{}, MongodbExtentCountMetricConfig{}, MongodbGlobalLockTimeMetricConfig{}, MongodbIndexAccessCountMetricConfig{}, MongodbIndexCountMetricConfig{}, MongodbIndexSizeMetricConfig{}, MongodbLockAcquireCountMetricConfig{}, MongodbLockAcquireTimeMetricConfig{}, MongodbLockAcquireWaitCountMetricConfig{}, MongodbLockDeadlockCountMetricConfig{}, MongodbMemoryUsageMetricConfig{}, MongodbNetworkIoReceiveMetricConfig{}, MongodbNetworkIoTransmitMetricConfig{}, MongodbNetworkRequestCountMetricConfig{}, MongodbObjectCountMetricConfig{}, MongodbOperationCountMetricConfig{}, MongodbOperationLatencyCountMetricConfig{}, MongodbOperationLatencyTimeMetricConfig{}, MongodbOperationTimeMetricConfig{}, MongodbSessionCountMetricConfig{}, MongodbStorageSizeMetricConfig{}, ResourceAttributeConfig{}))
 			require.Emptyf(t, diff, "Config mismatch (-expected +actual):\n%s", diff)
 		})
 	}
@@ -315,6 +345,18 @@ func TestMongodbConnectionCountMetricsConfig_Validate(t *testing.T) {
 	require.ErrorContains(t, cfg.Validate(), "invalid aggregation strategy")
 }
 
+func TestMongodbConnectionCreatedMetricsConfig_Validate(t *testing.T) {
+	cfg := DefaultMetricsConfig().MongodbConnectionCreated
+	require.NoError(t, cfg.Validate())
+
+	cfg.EnabledAttributes = []MongodbConnectionCreatedMetricAttributeKey{"invalid"}
+	require.ErrorContains(t, cfg.Validate(), "metric mongodb.connection.created doesn't have an attribute invalid, valid attributes: [database]")
+
+	cfg = DefaultMetricsConfig().MongodbConnectionCreated
+	cfg.AggregationStrategy = "invalid"
+	require.ErrorContains(t, cfg.Validate(), "invalid aggregation strategy")
+}
+
 func TestMongodbDataSizeMetricsConfig_Validate(t *testing.T) {
 	cfg := DefaultMetricsConfig().MongodbDataSize
 	require.NoError(t, cfg.Validate())
@@ -356,7 +398,7 @@ func TestMongodbIndexAccessCountMetricsConfig_Validate(t *testing.T) {
 	require.NoError(t, cfg.Validate())
 
 	cfg.EnabledAttributes = []MongodbIndexAccessCountMetricAttributeKey{"invalid"}
-	require.ErrorContains(t, cfg.Validate(), "metric mongodb.index.access.count doesn't have an attribute invalid, valid attributes: [database, collection]")
+	require.ErrorContains(t, cfg.Validate(), "metric mongodb.index.access.count doesn't have an attribute invalid, valid attributes: [database, collection, index]")
 
 	cfg = DefaultMetricsConfig().MongodbIndexAccessCount
 	cfg.AggregationStrategy = "invalid"
@@ -471,6 +513,30 @@ func TestMongodbOperationCountMetricsConfig_Validate(t *testing.T) {
 	require.ErrorContains(t, cfg.Validate(), "invalid aggregation strategy")
 }
 
+func TestMongodbOperationLatencyCountMetricsConfig_Validate(t *testing.T) {
+	cfg := DefaultMetricsConfig().MongodbOperationLatencyCount
+	require.NoError(t, cfg.Validate())
+
+	cfg.EnabledAttributes = []MongodbOperationLatencyCountMetricAttributeKey{"invalid"}
+	require.ErrorContains(t, cfg.Validate(), "metric mongodb.operation.latency.count doesn't have an attribute invalid, valid attributes: [operation]")
+
+	cfg = DefaultMetricsConfig().MongodbOperationLatencyCount
+	cfg.AggregationStrategy = "invalid"
+	require.ErrorContains(t, cfg.Validate(), "invalid aggregation strategy")
+}
+
+func TestMongodbOperationLatencyTimeMetricsConfig_Validate(t *testing.T) {
+	cfg := DefaultMetricsConfig().MongodbOperationLatencyTime
+	require.NoError(t, cfg.Validate())
+
+	cfg.EnabledAttributes = []MongodbOperationLatencyTimeMetricAttributeKey{"invalid"}
+	require.ErrorContains(t, cfg.Validate(), "metric mongodb.operation.latency.time doesn't have an attribute invalid, valid attributes: [operation]")
+
+	cfg = DefaultMetricsConfig().MongodbOperationLatencyTime
+	cfg.AggregationStrategy = "invalid"
+	require.ErrorContains(t, cfg.Validate(), "invalid aggregation strategy")
+}
+
 func TestMongodbOperationTimeMetricsConfig_Validate(t *testing.T) {
 	cfg := DefaultMetricsConfig().MongodbOperationTime
 	require.NoError(t, cfg.Validate())
@@ -201,6 +201,36 @@ var MapAttributeOperation = map[string]AttributeOperation{
 	"command": AttributeOperationCommand,
 }
 
+// AttributeOperationLatency specifies the value operation_latency attribute.
+type AttributeOperationLatency int
+
+const (
+	_ AttributeOperationLatency = iota
+	AttributeOperationLatencyRead
+	AttributeOperationLatencyWrite
+	AttributeOperationLatencyCommand
+)
+
+// String returns the string representation of the AttributeOperationLatency.
+func (av AttributeOperationLatency) String() string {
+	switch av {
+	case AttributeOperationLatencyRead:
+		return "read"
+	case AttributeOperationLatencyWrite:
+		return "write"
+	case AttributeOperationLatencyCommand:
+		return "command"
+	}
+	return ""
+}
+
+// MapAttributeOperationLatency is a helper map of string to AttributeOperationLatency attribute value.
+var MapAttributeOperationLatency = map[string]AttributeOperationLatency{
+	"read":    AttributeOperationLatencyRead,
+	"write":   AttributeOperationLatencyWrite,
+	"command": AttributeOperationLatencyCommand,
+}
+
 // AttributeType specifies the value type attribute.
 type AttributeType int
 
@@ -240,6 +270,10 @@ var MetricsInfo = metricsInfo{
 		Name:       "mongodb.connection.count",
 		Attributes: []string{"database", "connection_type"},
 	},
+	MongodbConnectionCreated: metricInfo{
+		Name:       "mongodb.connection.created",
+		Attributes: []string{"database"},
+	},
 	MongodbCursorCount: metricInfo{
 		Name: "mongodb.cursor.count",
 	},
@@ -266,7 +300,7 @@ var MetricsInfo = metricsInfo{
 	},
 	MongodbIndexAccessCount: metricInfo{
 		Name:       "mongodb.index.access.count",
-		Attributes: []string{"database", "collection"},
+		Attributes: []string{"database", "collection", "index"},
 	},
 	MongodbIndexCount: metricInfo{
 		Name:       "mongodb.index.count",
@@ -313,6 +347,14 @@ var MetricsInfo = metricsInfo{
 		Name:       "mongodb.operation.count",
 		Attributes: []string{"operation"},
 	},
+	MongodbOperationLatencyCount: metricInfo{
+		Name:       "mongodb.operation.latency.count",
+		Attributes: []string{"operation_latency"},
+	},
+	MongodbOperationLatencyTime: metricInfo{
+		Name:       "mongodb.operation.latency.time",
+		Attributes: []string{"operation_latency"},
+	},
 	MongodbOperationTime: metricInfo{
 		Name:       "mongodb.operation.time",
 		Attributes: []string{"operation"},
@@ -330,6 +372,7 @@ type metricsInfo struct {
 	MongodbCacheOperations        metricInfo
 	MongodbCollectionCount        metricInfo
 	MongodbConnectionCount        metricInfo
+	MongodbConnectionCreated      metricInfo
 	MongodbCursorCount            metricInfo
 	MongodbCursorTimeoutCount     metricInfo
 	MongodbDataSize               metricInfo
@@ -350,6 +393,8 @@ type metricsInfo struct {
 	MongodbNetworkRequestCount    metricInfo
 	MongodbObjectCount            metricInfo
 	MongodbOperationCount         metricInfo
+	MongodbOperationLatencyCount  metricInfo
+	MongodbOperationLatencyTime   metricInfo
 	MongodbOperationTime          metricInfo
 	MongodbSessionCount           metricInfo
 	MongodbStorageSize            metricInfo
@@ -636,6 +681,97 @@ func newMetricMongodbConnectionCount(cfg MongodbConnectionCountMetricConfig) met
 	return m
 }
 
+type metricMongodbConnectionCreated struct {
+	data          pmetric.Metric                       // data buffer for generated metric.
+	config        MongodbConnectionCreatedMetricConfig // metric config provided by user.
+	capacity      int                                  // max observed number of data points added to the metric.
+	aggDataPoints []int64                              // slice containing number of aggregated datapoints at each index
+}
+
+// init fills mongodb.connection.created metric with initial data.
+func (m *metricMongodbConnectionCreated) init() {
+	m.data.SetName("mongodb.connection.created")
+	m.data.SetDescription("The total number of connections created since the server started, as reported by serverStatus.connections.totalCreated.")
+	m.data.SetUnit("{connections}")
+	m.data.SetEmptySum()
+	m.data.Sum().SetIsMonotonic(true)
+	m.data.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	m.data.Sum().DataPoints().EnsureCapacity(m.capacity)
+	m.aggDataPoints = m.aggDataPoints[:0]
+}
+
+func (m *metricMongodbConnectionCreated) recordDataPoint(start pcommon.Timestamp, ts pcommon.Timestamp, val int64, databaseAttributeValue string) {
+	if !m.config.Enabled {
+		return
+	}
+
+	dp := pmetric.NewNumberDataPoint()
+	dp.SetStartTimestamp(start)
+	dp.SetTimestamp(ts)
+	if slices.Contains(m.config.EnabledAttributes, MongodbConnectionCreatedMetricAttributeKeyDatabase) {
+		dp.Attributes().PutStr("database", databaseAttributeValue)
+	}
+
+	var s string
+	dps := m.data.Sum().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		dpi := dps.At(i)
+		if dp.Attributes().Equal(dpi.Attributes()) && dp.StartTimestamp() == dpi.StartTimestamp() && dp.Timestamp() == dpi.Timestamp() {
+			switch s = m.config.AggregationStrategy; s {
+			case AggregationStrategySum, AggregationStrategyAvg:
+				dpi.SetIntValue(dpi.IntValue() + val)
+				m.aggDataPoints[i] += 1
+				return
+			case AggregationStrategyMin:
+				if dpi.IntValue() > val {
+					dpi.SetIntValue(val)
+				}
+				return
+			case AggregationStrategyMax:
+				if dpi.IntValue() < val {
+					dpi.SetIntValue(val)
+				}
+				return
+			}
+		}
+	}
+
+	dp.SetIntValue(val)
+	m.aggDataPoints = append(m.aggDataPoints, 1)
+	dp.MoveTo(dps.AppendEmpty())
+}
+
+// updateCapacity saves max length of data point slices that will be used for the slice capacity.
+func (m *metricMongodbConnectionCreated) updateCapacity() {
+	if m.data.Sum().DataPoints().Len() > m.capacity {
+		m.capacity = m.data.Sum().DataPoints().Len()
+	}
+}
+
+// emit appends recorded metric data to a metrics slice and prepares it for recording another set of data points.
+func (m *metricMongodbConnectionCreated) emit(metrics pmetric.MetricSlice) {
+	if m.config.Enabled && m.data.Sum().DataPoints().Len() > 0 {
+		if m.config.AggregationStrategy == AggregationStrategyAvg {
+			for i, aggCount := range m.aggDataPoints {
+				m.data.Sum().DataPoints().At(i).SetIntValue(m.data.Sum().DataPoints().At(i).IntValue() / aggCount)
+			}
+		}
+		m.updateCapacity()
+		m.data.MoveTo(metrics.AppendEmpty())
+		m.init()
+	}
+}
+
+func newMetricMongodbConnectionCreated(cfg MongodbConnectionCreatedMetricConfig) metricMongodbConnectionCreated {
+	m := metricMongodbConnectionCreated{config: cfg}
+
+	if cfg.Enabled {
+		m.data = pmetric.NewMetric()
+		m.init()
+	}
+	return m
+}
+
 type metricMongodbCursorCount struct {
 	data     pmetric.Metric                 // data buffer for generated metric.
 	config   MongodbCursorCountMetricConfig // metric config provided by user.
@@ -1139,7 +1275,7 @@ func (m *metricMongodbIndexAccessCount) init() {
 	m.aggDataPoints = m.aggDataPoints[:0]
 }
 
-func (m *metricMongodbIndexAccessCount) recordDataPoint(start pcommon.Timestamp, ts pcommon.Timestamp, val int64, databaseAttributeValue string, collectionAttributeValue string) {
+func (m *metricMongodbIndexAccessCount) recordDataPoint(start pcommon.Timestamp, ts pcommon.Timestamp, val int64, databaseAttributeValue string, collectionAttributeValue string, indexAttributeValue string) {
 	if !m.config.Enabled {
 		return
 	}
@@ -1153,6 +1289,9 @@ func (m *metricMongodbIndexAccessCount) recordDataPoint(start pcommon.Timestamp,
 	if slices.Contains(m.config.EnabledAttributes, MongodbIndexAccessCountMetricAttributeKeyCollection) {
 		dp.Attributes().PutStr("collection", collectionAttributeValue)
 	}
+	if slices.Contains(m.config.EnabledAttributes, MongodbIndexAccessCountMetricAttributeKeyIndex) {
+		dp.Attributes().PutStr("index", indexAttributeValue)
+	}
 
 	var s string
 	dps := m.data.Sum().DataPoints()
@@ -2216,6 +2355,188 @@ func newMetricMongodbOperationCount(cfg MongodbOperationCountMetricConfig) metri
 	return m
 }
 
+type metricMongodbOperationLatencyCount struct {
+	data          pmetric.Metric                           // data buffer for generated metric.
+	config        MongodbOperationLatencyCountMetricConfig // metric config provided by user.
+	capacity      int                                      // max observed number of data points added to the metric.
+	aggDataPoints []int64                                  // slice containing number of aggregated datapoints at each index
+}
+
+// init fills mongodb.operation.latency.count metric with initial data.
+func (m *metricMongodbOperationLatencyCount) init() {
+	m.data.SetName("mongodb.operation.latency.count")
+	m.data.SetDescription("The number of operations counted in mongodb.operation.latency.time, as reported by serverStatus.opLatencies.")
+	m.data.SetUnit("{operations}")
+	m.data.SetEmptySum()
+	m.data.Sum().SetIsMonotonic(true)
+	m.data.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	m.data.Sum().DataPoints().EnsureCapacity(m.capacity)
+	m.aggDataPoints = m.aggDataPoints[:0]
+}
+
+func (m *metricMongodbOperationLatencyCount) recordDataPoint(start pcommon.Timestamp, ts pcommon.Timestamp, val int64, operationLatencyAttributeValue string) {
+	if !m.config.Enabled {
+		return
+	}
+
+	dp := pmetric.NewNumberDataPoint()
+	dp.SetStartTimestamp(start)
+	dp.SetTimestamp(ts)
+	if slices.Contains(m.config.EnabledAttributes, MongodbOperationLatencyCountMetricAttributeKeyOperationLatency) {
+		dp.Attributes().PutStr("operation", operationLatencyAttributeValue)
+	}
+
+	var s string
+	dps := m.data.Sum().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		dpi := dps.At(i)
+		if dp.Attributes().Equal(dpi.Attributes()) && dp.StartTimestamp() == dpi.StartTimestamp() && dp.Timestamp() == dpi.Timestamp() {
+			switch s = m.config.AggregationStrategy; s {
+			case AggregationStrategySum, AggregationStrategyAvg:
+				dpi.SetIntValue(dpi.IntValue() + val)
+				m.aggDataPoints[i] += 1
+				return
+			case AggregationStrategyMin:
+				if dpi.IntValue() > val {
+					dpi.SetIntValue(val)
+				}
+				return
+			case AggregationStrategyMax:
+				if dpi.IntValue() < val {
+					dpi.SetIntValue(val)
+				}
+				return
+			}
+		}
+	}
+
+	dp.SetIntValue(val)
+	m.aggDataPoints = append(m.aggDataPoints, 1)
+	dp.MoveTo(dps.AppendEmpty())
+}
+
+// updateCapacity saves max length of data point slices that will be used for the slice capacity.
+func (m *metricMongodbOperationLatencyCount) updateCapacity() {
+	if m.data.Sum().DataPoints().Len() > m.capacity {
+		m.capacity = m.data.Sum().DataPoints().Len()
+	}
+}
+
+// emit appends recorded metric data to a metrics slice and prepares it for recording another set of data points.
+func (m *metricMongodbOperationLatencyCount) emit(metrics pmetric.MetricSlice) {
+	if m.config.Enabled && m.data.Sum().DataPoints().Len() > 0 {
+		if m.config.AggregationStrategy == AggregationStrategyAvg {
+			for i, aggCount := range m.aggDataPoints {
+				m.data.Sum().DataPoints().At(i).SetIntValue(m.data.Sum().DataPoints().At(i).IntValue() / aggCount)
+			}
+		}
+		m.updateCapacity()
+		m.data.MoveTo(metrics.AppendEmpty())
+		m.init()
+	}
+}
+
+func newMetricMongodbOperationLatencyCount(cfg MongodbOperationLatencyCountMetricConfig) metricMongodbOperationLatencyCount {
+	m := metricMongodbOperationLatencyCount{config: cfg}
+
+	if cfg.Enabled {
+		m.data = pmetric.NewMetric()
+		m.init()
+	}
+	return m
+}
+
+type metricMongodbOperationLatencyTime struct {
+	data          pmetric.Metric                          // data buffer for generated metric.
+	config        MongodbOperationLatencyTimeMetricConfig // metric config provided by user.
+	capacity      int                                     // max observed number of data points added to the metric.
+	aggDataPoints []int64                                 // slice containing number of aggregated datapoints at each index
+}
+
+// init fills mongodb.operation.latency.time metric with initial data.
+func (m *metricMongodbOperationLatencyTime) init() {
+	m.data.SetName("mongodb.operation.latency.time")
+	m.data.SetDescription("The total latency of operations, as reported by serverStatus.opLatencies. Divide by mongodb.operation.latency.count to get the mean latency.")
+	m.data.SetUnit("us")
+	m.data.SetEmptySum()
+	m.data.Sum().SetIsMonotonic(true)
+	m.data.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	m.data.Sum().DataPoints().EnsureCapacity(m.capacity)
+	m.aggDataPoints = m.aggDataPoints[:0]
+}
+
+func (m *metricMongodbOperationLatencyTime) recordDataPoint(start pcommon.Timestamp, ts pcommon.Timestamp, val int64, operationLatencyAttributeValue string) {
+	if !m.config.Enabled {
+		return
+	}
+
+	dp := pmetric.NewNumberDataPoint()
+	dp.SetStartTimestamp(start)
+	dp.SetTimestamp(ts)
+	if slices.Contains(m.config.EnabledAttributes, MongodbOperationLatencyTimeMetricAttributeKeyOperationLatency) {
+		dp.Attributes().PutStr("operation", operationLatencyAttributeValue)
+	}
+
+	var s string
+	dps := m.data.Sum().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		dpi := dps.At(i)
+		if dp.Attributes().Equal(dpi.Attributes()) && dp.StartTimestamp() == dpi.StartTimestamp() && dp.Timestamp() == dpi.Timestamp() {
+			switch s = m.config.AggregationStrategy; s {
+			case AggregationStrategySum, AggregationStrategyAvg:
+				dpi.SetIntValue(dpi.IntValue() + val)
+				m.aggDataPoints[i] += 1
+				return
+			case AggregationStrategyMin:
+				if dpi.IntValue() > val {
+					dpi.SetIntValue(val)
+				}
+				return
+			case AggregationStrategyMax:
+				if dpi.IntValue() < val {
+					dpi.SetIntValue(val)
+				}
+				return
+			}
+		}
+	}
+
+	dp.SetIntValue(val)
+	m.aggDataPoints = append(m.aggDataPoints, 1)
+	dp.MoveTo(dps.AppendEmpty())
+}
+
+// updateCapacity saves max length of data point slices that will be used for the slice capacity.
+func (m *metricMongodbOperationLatencyTime) updateCapacity() {
+	if m.data.Sum().DataPoints().Len() > m.capacity {
+		m.capacity = m.data.Sum().DataPoints().Len()
+	}
+}
+
+// emit appends recorded metric data to a metrics slice and prepares it for recording another set of data points.
+func (m *metricMongodbOperationLatencyTime) emit(metrics pmetric.MetricSlice) {
+	if m.config.Enabled && m.data.Sum().DataPoints().Len() > 0 {
+		if m.config.AggregationStrategy == AggregationStrategyAvg {
+			for i, aggCount := range m.aggDataPoints {
+				m.data.Sum().DataPoints().At(i).SetIntValue(m.data.Sum().DataPoints().At(i).IntValue() / aggCount)
+			}
+		}
+		m.updateCapacity()
+		m.data.MoveTo(metrics.AppendEmpty())
+		m.init()
+	}
+}
+
+func newMetricMongodbOperationLatencyTime(cfg MongodbOperationLatencyTimeMetricConfig) metricMongodbOperationLatencyTime {
+	m := metricMongodbOperationLatencyTime{config: cfg}
+
+	if cfg.Enabled {
+		m.data = pmetric.NewMetric()
+		m.init()
+	}
+	return m
+}
+
 type metricMongodbOperationTime struct {
 	data          pmetric.Metric                   // data buffer for generated metric.
 	config        MongodbOperationTimeMetricConfig // metric config provided by user.
@@ -2463,6 +2784,7 @@ type MetricsBuilder struct {
 	metricMongodbCacheOperations        metricMongodbCacheOperations
 	metricMongodbCollectionCount        metricMongodbCollectionCount
 	metricMongodbConnectionCount        metricMongodbConnectionCount
+	metricMongodbConnectionCreated      metricMongodbConnectionCreated
 	metricMongodbCursorCount            metricMongodbCursorCount
 	metricMongodbCursorTimeoutCount     metricMongodbCursorTimeoutCount
 	metricMongodbDataSize               metricMongodbDataSize
@@ -2483,6 +2805,8 @@ type MetricsBuilder struct {
 	metricMongodbNetworkRequestCount    metricMongodbNetworkRequestCount
 	metricMongodbObjectCount            metricMongodbObjectCount
 	metricMongodbOperationCount         metricMongodbOperationCount
+	metricMongodbOperationLatencyCount  metricMongodbOperationLatencyCount
+	metricMongodbOperationLatencyTime   metricMongodbOperationLatencyTime
 	metricMongodbOperationTime          metricMongodbOperationTime
 	metricMongodbSessionCount           metricMongodbSessionCount
 	metricMongodbStorageSize            metricMongodbStorageSize
@@ -2514,6 +2838,7 @@ func NewMetricsBuilder(mbc MetricsBuilderConfig, settings receiver.Settings, opt
 		metricMongodbCacheOperations:        newMetricMongodbCacheOperations(mbc.Metrics.MongodbCacheOperations),
 		metricMongodbCollectionCount:        newMetricMongodbCollectionCount(mbc.Metrics.MongodbCollectionCount),
 		metricMongodbConnectionCount:        newMetricMongodbConnectionCount(mbc.Metrics.MongodbConnectionCount),
+		metricMongodbConnectionCreated:      newMetricMongodbConnectionCreated(mbc.Metrics.MongodbConnectionCreated),
 		metricMongodbCursorCount:            newMetricMongodbCursorCount(mbc.Metrics.MongodbCursorCount),
 		metricMongodbCursorTimeoutCount:     newMetricMongodbCursorTimeoutCount(mbc.Metrics.MongodbCursorTimeoutCount),
 		metricMongodbDataSize:               newMetricMongodbDataSize(mbc.Metrics.MongodbDataSize),
@@ -2534,6 +2859,8 @@ func NewMetricsBuilder(mbc MetricsBuilderConfig, settings receiver.Settings, opt
 		metricMongodbNetworkRequestCount:    newMetricMongodbNetworkRequestCount(mbc.Metrics.MongodbNetworkRequestCount),
 		metricMongodbObjectCount:            newMetricMongodbObjectCount(mbc.Metrics.MongodbObjectCount),
 		metricMongodbOperationCount:         newMetricMongodbOperationCount(mbc.Metrics.MongodbOperationCount),
+		metricMongodbOperationLatencyCount:  newMetricMongodbOperationLatencyCount(mbc.Metrics.MongodbOperationLatencyCount),
+		metricMongodbOperationLatencyTime:   newMetricMongodbOperationLatencyTime(mbc.Metrics.MongodbOperationLatencyTime),
 		metricMongodbOperationTime:          newMetricMongodbOperationTime(mbc.Metrics.MongodbOperationTime),
 		metricMongodbSessionCount:           newMetricMongodbSessionCount(mbc.Metrics.MongodbSessionCount),
 		metricMongodbStorageSize:            newMetricMongodbStorageSize(mbc.Metrics.MongodbStorageSize),
@@ -2618,6 +2945,7 @@ func (mb *MetricsBuilder) EmitForResource(options ...ResourceMetricsOption) {
 	mb.metricMongodbCacheOperations.emit(ils.Metrics())
 	mb.metricMongodbCollectionCount.emit(ils.Metrics())
 	mb.metricMongodbConnectionCount.emit(ils.Metrics())
+	mb.metricMongodbConnectionCreated.emit(ils.Metrics())
 	mb.metricMongodbCursorCount.emit(ils.Metrics())
 	mb.metricMongodbCursorTimeoutCount.emit(ils.Metrics())
 	mb.metricMongodbDataSize.emit(ils.Metrics())
@@ -2638,6 +2966,8 @@ func (mb *MetricsBuilder) EmitForResource(options ...ResourceMetricsOption) {
 	mb.metricMongodbNetworkRequestCount.emit(ils.Metrics())
 	mb.metricMongodbObjectCount.emit(ils.Metrics())
 	mb.metricMongodbOperationCount.emit(ils.Metrics())
+	mb.metricMongodbOperationLatencyCount.emit(ils.Metrics())
+	mb.metricMongodbOperationLatencyTime.emit(ils.Metrics())
 	mb.metricMongodbOperationTime.emit(ils.Metrics())
 	mb.metricMongodbSessionCount.emit(ils.Metrics())
 	mb.metricMongodbStorageSize.emit(ils.Metrics())
@@ -2687,6 +3017,11 @@ func (mb *MetricsBuilder) RecordMongodbConnectionCountDataPoint(ts pcommon.Times
 	mb.metricMongodbConnectionCount.recordDataPoint(mb.startTime, ts, val, databaseAttributeValue, connectionTypeAttributeValue.String())
 }
 
+// RecordMongodbConnectionCreatedDataPoint adds a data point to mongodb.connection.created metric.
+func (mb *MetricsBuilder) RecordMongodbConnectionCreatedDataPoint(ts pcommon.Timestamp, val int64, databaseAttributeValue string) {
+	mb.metricMongodbConnectionCreated.recordDataPoint(mb.startTime, ts, val, databaseAttributeValue)
+}
+
 // RecordMongodbCursorCountDataPoint adds a data point to mongodb.cursor.count metric.
 func (mb *MetricsBuilder) RecordMongodbCursorCountDataPoint(ts pcommon.Timestamp, val int64) {
 	mb.metricMongodbCursorCount.recordDataPoint(mb.startTime, ts, val)
@@ -2723,8 +3058,8 @@ func (mb *MetricsBuilder) RecordMongodbGlobalLockTimeDataPoint(ts pcommon.Timest
 }
 
 // RecordMongodbIndexAccessCountDataPoint adds a data point to mongodb.index.access.count metric.
-func (mb *MetricsBuilder) RecordMongodbIndexAccessCountDataPoint(ts pcommon.Timestamp, val int64, databaseAttributeValue string, collectionAttributeValue string) {
-	mb.metricMongodbIndexAccessCount.recordDataPoint(mb.startTime, ts, val, databaseAttributeValue, collectionAttributeValue)
+func (mb *MetricsBuilder) RecordMongodbIndexAccessCountDataPoint(ts pcommon.Timestamp, val int64, databaseAttributeValue string, collectionAttributeValue string, indexAttributeValue string) {
+	mb.metricMongodbIndexAccessCount.recordDataPoint(mb.startTime, ts, val, databaseAttributeValue, collectionAttributeValue, indexAttributeValue)
 }
 
 // RecordMongodbIndexCountDataPoint adds a data point to mongodb.index.count metric.
@@ -2787,6 +3122,16 @@ func (mb *MetricsBuilder) RecordMongodbOperationCountDataPoint(ts pcommon.Timest
 	mb.metricMongodbOperationCount.recordDataPoint(mb.startTime, ts, val, operationAttributeValue.String())
 }
 
+// RecordMongodbOperationLatencyCountDataPoint adds a data point to mongodb.operation.latency.count metric.
+func (mb *MetricsBuilder) RecordMongodbOperationLatencyCountDataPoint(ts pcommon.Timestamp, val int64, operationLatencyAttributeValue AttributeOperationLatency) {
+	mb.metricMongodbOperationLatencyCount.recordDataPoint(mb.startTime, ts, val, operationLatencyAttributeValue.String())
+}
+
+// RecordMongodbOperationLatencyTimeDataPoint adds a data point to mongodb.operation.latency.time metric.
+func (mb *MetricsBuilder) RecordMongodbOperationLatencyTimeDataPoint(ts pcommon.Timestamp, val int64, operationLatencyAttributeValue AttributeOperationLatency) {
+	mb.metricMongodbOperationLatencyTime.recordDataPoint(mb.startTime, ts, val, operationLatencyAttributeValue.String())
+}
+
 // RecordMongodbOperationTimeDataPoint adds a data point to mongodb.operation.time metric.
 func (mb *MetricsBuilder) RecordMongodbOperationTimeDataPoint(ts pcommon.Timestamp, val int64, operationAttributeValue AttributeOperation) {
 	mb.metricMongodbOperationTime.recordDataPoint(mb.startTime, ts, val, operationAttributeValue.String())
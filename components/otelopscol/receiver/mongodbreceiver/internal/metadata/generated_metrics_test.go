@@ -70,6 +70,7 @@ func TestMetricsBuilder(t *testing.T) {
 			aggMap["mongodb.cache.operations"] = mb.metricMongodbCacheOperations.config.AggregationStrategy
 			aggMap["mongodb.collection.count"] = mb.metricMongodbCollectionCount.config.AggregationStrategy
 			aggMap["mongodb.connection.count"] = mb.metricMongodbConnectionCount.config.AggregationStrategy
+			aggMap["mongodb.connection.created"] = mb.metricMongodbConnectionCreated.config.AggregationStrategy
 			aggMap["mongodb.data.size"] = mb.metricMongodbDataSize.config.AggregationStrategy
 			aggMap["mongodb.document.operation.count"] = mb.metricMongodbDocumentOperationCount.config.AggregationStrategy
 			aggMap["mongodb.extent.count"] = mb.metricMongodbExtentCount.config.AggregationStrategy
@@ -83,6 +84,8 @@ func TestMetricsBuilder(t *testing.T) {
 			aggMap["mongodb.memory.usage"] = mb.metricMongodbMemoryUsage.config.AggregationStrategy
 			aggMap["mongodb.object.count"] = mb.metricMongodbObjectCount.config.AggregationStrategy
 			aggMap["mongodb.operation.count"] = mb.metricMongodbOperationCount.config.AggregationStrategy
+			aggMap["mongodb.operation.latency.count"] = mb.metricMongodbOperationLatencyCount.config.AggregationStrategy
+			aggMap["mongodb.operation.latency.time"] = mb.metricMongodbOperationLatencyTime.config.AggregationStrategy
 			aggMap["mongodb.operation.time"] = mb.metricMongodbOperationTime.config.AggregationStrategy
 			aggMap["mongodb.storage.size"] = mb.metricMongodbStorageSize.config.AggregationStrategy
 
@@ -111,6 +114,12 @@ func TestMetricsBuilder(t *testing.T) {
 			if tt.name == "reaggregate_set" {
 				mb.RecordMongodbConnectionCountDataPoint(ts, 3, "database-val-2", AttributeConnectionTypeAvailable)
 			}
+
+			allMetricsCount++
+			mb.RecordMongodbConnectionCreatedDataPoint(ts, 1, "database-val")
+			if tt.name == "reaggregate_set" {
+				mb.RecordMongodbConnectionCreatedDataPoint(ts, 3, "database-val-2")
+			}
 			defaultMetricsCount++
 			allMetricsCount++
 			mb.RecordMongodbCursorCountDataPoint(ts, 1)
@@ -143,9 +152,9 @@ func TestMetricsBuilder(t *testing.T) {
 			mb.RecordMongodbGlobalLockTimeDataPoint(ts, 1)
 			defaultMetricsCount++
 			allMetricsCount++
-			mb.RecordMongodbIndexAccessCountDataPoint(ts, 1, "database-val", "collection-val")
+			mb.RecordMongodbIndexAccessCountDataPoint(ts, 1, "database-val", "collection-val", "index-val")
 			if tt.name == "reaggregate_set" {
-				mb.RecordMongodbIndexAccessCountDataPoint(ts, 3, "database-val-2", "collection-val-2")
+				mb.RecordMongodbIndexAccessCountDataPoint(ts, 3, "database-val-2", "collection-val-2", "index-val-2")
 			}
 			defaultMetricsCount++
 			allMetricsCount++
@@ -212,6 +221,18 @@ func TestMetricsBuilder(t *testing.T) {
 			}
 			defaultMetricsCount++
 			allMetricsCount++
+			mb.RecordMongodbOperationLatencyCountDataPoint(ts, 1, AttributeOperationLatencyRead)
+			if tt.name == "reaggregate_set" {
+				mb.RecordMongodbOperationLatencyCountDataPoint(ts, 3, AttributeOperationLatencyWrite)
+			}
+			defaultMetricsCount++
+			allMetricsCount++
+			mb.RecordMongodbOperationLatencyTimeDataPoint(ts, 1, AttributeOperationLatencyRead)
+			if tt.name == "reaggregate_set" {
+				mb.RecordMongodbOperationLatencyTimeDataPoint(ts, 3, AttributeOperationLatencyWrite)
+			}
+			defaultMetricsCount++
+			allMetricsCount++
 			mb.RecordMongodbOperationTimeDataPoint(ts, 1, AttributeOperationInsert)
 			if tt.name == "reaggregate_set" {
 				mb.RecordMongodbOperationTimeDataPoint(ts, 3, AttributeOperationQuery)
@@ -234,6 +255,7 @@ func TestMetricsBuilder(t *testing.T) {
 				assert.Empty(t, mb.metricMongodbCacheOperations.aggDataPoints)
 				assert.Empty(t, mb.metricMongodbCollectionCount.aggDataPoints)
 				assert.Empty(t, mb.metricMongodbConnectionCount.aggDataPoints)
+				assert.Empty(t, mb.metricMongodbConnectionCreated.aggDataPoints)
 				assert.Empty(t, mb.metricMongodbDataSize.aggDataPoints)
 				assert.Empty(t, mb.metricMongodbDocumentOperationCount.aggDataPoints)
 				assert.Empty(t, mb.metricMongodbExtentCount.aggDataPoints)
@@ -247,6 +269,8 @@ func TestMetricsBuilder(t *testing.T) {
 				assert.Empty(t, mb.metricMongodbMemoryUsage.aggDataPoints)
 				assert.Empty(t, mb.metricMongodbObjectCount.aggDataPoints)
 				assert.Empty(t, mb.metricMongodbOperationCount.aggDataPoints)
+				assert.Empty(t, mb.metricMongodbOperationLatencyCount.aggDataPoints)
+				assert.Empty(t, mb.metricMongodbOperationLatencyTime.aggDataPoints)
 				assert.Empty(t, mb.metricMongodbOperationTime.aggDataPoints)
 				assert.Empty(t, mb.metricMongodbStorageSize.aggDataPoints)
 			}
@@ -413,6 +437,50 @@ func TestMetricsBuilder(t *testing.T) {
 						_, ok = dp.Attributes().Get("type")
 						assert.False(t, ok)
 					}
+				case "mongodb.connection.created":
+					if tt.name != "reaggregate_set" {
+						assert.False(t, validatedMetrics["mongodb.connection.created"], "Found a duplicate in the metrics slice: mongodb.connection.created")
+						validatedMetrics["mongodb.connection.created"] = true
+						assert.Equal(t, pmetric.MetricTypeSum, mi.Type())
+						assert.Equal(t, 1, mi.Sum().DataPoints().Len())
+						assert.Equal(t, "The total number of connections created since the server started, as reported by serverStatus.connections.totalCreated.", mi.Description())
+						assert.Equal(t, "{connections}", mi.Unit())
+						assert.True(t, mi.Sum().IsMonotonic())
+						assert.Equal(t, pmetric.AggregationTemporalityCumulative, mi.Sum().AggregationTemporality())
+						dp := mi.Sum().DataPoints().At(0)
+						assert.Equal(t, start, dp.StartTimestamp())
+						assert.Equal(t, ts, dp.Timestamp())
+						assert.Equal(t, pmetric.NumberDataPointValueTypeInt, dp.ValueType())
+						assert.Equal(t, int64(1), dp.IntValue())
+						databaseAttrVal, ok := dp.Attributes().Get("database")
+						assert.True(t, ok)
+						assert.Equal(t, "database-val", databaseAttrVal.Str())
+					} else {
+						assert.False(t, validatedMetrics["mongodb.connection.created"], "Found a duplicate in the metrics slice: mongodb.connection.created")
+						validatedMetrics["mongodb.connection.created"] = true
+						assert.Equal(t, pmetric.MetricTypeSum, mi.Type())
+						assert.Equal(t, 1, mi.Sum().DataPoints().Len())
+						assert.Equal(t, "The total number of connections created since the server started, as reported by serverStatus.connections.totalCreated.", mi.Description())
+						assert.Equal(t, "{connections}", mi.Unit())
+						assert.True(t, mi.Sum().IsMonotonic())
+						assert.Equal(t, pmetric.AggregationTemporalityCumulative, mi.Sum().AggregationTemporality())
+						dp := mi.Sum().DataPoints().At(0)
+						assert.Equal(t, start, dp.StartTimestamp())
+						assert.Equal(t, ts, dp.Timestamp())
+						assert.Equal(t, pmetric.NumberDataPointValueTypeInt, dp.ValueType())
+						switch aggMap["mongodb.connection.created"] {
+						case "sum":
+							assert.Equal(t, int64(4), dp.IntValue())
+						case "avg":
+							assert.Equal(t, int64(2), dp.IntValue())
+						case "min":
+							assert.Equal(t, int64(1), dp.IntValue())
+						case "max":
+							assert.Equal(t, int64(3), dp.IntValue())
+						}
+						_, ok := dp.Attributes().Get("database")
+						assert.False(t, ok)
+					}
 				case "mongodb.cursor.count":
 					assert.False(t, validatedMetrics["mongodb.cursor.count"], "Found a duplicate in the metrics slice: mongodb.cursor.count")
 					validatedMetrics["mongodb.cursor.count"] = true
@@ -627,6 +695,9 @@ func TestMetricsBuilder(t *testing.T) {
 						collectionAttrVal, ok := dp.Attributes().Get("collection")
 						assert.True(t, ok)
 						assert.Equal(t, "collection-val", collectionAttrVal.Str())
+						indexAttrVal, ok := dp.Attributes().Get("index")
+						assert.True(t, ok)
+						assert.Equal(t, "index-val", indexAttrVal.Str())
 					} else {
 						assert.False(t, validatedMetrics["mongodb.index.access.count"], "Found a duplicate in the metrics slice: mongodb.index.access.count")
 						validatedMetrics["mongodb.index.access.count"] = true
@@ -654,6 +725,8 @@ func TestMetricsBuilder(t *testing.T) {
 						assert.False(t, ok)
 						_, ok = dp.Attributes().Get("collection")
 						assert.False(t, ok)
+						_, ok = dp.Attributes().Get("index")
+						assert.False(t, ok)
 					}
 				case "mongodb.index.count":
 					if tt.name != "reaggregate_set" {
@@ -1138,6 +1211,94 @@ func TestMetricsBuilder(t *testing.T) {
 						_, ok := dp.Attributes().Get("operation")
 						assert.False(t, ok)
 					}
+				case "mongodb.operation.latency.count":
+					if tt.name != "reaggregate_set" {
+						assert.False(t, validatedMetrics["mongodb.operation.latency.count"], "Found a duplicate in the metrics slice: mongodb.operation.latency.count")
+						validatedMetrics["mongodb.operation.latency.count"] = true
+						assert.Equal(t, pmetric.MetricTypeSum, mi.Type())
+						assert.Equal(t, 1, mi.Sum().DataPoints().Len())
+						assert.Equal(t, "The number of operations counted in mongodb.operation.latency.time, as reported by serverStatus.opLatencies.", mi.Description())
+						assert.Equal(t, "{operations}", mi.Unit())
+						assert.True(t, mi.Sum().IsMonotonic())
+						assert.Equal(t, pmetric.AggregationTemporalityCumulative, mi.Sum().AggregationTemporality())
+						dp := mi.Sum().DataPoints().At(0)
+						assert.Equal(t, start, dp.StartTimestamp())
+						assert.Equal(t, ts, dp.Timestamp())
+						assert.Equal(t, pmetric.NumberDataPointValueTypeInt, dp.ValueType())
+						assert.Equal(t, int64(1), dp.IntValue())
+						operationAttrVal, ok := dp.Attributes().Get("operation")
+						assert.True(t, ok)
+						assert.Equal(t, "read", operationAttrVal.Str())
+					} else {
+						assert.False(t, validatedMetrics["mongodb.operation.latency.count"], "Found a duplicate in the metrics slice: mongodb.operation.latency.count")
+						validatedMetrics["mongodb.operation.latency.count"] = true
+						assert.Equal(t, pmetric.MetricTypeSum, mi.Type())
+						assert.Equal(t, 1, mi.Sum().DataPoints().Len())
+						assert.Equal(t, "The number of operations counted in mongodb.operation.latency.time, as reported by serverStatus.opLatencies.", mi.Description())
+						assert.Equal(t, "{operations}", mi.Unit())
+						assert.True(t, mi.Sum().IsMonotonic())
+						assert.Equal(t, pmetric.AggregationTemporalityCumulative, mi.Sum().AggregationTemporality())
+						dp := mi.Sum().DataPoints().At(0)
+						assert.Equal(t, start, dp.StartTimestamp())
+						assert.Equal(t, ts, dp.Timestamp())
+						assert.Equal(t, pmetric.NumberDataPointValueTypeInt, dp.ValueType())
+						switch aggMap["mongodb.operation.latency.count"] {
+						case "sum":
+							assert.Equal(t, int64(4), dp.IntValue())
+						case "avg":
+							assert.Equal(t, int64(2), dp.IntValue())
+						case "min":
+							assert.Equal(t, int64(1), dp.IntValue())
+						case "max":
+							assert.Equal(t, int64(3), dp.IntValue())
+						}
+						_, ok := dp.Attributes().Get("operation")
+						assert.False(t, ok)
+					}
+				case "mongodb.operation.latency.time":
+					if tt.name != "reaggregate_set" {
+						assert.False(t, validatedMetrics["mongodb.operation.latency.time"], "Found a duplicate in the metrics slice: mongodb.operation.latency.time")
+						validatedMetrics["mongodb.operation.latency.time"] = true
+						assert.Equal(t, pmetric.MetricTypeSum, mi.Type())
+						assert.Equal(t, 1, mi.Sum().DataPoints().Len())
+						assert.Equal(t, "The total latency of operations, as reported by serverStatus.opLatencies. Divide by mongodb.operation.latency.count to get the mean latency.", mi.Description())
+						assert.Equal(t, "us", mi.Unit())
+						assert.True(t, mi.Sum().IsMonotonic())
+						assert.Equal(t, pmetric.AggregationTemporalityCumulative, mi.Sum().AggregationTemporality())
+						dp := mi.Sum().DataPoints().At(0)
+						assert.Equal(t, start, dp.StartTimestamp())
+						assert.Equal(t, ts, dp.Timestamp())
+						assert.Equal(t, pmetric.NumberDataPointValueTypeInt, dp.ValueType())
+						assert.Equal(t, int64(1), dp.IntValue())
+						operationAttrVal, ok := dp.Attributes().Get("operation")
+						assert.True(t, ok)
+						assert.Equal(t, "read", operationAttrVal.Str())
+					} else {
+						assert.False(t, validatedMetrics["mongodb.operation.latency.time"], "Found a duplicate in the metrics slice: mongodb.operation.latency.time")
+						validatedMetrics["mongodb.operation.latency.time"] = true
+						assert.Equal(t, pmetric.MetricTypeSum, mi.Type())
+						assert.Equal(t, 1, mi.Sum().DataPoints().Len())
+						assert.Equal(t, "The total latency of operations, as reported by serverStatus.opLatencies. Divide by mongodb.operation.latency.count to get the mean latency.", mi.Description())
+						assert.Equal(t, "us", mi.Unit())
+						assert.True(t, mi.Sum().IsMonotonic())
+						assert.Equal(t, pmetric.AggregationTemporalityCumulative, mi.Sum().AggregationTemporality())
+						dp := mi.Sum().DataPoints().At(0)
+						assert.Equal(t, start, dp.StartTimestamp())
+						assert.Equal(t, ts, dp.Timestamp())
+						assert.Equal(t, pmetric.NumberDataPointValueTypeInt, dp.ValueType())
+						switch aggMap["mongodb.operation.latency.time"] {
+						case "sum":
+							assert.Equal(t, int64(4), dp.IntValue())
+						case "avg":
+							assert.Equal(t, int64(2), dp.IntValue())
+						case "min":
+							assert.Equal(t, int64(1), dp.IntValue())
+						case "max":
+							assert.Equal(t, int64(3), dp.IntValue())
+						}
+						_, ok := dp.Attributes().Get("operation")
+						assert.False(t, ok)
+					}
 				case "mongodb.operation.time":
 					if tt.name != "reaggregate_set" {
 						assert.False(t, validatedMetrics["mongodb.operation.time"], "Found a duplicate in the metrics slice: mongodb.operation.time")
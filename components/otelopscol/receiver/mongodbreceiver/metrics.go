@@ -38,6 +38,12 @@ var operationsMap = map[string]metadata.AttributeOperation{
 	"commands": metadata.AttributeOperationCommand,
 }
 
+var operationLatencyMap = map[string]metadata.AttributeOperationLatency{
+	"reads":    metadata.AttributeOperationLatencyRead,
+	"writes":   metadata.AttributeOperationLatencyWrite,
+	"commands": metadata.AttributeOperationLatencyCommand,
+}
+
 var documentMap = map[string]metadata.AttributeOperation{
 	"inserted": metadata.AttributeOperationInsert,
 	"updated":  metadata.AttributeOperationUpdate,
@@ -171,6 +177,24 @@ func (s *mongodbScraper) recordConnections(now pcommon.Timestamp, doc bson.M, db
 	}
 }
 
+// recordConnectionsCreated records connections.totalCreated. It is kept out of
+// mongodb.connection.count because it is a running total rather than a count
+// of open connections, and adding a type to that metric would change the
+// series existing users get; it is opt-in instead.
+func (s *mongodbScraper) recordConnectionsCreated(now pcommon.Timestamp, doc bson.M, dbName string, errs *scrapererror.ScrapeErrors) {
+	if !s.config.Metrics.MongodbConnectionCreated.Enabled {
+		return
+	}
+	metricPath := []string{"connections", "totalCreated"}
+	metricName := "mongodb.connection.created"
+	val, err := collectMetric(doc, metricPath)
+	if err != nil {
+		errs.AddPartial(1, fmt.Errorf(collectMetricWithAttributes, metricName, dbName, err))
+		return
+	}
+	s.mb.RecordMongodbConnectionCreatedDataPoint(now, val, dbName)
+}
+
 func (s *mongodbScraper) recordMemoryUsage(now pcommon.Timestamp, doc bson.M, dbName string, errs *scrapererror.ScrapeErrors) {
 	for mtVal, mt := range metadata.MapAttributeMemoryType {
 		metricPath := []string{"mem", mtVal}
@@ -243,6 +267,41 @@ func (s *mongodbScraper) recordOperations(now pcommon.Timestamp, doc bson.M, err
 	}
 }
 
+func (s *mongodbScraper) recordOperationLatencies(now pcommon.Timestamp, doc bson.M, errs *scrapererror.ScrapeErrors) {
+	if !s.config.Metrics.MongodbOperationLatencyTime.Enabled && !s.config.Metrics.MongodbOperationLatencyCount.Enabled {
+		return
+	}
+	// opLatencies is only reported by MongoDB 3.2+, so skip the metric
+	// rather than report a scrape error on every scrape of older servers.
+	// https://www.mongodb.com/docs/manual/reference/command/serverStatus/#opLatencies
+	if _, err := dig(doc, []string{"opLatencies"}); err != nil {
+		if !s.loggedMissingOpLatencies {
+			s.logger.Info("serverStatus does not report opLatencies, skipping mongodb.operation.latency.time and mongodb.operation.latency.count")
+			s.loggedMissingOpLatencies = true
+		}
+		return
+	}
+	for operationVal, operation := range operationLatencyMap {
+		metricPath := []string{"opLatencies", operationVal, "latency"}
+		metricName := "mongodb.operation.latency.time"
+		val, err := collectMetric(doc, metricPath)
+		if err != nil {
+			errs.AddPartial(1, fmt.Errorf(collectMetricWithAttributes, metricName, operationVal, err))
+			continue
+		}
+		s.mb.RecordMongodbOperationLatencyTimeDataPoint(now, val, operation)
+
+		metricPath = []string{"opLatencies", operationVal, "ops"}
+		metricName = "mongodb.operation.latency.count"
+		val, err = collectMetric(doc, metricPath)
+		if err != nil {
+			errs.AddPartial(1, fmt.Errorf(collectMetricWithAttributes, metricName, operationVal, err))
+			continue
+		}
+		s.mb.RecordMongodbOperationLatencyCountDataPoint(now, val, operation)
+	}
+}
+
 func (s *mongodbScraper) recordCacheOperations(now pcommon.Timestamp, doc bson.M, errs *scrapererror.ScrapeErrors) {
 	// Collect Cache Hits & Misses if wiredTiger storage engine is used
 	// WiredTiger.cache metrics are available in 3.0+
@@ -462,23 +521,22 @@ func (s *mongodbScraper) recordIndexAccess(now pcommon.Timestamp, documents []bs
 	mongo32, _ := version.NewVersion("3.2")
 	if s.mongoVersion.GreaterThanOrEqual(mongo32) {
 		metricName := "mongodb.index.access.count"
-		var indexAccessTotal int64
 		for _, doc := range documents {
-			metricAttributes := fmt.Sprintf("%s, %s", dbName, collectionName)
-			indexAccess, ok := doc["accesses"].(bson.M)["ops"]
+			indexName, ok := doc["name"].(string)
 			if !ok {
-				err := errors.New("could not find key for index access metric")
+				metricAttributes := fmt.Sprintf("%s, %s", dbName, collectionName)
+				err := errors.New("could not find key for index name")
 				errs.AddPartial(1, fmt.Errorf(collectMetricWithAttributes, metricName, metricAttributes, err))
-				return
+				continue
 			}
-			indexAccessValue, err := parseInt(indexAccess)
+			metricAttributes := fmt.Sprintf("%s, %s, %s", dbName, collectionName, indexName)
+			indexAccessValue, err := collectMetric(doc, []string{"accesses", "ops"})
 			if err != nil {
 				errs.AddPartial(1, fmt.Errorf(collectMetricWithAttributes, metricName, metricAttributes, err))
-				return
+				continue
 			}
-			indexAccessTotal += indexAccessValue
+			s.mb.RecordMongodbIndexAccessCountDataPoint(now, indexAccessValue, dbName, collectionName, indexName)
 		}
-		s.mb.RecordMongodbIndexAccessCountDataPoint(now, indexAccessTotal, dbName, collectionName)
 	}
 }
 
@@ -18,10 +18,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/hashicorp/go-version"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
@@ -38,6 +40,13 @@ type mongodbScraper struct {
 	client       client
 	mongoVersion *version.Version
 	mb           *metadata.MetricsBuilder
+
+	// Whether the absence of opLatencies in serverStatus has been logged.
+	loggedMissingOpLatencies bool
+	// Whether a missing indexStats privilege has been logged.
+	loggedIndexStatsUnauthorized bool
+	// Configured databases whose absence from the server has been logged.
+	loggedMissingDatabases map[string]bool
 }
 
 func newMongodbScraper(settings receiver.Settings, config *Config) *mongodbScraper {
@@ -60,10 +69,14 @@ func (s *mongodbScraper) start(ctx context.Context, _ component.Host) error {
 }
 
 func (s *mongodbScraper) shutdown(ctx context.Context) error {
-	if s.client != nil {
-		return s.client.Disconnect(ctx)
+	if s.client == nil {
+		return nil
 	}
-	return nil
+	err := s.client.Disconnect(ctx)
+	// Drop the client even if Disconnect failed, so that a repeated shutdown
+	// does not try to disconnect it again.
+	s.client = nil
+	return err
 }
 
 func (s *mongodbScraper) scrape(ctx context.Context) (pmetric.Metrics, error) {
@@ -97,7 +110,7 @@ func (s *mongodbScraper) collectMetrics(ctx context.Context, errs *scrapererror.
 	s.collectAdminDatabase(ctx, now, errs)
 	s.collectTopStats(ctx, now, errs)
 
-	for _, dbName := range dbNames {
+	for _, dbName := range s.databasesToScrape(dbNames) {
 		s.collectDatabase(ctx, now, dbName, errs)
 		collectionNames, err := s.client.ListCollectionNames(ctx, dbName)
 		if err != nil {
@@ -108,7 +121,7 @@ func (s *mongodbScraper) collectMetrics(ctx context.Context, errs *scrapererror.
 		// The indexStats aggregation is only available if version is >= 3.2
 		// https://www.mongodb.com/docs/v3.2/reference/operator/aggregation/indexStats/
 		mongo32, _ := version.NewVersion("3.2")
-		if s.mongoVersion.GreaterThanOrEqual(mongo32) {
+		if s.config.CollectIndexStats && s.mongoVersion.GreaterThanOrEqual(mongo32) {
 			for _, collectionName := range collectionNames {
 				s.collectIndexStats(ctx, now, dbName, collectionName, errs)
 			}
@@ -116,6 +129,33 @@ func (s *mongodbScraper) collectMetrics(ctx context.Context, errs *scrapererror.
 	}
 }
 
+// databasesToScrape returns the databases in dbNames to collect per-database
+// metrics for: all of them, or only those in Config.Databases if it is set.
+// Configured databases that don't exist are skipped rather than failing the
+// scrape, and a warning is logged the first time each one is missing.
+func (s *mongodbScraper) databasesToScrape(dbNames []string) []string {
+	if len(s.config.Databases) == 0 {
+		return dbNames
+	}
+	var selected []string
+	for _, dbName := range dbNames {
+		if slices.Contains(s.config.Databases, dbName) {
+			selected = append(selected, dbName)
+		}
+	}
+	for _, dbName := range s.config.Databases {
+		if slices.Contains(dbNames, dbName) || s.loggedMissingDatabases[dbName] {
+			continue
+		}
+		s.logger.Warn("configured database was not found on the server, skipping it", zap.String("database", dbName))
+		if s.loggedMissingDatabases == nil {
+			s.loggedMissingDatabases = make(map[string]bool)
+		}
+		s.loggedMissingDatabases[dbName] = true
+	}
+	return selected
+}
+
 func (s *mongodbScraper) collectDatabase(ctx context.Context, now pcommon.Timestamp, databaseName string, errs *scrapererror.ScrapeErrors) {
 	dbStats, err := s.client.DBStats(ctx, databaseName)
 	if err != nil {
@@ -158,6 +198,15 @@ func (s *mongodbScraper) collectTopStats(ctx context.Context, now pcommon.Timest
 
 func (s *mongodbScraper) collectIndexStats(ctx context.Context, now pcommon.Timestamp, databaseName string, collectionName string, errs *scrapererror.ScrapeErrors) {
 	indexStats, err := s.client.IndexStats(ctx, databaseName, collectionName)
+	if isUnauthorizedError(err) {
+		// The monitoring user may not have been granted the indexStats
+		// privilege; skip the collection rather than fail every scrape.
+		if !s.loggedIndexStatsUnauthorized {
+			s.logger.Warn("not authorized to run $indexStats, skipping mongodb.index.access.count for unauthorized collections", zap.Error(err))
+			s.loggedIndexStatsUnauthorized = true
+		}
+		return
+	}
 	if err != nil {
 		errs.AddPartial(1, fmt.Errorf("failed to fetch index stats metrics: %w", err))
 		return
@@ -178,6 +227,7 @@ func (s *mongodbScraper) recordDBStats(now pcommon.Timestamp, doc bson.M, dbName
 
 func (s *mongodbScraper) recordNormalServerStats(now pcommon.Timestamp, doc bson.M, dbName string, errs *scrapererror.ScrapeErrors) {
 	s.recordConnections(now, doc, dbName, errs)
+	s.recordConnectionsCreated(now, doc, dbName, errs)
 	s.recordDocumentOperations(now, doc, dbName, errs)
 	s.recordMemoryUsage(now, doc, dbName, errs)
 	s.recordLockAcquireCounts(now, doc, dbName, errs)
@@ -193,9 +243,20 @@ func (s *mongodbScraper) recordAdminStats(now pcommon.Timestamp, document bson.M
 	s.recordGlobalLockTime(now, document, errs)
 	s.recordNetworkCount(now, document, errs)
 	s.recordOperations(now, document, errs)
+	s.recordOperationLatencies(now, document, errs)
 	s.recordSessionCount(now, document, errs)
 }
 
 func (s *mongodbScraper) recordIndexStats(now pcommon.Timestamp, indexStats []bson.M, databaseName string, collectionName string, errs *scrapererror.ScrapeErrors) {
 	s.recordIndexAccess(now, indexStats, databaseName, collectionName, errs)
 }
+
+// unauthorizedErrorCode is the server error code returned when the user lacks
+// the privilege to run a command.
+// https://www.mongodb.com/docs/manual/reference/error-codes/
+const unauthorizedErrorCode = 13
+
+func isUnauthorizedError(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(unauthorizedErrorCode)
+}
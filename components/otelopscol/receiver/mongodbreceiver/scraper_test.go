@@ -0,0 +1,381 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbreceiver // import "github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/mongodbreceiver"
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"go.opentelemetry.io/collector/scraper/scrapererror"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/mongodbreceiver/internal/metadata"
+)
+
+// disconnectCountingClient is a client that only counts calls to Disconnect.
+type disconnectCountingClient struct {
+	client
+	disconnects int
+}
+
+func (c *disconnectCountingClient) Disconnect(context.Context) error {
+	c.disconnects++
+	return nil
+}
+
+func TestShutdownDisconnectsOnce(t *testing.T) {
+	scraper := newMongodbScraper(receivertest.NewNopSettings(metadata.Type), createDefaultConfig().(*Config))
+	fakeClient := &disconnectCountingClient{}
+	scraper.client = fakeClient
+
+	require.NoError(t, scraper.shutdown(context.Background()))
+	require.NoError(t, scraper.shutdown(context.Background()))
+	require.Equal(t, 1, fakeClient.disconnects)
+	require.Nil(t, scraper.client)
+}
+
+// loadServerStatusM loads a recorded serverStatus document the same way the
+// driver decodes it for the scraper.
+func loadServerStatusM(t *testing.T, filePath string) bson.M {
+	testFile, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	var doc bson.M
+	require.NoError(t, bson.UnmarshalExtJSON(testFile, true, &doc))
+	return doc
+}
+
+func TestRecordOperationLatencies(t *testing.T) {
+	scraper := newMongodbScraper(receivertest.NewNopSettings(metadata.Type), createDefaultConfig().(*Config))
+	doc := loadServerStatusM(t, "./testdata/serverStatus.json")
+
+	errs := &scrapererror.ScrapeErrors{}
+	scraper.recordOperationLatencies(pcommon.NewTimestampFromTime(time.Now()), doc, errs)
+	require.NoError(t, errs.Combine())
+
+	metrics := scraper.mb.Emit()
+	require.Equal(t, 2, metrics.MetricCount())
+	units := map[string]string{}
+	values := map[string]map[string]int64{}
+	ms := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < ms.Len(); i++ {
+		metric := ms.At(i)
+		units[metric.Name()] = metric.Unit()
+		values[metric.Name()] = map[string]int64{}
+		dps := metric.Sum().DataPoints()
+		for j := 0; j < dps.Len(); j++ {
+			operation, ok := dps.At(j).Attributes().Get("operation")
+			require.True(t, ok)
+			values[metric.Name()][operation.Str()] = dps.At(j).IntValue()
+		}
+	}
+	require.Equal(t, map[string]string{
+		"mongodb.operation.latency.time":  "us",
+		"mongodb.operation.latency.count": "{operations}",
+	}, units)
+	require.Equal(t, map[string]map[string]int64{
+		"mongodb.operation.latency.time":  {"read": 0, "write": 0, "command": 8631},
+		"mongodb.operation.latency.count": {"read": 0, "write": 0, "command": 23},
+	}, values)
+}
+
+func TestRecordOperationLatenciesMissing(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	settings := receivertest.NewNopSettings(metadata.Type)
+	settings.Logger = zap.New(core)
+	scraper := newMongodbScraper(settings, createDefaultConfig().(*Config))
+	// Servers older than 3.2 don't report opLatencies.
+	doc := loadServerStatusM(t, "./testdata/only_storage_engine.json")
+
+	for i := 0; i < 3; i++ {
+		errs := &scrapererror.ScrapeErrors{}
+		scraper.recordOperationLatencies(pcommon.NewTimestampFromTime(time.Now()), doc, errs)
+		require.NoError(t, errs.Combine())
+		require.Equal(t, 0, scraper.mb.Emit().MetricCount())
+	}
+	require.Equal(t, 1, logs.FilterMessageSnippet("opLatencies").Len())
+}
+
+// indexStatsClient is a client that serves recorded $indexStats results.
+type indexStatsClient struct {
+	client
+	indexStats map[string][]bson.M
+	err        error
+	calls      int
+}
+
+func (c *indexStatsClient) IndexStats(_ context.Context, _, collectionName string) ([]bson.M, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.indexStats[collectionName], nil
+}
+
+// collectOrdersIndexStats collects the recorded $indexStats of a collection
+// with three indexes, and returns the index access counts reported for it by
+// index name, or under "-" if there is no index attribute.
+func collectOrdersIndexStats(t *testing.T, cfg *Config) map[string]int64 {
+	scraper := newMongodbScraper(receivertest.NewNopSettings(metadata.Type), cfg)
+	scraper.mongoVersion, _ = version.NewVersion("4.4")
+	scraper.client = &indexStatsClient{
+		indexStats: map[string][]bson.M{
+			"orders": {
+				loadServerStatusM(t, "./testdata/ordersIndexStats0.json"),
+				loadServerStatusM(t, "./testdata/ordersIndexStats1.json"),
+				loadServerStatusM(t, "./testdata/ordersIndexStats2.json"),
+			},
+		},
+	}
+
+	errs := &scrapererror.ScrapeErrors{}
+	scraper.collectIndexStats(context.Background(), pcommon.NewTimestampFromTime(time.Now()), "testdb", "orders", errs)
+	require.NoError(t, errs.Combine())
+
+	metrics := scraper.mb.Emit()
+	require.Equal(t, 1, metrics.MetricCount())
+	metric := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	require.Equal(t, "mongodb.index.access.count", metric.Name())
+
+	accesses := map[string]int64{}
+	dps := metric.Sum().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		attrs := dps.At(i).Attributes()
+		database, _ := attrs.Get("database")
+		require.Equal(t, "testdb", database.Str())
+		collection, _ := attrs.Get("collection")
+		require.Equal(t, "orders", collection.Str())
+		indexName := "-"
+		if index, ok := attrs.Get("index"); ok {
+			indexName = index.Str()
+		}
+		accesses[indexName] = dps.At(i).IntValue()
+	}
+	return accesses
+}
+
+func TestCollectIndexStats(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	require.Equal(t, map[string]int64{"item_1_quantity_1": 1, "_id_": 0, "type_1_item_1": 1}, collectOrdersIndexStats(t, cfg))
+}
+
+func TestCollectIndexStatsWithoutIndexAttribute(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Metrics.MongodbIndexAccessCount.EnabledAttributes = []metadata.MongodbIndexAccessCountMetricAttributeKey{
+		metadata.MongodbIndexAccessCountMetricAttributeKeyDatabase,
+		metadata.MongodbIndexAccessCountMetricAttributeKeyCollection,
+	}
+	// The accesses of all indexes are summed into one series per collection.
+	require.Equal(t, map[string]int64{"-": 2}, collectOrdersIndexStats(t, cfg))
+}
+
+func TestScrapeIndexStatsEnabled(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("collect_index_stats=%t", enabled), func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.CollectIndexStats = enabled
+			cfg.Databases = []string{"orders"}
+			scraper := newMongodbScraper(receivertest.NewNopSettings(metadata.Type), cfg)
+			scraper.mongoVersion, _ = version.NewVersion("4.4")
+			fakeClient := &indexStatsClient{
+				client: &databasesClient{t: t, collections: []string{"orders", "products"}},
+			}
+			scraper.client = fakeClient
+
+			_, _ = scraper.scrape(context.Background())
+			if enabled {
+				require.Equal(t, 2, fakeClient.calls)
+			} else {
+				require.Zero(t, fakeClient.calls, "$indexStats was run with collect_index_stats disabled")
+			}
+		})
+	}
+}
+
+func TestCollectIndexStatsUnauthorized(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	settings := receivertest.NewNopSettings(metadata.Type)
+	settings.Logger = zap.New(core)
+	scraper := newMongodbScraper(settings, createDefaultConfig().(*Config))
+	scraper.mongoVersion, _ = version.NewVersion("4.4")
+	fakeClient := &indexStatsClient{
+		err: mongo.CommandError{Code: unauthorizedErrorCode, Message: "not authorized on testdb to execute command"},
+	}
+	scraper.client = fakeClient
+
+	for _, collectionName := range []string{"orders", "products"} {
+		errs := &scrapererror.ScrapeErrors{}
+		scraper.collectIndexStats(context.Background(), pcommon.NewTimestampFromTime(time.Now()), "testdb", collectionName, errs)
+		require.NoError(t, errs.Combine())
+	}
+	require.Equal(t, 2, fakeClient.calls)
+	require.Equal(t, 0, scraper.mb.Emit().MetricCount())
+	require.Equal(t, 1, logs.FilterMessageSnippet("indexStats").Len())
+}
+
+// databasesClient is a client that serves a recorded listDatabases response
+// and records which databases dbStats was run on. Every database has the
+// given collections.
+type databasesClient struct {
+	client
+	t           *testing.T
+	dbStats     []string
+	collections []string
+}
+
+func (c *databasesClient) ListDatabaseNames(context.Context, interface{}, ...*options.ListDatabasesOptions) ([]string, error) {
+	var names []string
+	for _, db := range loadServerStatusM(c.t, "./testdata/listDatabases.json")["databases"].(bson.A) {
+		names = append(names, db.(bson.M)["name"].(string))
+	}
+	return names, nil
+}
+
+func (c *databasesClient) DBStats(_ context.Context, dbName string) (bson.M, error) {
+	c.dbStats = append(c.dbStats, dbName)
+	return loadServerStatusM(c.t, "./testdata/dbstats.json"), nil
+}
+
+func (c *databasesClient) ServerStatus(context.Context, string) (bson.M, error) {
+	return loadServerStatusM(c.t, "./testdata/serverStatus.json"), nil
+}
+
+func (c *databasesClient) TopStats(context.Context) (bson.M, error) {
+	return loadServerStatusM(c.t, "./testdata/top.json"), nil
+}
+
+func (c *databasesClient) ListCollectionNames(context.Context, string) ([]string, error) {
+	return c.collections, nil
+}
+
+func TestScrapeDatabases(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		databases []string
+		expected  []string
+		warnings  int
+	}{
+		{
+			desc:     "all databases by default",
+			expected: []string{"admin", "config", "inventory", "local", "orders"},
+		},
+		{
+			desc:      "only listed databases",
+			databases: []string{"orders", "inventory"},
+			expected:  []string{"inventory", "orders"},
+		},
+		{
+			desc:      "missing database",
+			databases: []string{"orders", "archive"},
+			expected:  []string{"orders"},
+			warnings:  1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			settings := receivertest.NewNopSettings(metadata.Type)
+			settings.Logger = zap.New(core)
+			cfg := createDefaultConfig().(*Config)
+			cfg.Databases = tc.databases
+			scraper := newMongodbScraper(settings, cfg)
+			scraper.mongoVersion, _ = version.NewVersion("4.4")
+
+			for i := 0; i < 2; i++ {
+				fakeClient := &databasesClient{t: t}
+				scraper.client = fakeClient
+				metrics, _ := scraper.scrape(context.Background())
+				require.Equal(t, tc.expected, fakeClient.dbStats)
+
+				var scraped []string
+				rms := metrics.ResourceMetrics()
+				for j := 0; j < rms.Len(); j++ {
+					if database, ok := rms.At(j).Resource().Attributes().Get("database"); ok {
+						scraped = append(scraped, database.Str())
+					}
+				}
+				require.Equal(t, tc.expected, scraped)
+			}
+			// Missing databases are only logged once, not on every scrape.
+			require.Equal(t, tc.warnings, logs.FilterMessageSnippet("configured database").Len())
+		})
+	}
+}
+
+func TestRecordConnections(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		created bool
+	}{
+		{desc: "default"},
+		{desc: "connection.created enabled", created: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.Metrics.MongodbConnectionCreated.Enabled = tc.created
+			scraper := newMongodbScraper(receivertest.NewNopSettings(metadata.Type), cfg)
+			scraper.mongoVersion, _ = version.NewVersion("4.4")
+			doc := loadServerStatusM(t, "./testdata/serverStatus.json")
+
+			errs := &scrapererror.ScrapeErrors{}
+			now := pcommon.NewTimestampFromTime(time.Now())
+			scraper.recordConnections(now, doc, "orders", errs)
+			scraper.recordConnectionsCreated(now, doc, "orders", errs)
+			require.NoError(t, errs.Combine())
+
+			counts := map[string]int64{}
+			var created []int64
+			metrics := scraper.mb.Emit().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+			for i := 0; i < metrics.Len(); i++ {
+				metric := metrics.At(i)
+				dps := metric.Sum().DataPoints()
+				switch metric.Name() {
+				case "mongodb.connection.count":
+					for j := 0; j < dps.Len(); j++ {
+						connectionType, ok := dps.At(j).Attributes().Get("type")
+						require.True(t, ok)
+						counts[connectionType.Str()] = dps.At(j).IntValue()
+					}
+				case "mongodb.connection.created":
+					require.True(t, metric.Sum().IsMonotonic())
+					for j := 0; j < dps.Len(); j++ {
+						created = append(created, dps.At(j).IntValue())
+					}
+				default:
+					t.Fatalf("unexpected metric %s", metric.Name())
+				}
+			}
+			// The types of mongodb.connection.count don't change when
+			// mongodb.connection.created is enabled.
+			require.Equal(t, map[string]int64{"active": 1, "available": 838857, "current": 3}, counts)
+			if tc.created {
+				require.Equal(t, []int64{3}, created)
+			} else {
+				require.Empty(t, created)
+			}
+		})
+	}
+}
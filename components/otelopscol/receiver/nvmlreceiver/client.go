@@ -40,6 +40,9 @@ type nvmlClient struct {
 	deviceToLastSeenTimestamp      map[nvml.Device]uint64
 	deviceMetricToFailedQueryCount map[string]uint64
 	deviceToAccountingIsEnabled    map[nvml.Device]bool
+	// The kinds of running processes ("compute" or "graphics") that the
+	// driver doesn't support listing, by device.
+	deviceToUnsupportedProcessKinds map[nvml.Device]map[string]bool
 }
 
 type deviceMetric struct {
@@ -49,6 +52,14 @@ type deviceMetric struct {
 	value    [8]byte
 }
 
+type processMemoryMetric struct {
+	time        time.Time
+	gpuIndex    uint
+	processPid  int
+	processName string
+	usedBytes   uint64
+}
+
 type processMetric struct {
 	time                   time.Time
 	gpuIndex               uint
@@ -75,6 +86,11 @@ var nvmlDeviceGetSamples = nvml.DeviceGetSamples
 var nvmlDeviceGetMemoryInfo = nvml.DeviceGetMemoryInfo
 var nvmlDeviceSetAccountingMode = nvml.DeviceSetAccountingMode
 var nvmlDeviceGetAccountingPids = nvml.DeviceGetAccountingPids
+var nvmlDeviceGetComputeRunningProcesses = nvml.DeviceGetComputeRunningProcesses
+var nvmlDeviceGetGraphicsRunningProcesses = nvml.DeviceGetGraphicsRunningProcesses
+
+// nvmlValueNotAvailable is NVML_VALUE_NOT_AVAILABLE as an unsigned long long.
+const nvmlValueNotAvailable = ^uint64(0)
 
 func newClient(config *Config, logger *zap.Logger) (*nvmlClient, error) {
 	nvmlCleanup, err := initializeNvml(logger)
@@ -103,6 +119,8 @@ func newClient(config *Config, logger *zap.Logger) (*nvmlClient, error) {
 		deviceToLastSeenTimestamp:      make(map[nvml.Device]uint64),
 		deviceMetricToFailedQueryCount: make(map[string]uint64),
 		deviceToAccountingIsEnabled:    deviceToAccountingIsEnabled,
+
+		deviceToUnsupportedProcessKinds: make(map[nvml.Device]map[string]bool),
 	}, nil
 }
 
@@ -232,12 +250,15 @@ func enableProcessAccountingModeOnSupportingDevices(logger *zap.Logger, devices
 	return deviceToAccountingIsEnabled
 }
 
+// cleanup shuts down the Nvidia Management Library client. It is safe to call
+// more than once; only the first successful call shuts the library down.
 func (client *nvmlClient) cleanup() error {
 	if client.handleCleanup != nil {
 		err := client.handleCleanup()
 		if err != nil {
 			return err
 		}
+		client.handleCleanup = nil
 	}
 	if !client.disable {
 		client.logger.Info("Shutdown Nvidia Management Library client")
@@ -408,6 +429,76 @@ func (client *nvmlClient) collectProcessMetrics() []processMetric {
 	return processMetrics
 }
 
+// collectProcessMemoryMetrics reports the GPU memory currently used by each
+// process running on each device, as listed by NVML for both compute and
+// graphics contexts.
+func (client *nvmlClient) collectProcessMemoryMetrics() []processMemoryMetric {
+	if client.disable {
+		return nil
+	}
+
+	processMemoryMetrics := make([]processMemoryMetric, 0)
+
+	for gpuIndex, device := range client.devices {
+		// A process with both a compute and a graphics context is listed
+		// twice with the same memory usage; only count it once.
+		seenPids := make(map[uint32]bool)
+		for _, kind := range []struct {
+			name                string
+			getRunningProcesses func(nvml.Device) ([]nvml.ProcessInfo, nvml.Return)
+		}{
+			{"compute", nvmlDeviceGetComputeRunningProcesses},
+			{"graphics", nvmlDeviceGetGraphicsRunningProcesses},
+		} {
+			if client.deviceToUnsupportedProcessKinds[device][kind.name] {
+				continue
+			}
+
+			infos, ret := kind.getRunningProcesses(device)
+			if ret == nvml.ERROR_NOT_SUPPORTED || ret == nvml.ERROR_FUNCTION_NOT_FOUND {
+				client.logger.Infof("Nvidia device %d does not support listing running %s processes on '%v'; skipping them for per-process memory metrics.",
+					gpuIndex, kind.name, nvml.ErrorString(ret))
+				if client.deviceToUnsupportedProcessKinds[device] == nil {
+					client.deviceToUnsupportedProcessKinds[device] = make(map[string]bool)
+				}
+				client.deviceToUnsupportedProcessKinds[device][kind.name] = true
+				continue
+			}
+			if ret != nvml.SUCCESS {
+				msg := fmt.Sprintf("Unable to query running %s processes on '%v'", kind.name, nvml.ErrorString(ret))
+				client.issueWarningForFailedQueryUptoThreshold(gpuIndex, "gpu.process.memory.used", msg)
+				continue
+			}
+
+			timestamp := time.Now()
+			for _, info := range infos {
+				// Memory usage is not available for processes on Windows
+				// devices in WDDM mode.
+				if seenPids[info.Pid] || info.UsedGpuMemory == nvmlValueNotAvailable {
+					continue
+				}
+				seenPids[info.Pid] = true
+
+				metric := processMemoryMetric{
+					time:       timestamp,
+					gpuIndex:   uint(gpuIndex),
+					processPid: int(info.Pid),
+					usedBytes:  info.UsedGpuMemory,
+				}
+				if proc, err := process.NewProcess(int32(info.Pid)); err == nil {
+					metric.processName, _ = proc.Name()
+				}
+				processMemoryMetrics = append(processMemoryMetrics, metric)
+
+				client.logger.Debugf("Found pid %d (%s) using %d bytes of Nvidia device %d",
+					metric.processPid, metric.processName, metric.usedBytes, metric.gpuIndex)
+			}
+		}
+	}
+
+	return processMemoryMetrics
+}
+
 func (metric *processMetric) setMetadataLabels() error {
 	process, err := process.NewProcess(int32(metric.processPid))
 	if err != nil {
@@ -20,6 +20,7 @@ import (
 	"go.opentelemetry.io/collector/scraper/scraperhelper"
 
 	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/nvmlreceiver/internal/metadata"
+	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/internal/scrapeschedule"
 )
 
 const defaultCollectionInterval = 10 * time.Second
@@ -27,4 +28,18 @@ const defaultCollectionInterval = 10 * time.Second
 type Config struct {
 	scraperhelper.ControllerConfig `mapstructure:",squash"`
 	Metrics                        metadata.MetricsConfig `mapstructure:"metrics"`
+	// InitialDelayJitter is the upper bound of a random delay added to
+	// initial_delay before the first scrape, so that collectors started at
+	// the same time don't all query the driver at once.
+	InitialDelayJitter time.Duration `mapstructure:"initial_delay_jitter"`
+	// IntervalJitter is the upper bound of a random delay before each
+	// scrape, so that collectors scraping at the same interval drift apart
+	// instead of staying in sync. It must be shorter than
+	// collection_interval.
+	IntervalJitter time.Duration `mapstructure:"interval_jitter"`
+}
+
+// Validate checks that the jitter bounds are usable.
+func (c *Config) Validate() error {
+	return scrapeschedule.ValidateJitter(c.InitialDelayJitter, c.IntervalJitter, c.CollectionInterval)
 }
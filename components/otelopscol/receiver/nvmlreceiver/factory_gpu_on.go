@@ -28,6 +28,7 @@ import (
 	"go.opentelemetry.io/collector/scraper/scraperhelper"
 
 	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/nvmlreceiver/internal/metadata"
+	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/internal/scrapeschedule"
 )
 
 func createMetricsReceiver(
@@ -42,16 +43,34 @@ func createMetricsReceiver(
 	}
 
 	ns := newNvmlScraper(cfg, params)
+	// Keep collectors that scrape at the same interval from staying in sync.
+	// The jitter is applied to the ticks between scrapes, so that it doesn't
+	// count against the scrape timeout.
+	ticker := scrapeschedule.NewTicker(cfg.CollectionInterval, cfg.IntervalJitter)
 	scp, err := scraper.NewMetrics(
 		ns.scrape,
-		scraper.WithStart(ns.start),
-		scraper.WithShutdown(ns.stop))
+		scraper.WithStart(func(ctx context.Context, host component.Host) error {
+			if err := ns.start(ctx, host); err != nil {
+				return err
+			}
+			ticker.Start()
+			return nil
+		}),
+		scraper.WithShutdown(func(ctx context.Context) error {
+			ticker.Stop()
+			return ns.stop(ctx)
+		}))
 	if err != nil {
 		return nil, err
 	}
 
+	// Extend the initial delay so that collectors started together don't all
+	// scrape at once.
+	controllerConfig := cfg.ControllerConfig
+	controllerConfig.InitialDelay += scrapeschedule.Jitter(cfg.InitialDelayJitter)
 	return scraperhelper.NewMetricsController(
-		&cfg.ControllerConfig, params, consumer,
+		&controllerConfig, params, consumer,
 		scraperhelper.AddScraper(metadata.Type, scp),
+		scraperhelper.WithTickerChannel(ticker.C),
 	)
 }
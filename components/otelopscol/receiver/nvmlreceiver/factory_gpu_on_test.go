@@ -20,9 +20,12 @@ package nvmlreceiver
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/nvmlreceiver/internal/metadata"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/receiver/receivertest"
 )
@@ -42,3 +45,33 @@ func TestCreateMetricsReceiverGPUSupportOn(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, receiver, "failed to create metrics receiver")
 }
+
+// The collector recreates the receiver when its configuration is reloaded,
+// so a changed collection_interval applies to the receiver created from it.
+func TestCreateMetricsReceiverCollectionInterval(t *testing.T) {
+	// Scrape without NVML, whether or not this machine has a GPU.
+	realNvmlInit := nvmlInit
+	defer func() { nvmlInit = realNvmlInit }()
+	nvmlInit = func() nvml.Return { panic("library not found") }
+
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.InitialDelay = 0
+	cfg.CollectionInterval = 20 * time.Millisecond
+	sink := new(consumertest.MetricsSink)
+	rcvr, err := factory.CreateMetrics(
+		context.Background(),
+		receivertest.NewNopSettings(metadata.Type),
+		cfg,
+		sink,
+	)
+	require.NoError(t, err)
+	require.NoError(t, rcvr.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, rcvr.Shutdown(context.Background()))
+	}()
+
+	// The default interval is several seconds long, so this many scrapes can
+	// only come from the configured interval.
+	require.Eventually(t, func() bool { return len(sink.AllMetrics()) >= 5 }, 10*time.Second, 10*time.Millisecond)
+}
@@ -3,6 +3,7 @@ module github.com/GoogleCloudPlatform/opentelemetry-operations-collector/compone
 go 1.25.0
 
 require (
+	github.com/GoogleCloudPlatform/opentelemetry-operations-collector v0.156.0
 	github.com/NVIDIA/go-nvml v0.12.4-0
 	github.com/google/go-cmp v0.7.0
 	github.com/shirou/gopsutil/v3 v3.24.5
@@ -69,3 +70,5 @@ require (
 	google.golang.org/protobuf v1.36.11 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/GoogleCloudPlatform/opentelemetry-operations-collector => ../../../../
@@ -8,6 +8,58 @@ import (
 	"go.opentelemetry.io/collector/confmap"
 )
 
+// GpuProcessMemoryUsedMetricAttributeKey specifies the key of an attribute for the gpu.process.memory.used metric.
+type GpuProcessMemoryUsedMetricAttributeKey string
+
+const (
+	GpuProcessMemoryUsedMetricAttributeKeyModel       GpuProcessMemoryUsedMetricAttributeKey = "model"
+	GpuProcessMemoryUsedMetricAttributeKeyGpuNumber   GpuProcessMemoryUsedMetricAttributeKey = "gpu_number"
+	GpuProcessMemoryUsedMetricAttributeKeyUUID        GpuProcessMemoryUsedMetricAttributeKey = "uuid"
+	GpuProcessMemoryUsedMetricAttributeKeyPid         GpuProcessMemoryUsedMetricAttributeKey = "pid"
+	GpuProcessMemoryUsedMetricAttributeKeyProcessName GpuProcessMemoryUsedMetricAttributeKey = "process.name"
+)
+
+// GpuProcessMemoryUsedMetricConfig provides config for the gpu.process.memory.used metric.
+type GpuProcessMemoryUsedMetricConfig struct {
+	Enabled          bool `mapstructure:"enabled"`
+	enabledSetByUser bool
+
+	AggregationStrategy string                                   `mapstructure:"aggregation_strategy"`
+	EnabledAttributes   []GpuProcessMemoryUsedMetricAttributeKey `mapstructure:"attributes"`
+}
+
+func (ms *GpuProcessMemoryUsedMetricConfig) Unmarshal(parser *confmap.Conf) error {
+	if parser == nil {
+		return nil
+	}
+
+	err := parser.Unmarshal(ms)
+	if err != nil {
+		return err
+	}
+
+	ms.enabledSetByUser = parser.IsSet("enabled")
+	return nil
+}
+
+func (ms *GpuProcessMemoryUsedMetricConfig) Validate() error {
+	for _, val := range ms.EnabledAttributes {
+		switch val {
+		case GpuProcessMemoryUsedMetricAttributeKeyModel, GpuProcessMemoryUsedMetricAttributeKeyGpuNumber, GpuProcessMemoryUsedMetricAttributeKeyUUID, GpuProcessMemoryUsedMetricAttributeKeyPid, GpuProcessMemoryUsedMetricAttributeKeyProcessName:
+		default:
+			return fmt.Errorf("metric gpu.process.memory.used doesn't have an attribute %v, valid attributes: [model, gpu_number, uuid, pid, process.name]", val)
+		}
+	}
+
+	switch ms.AggregationStrategy {
+	case AggregationStrategySum, AggregationStrategyAvg, AggregationStrategyMin, AggregationStrategyMax:
+	default:
+		return fmt.Errorf("invalid aggregation strategy %q, valid strategies: [%s, %s, %s, %s]", ms.AggregationStrategy, AggregationStrategySum, AggregationStrategyAvg, AggregationStrategyMin, AggregationStrategyMax)
+	}
+
+	return nil
+}
+
 // NvmlGpuMemoryBytesUsedMetricAttributeKey specifies the key of an attribute for the nvml.gpu.memory.bytes_used metric.
 type NvmlGpuMemoryBytesUsedMetricAttributeKey string
 
@@ -221,6 +273,7 @@ func (ms *NvmlGpuUtilizationMetricConfig) Validate() error {
 
 // MetricsConfig provides config for nvml metrics.
 type MetricsConfig struct {
+	GpuProcessMemoryUsed         GpuProcessMemoryUsedMetricConfig         `mapstructure:"gpu.process.memory.used"`
 	NvmlGpuMemoryBytesUsed       NvmlGpuMemoryBytesUsedMetricConfig       `mapstructure:"nvml.gpu.memory.bytes_used"`
 	NvmlGpuProcessesMaxBytesUsed NvmlGpuProcessesMaxBytesUsedMetricConfig `mapstructure:"nvml.gpu.processes.max_bytes_used"`
 	NvmlGpuProcessesUtilization  NvmlGpuProcessesUtilizationMetricConfig  `mapstructure:"nvml.gpu.processes.utilization"`
@@ -229,6 +282,11 @@ type MetricsConfig struct {
 
 func DefaultMetricsConfig() MetricsConfig {
 	return MetricsConfig{
+		GpuProcessMemoryUsed: GpuProcessMemoryUsedMetricConfig{
+			Enabled:             false,
+			AggregationStrategy: AggregationStrategyAvg,
+			EnabledAttributes:   []GpuProcessMemoryUsedMetricAttributeKey{GpuProcessMemoryUsedMetricAttributeKeyModel, GpuProcessMemoryUsedMetricAttributeKeyGpuNumber, GpuProcessMemoryUsedMetricAttributeKeyUUID, GpuProcessMemoryUsedMetricAttributeKeyPid, GpuProcessMemoryUsedMetricAttributeKeyProcessName},
+		},
 		NvmlGpuMemoryBytesUsed: NvmlGpuMemoryBytesUsedMetricConfig{
 			Enabled:             true,
 			AggregationStrategy: AggregationStrategyAvg,
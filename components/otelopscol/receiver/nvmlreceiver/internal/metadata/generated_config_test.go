@@ -26,6 +26,11 @@ func TestMetricsBuilderConfig(t *testing.T) {
 			name: "all_set",
 			want: MetricsBuilderConfig{
 				Metrics: MetricsConfig{
+					GpuProcessMemoryUsed: GpuProcessMemoryUsedMetricConfig{
+						Enabled:             true,
+						AggregationStrategy: AggregationStrategyAvg,
+						EnabledAttributes:   []GpuProcessMemoryUsedMetricAttributeKey{GpuProcessMemoryUsedMetricAttributeKeyModel, GpuProcessMemoryUsedMetricAttributeKeyGpuNumber, GpuProcessMemoryUsedMetricAttributeKeyUUID, GpuProcessMemoryUsedMetricAttributeKeyPid, GpuProcessMemoryUsedMetricAttributeKeyProcessName},
+					},
 					NvmlGpuMemoryBytesUsed: NvmlGpuMemoryBytesUsedMetricConfig{
 						Enabled:             true,
 						AggregationStrategy: AggregationStrategyAvg,
@@ -53,6 +58,11 @@ func TestMetricsBuilderConfig(t *testing.T) {
 			name: "none_set",
 			want: MetricsBuilderConfig{
 				Metrics: MetricsConfig{
+					GpuProcessMemoryUsed: GpuProcessMemoryUsedMetricConfig{
+						Enabled:             false,
+						AggregationStrategy: AggregationStrategyAvg,
+						EnabledAttributes:   []GpuProcessMemoryUsedMetricAttributeKey{GpuProcessMemoryUsedMetricAttributeKeyModel, GpuProcessMemoryUsedMetricAttributeKeyGpuNumber, GpuProcessMemoryUsedMetricAttributeKeyUUID, GpuProcessMemoryUsedMetricAttributeKeyPid, GpuProcessMemoryUsedMetricAttributeKeyProcessName},
+					},
 					NvmlGpuMemoryBytesUsed: NvmlGpuMemoryBytesUsedMetricConfig{
 						Enabled:             false,
 						AggregationStrategy: AggregationStrategyAvg,
@@ -80,11 +90,23 @@ func TestMetricsBuilderConfig(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := loadMetricsBuilderConfig(t, tt.name)
-			diff := cmp.Diff(tt.want, cfg, cmpopts.IgnoreUnexported(NvmlGpuMemoryBytesUsedMetricConfig{}, NvmlGpuProcessesMaxBytesUsedMetricConfig{}, NvmlGpuProcessesUtilizationMetricConfig{}, NvmlGpuUtilizationMetricConfig{}))
+			diff := cmp.Diff(tt.want, cfg, cmpopts.IgnoreUnexported(GpuProcessMemoryUsedMetricConfig{}, NvmlGpuMemoryBytesUsedMetricConfig{}, NvmlGpuProcessesMaxBytesUsedMetricConfig{}, NvmlGpuProcessesUtilizationMetricConfig{}, NvmlGpuUtilizationMetricConfig{}))
 			require.Emptyf(t, diff, "Config mismatch (-expected +actual):\n%s", diff)
 		})
 	}
 }
+func TestGpuProcessMemoryUsedMetricsConfig_Validate(t *testing.T) {
+	cfg := DefaultMetricsConfig().GpuProcessMemoryUsed
+	require.NoError(t, cfg.Validate())
+
+	cfg.EnabledAttributes = []GpuProcessMemoryUsedMetricAttributeKey{"invalid"}
+	require.ErrorContains(t, cfg.Validate(), "metric gpu.process.memory.used doesn't have an attribute invalid, valid attributes: [model, gpu_number, uuid, pid, process.name]")
+
+	cfg = DefaultMetricsConfig().GpuProcessMemoryUsed
+	cfg.AggregationStrategy = "invalid"
+	require.ErrorContains(t, cfg.Validate(), "invalid aggregation strategy")
+}
+
 func TestNvmlGpuMemoryBytesUsedMetricsConfig_Validate(t *testing.T) {
 	cfg := DefaultMetricsConfig().NvmlGpuMemoryBytesUsed
 	require.NoError(t, cfg.Validate())
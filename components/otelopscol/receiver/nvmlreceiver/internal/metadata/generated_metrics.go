@@ -45,6 +45,10 @@ var MapAttributeMemoryState = map[string]AttributeMemoryState{
 }
 
 var MetricsInfo = metricsInfo{
+	GpuProcessMemoryUsed: metricInfo{
+		Name:       "gpu.process.memory.used",
+		Attributes: []string{"model", "gpu_number", "uuid", "pid", "process_name"},
+	},
 	NvmlGpuMemoryBytesUsed: metricInfo{
 		Name:       "nvml.gpu.memory.bytes_used",
 		Attributes: []string{"model", "gpu_number", "uuid", "memory_state"},
@@ -64,6 +68,7 @@ var MetricsInfo = metricsInfo{
 }
 
 type metricsInfo struct {
+	GpuProcessMemoryUsed         metricInfo
 	NvmlGpuMemoryBytesUsed       metricInfo
 	NvmlGpuProcessesMaxBytesUsed metricInfo
 	NvmlGpuProcessesUtilization  metricInfo
@@ -75,6 +80,107 @@ type metricInfo struct {
 	Attributes []string
 }
 
+type metricGpuProcessMemoryUsed struct {
+	data          pmetric.Metric                   // data buffer for generated metric.
+	config        GpuProcessMemoryUsedMetricConfig // metric config provided by user.
+	capacity      int                              // max observed number of data points added to the metric.
+	aggDataPoints []int64                          // slice containing number of aggregated datapoints at each index
+}
+
+// init fills gpu.process.memory.used metric with initial data.
+func (m *metricGpuProcessMemoryUsed) init() {
+	m.data.SetName("gpu.process.memory.used")
+	m.data.SetDescription("Current GPU memory in bytes used by the process.")
+	m.data.SetUnit("By")
+	m.data.SetEmptyGauge()
+	m.data.Gauge().DataPoints().EnsureCapacity(m.capacity)
+	m.aggDataPoints = m.aggDataPoints[:0]
+}
+
+func (m *metricGpuProcessMemoryUsed) recordDataPoint(start pcommon.Timestamp, ts pcommon.Timestamp, val int64, modelAttributeValue string, gpuNumberAttributeValue string, uuidAttributeValue string, pidAttributeValue int64, processNameAttributeValue string) {
+	if !m.config.Enabled {
+		return
+	}
+
+	dp := pmetric.NewNumberDataPoint()
+	dp.SetStartTimestamp(start)
+	dp.SetTimestamp(ts)
+	if slices.Contains(m.config.EnabledAttributes, GpuProcessMemoryUsedMetricAttributeKeyModel) {
+		dp.Attributes().PutStr("model", modelAttributeValue)
+	}
+	if slices.Contains(m.config.EnabledAttributes, GpuProcessMemoryUsedMetricAttributeKeyGpuNumber) {
+		dp.Attributes().PutStr("gpu_number", gpuNumberAttributeValue)
+	}
+	if slices.Contains(m.config.EnabledAttributes, GpuProcessMemoryUsedMetricAttributeKeyUUID) {
+		dp.Attributes().PutStr("uuid", uuidAttributeValue)
+	}
+	if slices.Contains(m.config.EnabledAttributes, GpuProcessMemoryUsedMetricAttributeKeyPid) {
+		dp.Attributes().PutInt("pid", pidAttributeValue)
+	}
+	if slices.Contains(m.config.EnabledAttributes, GpuProcessMemoryUsedMetricAttributeKeyProcessName) {
+		dp.Attributes().PutStr("process.name", processNameAttributeValue)
+	}
+
+	var s string
+	dps := m.data.Gauge().DataPoints()
+	for i := 0; i < dps.Len(); i++ {
+		dpi := dps.At(i)
+		if dp.Attributes().Equal(dpi.Attributes()) && dp.StartTimestamp() == dpi.StartTimestamp() && dp.Timestamp() == dpi.Timestamp() {
+			switch s = m.config.AggregationStrategy; s {
+			case AggregationStrategySum, AggregationStrategyAvg:
+				dpi.SetIntValue(dpi.IntValue() + val)
+				m.aggDataPoints[i] += 1
+				return
+			case AggregationStrategyMin:
+				if dpi.IntValue() > val {
+					dpi.SetIntValue(val)
+				}
+				return
+			case AggregationStrategyMax:
+				if dpi.IntValue() < val {
+					dpi.SetIntValue(val)
+				}
+				return
+			}
+		}
+	}
+
+	dp.SetIntValue(val)
+	m.aggDataPoints = append(m.aggDataPoints, 1)
+	dp.MoveTo(dps.AppendEmpty())
+}
+
+// updateCapacity saves max length of data point slices that will be used for the slice capacity.
+func (m *metricGpuProcessMemoryUsed) updateCapacity() {
+	if m.data.Gauge().DataPoints().Len() > m.capacity {
+		m.capacity = m.data.Gauge().DataPoints().Len()
+	}
+}
+
+// emit appends recorded metric data to a metrics slice and prepares it for recording another set of data points.
+func (m *metricGpuProcessMemoryUsed) emit(metrics pmetric.MetricSlice) {
+	if m.config.Enabled && m.data.Gauge().DataPoints().Len() > 0 {
+		if m.config.AggregationStrategy == AggregationStrategyAvg {
+			for i, aggCount := range m.aggDataPoints {
+				m.data.Gauge().DataPoints().At(i).SetIntValue(m.data.Gauge().DataPoints().At(i).IntValue() / aggCount)
+			}
+		}
+		m.updateCapacity()
+		m.data.MoveTo(metrics.AppendEmpty())
+		m.init()
+	}
+}
+
+func newMetricGpuProcessMemoryUsed(cfg GpuProcessMemoryUsedMetricConfig) metricGpuProcessMemoryUsed {
+	m := metricGpuProcessMemoryUsed{config: cfg}
+
+	if cfg.Enabled {
+		m.data = pmetric.NewMetric()
+		m.init()
+	}
+	return m
+}
+
 type metricNvmlGpuMemoryBytesUsed struct {
 	data          pmetric.Metric                     // data buffer for generated metric.
 	config        NvmlGpuMemoryBytesUsedMetricConfig // metric config provided by user.
@@ -496,6 +602,7 @@ type MetricsBuilder struct {
 	metricsCapacity                    int                  // maximum observed number of metrics per resource.
 	metricsBuffer                      pmetric.Metrics      // accumulates metrics data before emitting.
 	buildInfo                          component.BuildInfo  // contains version information.
+	metricGpuProcessMemoryUsed         metricGpuProcessMemoryUsed
 	metricNvmlGpuMemoryBytesUsed       metricNvmlGpuMemoryBytesUsed
 	metricNvmlGpuProcessesMaxBytesUsed metricNvmlGpuProcessesMaxBytesUsed
 	metricNvmlGpuProcessesUtilization  metricNvmlGpuProcessesUtilization
@@ -525,6 +632,7 @@ func NewMetricsBuilder(mbc MetricsBuilderConfig, settings receiver.Settings, opt
 		startTime:                          pcommon.NewTimestampFromTime(time.Now()),
 		metricsBuffer:                      pmetric.NewMetrics(),
 		buildInfo:                          settings.BuildInfo,
+		metricGpuProcessMemoryUsed:         newMetricGpuProcessMemoryUsed(mbc.Metrics.GpuProcessMemoryUsed),
 		metricNvmlGpuMemoryBytesUsed:       newMetricNvmlGpuMemoryBytesUsed(mbc.Metrics.NvmlGpuMemoryBytesUsed),
 		metricNvmlGpuProcessesMaxBytesUsed: newMetricNvmlGpuProcessesMaxBytesUsed(mbc.Metrics.NvmlGpuProcessesMaxBytesUsed),
 		metricNvmlGpuProcessesUtilization:  newMetricNvmlGpuProcessesUtilization(mbc.Metrics.NvmlGpuProcessesUtilization),
@@ -594,6 +702,7 @@ func (mb *MetricsBuilder) EmitForResource(options ...ResourceMetricsOption) {
 	ils.Scope().SetName(ScopeName)
 	ils.Scope().SetVersion(mb.buildInfo.Version)
 	ils.Metrics().EnsureCapacity(mb.metricsCapacity)
+	mb.metricGpuProcessMemoryUsed.emit(ils.Metrics())
 	mb.metricNvmlGpuMemoryBytesUsed.emit(ils.Metrics())
 	mb.metricNvmlGpuProcessesMaxBytesUsed.emit(ils.Metrics())
 	mb.metricNvmlGpuProcessesUtilization.emit(ils.Metrics())
@@ -619,6 +728,11 @@ func (mb *MetricsBuilder) Emit(options ...ResourceMetricsOption) pmetric.Metrics
 	return metrics
 }
 
+// RecordGpuProcessMemoryUsedDataPoint adds a data point to gpu.process.memory.used metric.
+func (mb *MetricsBuilder) RecordGpuProcessMemoryUsedDataPoint(ts pcommon.Timestamp, val int64, modelAttributeValue string, gpuNumberAttributeValue string, uuidAttributeValue string, pidAttributeValue int64, processNameAttributeValue string) {
+	mb.metricGpuProcessMemoryUsed.recordDataPoint(mb.startTime, ts, val, modelAttributeValue, gpuNumberAttributeValue, uuidAttributeValue, pidAttributeValue, processNameAttributeValue)
+}
+
 // RecordNvmlGpuMemoryBytesUsedDataPoint adds a data point to nvml.gpu.memory.bytes_used metric.
 func (mb *MetricsBuilder) RecordNvmlGpuMemoryBytesUsedDataPoint(ts pcommon.Timestamp, val int64, modelAttributeValue string, gpuNumberAttributeValue string, uuidAttributeValue string, memoryStateAttributeValue AttributeMemoryState) {
 	mb.metricNvmlGpuMemoryBytesUsed.recordDataPoint(mb.startTime, ts, val, modelAttributeValue, gpuNumberAttributeValue, uuidAttributeValue, memoryStateAttributeValue.String())
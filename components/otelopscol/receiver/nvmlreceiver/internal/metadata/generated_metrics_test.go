@@ -58,6 +58,7 @@ func TestMetricsBuilder(t *testing.T) {
 			settings.Logger = zap.New(observedZapCore)
 			mb := NewMetricsBuilder(loadMetricsBuilderConfig(t, tt.name), settings, WithStartTime(start))
 			aggMap := make(map[string]string) // contains the aggregation strategies for each metric name
+			aggMap["gpu.process.memory.used"] = mb.metricGpuProcessMemoryUsed.config.AggregationStrategy
 			aggMap["nvml.gpu.memory.bytes_used"] = mb.metricNvmlGpuMemoryBytesUsed.config.AggregationStrategy
 			aggMap["nvml.gpu.processes.max_bytes_used"] = mb.metricNvmlGpuProcessesMaxBytesUsed.config.AggregationStrategy
 			aggMap["nvml.gpu.processes.utilization"] = mb.metricNvmlGpuProcessesUtilization.config.AggregationStrategy
@@ -70,6 +71,11 @@ func TestMetricsBuilder(t *testing.T) {
 
 			defaultMetricsCount := 0
 			allMetricsCount := 0
+			allMetricsCount++
+			mb.RecordGpuProcessMemoryUsedDataPoint(ts, 1, "model-val", "gpu_number-val", "uuid-val", 3, "process_name-val")
+			if tt.name == "reaggregate_set" {
+				mb.RecordGpuProcessMemoryUsedDataPoint(ts, 3, "model-val-2", "gpu_number-val-2", "uuid-val-2", 4, "process_name-val-2")
+			}
 			defaultMetricsCount++
 			allMetricsCount++
 			mb.RecordNvmlGpuMemoryBytesUsedDataPoint(ts, 1, "model-val", "gpu_number-val", "uuid-val", AttributeMemoryStateUsed)
@@ -98,6 +104,7 @@ func TestMetricsBuilder(t *testing.T) {
 			res := pcommon.NewResource()
 			metrics := mb.Emit(WithResource(res))
 			if tt.name == "reaggregate_set" {
+				assert.Empty(t, mb.metricGpuProcessMemoryUsed.aggDataPoints)
 				assert.Empty(t, mb.metricNvmlGpuMemoryBytesUsed.aggDataPoints)
 				assert.Empty(t, mb.metricNvmlGpuProcessesMaxBytesUsed.aggDataPoints)
 				assert.Empty(t, mb.metricNvmlGpuProcessesUtilization.aggDataPoints)
@@ -129,6 +136,66 @@ func TestMetricsBuilder(t *testing.T) {
 			validatedMetrics := make(map[string]bool)
 			for _, mi := range allMetricsList {
 				switch mi.Name() {
+				case "gpu.process.memory.used":
+					if tt.name != "reaggregate_set" {
+						assert.False(t, validatedMetrics["gpu.process.memory.used"], "Found a duplicate in the metrics slice: gpu.process.memory.used")
+						validatedMetrics["gpu.process.memory.used"] = true
+						assert.Equal(t, pmetric.MetricTypeGauge, mi.Type())
+						assert.Equal(t, 1, mi.Gauge().DataPoints().Len())
+						assert.Equal(t, "Current GPU memory in bytes used by the process.", mi.Description())
+						assert.Equal(t, "By", mi.Unit())
+						dp := mi.Gauge().DataPoints().At(0)
+						assert.Equal(t, start, dp.StartTimestamp())
+						assert.Equal(t, ts, dp.Timestamp())
+						assert.Equal(t, pmetric.NumberDataPointValueTypeInt, dp.ValueType())
+						assert.Equal(t, int64(1), dp.IntValue())
+						modelAttrVal, ok := dp.Attributes().Get("model")
+						assert.True(t, ok)
+						assert.Equal(t, "model-val", modelAttrVal.Str())
+						gpuNumberAttrVal, ok := dp.Attributes().Get("gpu_number")
+						assert.True(t, ok)
+						assert.Equal(t, "gpu_number-val", gpuNumberAttrVal.Str())
+						uuidAttrVal, ok := dp.Attributes().Get("uuid")
+						assert.True(t, ok)
+						assert.Equal(t, "uuid-val", uuidAttrVal.Str())
+						pidAttrVal, ok := dp.Attributes().Get("pid")
+						assert.True(t, ok)
+						assert.EqualValues(t, 3, pidAttrVal.Int())
+						processNameAttrVal, ok := dp.Attributes().Get("process.name")
+						assert.True(t, ok)
+						assert.Equal(t, "process_name-val", processNameAttrVal.Str())
+					} else {
+						assert.False(t, validatedMetrics["gpu.process.memory.used"], "Found a duplicate in the metrics slice: gpu.process.memory.used")
+						validatedMetrics["gpu.process.memory.used"] = true
+						assert.Equal(t, pmetric.MetricTypeGauge, mi.Type())
+						assert.Equal(t, 1, mi.Gauge().DataPoints().Len())
+						assert.Equal(t, "Current GPU memory in bytes used by the process.", mi.Description())
+						assert.Equal(t, "By", mi.Unit())
+						dp := mi.Gauge().DataPoints().At(0)
+						assert.Equal(t, start, dp.StartTimestamp())
+						assert.Equal(t, ts, dp.Timestamp())
+						assert.Equal(t, pmetric.NumberDataPointValueTypeInt, dp.ValueType())
+						switch aggMap["gpu.process.memory.used"] {
+						case "sum":
+							assert.Equal(t, int64(4), dp.IntValue())
+						case "avg":
+							assert.Equal(t, int64(2), dp.IntValue())
+						case "min":
+							assert.Equal(t, int64(1), dp.IntValue())
+						case "max":
+							assert.Equal(t, int64(3), dp.IntValue())
+						}
+						_, ok := dp.Attributes().Get("model")
+						assert.False(t, ok)
+						_, ok = dp.Attributes().Get("gpu_number")
+						assert.False(t, ok)
+						_, ok = dp.Attributes().Get("uuid")
+						assert.False(t, ok)
+						_, ok = dp.Attributes().Get("pid")
+						assert.False(t, ok)
+						_, ok = dp.Attributes().Get("process.name")
+						assert.False(t, ok)
+					}
 				case "nvml.gpu.memory.bytes_used":
 					if tt.name != "reaggregate_set" {
 						assert.False(t, validatedMetrics["nvml.gpu.memory.bytes_used"], "Found a duplicate in the metrics slice: nvml.gpu.memory.bytes_used")
@@ -58,9 +58,13 @@ func (s *nvmlScraper) start(_ context.Context, _ component.Host) error {
 }
 
 func (s *nvmlScraper) stop(_ context.Context) error {
-	if s.client != nil {
-		return s.client.cleanup()
+	if s.client == nil {
+		return nil
 	}
+	if err := s.client.cleanup(); err != nil {
+		return err
+	}
+	s.client = nil
 	return nil
 }
 
@@ -101,5 +105,18 @@ func (s *nvmlScraper) scrape(_ context.Context) (pmetric.Metrics, error) {
 			metric.processName, metric.command, metric.commandLine, metric.owner)
 	}
 
+	if s.config.Metrics.GpuProcessMemoryUsed.Enabled {
+		processMemoryMetrics := s.client.collectProcessMemoryMetrics()
+		for _, metric := range processMemoryMetrics {
+			timestamp := pcommon.NewTimestampFromTime(metric.time)
+			model := s.client.getDeviceModelName(metric.gpuIndex)
+			UUID := s.client.getDeviceUUID(metric.gpuIndex)
+			gpuIndex := fmt.Sprintf("%d", metric.gpuIndex)
+
+			s.mb.RecordGpuProcessMemoryUsedDataPoint(
+				timestamp, int64(metric.usedBytes), model, gpuIndex, UUID, int64(metric.processPid), metric.processName)
+		}
+	}
+
 	return s.mb.Emit(), err
 }
@@ -19,6 +19,7 @@ package nvmlreceiver
 
 import (
 	"context"
+	"os"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/nvmlreceiver/internal/metadata"
@@ -26,6 +27,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/receiver/receivertest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestScrapeOnLibraryNotFound(t *testing.T) {
@@ -43,3 +46,163 @@ func TestScrapeOnLibraryNotFound(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 0, metrics.MetricCount())
 }
+
+func TestStopReleasesClientOnce(t *testing.T) {
+	scraper := newNvmlScraper(createDefaultConfig().(*Config), receivertest.NewNopSettings(metadata.Type))
+	require.NotNil(t, scraper)
+
+	shutdownCount := 0
+	scraper.client = &nvmlClient{
+		logger: zap.NewNop().Sugar(),
+		handleCleanup: func() error {
+			shutdownCount++
+			return nil
+		},
+	}
+
+	require.NoError(t, scraper.stop(context.Background()))
+	require.NoError(t, scraper.stop(context.Background()))
+	require.Equal(t, 1, shutdownCount)
+	require.Nil(t, scraper.client)
+}
+
+type fakeDevice struct {
+	nvml.Device
+	index int
+}
+
+// fakeNvml replaces the NVML queries made by a scrape with fakes that report
+// no device metrics and the given running processes, and returns the number of
+// times the running graphics processes were listed for each device.
+func fakeNvml(t *testing.T, compute, graphics func(device fakeDevice) ([]nvml.ProcessInfo, nvml.Return)) map[fakeDevice]int {
+	realGetSamples := nvmlDeviceGetSamples
+	realGetMemoryInfo := nvmlDeviceGetMemoryInfo
+	realGetComputeRunningProcesses := nvmlDeviceGetComputeRunningProcesses
+	realGetGraphicsRunningProcesses := nvmlDeviceGetGraphicsRunningProcesses
+	t.Cleanup(func() {
+		nvmlDeviceGetSamples = realGetSamples
+		nvmlDeviceGetMemoryInfo = realGetMemoryInfo
+		nvmlDeviceGetComputeRunningProcesses = realGetComputeRunningProcesses
+		nvmlDeviceGetGraphicsRunningProcesses = realGetGraphicsRunningProcesses
+	})
+
+	nvmlDeviceGetSamples = func(nvml.Device, nvml.SamplingType, uint64) (nvml.ValueType, []nvml.Sample, nvml.Return) {
+		return 0, nil, nvml.ERROR_NOT_SUPPORTED
+	}
+	nvmlDeviceGetMemoryInfo = func(nvml.Device) (nvml.Memory, nvml.Return) {
+		return nvml.Memory{}, nvml.ERROR_NOT_SUPPORTED
+	}
+	nvmlDeviceGetComputeRunningProcesses = func(device nvml.Device) ([]nvml.ProcessInfo, nvml.Return) {
+		return compute(device.(fakeDevice))
+	}
+	graphicsCalls := make(map[fakeDevice]int)
+	nvmlDeviceGetGraphicsRunningProcesses = func(device nvml.Device) ([]nvml.ProcessInfo, nvml.Return) {
+		graphicsCalls[device.(fakeDevice)]++
+		return graphics(device.(fakeDevice))
+	}
+	return graphicsCalls
+}
+
+func newScraperWithFakeDevices(t *testing.T, config *Config, logger *zap.Logger, devices ...fakeDevice) *nvmlScraper {
+	settings := receivertest.NewNopSettings(metadata.Type)
+	scraper := newNvmlScraper(config, settings)
+	scraper.client = &nvmlClient{
+		logger:                          logger.Sugar(),
+		handleCleanup:                   func() error { return nil },
+		deviceToLastSeenTimestamp:       make(map[nvml.Device]uint64),
+		deviceMetricToFailedQueryCount:  make(map[string]uint64),
+		deviceToAccountingIsEnabled:     make(map[nvml.Device]bool),
+		deviceToUnsupportedProcessKinds: make(map[nvml.Device]map[string]bool),
+	}
+	for _, device := range devices {
+		scraper.client.devices = append(scraper.client.devices, device)
+		scraper.client.devicesModelName = append(scraper.client.devicesModelName, "Tesla T4")
+		scraper.client.devicesUUID = append(scraper.client.devicesUUID, "GPU-00000000-0000-0000-0000-000000000000")
+	}
+
+	mbConfig := metadata.DefaultMetricsBuilderConfig()
+	mbConfig.Metrics = config.Metrics
+	scraper.mb = metadata.NewMetricsBuilder(mbConfig, settings)
+	return scraper
+}
+
+func TestScrapeProcessMemory(t *testing.T) {
+	device0 := fakeDevice{index: 0}
+	device1 := fakeDevice{index: 1}
+	pid := uint32(os.Getpid())
+	graphicsCalls := fakeNvml(t,
+		func(device fakeDevice) ([]nvml.ProcessInfo, nvml.Return) {
+			if device == device0 {
+				return []nvml.ProcessInfo{
+					{Pid: pid, UsedGpuMemory: 1024},
+					{Pid: 1, UsedGpuMemory: nvmlValueNotAvailable},
+				}, nvml.SUCCESS
+			}
+			return []nvml.ProcessInfo{{Pid: pid, UsedGpuMemory: 2048}}, nvml.SUCCESS
+		},
+		func(device fakeDevice) ([]nvml.ProcessInfo, nvml.Return) {
+			if device == device0 {
+				// The same process also holds a graphics context.
+				return []nvml.ProcessInfo{{Pid: pid, UsedGpuMemory: 1024}}, nvml.SUCCESS
+			}
+			return nil, nvml.ERROR_NOT_SUPPORTED
+		})
+
+	config := createDefaultConfig().(*Config)
+	config.Metrics.GpuProcessMemoryUsed.Enabled = true
+	core, logs := observer.New(zap.InfoLevel)
+	scraper := newScraperWithFakeDevices(t, config, zap.New(core), device0, device1)
+
+	for scrape := 0; scrape < 2; scrape++ {
+		metrics, err := scraper.scrape(context.Background())
+		require.NoError(t, err)
+
+		used := make(map[string]int64)
+		rms := metrics.ResourceMetrics()
+		for i := 0; i < rms.Len(); i++ {
+			ms := rms.At(i).ScopeMetrics().At(0).Metrics()
+			for j := 0; j < ms.Len(); j++ {
+				if ms.At(j).Name() != "gpu.process.memory.used" {
+					continue
+				}
+				dps := ms.At(j).Gauge().DataPoints()
+				for k := 0; k < dps.Len(); k++ {
+					dp := dps.At(k)
+					gpuNumber, _ := dp.Attributes().Get("gpu_number")
+					processPid, _ := dp.Attributes().Get("pid")
+					require.EqualValues(t, pid, processPid.Int())
+					processName, ok := dp.Attributes().Get("process.name")
+					require.True(t, ok)
+					require.NotEmpty(t, processName.Str())
+					used[gpuNumber.Str()] = dp.IntValue()
+				}
+			}
+		}
+		require.Equal(t, map[string]int64{"0": 1024, "1": 2048}, used)
+	}
+
+	// The unsupported graphics query is only attempted, and logged, once.
+	require.Equal(t, 2, graphicsCalls[device0])
+	require.Equal(t, 1, graphicsCalls[device1])
+	require.Equal(t, 1, logs.FilterMessageSnippet("does not support listing running graphics processes").Len())
+}
+
+func TestScrapeProcessMemoryDisabledByDefault(t *testing.T) {
+	device := fakeDevice{}
+	listed := false
+	fakeNvml(t,
+		func(fakeDevice) ([]nvml.ProcessInfo, nvml.Return) {
+			listed = true
+			return []nvml.ProcessInfo{{Pid: uint32(os.Getpid()), UsedGpuMemory: 1024}}, nvml.SUCCESS
+		},
+		func(fakeDevice) ([]nvml.ProcessInfo, nvml.Return) {
+			listed = true
+			return nil, nvml.SUCCESS
+		})
+
+	scraper := newScraperWithFakeDevices(t, createDefaultConfig().(*Config), zap.NewNop(), device)
+	metrics, err := scraper.scrape(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, metrics.MetricCount())
+	require.False(t, listed)
+}
@@ -35,6 +35,10 @@ type varnishScraper struct {
 	telemetrySettings component.TelemetrySettings
 	mb                *metadata.MetricsBuilder
 	cacheName         string
+	// clock returns the current time. It can be replaced in tests.
+	clock func() time.Time
+	// lastTimestamp is the timestamp of the previous scrape.
+	lastTimestamp pcommon.Timestamp
 }
 
 func newVarnishScraper(settings receiver.Settings, config *Config) *varnishScraper {
@@ -42,6 +46,7 @@ func newVarnishScraper(settings receiver.Settings, config *Config) *varnishScrap
 		telemetrySettings: settings.TelemetrySettings,
 		config:            config,
 		mb:                metadata.NewMetricsBuilder(metadata.DefaultMetricsBuilderConfig(), settings),
+		clock:             time.Now,
 	}
 }
 
@@ -76,7 +81,7 @@ func (v *varnishScraper) scrape(context.Context) (pmetric.Metrics, error) {
 		return pmetric.NewMetrics(), err
 	}
 
-	now := pcommon.NewTimestampFromTime(time.Now())
+	now := v.timestamp()
 
 	rb := v.mb.NewResourceBuilder()
 	rb.SetVarnishCacheName(v.cacheName)
@@ -96,3 +101,15 @@ func (v *varnishScraper) scrape(context.Context) (pmetric.Metrics, error) {
 
 	return v.mb.Emit(metadata.WithResource(rb.Emit())), nil
 }
+
+// timestamp returns the timestamp for the data points of the current scrape.
+// It never goes backwards across scrapes, even if the wall clock is stepped
+// back, because backends reject cumulative points older than the last one.
+func (v *varnishScraper) timestamp() pcommon.Timestamp {
+	now := pcommon.NewTimestampFromTime(v.clock())
+	if now < v.lastTimestamp {
+		now = v.lastTimestamp
+	}
+	v.lastTimestamp = now
+	return now
+}
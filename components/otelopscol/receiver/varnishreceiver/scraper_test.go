@@ -21,8 +21,10 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/components/otelopscol/receiver/varnishreceiver/internal/metadata"
+	"github.com/GoogleCloudPlatform/opentelemetry-operations-collector/internal/metricstest"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/component/componenttest"
@@ -225,6 +227,31 @@ func validateScraperResult(t *testing.T, actualMetrics pmetric.Metrics) {
 	}
 }
 
+func TestScrapeMonotonicTimestamps(t *testing.T) {
+	f := NewFactory()
+	cfg := f.CreateDefaultConfig().(*Config)
+	mockClient := new(mockClient)
+	mockClient.On("GetStats").Return(getStats(t, "mock_response6_5.json"))
+
+	scraper := newVarnishScraper(receivertest.NewNopSettings(metadata.Type), cfg)
+	scraper.client = mockClient
+	// The wall clock is stepped back by a minute between the two scrapes.
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clockTimes := []time.Time{start, start.Add(-time.Minute)}
+	scraper.clock = func() time.Time {
+		now := clockTimes[0]
+		clockTimes = clockTimes[1:]
+		return now
+	}
+
+	first, err := scraper.scrape(context.Background())
+	require.NoError(t, err)
+	second, err := scraper.scrape(context.Background())
+	require.NoError(t, err)
+
+	metricstest.AssertMonotonicTimestamps(t, first, second)
+}
+
 func TestStart(t *testing.T) {
 	t.Run("start with default hostname", func(t *testing.T) {
 		hostname, err := os.Hostname()
@@ -0,0 +1,95 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+// doubleSeries returns a time series with a single point of the given value.
+func doubleSeries(value float64) *monitoringpb.TimeSeries {
+	return &monitoringpb.TimeSeries{
+		Points: []*monitoringpb.Point{{
+			Value: &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_DoubleValue{DoubleValue: value}},
+		}},
+	}
+}
+
+func TestAssertMetricAgreesAcrossAgentVersions(t *testing.T) {
+	tests := []struct {
+		name               string
+		oldValue, newValue float64
+		wantErr            bool
+	}{
+		{
+			name:     "close values",
+			oldValue: 1000,
+			newValue: 1030,
+		},
+		{
+			name:     "divergent values",
+			oldValue: 1000,
+			newValue: 1300,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			oldVM := &VM{Name: "old", Project: "p", ID: 1}
+			newVM := &VM{Name: "new", Project: "p", ID: 2}
+			fakeListTimeSeries(t, func(req *monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+				if strings.Contains(req.Filter, fmt.Sprintf(`instance_id = "%d"`, oldVM.ID)) {
+					return []*monitoringpb.TimeSeries{doubleSeries(tc.oldValue)}
+				}
+				return []*monitoringpb.TimeSeries{doubleSeries(tc.newValue)}
+			})
+			var loaded, stopped []string
+			origGenerate := generateSyntheticLoad
+			t.Cleanup(func() { generateSyntheticLoad = origGenerate })
+			generateSyntheticLoad = func(_ context.Context, _ *log.Logger, vm *VM, _, _ int, _ time.Duration) (func() error, error) {
+				loaded = append(loaded, vm.Name)
+				return func() error {
+					stopped = append(stopped, vm.Name)
+					return nil
+				}, nil
+			}
+
+			err := AssertMetricAgreesAcrossAgentVersions(context.Background(), log.New(io.Discard, "", 0), oldVM, newVM, AgentVersionComparisonOptions{
+				Metric:        "workload.googleapis.com/" + SyntheticLoadMetric,
+				MetricsPerSec: 10,
+				LoadDuration:  time.Millisecond,
+				Tolerance:     0.05,
+			})
+			if tc.wantErr && err == nil {
+				t.Error("AssertMetricAgreesAcrossAgentVersions() unexpectedly succeeded")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("AssertMetricAgreesAcrossAgentVersions() failed: %v", err)
+			}
+			if len(loaded) != 2 || len(stopped) != 2 {
+				t.Errorf("load was started on %v and stopped on %v; want both VMs", loaded, stopped)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAgentConfigCommands(t *testing.T) {
+	for _, tc := range []struct {
+		imageSpec   string
+		wantPath    string
+		wantRestart string
+		wantState   string
+	}{
+		{
+			imageSpec:   "debian-cloud:debian-12",
+			wantPath:    "/etc/google-cloud-ops-agent/config.yaml",
+			wantRestart: "sudo systemctl restart google-cloud-ops-agent",
+			wantState:   "active",
+		},
+		{
+			imageSpec:   "windows-cloud:windows-2022",
+			wantPath:    `C:\Program Files\Google\Cloud Operations\Ops Agent\config\config.yaml`,
+			wantRestart: "Restart-Service -Name google-cloud-ops-agent -Force",
+			wantState:   "Running",
+		},
+	} {
+		t.Run(tc.imageSpec, func(t *testing.T) {
+			if got := agentConfigPath(tc.imageSpec); got != tc.wantPath {
+				t.Errorf("agentConfigPath() = %q, want %q", got, tc.wantPath)
+			}
+			if got := restartAgentCommand(tc.imageSpec); got != tc.wantRestart {
+				t.Errorf("restartAgentCommand() = %q, want %q", got, tc.wantRestart)
+			}
+			if got := runningServiceState(tc.imageSpec); got != tc.wantState {
+				t.Errorf("runningServiceState() = %q, want %q", got, tc.wantState)
+			}
+		})
+	}
+}
+
+func TestWaitForAgentServices(t *testing.T) {
+	origGetStatus, origBackoff := getServiceStatus, serviceStateBackoffDuration
+	t.Cleanup(func() {
+		getServiceStatus, serviceStateBackoffDuration = origGetStatus, origBackoff
+	})
+	serviceStateBackoffDuration = time.Millisecond
+
+	var polled []string
+	states := map[string][]string{
+		"google-cloud-ops-agent":                         {"Running"},
+		"google-cloud-ops-agent-fluent-bit":              {"StartPending", "Running"},
+		"google-cloud-ops-agent-opentelemetry-collector": {"Running"},
+	}
+	getServiceStatus = func(_ context.Context, _ *log.Logger, _ *VM, serviceName string) (serviceStatus, error) {
+		polled = append(polled, serviceName)
+		state := states[serviceName][0]
+		if len(states[serviceName]) > 1 {
+			states[serviceName] = states[serviceName][1:]
+		}
+		return serviceStatus{State: state}, nil
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	vm := &VM{Name: "vm", ImageSpec: "windows-cloud:windows-2022"}
+	if err := waitForAgentServices(context.Background(), logger, vm, time.Minute); err != nil {
+		t.Fatalf("waitForAgentServices() failed: %v", err)
+	}
+	want := []string{
+		"google-cloud-ops-agent",
+		"google-cloud-ops-agent-fluent-bit",
+		"google-cloud-ops-agent-fluent-bit",
+		"google-cloud-ops-agent-opentelemetry-collector",
+	}
+	if !slices.Equal(polled, want) {
+		t.Errorf("waitForAgentServices() polled %v, want %v", polled, want)
+	}
+
+	states["google-cloud-ops-agent-opentelemetry-collector"] = []string{"Stopped"}
+	if err := waitForAgentServices(context.Background(), logger, vm, 20*time.Millisecond); err == nil {
+		t.Error("waitForAgentServices() with a stopped service succeeded, want an error")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestGrpcurlArchive(t *testing.T) {
+	for _, tc := range []struct {
+		imageSpec string
+		want      string
+	}{
+		{imageSpec: "debian-cloud:debian-12", want: "grpcurl_1.8.6_linux_x86_64.tar.gz"},
+		{imageSpec: "debian-cloud:debian-12-arm64", want: "grpcurl_1.8.6_linux_arm64.tar.gz"},
+		{imageSpec: "windows-cloud:windows-2022", want: "grpcurl_1.8.6_windows_x86_64.zip"},
+		{imageSpec: "windows-cloud:windows-2025-arm64", want: "grpcurl_1.8.6_windows_arm64.zip"},
+	} {
+		if got := grpcurlArchive(tc.imageSpec); got != tc.want {
+			t.Errorf("grpcurlArchive(%q) = %q, want %q", tc.imageSpec, got, tc.want)
+		}
+	}
+}
+
+func TestGcloudLinuxTarball(t *testing.T) {
+	for _, tc := range []struct {
+		imageSpec string
+		want      string
+	}{
+		{imageSpec: "suse-cloud:sles-15", want: "google-cloud-cli-561.0.0-linux-x86_64.tar.gz"},
+		{imageSpec: "suse-cloud:sles-15-arm64", want: "google-cloud-cli-561.0.0-linux-arm.tar.gz"},
+	} {
+		if got := gcloudLinuxTarball(tc.imageSpec); got != tc.want {
+			t.Errorf("gcloudLinuxTarball(%q) = %q, want %q", tc.imageSpec, got, tc.want)
+		}
+	}
+}
+
+func TestInstallGcloudIfNeededSkipsWindows(t *testing.T) {
+	// This would fail if it tried to run anything on the VM, since the VM
+	// does not exist.
+	for _, imageSpec := range []string{"windows-cloud:windows-2022", "windows-cloud:windows-2025-arm64"} {
+		vm := &VM{Name: "vm", Project: "p", ImageSpec: imageSpec}
+		if err := InstallGcloudIfNeeded(context.Background(), log.New(io.Discard, "", 0), vm); err != nil {
+			t.Errorf("InstallGcloudIfNeeded() on %v failed: %v", imageSpec, err)
+		}
+	}
+}
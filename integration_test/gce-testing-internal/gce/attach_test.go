@@ -0,0 +1,187 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+)
+
+// runningInstanceJSON is trimmed gcloud --format=json output for a running
+// VM.
+const runningInstanceJSON = `[
+  {
+    "id": "1234",
+    "status": "RUNNING",
+    "creationTimestamp": "2024-01-10T08:00:00.000-08:00",
+    "machineType": "https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-b/machineTypes/e2-standard-4",
+    "networkInterfaces": [
+      {
+        "network": "https://www.googleapis.com/compute/v1/projects/p/global/networks/test-net",
+        "networkIP": "10.128.0.2",
+        "accessConfigs": [{"natIP": "203.0.113.7"}]
+      }
+    ],
+    "disks": [
+      {"boot": true, "source": "https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-b/disks/boot-disk"}
+    ],
+    "scheduling": {
+      "instanceTerminationAction": "DELETE",
+      "maxRunDuration": {"seconds": "14400"},
+      "provisioningModel": "STANDARD"
+    }
+  }
+]`
+
+func TestImageSpecFromSourceImage(t *testing.T) {
+	got, err := imageSpecFromSourceImage("https://www.googleapis.com/compute/v1/projects/debian-cloud/global/images/debian-12-bookworm-v20240110")
+	if err != nil {
+		t.Fatalf("imageSpecFromSourceImage() failed: %v", err)
+	}
+	if want := "debian-cloud=debian-12-bookworm-v20240110"; got != want {
+		t.Errorf("imageSpecFromSourceImage() = %q, want %q", got, want)
+	}
+
+	if _, err := imageSpecFromSourceImage(""); err == nil {
+		t.Error("imageSpecFromSourceImage(\"\") succeeded, want error")
+	}
+}
+
+func TestVMFromInstance(t *testing.T) {
+	t.Setenv("USE_IAP", "")
+	t.Setenv("USE_INTERNAL_IP", "")
+	inst, err := extractSingleInstance(runningInstanceJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm, err := vmFromInstance("p", "us-central1-b", "vm", inst)
+	if err != nil {
+		t.Fatalf("vmFromInstance() failed: %v", err)
+	}
+	want := VM{
+		Name:        "vm",
+		Project:     "p",
+		Network:     "test-net",
+		Zone:        "us-central1-b",
+		MachineType: "e2-standard-4",
+		ID:          1234,
+		IPAddress:   "203.0.113.7",
+		TimeToLive:  "14400s",
+	}
+	if *vm != want {
+		t.Errorf("vmFromInstance() = %#v, want %#v", *vm, want)
+	}
+	if got := bootDiskName(inst); got != "boot-disk" {
+		t.Errorf("bootDiskName() = %q, want %q", got, "boot-disk")
+	}
+
+	inst.Scheduling.InstanceTerminationAction = "STOP"
+	if vm, err := vmFromInstance("p", "us-central1-b", "vm", inst); err != nil || vm.TimeToLive != "" {
+		t.Errorf("vmFromInstance() for an instance that stops after its max run duration = %+v, %v; want no TimeToLive", vm, err)
+	}
+
+	inst.Status = "TERMINATED"
+	if _, err := vmFromInstance("p", "us-central1-b", "vm", inst); err == nil {
+		t.Error("vmFromInstance() succeeded for a TERMINATED instance, want error")
+	}
+}
+
+// fakeAttachOrCreate replaces attachToExistingVM and createInstance with
+// fakes. The attach fake returns attachErr, or a VM if it is nil. It returns a
+// function that reports how many VMs were created.
+func fakeAttachOrCreate(t *testing.T, attachErr error) (created func() int) {
+	creations := 0
+	replaceForTest(t, &attachToExistingVM, func(_ context.Context, _ *log.Logger, project, zone, name string) (*VM, error) {
+		if attachErr != nil {
+			return nil, attachErr
+		}
+		return &VM{Name: name, Project: project, Zone: zone}, nil
+	})
+	replaceForTest(t, &createInstance, func(_ context.Context, _ *log.Logger, options VMOptions) (*VM, error) {
+		creations++
+		return &VM{Name: options.Name, Project: options.Project, Zone: options.Zone}, nil
+	})
+	return func() int { return creations }
+}
+
+func TestAttachOrCreateInstance(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	options := VMOptions{Name: "vm", Project: "p", Zone: "us-central1-b", AttachIfExists: true}
+
+	testCases := []struct {
+		desc         string
+		options      VMOptions
+		attachErr    error
+		wantAttached bool
+		wantCreated  int
+		wantErr      bool
+	}{
+		{
+			desc:         "existing VM",
+			options:      options,
+			wantAttached: true,
+		},
+		{
+			desc:        "missing VM",
+			options:     options,
+			attachErr:   errors.New("The resource 'projects/p/zones/us-central1-b/instances/vm' was not found"),
+			wantCreated: 1,
+		},
+		{
+			desc:      "attach failure",
+			options:   options,
+			attachErr: errors.New("instance vm has status TERMINATED, want RUNNING"),
+			wantErr:   true,
+		},
+		{
+			desc:        "attach not requested",
+			options:     VMOptions{Name: "vm", Project: "p", Zone: "us-central1-b"},
+			wantCreated: 1,
+		},
+		{
+			desc:    "no name",
+			options: VMOptions{Project: "p", Zone: "us-central1-b", AttachIfExists: true},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			created := fakeAttachOrCreate(t, tc.attachErr)
+			vm, attached, err := attachOrCreateInstance(context.Background(), logger, tc.options)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("attachOrCreateInstance() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("attachOrCreateInstance() failed: %v", err)
+			}
+			if vm.Name != "vm" {
+				t.Errorf("attachOrCreateInstance() returned VM %q, want %q", vm.Name, "vm")
+			}
+			if attached != tc.wantAttached {
+				t.Errorf("attachOrCreateInstance() attached = %v, want %v", attached, tc.wantAttached)
+			}
+			if got := created(); got != tc.wantCreated {
+				t.Errorf("attachOrCreateInstance() created %d VMs, want %d", got, tc.wantCreated)
+			}
+		})
+	}
+}
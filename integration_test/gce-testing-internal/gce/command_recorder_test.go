@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithCommandRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := WithCommandRecorder(context.Background(), NewCommandRecorder(&buf))
+	logger := log.New(io.Discard, "", 0)
+
+	if _, err := runCommand(ctx, logger, nil, []string{"sh", "-c", "echo hello"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCommand(ctx, logger, nil, []string{"sh", "-c", "echo oops >&2; exit 3"}, nil); err == nil {
+		t.Fatal("runCommand() with exit code 3 succeeded; want error")
+	}
+
+	var records []CommandRecord
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record CommandRecord
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records; want 2: %v", len(records), records)
+	}
+	if want := []string{"sh", "-c", "echo hello"}; !reflect.DeepEqual(records[0].Command, want) {
+		t.Errorf("records[0].Command = %v; want %v", records[0].Command, want)
+	}
+	if records[0].ExitCode != 0 || records[0].Output != "hello\n" || records[0].Error != "" {
+		t.Errorf("records[0] = %+v; want exit code 0, output \"hello\\n\" and no error", records[0])
+	}
+	if records[1].ExitCode != 3 || records[1].Output != "oops\n" || records[1].Error == "" {
+		t.Errorf("records[1] = %+v; want exit code 3, output \"oops\\n\" and an error", records[1])
+	}
+	if records[1].DurationSeconds < 0 || records[1].StartTime.Before(records[0].StartTime) {
+		t.Errorf("records have inconsistent timing: %+v", records)
+	}
+}
+
+func TestCommandRecorderTruncatesOutput(t *testing.T) {
+	var buf bytes.Buffer
+	output := strings.Repeat("a", maxRecordedOutputBytes) + "the end"
+	if err := NewCommandRecorder(&buf).record([]string{"true"}, time.Now(), 1, output, errors.New("exit status 1")); err != nil {
+		t.Fatal(err)
+	}
+	var record CommandRecord
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Output) != maxRecordedOutputBytes || !strings.HasSuffix(record.Output, "the end") || !record.OutputTruncated {
+		t.Errorf("record has %d bytes of output ending in %q, truncated=%v; want %d bytes ending in \"the end\", truncated=true",
+			len(record.Output), record.Output[len(record.Output)-7:], record.OutputTruncated, maxRecordedOutputBytes)
+	}
+}
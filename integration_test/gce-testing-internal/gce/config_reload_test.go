@@ -0,0 +1,108 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+func TestMaxGapAcrossReload(t *testing.T) {
+	reload := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(seconds ...int) []time.Time {
+		var timestamps []time.Time
+		for _, s := range seconds {
+			timestamps = append(timestamps, reload.Add(time.Duration(s)*time.Second))
+		}
+		return timestamps
+	}
+
+	baseline, gap, err := maxGapAcrossReload(at(-30, -20, -10, 0, 10, 20), reload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseline != 10*time.Second || gap != 10*time.Second {
+		t.Errorf("maxGapAcrossReload() = (%v, %v); want (10s, 10s)", baseline, gap)
+	}
+
+	baseline, gap, err = maxGapAcrossReload(at(-30, -20, -5, 55, 65), reload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseline != 15*time.Second || gap != 60*time.Second {
+		t.Errorf("maxGapAcrossReload() = (%v, %v); want (15s, 60s)", baseline, gap)
+	}
+
+	if _, _, err := maxGapAcrossReload(at(-10, 10, 20), reload); err == nil {
+		t.Error("maxGapAcrossReload() with one point before the reload unexpectedly succeeded")
+	}
+	if _, _, err := maxGapAcrossReload(at(-20, -10), reload); err == nil {
+		t.Error("maxGapAcrossReload() with no points after the reload unexpectedly succeeded")
+	}
+}
+
+func TestAssertNoGapAcrossConfigReload(t *testing.T) {
+	tests := []struct {
+		name string
+		// Offsets of the points reported after the reload, relative to the
+		// time of the reload.
+		afterReload []time.Duration
+		wantErr     bool
+	}{
+		{
+			name:        "small gap",
+			afterReload: []time.Duration{5 * time.Second, 15 * time.Second},
+		},
+		{
+			name:        "large gap",
+			afterReload: []time.Duration{90 * time.Second, 100 * time.Second},
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			var reloadTime time.Time
+			fakeListTimeSeries(t, func(*monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+				series := seriesWithPoints(start.Add(-30*time.Second), start.Add(-20*time.Second), start.Add(-10*time.Second))
+				if !reloadTime.IsZero() {
+					for _, offset := range tc.afterReload {
+						series.Points = append(series.Points, seriesWithPoints(reloadTime.Add(offset)).Points...)
+					}
+				}
+				return []*monitoringpb.TimeSeries{series}
+			})
+			reloadFunc := func() error {
+				reloadTime = time.Now()
+				return nil
+			}
+
+			vm := &VM{Name: "vm", Project: "p", ID: 1234}
+			err := AssertNoGapAcrossConfigReload(context.Background(), log.New(io.Discard, "", 0), vm, "agent.googleapis.com/agent/uptime", reloadFunc, time.Hour, false)
+			if tc.wantErr && err == nil {
+				t.Error("AssertNoGapAcrossConfigReload() unexpectedly succeeded")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("AssertNoGapAcrossConfigReload() failed: %v", err)
+			}
+		})
+	}
+}
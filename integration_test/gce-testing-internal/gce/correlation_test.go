@@ -0,0 +1,123 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	cloudlogging "cloud.google.com/go/logging"
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseLogTrace(t *testing.T) {
+	project, traceID, err := parseLogTrace("projects/my-project/traces/0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project != "my-project" || traceID != "0123456789abcdef0123456789abcdef" {
+		t.Errorf("parseLogTrace() = (%q, %q); want (%q, %q)", project, traceID, "my-project", "0123456789abcdef0123456789abcdef")
+	}
+
+	for _, malformed := range []string{
+		"0123456789abcdef0123456789abcdef",
+		"projects/my-project/traces/",
+		"projects//traces/0123",
+		"projects/my-project/spans/0123",
+	} {
+		if _, _, err := parseLogTrace(malformed); err == nil {
+			t.Errorf("parseLogTrace(%q) unexpectedly succeeded", malformed)
+		}
+	}
+}
+
+func TestAssertLogTraceCorrelation(t *testing.T) {
+	origQueryLog, origGetTrace, origBackoff := queryLog, getTrace, queryBackoffDuration
+	t.Cleanup(func() {
+		queryLog, getTrace, queryBackoffDuration = origQueryLog, origGetTrace, origBackoff
+	})
+	queryBackoffDuration = time.Millisecond
+
+	tests := []struct {
+		name          string
+		trace         string
+		wantTraceID   string
+		wantGetTraces int
+		wantErr       bool
+	}{
+		{
+			name:        "trace in same project",
+			trace:       "projects/p/traces/abc123",
+			wantTraceID: "abc123",
+			// The first lookup returns NotFound and is retried.
+			wantGetTraces: 2,
+		},
+		{
+			name:          "trace in other project",
+			trace:         "projects/other/traces/abc123",
+			wantTraceID:   "abc123",
+			wantGetTraces: 0,
+		},
+		{
+			name:    "no trace",
+			trace:   "",
+			wantErr: true,
+		},
+		{
+			name:    "malformed trace",
+			trace:   "abc123",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			queryLog = func(context.Context, *log.Logger, *VM, string, time.Duration, string, int) (*cloudlogging.Entry, error) {
+				return &cloudlogging.Entry{Trace: tc.trace, SpanID: "000000000000004a"}, nil
+			}
+			getTraces := 0
+			getTrace = func(_ context.Context, req *cloudtrace.GetTraceRequest) (*cloudtrace.Trace, error) {
+				getTraces++
+				if getTraces == 1 {
+					return nil, status.Error(codes.NotFound, "trace not found")
+				}
+				return &cloudtrace.Trace{ProjectId: req.ProjectId, TraceId: req.TraceId}, nil
+			}
+
+			vm := &VM{Name: "vm", Project: "p", ID: 1234}
+			traceID, err := AssertLogTraceCorrelation(context.Background(), log.New(io.Discard, "", 0), vm, "otel", time.Hour, "")
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("AssertLogTraceCorrelation() = %q; want error", traceID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if traceID != tc.wantTraceID {
+				t.Errorf("AssertLogTraceCorrelation() = %q; want %q", traceID, tc.wantTraceID)
+			}
+			if getTraces != tc.wantGetTraces {
+				t.Errorf("getTrace was called %d times; want %d", getTraces, tc.wantGetTraces)
+			}
+		})
+	}
+}
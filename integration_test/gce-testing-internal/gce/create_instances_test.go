@@ -0,0 +1,126 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCreateInstances replaces createInstance and deleteInstance with fakes
+// that "create" a VM named after each VMOptions, failing for the given image
+// specs. It returns functions that report the sorted names of the VMs that
+// were deleted and the most creations that were in progress at once.
+func fakeCreateInstances(t *testing.T, failingImageSpecs ...string) (deleted func() []string, maxConcurrent func() int) {
+	var mu sync.Mutex
+	var deletedNames []string
+	concurrent, peak := 0, 0
+	replaceForTest(t, &createInstance, func(_ context.Context, _ *log.Logger, options VMOptions) (*VM, error) {
+		mu.Lock()
+		concurrent++
+		peak = max(peak, concurrent)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			concurrent--
+			mu.Unlock()
+		}()
+		// Give other creations a chance to overlap with this one.
+		time.Sleep(10 * time.Millisecond)
+
+		if slices.Contains(failingImageSpecs, options.ImageSpec) {
+			return nil, errors.New("Quota 'CPUS' exceeded")
+		}
+		return &VM{Name: options.Name, ImageSpec: options.ImageSpec}, nil
+	})
+	replaceForTest(t, &deleteInstance, func(_ context.Context, _ *log.Logger, vm *VM) error {
+		mu.Lock()
+		defer mu.Unlock()
+		deletedNames = append(deletedNames, vm.Name)
+		return nil
+	})
+	return func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			slices.Sort(deletedNames)
+			return deletedNames
+		}, func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return peak
+		}
+}
+
+func optionsForImages(imageSpecs ...string) []VMOptions {
+	var optsList []VMOptions
+	for i, imageSpec := range imageSpecs {
+		optsList = append(optsList, VMOptions{ImageSpec: imageSpec, Name: fmt.Sprintf("vm-%d", i)})
+	}
+	return optsList
+}
+
+func TestCreateInstances(t *testing.T) {
+	replaceForTest(t, &CreateInstancesParallelism, 2)
+	deleted, maxConcurrent := fakeCreateInstances(t)
+
+	optsList := optionsForImages("debian-cloud:debian-11", "debian-cloud:debian-12", "rocky-linux-cloud:rocky-linux-9", "ubuntu-os-cloud:ubuntu-2404-lts-amd64", "windows-cloud:windows-2022")
+	vms, err := CreateInstances(context.Background(), log.New(io.Discard, "", 0), optsList)
+	if err != nil {
+		t.Fatalf("CreateInstances() failed: %v", err)
+	}
+	if len(vms) != len(optsList) {
+		t.Fatalf("CreateInstances() returned %d VMs; want %d", len(vms), len(optsList))
+	}
+	for i, vm := range vms {
+		if vm.Name != optsList[i].Name {
+			t.Errorf("CreateInstances()[%d] = %v; want %v", i, vm.Name, optsList[i].Name)
+		}
+	}
+	if got := maxConcurrent(); got > CreateInstancesParallelism {
+		t.Errorf("CreateInstances() created %d VMs at once; want at most %d", got, CreateInstancesParallelism)
+	}
+	if got := deleted(); len(got) > 0 {
+		t.Errorf("CreateInstances() deleted %v; want no deletions", got)
+	}
+}
+
+func TestCreateInstancesPartialFailure(t *testing.T) {
+	deleted, _ := fakeCreateInstances(t, "debian-cloud:debian-12", "windows-cloud:windows-2022")
+
+	optsList := optionsForImages("debian-cloud:debian-11", "debian-cloud:debian-12", "rocky-linux-cloud:rocky-linux-9", "windows-cloud:windows-2022")
+	vms, err := CreateInstances(context.Background(), log.New(io.Discard, "", 0), optsList)
+	if err == nil {
+		t.Fatalf("CreateInstances() = %v; want an error", vms)
+	}
+	if vms != nil {
+		t.Errorf("CreateInstances() returned VMs %v along with error %v; want no VMs", vms, err)
+	}
+	for _, imageSpec := range []string{"debian-cloud:debian-12", "windows-cloud:windows-2022"} {
+		if !strings.Contains(err.Error(), imageSpec) {
+			t.Errorf("CreateInstances() error = %v; want it to mention %v", err, imageSpec)
+		}
+	}
+	if got, want := deleted(), []string{"vm-0", "vm-2"}; !slices.Equal(got, want) {
+		t.Errorf("CreateInstances() deleted %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDefaultTimeToLive(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: builtInDefaultTimeToLive},
+		{value: "none", want: ""},
+		{value: "6h", want: "6h"},
+		{value: "1d", want: "1d"},
+		{value: "2h30m", want: "2h30m"},
+		{value: "2h", wantErr: true},
+		{value: "90m", wantErr: true},
+		{value: "forever", wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := parseDefaultTimeToLive(tc.value)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseDefaultTimeToLive(%q) error = %v; want error: %v", tc.value, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseDefaultTimeToLive(%q) = %q; want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestBuiltInDefaultTimeToLiveOutlastsSuggestedTimeout(t *testing.T) {
+	d, err := parseGcloudDuration(builtInDefaultTimeToLive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d <= SuggestedTimeout {
+		t.Errorf("builtInDefaultTimeToLive = %v; want longer than SuggestedTimeout (%v)", d, SuggestedTimeout)
+	}
+}
+
+func TestParseGcloudDuration(t *testing.T) {
+	got, err := parseGcloudDuration("1d2h3m4s")
+	want := 26*time.Hour + 3*time.Minute + 4*time.Second
+	if err != nil || got != want {
+		t.Errorf("parseGcloudDuration(\"1d2h3m4s\") = %v, %v; want %v, nil", got, err, want)
+	}
+}
+
+func TestCreateVMFromVMOptionsDefaultTimeToLive(t *testing.T) {
+	orig := defaultTimeToLive
+	t.Cleanup(func() { defaultTimeToLive = orig })
+	defaultTimeToLive = "5h"
+
+	tests := []struct {
+		name      string
+		options   VMOptions
+		want      string
+		wantFlags []string
+	}{
+		{
+			name:      "default",
+			options:   VMOptions{},
+			want:      "5h",
+			wantFlags: []string{"--max-run-duration=5h", "--instance-termination-action=DELETE", "--provisioning-model=STANDARD"},
+		},
+		{
+			name:      "explicit",
+			options:   VMOptions{TimeToLive: "1d"},
+			want:      "1d",
+			wantFlags: []string{"--max-run-duration=1d", "--instance-termination-action=DELETE", "--provisioning-model=STANDARD"},
+		},
+		{
+			name:      "spot",
+			options:   VMOptions{Spot: true},
+			want:      "",
+			wantFlags: []string{"--provisioning-model=SPOT", "--instance-termination-action=DELETE"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.options.ImageSpec = "debian-cloud:debian-12"
+			tc.options.Project = "p"
+			tc.options.Zone = "us-central1-b"
+			vm := createVMFromVMOptions(tc.options)
+			if vm.TimeToLive != tc.want {
+				t.Errorf("createVMFromVMOptions() TimeToLive = %q; want %q", vm.TimeToLive, tc.want)
+			}
+			args, err := additionalCreateInstanceArgs(tc.options, vm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Contains(args, tc.wantFlags[0]) || !slices.Contains(args, tc.wantFlags[1]) {
+				t.Errorf("additionalCreateInstanceArgs() = %v; want it to contain %v", args, tc.wantFlags)
+			}
+		})
+	}
+}
+
+func TestNewManagedInstanceGroupVMSkipsDefaultTimeToLive(t *testing.T) {
+	orig := defaultTimeToLive
+	t.Cleanup(func() { defaultTimeToLive = orig })
+	defaultTimeToLive = "5h"
+
+	options := VMOptions{ImageSpec: "debian-cloud:debian-12", Project: "p", Zone: "us-central1-b"}
+	migVM := newManagedInstanceGroupVM(options)
+	if migVM.TimeToLive != "" {
+		t.Errorf("newManagedInstanceGroupVM() TimeToLive = %q; want none", migVM.TimeToLive)
+	}
+	args, err := additionalCreateInstanceArgs(options, migVM.VM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.ContainsFunc(args, func(arg string) bool { return strings.HasPrefix(arg, "--max-run-duration") }) {
+		t.Errorf("additionalCreateInstanceArgs() = %v; want no --max-run-duration", args)
+	}
+
+	options.TimeToLive = "1d"
+	if migVM := newManagedInstanceGroupVM(options); migVM.TimeToLive != "1d" {
+		t.Errorf("newManagedInstanceGroupVM() TimeToLive = %q; want the explicit 1d", migVM.TimeToLive)
+	}
+}
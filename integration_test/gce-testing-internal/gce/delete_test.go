@@ -0,0 +1,79 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestDeleteInstanceAsyncAlreadyDeleted(t *testing.T) {
+	vm := &VM{Name: "vm", AlreadyDeleted: true}
+	if err := <-DeleteInstanceAsync(context.Background(), log.New(io.Discard, "", 0), vm); err != nil {
+		t.Errorf("DeleteInstanceAsync() of an already-deleted VM failed: %v", err)
+	}
+}
+
+func TestWaitForDeletions(t *testing.T) {
+	deletion := func(err error) <-chan error {
+		result := make(chan error, 1)
+		result <- err
+		close(result)
+		return result
+	}
+
+	if err := WaitForDeletions(); err != nil {
+		t.Errorf("WaitForDeletions() with no deletions = %v; want nil", err)
+	}
+	if err := WaitForDeletions(deletion(nil), deletion(nil)); err != nil {
+		t.Errorf("WaitForDeletions() with successful deletions = %v; want nil", err)
+	}
+
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	err := WaitForDeletions(deletion(errA), deletion(nil), deletion(errB))
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("WaitForDeletions() = %v; want both %v and %v", err, errA, errB)
+	}
+}
+
+func TestDeleteInstanceCtxHonorsCancellation(t *testing.T) {
+	origPath := gcloudPath
+	t.Cleanup(func() { gcloudPath = origPath })
+	// Deletion would succeed, if it were attempted.
+	gcloudPath = "true"
+	logger := log.New(io.Discard, "", 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	vm := &VM{Name: "vm", Project: "p", Zone: "z"}
+	if err := DeleteInstanceCtx(ctx, logger, vm); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("DeleteInstanceCtx() with a cancelled context = %v; want a cancellation error", err)
+	}
+	if vm.AlreadyDeleted {
+		t.Error("DeleteInstanceCtx() with a cancelled context marked the VM as deleted")
+	}
+
+	// DeleteInstance runs to completion regardless.
+	if err := DeleteInstance(ctx, logger, vm); err != nil {
+		t.Errorf("DeleteInstance() with a cancelled context = %v; want nil", err)
+	}
+	if !vm.AlreadyDeleted {
+		t.Error("DeleteInstance() did not mark the VM as deleted")
+	}
+}
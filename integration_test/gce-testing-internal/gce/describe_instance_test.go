@@ -0,0 +1,94 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestToInstanceInfo(t *testing.T) {
+	stdout := `{
+  "id": "1234",
+  "status": "RUNNING",
+  "creationTimestamp": "2026-02-01T10:30:00.123-08:00",
+  "machineType": "https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-b/machineTypes/e2-standard-4",
+  "zone": "https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-b",
+  "networkInterfaces": [{
+    "name": "nic0",
+    "network": "https://www.googleapis.com/compute/v1/projects/p/global/networks/default",
+    "networkIP": "10.128.0.2",
+    "accessConfigs": [{"natIP": "34.1.2.3"}],
+    "ipv6AccessConfigs": [{"externalIpv6": "2600:1900::1"}]
+  }],
+  "metadata": {"items": [{"key": "enable-oslogin", "value": "true"}]},
+  "disks": [{
+    "deviceName": "persistent-disk-0",
+    "source": "https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-b/disks/vm",
+    "boot": true,
+    "type": "PERSISTENT",
+    "mode": "READ_WRITE"
+  }]
+}`
+	var inst instance
+	if err := json.Unmarshal([]byte(stdout), &inst); err != nil {
+		t.Fatal(err)
+	}
+	got, err := toInstanceInfo(inst)
+	if err != nil {
+		t.Fatalf("toInstanceInfo() failed: %v", err)
+	}
+
+	wantCreation := time.Date(2026, 2, 1, 18, 30, 0, 123000000, time.UTC)
+	if !got.CreationTimestamp.Equal(wantCreation) {
+		t.Errorf("toInstanceInfo() returned CreationTimestamp %v; want %v", got.CreationTimestamp, wantCreation)
+	}
+	got.CreationTimestamp = time.Time{}
+
+	want := &InstanceInfo{
+		ID:          1234,
+		Status:      "RUNNING",
+		MachineType: "e2-standard-4",
+		Zone:        "us-central1-b",
+		NetworkInterfaces: []NetworkInterfaceInfo{{
+			Name:          "nic0",
+			Network:       "default",
+			InternalIP:    "10.128.0.2",
+			ExternalIPs:   []string{"34.1.2.3"},
+			ExternalIPv6s: []string{"2600:1900::1"},
+		}},
+		Metadata: map[string]string{"enable-oslogin": "true"},
+		// The VM has no labels, but Labels is still non-nil.
+		Labels: map[string]string{},
+		Disks: []DiskInfo{{
+			DeviceName: "persistent-disk-0",
+			Source:     "vm",
+			Boot:       true,
+			Type:       "PERSISTENT",
+			Mode:       "READ_WRITE",
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("toInstanceInfo() = %+v; want %+v", got, want)
+	}
+}
+
+func TestToInstanceInfoBadTimestamp(t *testing.T) {
+	if _, err := toInstanceInfo(instance{ID: "1234", CreationTimestamp: "yesterday"}); err == nil {
+		t.Error("toInstanceInfo() with a malformed creation timestamp unexpectedly succeeded")
+	}
+}
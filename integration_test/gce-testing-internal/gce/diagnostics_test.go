@@ -0,0 +1,57 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticsScriptLinux(t *testing.T) {
+	vm := &VM{Name: "vm", Project: "p", ImageSpec: "debian-cloud:debian-12"}
+	script, archive := diagnosticsScript(vm)
+	if !strings.HasPrefix(archive, "/tmp/diagnostics-") || !strings.HasSuffix(archive, ".tar.gz") {
+		t.Errorf("diagnosticsScript() archive = %q, want /tmp/diagnostics-*.tar.gz", archive)
+	}
+	for _, want := range append([]string{
+		SyslogLocation(vm.ImageSpec),
+		"systemctl --failed",
+		"/etc/google-cloud-ops-agent",
+		"tar -czf " + archive,
+	}, agentServices...) {
+		if !strings.Contains(script, want) {
+			t.Errorf("diagnosticsScript() script does not contain %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestDiagnosticsScriptWindows(t *testing.T) {
+	vm := &VM{Name: "vm", Project: "p", ImageSpec: "windows-cloud:windows-2022"}
+	script, archive := diagnosticsScript(vm)
+	if !strings.HasSuffix(archive, ".zip") {
+		t.Errorf("diagnosticsScript() archive = %q, want a .zip", archive)
+	}
+	for _, want := range []string{
+		"wevtutil epl System",
+		"wevtutil epl Application",
+		`Ops Agent\config`,
+		"Compress-Archive",
+		archive,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("diagnosticsScript() script does not contain %q:\n%s", want, script)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeFirewallGcloud replaces runGcloud with a fake that fails with the
+// given error, and returns the arguments of every call to it.
+func fakeFirewallGcloud(t *testing.T, err error) *[][]string {
+	t.Helper()
+	var calls [][]string
+	fakeRunGcloud(t, func(args []string) (CommandOutput, error) {
+		calls = append(calls, args)
+		return CommandOutput{}, err
+	})
+	return &calls
+}
+
+func TestCreateEgressDenyRule(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	calls := fakeFirewallGcloud(t, nil)
+	if err := CreateEgressDenyRule(context.Background(), logger, "p", "default"); err != nil {
+		t.Fatalf("CreateEgressDenyRule() failed: %v", err)
+	}
+	if len(*calls) != 1 || !slices.Contains((*calls)[0], "--target-tags="+DenyEgressTrafficTag) || !slices.Contains((*calls)[0], "--direction=EGRESS") {
+		t.Errorf("CreateEgressDenyRule() ran gcloud with %v; want one egress rule targeting %q", *calls, DenyEgressTrafficTag)
+	}
+
+	fakeFirewallGcloud(t, errors.New("ERROR: (gcloud.compute.firewall-rules.create) Could not fetch resource:\n - The resource 'projects/p/global/firewalls/x' already exists"))
+	if err := CreateEgressDenyRule(context.Background(), logger, "p", "default"); err != nil {
+		t.Errorf("CreateEgressDenyRule() with an existing rule failed: %v", err)
+	}
+
+	fakeFirewallGcloud(t, errors.New("ERROR: (gcloud.compute.firewall-rules.create) Permission denied"))
+	if err := CreateEgressDenyRule(context.Background(), logger, "p", "default"); err == nil {
+		t.Error("CreateEgressDenyRule() unexpectedly succeeded")
+	}
+}
+
+func TestDeleteEgressDenyRule(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	fakeFirewallGcloud(t, errors.New("ERROR: (gcloud.compute.firewall-rules.delete) Could not fetch resource:\n - The resource 'projects/p/global/firewalls/x' was not found"))
+	if err := DeleteEgressDenyRule(context.Background(), logger, "p", "default"); err != nil {
+		t.Errorf("DeleteEgressDenyRule() with a missing rule failed: %v", err)
+	}
+
+	fakeFirewallGcloud(t, errors.New("ERROR: (gcloud.compute.firewall-rules.delete) Permission denied"))
+	if err := DeleteEgressDenyRule(context.Background(), logger, "p", "default"); err == nil {
+		t.Error("DeleteEgressDenyRule() unexpectedly succeeded")
+	}
+}
+
+func TestEgressDenyRuleName(t *testing.T) {
+	if got, want := egressDenyRuleName("default"), DenyEgressTrafficTag+"-default"; got != want {
+		t.Errorf("egressDenyRuleName(%q) = %q; want %q", "default", got, want)
+	}
+	name := egressDenyRuleName(strings.Repeat("n", 30))
+	if len(name) > 63 || strings.HasSuffix(name, "-") {
+		t.Errorf("egressDenyRuleName() = %q; want at most 63 characters, not ending in a hyphen", name)
+	}
+}
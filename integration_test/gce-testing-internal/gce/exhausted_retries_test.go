@@ -0,0 +1,70 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExhaustedRetriesErrorMessage(t *testing.T) {
+	for _, tc := range []struct {
+		err  *ExhaustedRetriesError
+		want string
+	}{
+		{
+			err:  &ExhaustedRetriesError{Op: "WaitForTrace()", Attempts: 10},
+			want: "WaitForTrace() failed: exhausted retries",
+		},
+		{
+			err:  &ExhaustedRetriesError{Op: "QueryLog()", Target: "syslog", Detail: "syslog not found"},
+			want: "QueryLog() failed: syslog not found, exhausted retries",
+		},
+		{
+			err:  &ExhaustedRetriesError{Op: "Poll()", LastErr: errors.New("not yet")},
+			want: "Poll() failed: last err=not yet, exhausted retries",
+		},
+	} {
+		if got := tc.err.Error(); got != tc.want {
+			t.Errorf("Error() = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestIsExhaustedRetriesMetricError(t *testing.T) {
+	exhausted := &ExhaustedRetriesError{Op: "WaitForMetricSeries(metric=foo, extraFilters=[])", Target: "foo", Attempts: 3}
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "typed", err: exhausted, want: true},
+		{name: "wrapped", err: fmt.Errorf("AssertFoo(): %w", exhausted), want: true},
+		// Deprecated: matched by the message suffix only.
+		{name: "wrapped with %v", err: fmt.Errorf("AssertFoo(): %v", exhausted), want: true},
+		{name: "other error", err: errors.New("permission denied"), want: false},
+		{name: "nil", err: nil, want: false},
+	} {
+		if got := IsExhaustedRetriesMetricError(tc.err); got != tc.want {
+			t.Errorf("%s: IsExhaustedRetriesMetricError(%v) = %v, want %v", tc.name, tc.err, got, tc.want)
+		}
+	}
+
+	var target *ExhaustedRetriesError
+	if !errors.As(fmt.Errorf("AssertFoo(): %w", exhausted), &target) || target.Target != "foo" || target.Attempts != 3 {
+		t.Errorf("errors.As() = %+v, want the original ExhaustedRetriesError", target)
+	}
+}
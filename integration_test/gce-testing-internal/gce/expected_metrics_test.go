@@ -0,0 +1,106 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+func TestAssertOnlyExpectedMetrics(t *testing.T) {
+	allowed := []string{
+		"agent.googleapis.com/agent/uptime",
+		"agent.googleapis.com/cpu/utilization",
+		"workload.googleapis.com/apache.requests",
+	}
+	tests := []struct {
+		name string
+		// The metric types that the VM has reported.
+		reported       []string
+		wantUnexpected []string
+	}{
+		{
+			name:     "only allowed metrics",
+			reported: []string{"agent.googleapis.com/agent/uptime", "workload.googleapis.com/apache.requests"},
+		},
+		{
+			name: "unexpected metrics",
+			reported: []string{
+				"agent.googleapis.com/agent/uptime",
+				"agent.googleapis.com/memory/bytes_used",
+				"workload.googleapis.com/apache.traffic",
+			},
+			wantUnexpected: []string{"agent.googleapis.com/memory/bytes_used", "workload.googleapis.com/apache.traffic"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			origListTypes := listMetricTypes
+			t.Cleanup(func() { listMetricTypes = origListTypes })
+			// The project has descriptors for more metrics than the VM reports.
+			descriptors := append(slices.Clone(allowed),
+				"agent.googleapis.com/memory/bytes_used",
+				"workload.googleapis.com/apache.traffic",
+				"workload.googleapis.com/mysql.threads",
+			)
+			listMetricTypes = func(_ context.Context, project, prefix string) ([]string, error) {
+				if project != "p" {
+					t.Errorf("listMetricTypes() called for project %q; want %q", project, "p")
+				}
+				var types []string
+				for _, d := range descriptors {
+					if strings.HasPrefix(d, prefix) {
+						types = append(types, d)
+					}
+				}
+				return types, nil
+			}
+			fakeListTimeSeries(t, func(req *monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+				for _, metric := range tc.reported {
+					if strings.Contains(req.Filter, fmt.Sprintf("metric.type = %q", metric)) {
+						return []*monitoringpb.TimeSeries{seriesWithPoints(time.Now())}
+					}
+				}
+				return nil
+			})
+
+			vm := &VM{Name: "vm", Project: "p", ID: 1234}
+			err := AssertOnlyExpectedMetrics(context.Background(), log.New(io.Discard, "", 0), vm, allowed, time.Hour)
+			if len(tc.wantUnexpected) == 0 {
+				if err != nil {
+					t.Errorf("AssertOnlyExpectedMetrics() failed: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("AssertOnlyExpectedMetrics() unexpectedly succeeded")
+			}
+			for _, metric := range tc.wantUnexpected {
+				if !strings.Contains(err.Error(), metric) {
+					t.Errorf("AssertOnlyExpectedMetrics() = %v; want it to name %q", err, metric)
+				}
+			}
+		})
+	}
+}
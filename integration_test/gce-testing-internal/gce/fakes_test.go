@@ -0,0 +1,92 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"google.golang.org/api/iterator"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeTimeSeriesIterator iterates over a fixed list of time series.
+type fakeTimeSeriesIterator struct {
+	series []*monitoringpb.TimeSeries
+}
+
+func (it *fakeTimeSeriesIterator) Next() (*monitoringpb.TimeSeries, error) {
+	if len(it.series) == 0 {
+		return nil, iterator.Done
+	}
+	next := it.series[0]
+	it.series = it.series[1:]
+	return next, nil
+}
+
+// fakeListTimeSeries replaces listTimeSeries with the given function for the
+// duration of the test. It also shortens queryBackoffDuration so that retries
+// don't slow the test down.
+func fakeListTimeSeries(t *testing.T, fake func(req *monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries) {
+	t.Helper()
+	origList, origBackoff := listTimeSeries, queryBackoffDuration
+	t.Cleanup(func() {
+		listTimeSeries, queryBackoffDuration = origList, origBackoff
+	})
+	listTimeSeries = func(_ context.Context, req *monitoringpb.ListTimeSeriesRequest) timeSeriesIterator {
+		return &fakeTimeSeriesIterator{series: fake(req)}
+	}
+	queryBackoffDuration = time.Millisecond
+}
+
+// replaceForTest sets *v to fake for the duration of the test.
+func replaceForTest[T any](t *testing.T, v *T, fake T) {
+	t.Helper()
+	orig := *v
+	t.Cleanup(func() { *v = orig })
+	*v = fake
+}
+
+// fakeRunGcloud replaces runGcloud with the given function for the duration
+// of the test.
+func fakeRunGcloud(t *testing.T, fake func(args []string) (CommandOutput, error)) {
+	t.Helper()
+	replaceForTest(t, &runGcloud, func(_ context.Context, _ *log.Logger, _ string, args []string) (CommandOutput, error) {
+		return fake(args)
+	})
+}
+
+// fakeRunRemotely replaces runRemotely with the given function for the
+// duration of the test.
+func fakeRunRemotely(t *testing.T, fake func(ctx context.Context, vm *VM, command string) (CommandOutput, error)) {
+	t.Helper()
+	replaceForTest(t, &runRemotely, func(ctx context.Context, _ *log.Logger, vm *VM, command string) (CommandOutput, error) {
+		return fake(ctx, vm, command)
+	})
+}
+
+// seriesWithPoints returns a time series with one point per given timestamp.
+func seriesWithPoints(timestamps ...time.Time) *monitoringpb.TimeSeries {
+	series := &monitoringpb.TimeSeries{}
+	for _, ts := range timestamps {
+		series.Points = append(series.Points, &monitoringpb.Point{
+			Interval: &monitoringpb.TimeInterval{EndTime: timestamppb.New(ts)},
+		})
+	}
+	return series
+}
@@ -42,31 +42,57 @@ expensive), and instead the VM will use cloud NAT to get to the external
 internet. ssh-ing to the VM is done via its internal IP address.
 Only useful on Kokoro.
 
+USE_IAP: If set to "true", ssh to all VMs through Identity-Aware Proxy TCP
+forwarding, as if VMOptions.UseIAP were set. This works when the test runner
+can reach neither the external nor the internal IP address of the VM.
+
 SERVICE_EMAIL: If provided, which service account to use for spawned VMs. The
-default is the project's "Compute Engine default service account".
+default is the project's "Compute Engine default service account". Can be
+overridden for individual VMs with VMOptions.ServiceAccountEmail.
 TRANSFERS_BUCKET: A GCS bucket name to use to transfer files to testing VMs.
-The default is "stackdriver-test-143416-file-transfers".
+The default is "stackdriver-test-143416-file-transfers". Can be overridden for
+individual VMs with VMOptions.TransfersBucket.
 INSTANCE_SIZE: What size of VMs to make. Passed in to gcloud as --machine-type.
 If provided, this value overrides the selection made by the callers to
 this library.
+DEFAULT_ARM_MACHINE_TYPES: A comma-separated list of machine types to use for
+ARM VMs whose callers did not choose one, tried in order until one is
+available in the VM's zone. The default is "t2a-standard-4,c4a-standard-4".
+KEEP_VMS_ON_FAILURE: If set to "true", VMs created by SetupVM() are not
+deleted when their test fails, so that they can be inspected. See
+SetKeepVMsOnFailure().
+DEFAULT_VM_TTL: The TimeToLive given to VMs whose VMOptions don't set one,
+as a duration like "6h" or "1d". It must be longer than SuggestedTimeout.
+Set it to "none" to create such VMs without a TimeToLive. The default is "4h".
+Spot VMs and Managed Instance Group VMs never get the default.
 */
 package gce
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"maps"
+	"math"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -84,8 +110,11 @@ import (
 	"go.uber.org/multierr"
 	"golang.org/x/text/encoding/unicode"
 	"google.golang.org/api/iterator"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -131,7 +160,6 @@ const (
 	// Retries are spaced by 10 seconds, so 40 retries denotes 6 minutes 40 seconds total.
 	QueryMaxAttempts              = 40 // 6 minutes 40 seconds total.
 	queryMaxAttemptsMetricMissing = 5  // 50 seconds total.
-	queryBackoffDuration          = 10 * time.Second
 
 	// LogQueryMaxAttempts is the default number of retries when calling WaitForLog.
 	// Retries are spaced by 30 seconds, so 15 retries denotes 7 minutes 30 seconds total.
@@ -161,6 +189,11 @@ const (
 	TraceQueryMaxAttempts = QueryMaxAttempts / traceQueryDerate
 )
 
+var (
+	// queryBackoffDuration is how long to wait between metric and trace queries.
+	queryBackoffDuration = 10 * time.Second
+)
+
 func init() {
 	ctx := context.Background()
 	var err error
@@ -189,6 +222,13 @@ func init() {
 		log.Fatal(err)
 	}
 
+	defaultTimeToLive, err = parseDefaultTimeToLive(os.Getenv("DEFAULT_VM_TTL"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keepVMsOnFailure.Store(os.Getenv("KEEP_VMS_ON_FAILURE") == "true")
+
 	// Some useful options to pass to gcloud.
 	os.Setenv("CLOUDSDK_PYTHON", "/usr/bin/python3")
 	os.Setenv("CLOUDSDK_CORE_DISABLE_PROMPTS", "1")
@@ -211,13 +251,7 @@ func init() {
 	// easy to identify.
 	sandboxPrefix = fmt.Sprintf("test-%s-%s", time.Now().Format("20060102"), uuid.NewString()[:5])
 
-	// This prefix is needed for builds running as build-and-test-external
-	// because that service account is only allowed to interact with VMs whose
-	// names start with "github-" to isolate them from our release builds.
-	// go/sdi-kokoro-security
-	if strings.Contains(os.Getenv("SERVICE_EMAIL"), "build-and-test-external@") {
-		sandboxPrefix = "github-" + sandboxPrefix
-	}
+	sandboxPrefix = sandboxPrefixForServiceAccount(os.Getenv("SERVICE_EMAIL"))
 
 	logRootDir = os.Getenv("TEST_UNDECLARED_OUTPUTS_DIR")
 	if logRootDir == "" {
@@ -232,6 +266,10 @@ func init() {
 // CleanupKeysOrDie deletes ssh key files created in init(). It is intended to
 // be called from inside TestMain() after tests have finished running.
 func CleanupKeysOrDie() {
+	if keptVMs.Load() {
+		log.Printf("CleanupKeysOrDie() is keeping ssh keys in %v because some VMs were kept for debugging", keysDir)
+		return
+	}
 	if err := os.RemoveAll(keysDir); err != nil {
 		log.Fatalf("CleanupKeysOrDie() failed to remove temporary ssh key dir %v: %v", keysDir, err)
 	}
@@ -268,6 +306,53 @@ func (f *logClientFactory) new(project string) (*logadmin.Client, error) {
 type OS struct {
 	// The same as ID from /etc/os-release, or "windows".
 	ID string
+	// The family of operating systems that ID belongs to, derived from ID and
+	// ID_LIKE in /etc/os-release. Empty if the family is not recognized.
+	Family OSFamily
+}
+
+// OSFamily is a group of operating systems that are administered the same
+// way, e.g. that share a package manager.
+type OSFamily string
+
+const (
+	OSFamilyDebian  OSFamily = "debian"
+	OSFamilyRHEL    OSFamily = "rhel"
+	OSFamilySUSE    OSFamily = "suse"
+	OSFamilyWindows OSFamily = "windows"
+)
+
+// IsDebianLike returns whether the OS is Debian or derived from it, e.g. Ubuntu.
+func (o OS) IsDebianLike() bool {
+	return o.Family == OSFamilyDebian
+}
+
+// IsRHELLike returns whether the OS is RHEL or compatible with it, e.g.
+// CentOS, Rocky Linux or AlmaLinux.
+func (o OS) IsRHELLike() bool {
+	return o.Family == OSFamilyRHEL
+}
+
+// IsSUSELike returns whether the OS is SLES or openSUSE.
+func (o OS) IsSUSELike() bool {
+	return o.Family == OSFamilySUSE
+}
+
+// osFamily returns the family of the OS with the given ID and ID_LIKE from
+// /etc/os-release. ID is consulted first, followed by the space-separated
+// entries of ID_LIKE in order.
+func osFamily(id, idLike string) OSFamily {
+	for _, candidate := range append([]string{id}, strings.Fields(idLike)...) {
+		switch {
+		case candidate == "debian" || candidate == "ubuntu":
+			return OSFamilyDebian
+		case candidate == "rhel" || candidate == "centos" || candidate == "fedora":
+			return OSFamilyRHEL
+		case candidate == "suse" || candidate == "sles" || candidate == "sles_sap" || strings.HasPrefix(candidate, "opensuse"):
+			return OSFamilySUSE
+		}
+	}
+	return ""
 }
 
 // VM represents an individual virtual machine.
@@ -283,14 +368,51 @@ type VM struct {
 	ID          int64
 	// The IP address to ssh to. This is the external IP address, unless
 	// USE_INTERNAL_IP is set to 'true'. See comment on extractIPAddress() for
-	// rationale.
+	// rationale. It is an IPv6 address if PreferIPv6 is set and the VM has one.
 	IPAddress      string
 	AlreadyDeleted bool
+	// The VMOptions.PreferIPv6 used to create the VM.
+	PreferIPv6 bool
+	// How long until the VM deletes itself, or empty if it never does. This
+	// is VMOptions.TimeToLive, or DEFAULT_VM_TTL if that was not set. For an
+	// attached VM, it is the max run duration the VM was created with.
+	TimeToLive string
+	// The VMOptions.TransfersBucket used to create the VM. If empty,
+	// TRANSFERS_BUCKET or its default is used instead.
+	TransfersBucket string
+	// Whether to ssh to the VM through an IAP tunnel instead of IPAddress.
+	// See VMOptions.UseIAP.
+	UseIAP bool
+	// The host name to ssh to instead of IPAddress, if not empty. See
+	// VMOptions.ConnectHost.
+	ConnectHost string
 }
 
 // ManagedInstanceGroupVM represents an individual VM in a Managed Instace Group.
 type ManagedInstanceGroupVM struct {
 	*VM
+	// The region of a regional Managed Instance Group, whose instances are
+	// spread across several zones, or empty for a zonal one. Either way,
+	// VM.Zone is the zone of the VM itself.
+	Region string
+}
+
+// locationFlag returns the gcloud flag that says where the Managed Instance
+// Group is: its region if it is regional, or else its zone.
+func (migVM ManagedInstanceGroupVM) locationFlag() string {
+	if migVM.Region != "" {
+		return "--region=" + migVM.Region
+	}
+	return "--zone=" + migVM.Zone
+}
+
+// zoneRegion returns the region that the given zone is in, e.g.
+// "us-central1" for "us-central1-a".
+func zoneRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i >= 0 {
+		return zone[:i]
+	}
+	return zone
 }
 
 func (migVM ManagedInstanceGroupVM) ManagedInstanceGroupName() string {
@@ -335,32 +457,49 @@ func SetGcloudPath(path string) {
 	gcloudPath = path
 }
 
+var (
+	// Whether SetupVM() should keep VMs around when their test fails. It is
+	// read from the cleanups of tests that may run in parallel.
+	keepVMsOnFailure atomic.Bool
+	// Set once any VM has been kept for debugging, so that
+	// CleanupKeysOrDie() knows to keep the ssh keys needed to connect to it.
+	keptVMs atomic.Bool
+)
+
+// SetKeepVMsOnFailure configures whether SetupVM() should skip deleting VMs
+// whose test failed, so that they can be inspected after the fact. Instead of
+// deleting such a VM, SetupVM() logs the ssh command needed to connect to it.
+// The default comes from the KEEP_VMS_ON_FAILURE environment variable.
+//
+// Kept VMs are only cleaned up by their TimeToLive, so make sure
+// DEFAULT_VM_TTL (or VMOptions.TimeToLive) leaves enough time to inspect them.
+func SetKeepVMsOnFailure(keep bool) {
+	keepVMsOnFailure.Store(keep)
+}
+
 // IsWindows returns whether the given image spec is a version of Windows (including Microsoft SQL Server).
 func IsWindows(imageSpec string) bool {
-	return strings.HasPrefix(imageSpec, "windows-")
+	return unparsedImageSpec(imageSpec).IsWindows()
 }
 
 // IsWindowsCore returns whether the given image spec is a version of Windows core.
 func IsWindowsCore(imageSpec string) bool {
-	return IsWindows(imageSpec) && strings.HasSuffix(imageSpec, "-core")
+	return unparsedImageSpec(imageSpec).IsWindowsCore()
 }
 
 // IsWindows2016 returns whether the given image is a Windows 2016 image.
 func IsWindows2016(imageSpec string) bool {
-	return IsWindows(imageSpec) && strings.Contains(imageSpec, "2016")
+	return unparsedImageSpec(imageSpec).IsWindows2016()
 }
 
 // IsWindows2019 returns whether the given image is a Windows 2019 image.
 func IsWindows2019(imageSpec string) bool {
-	return IsWindows(imageSpec) && strings.Contains(imageSpec, "2019")
+	return unparsedImageSpec(imageSpec).IsWindows2019()
 }
 
 // OSKind returns "linux" or "windows" based on the given image spec.
 func OSKind(imageSpec string) string {
-	if IsWindows(imageSpec) {
-		return "windows"
-	}
-	return "linux"
+	return unparsedImageSpec(imageSpec).OSKind()
 }
 
 // isRetriableLookupError returns whether the given error, returned from
@@ -378,20 +517,65 @@ func isRetriableLookupError(err error) bool {
 	return ok && (myStatus.Code() == codes.NotFound || myStatus.Code() == codes.Internal || myStatus.Code() == codes.ResourceExhausted)
 }
 
+// timeSeriesIterator is the subset of *monitoring.TimeSeriesIterator that this
+// library uses.
+type timeSeriesIterator interface {
+	Next() (*monitoringpb.TimeSeries, error)
+}
+
+// listTimeSeries issues a ListTimeSeries request to the monitoring backend.
+var listTimeSeries = func(ctx context.Context, req *monitoringpb.ListTimeSeriesRequest) timeSeriesIterator {
+	return monClient.ListTimeSeries(ctx, req)
+}
+
+// LookupOptions selects the monitored resource whose time series a metric
+// lookup finds. The zero value finds the VM's own series, by its instance ID
+// (gce_instance) or, for Prometheus metrics, its namespace
+// (prometheus_target). Set it when the agent writes metrics under another
+// resource type, e.g. with a non-default resource detector.
+type LookupOptions struct {
+	// The monitored resource type that series must have, e.g.
+	// "generic_node". If empty, any type matches.
+	ResourceType string
+	// Resource labels that series must have, e.g. {"node_id": vm.Name}.
+	// Other resource types don't have the labels used to find the VM's own
+	// series, so if this is set, it replaces them.
+	ResourceLabels map[string]string
+}
+
+// resourceFilters returns the filters that restrict a metric lookup to the
+// monitored resource selected by opts.
+func resourceFilters(vm *VM, isPrometheus bool, opts LookupOptions) []string {
+	var filters []string
+	if opts.ResourceType != "" {
+		filters = append(filters, fmt.Sprintf("resource.type = %q", opts.ResourceType))
+	}
+	if len(opts.ResourceLabels) > 0 {
+		for _, k := range slices.Sorted(maps.Keys(opts.ResourceLabels)) {
+			filters = append(filters, fmt.Sprintf("resource.labels.%s = %q", k, opts.ResourceLabels[k]))
+		}
+		return filters
+	}
+	if isPrometheus {
+		return append(filters, fmt.Sprintf(`resource.labels.namespace = "%d/%s"`, vm.ID, vm.Name))
+	}
+	return append(filters, fmt.Sprintf(`resource.labels.instance_id = "%d"`, vm.ID))
+}
+
 // lookupMetric does a single lookup of the given metric in the backend.
-func lookupMetric(ctx context.Context, logger *log.Logger, vm *VM, metric string, window time.Duration, extraFilters []string, isPrometheus bool) *monitoring.TimeSeriesIterator {
+func lookupMetric(ctx context.Context, logger *log.Logger, vm *VM, metric string, window time.Duration, extraFilters []string, isPrometheus bool) timeSeriesIterator {
+	return lookupMetricWithOptions(ctx, logger, vm, metric, window, extraFilters, isPrometheus, LookupOptions{})
+}
+
+// lookupMetricWithOptions is like lookupMetric, but looks for series under
+// the monitored resource selected by opts.
+func lookupMetricWithOptions(ctx context.Context, logger *log.Logger, vm *VM, metric string, window time.Duration, extraFilters []string, isPrometheus bool, opts LookupOptions) timeSeriesIterator {
 	now := time.Now()
 	start := timestamppb.New(now.Add(-window))
 	end := timestamppb.New(now)
-	filters := []string{
+	filters := append([]string{
 		fmt.Sprintf("metric.type = %q", metric),
-	}
-
-	if isPrometheus {
-		filters = append(filters, fmt.Sprintf(`resource.labels.namespace = "%d/%s"`, vm.ID, vm.Name))
-	} else {
-		filters = append(filters, fmt.Sprintf(`resource.labels.instance_id = "%d"`, vm.ID))
-	}
+	}, resourceFilters(vm, isPrometheus, opts)...)
 
 	req := &monitoringpb.ListTimeSeriesRequest{
 		Name:   "projects/" + vm.Project,
@@ -402,7 +586,7 @@ func lookupMetric(ctx context.Context, logger *log.Logger, vm *VM, metric string
 		},
 		View: monitoringpb.ListTimeSeriesRequest_FULL,
 	}
-	return monClient.ListTimeSeries(ctx, req)
+	return listTimeSeries(ctx, req)
 }
 
 // lookupTrace does a single lookup of any trace from the given VM in the backend.
@@ -419,12 +603,23 @@ func lookupTrace(ctx context.Context, vm *VM, options WaitForTraceOptions) *trac
 	return traceClient.ListTraces(ctx, req)
 }
 
+// lookupFirstTrace does a single lookup of any trace from the given VM in the
+// backend, and returns the first one, or nil if there are none.
+var lookupFirstTrace = func(ctx context.Context, vm *VM, options WaitForTraceOptions) (*cloudtrace.Trace, error) {
+	return firstTrace(lookupTrace(ctx, vm, options))
+}
+
+// getTrace fetches a single trace, including its spans, from the backend.
+var getTrace = func(ctx context.Context, req *cloudtrace.GetTraceRequest) (*cloudtrace.Trace, error) {
+	return traceClient.GetTrace(ctx, req)
+}
+
 // nonEmptySeriesList evaluates the given iterator, returning a non-empty slice of
 // time series, the length of the slice is guaranteed to be of size minimumRequiredSeries or greater.
 // A panic is issued if minimumRequiredSeries is zero or negative.
 // An error is returned if the evaluation fails or produces a non-empty slice with length less than minimumRequiredSeries.
 // A return value of (nil, nil) indicates that the evaluation succeeded but returned no data.
-func nonEmptySeriesList(logger *log.Logger, it *monitoring.TimeSeriesIterator, minimumRequiredSeries int) ([]*monitoringpb.TimeSeries, error) {
+func nonEmptySeriesList(logger *log.Logger, it timeSeriesIterator, minimumRequiredSeries int) ([]*monitoringpb.TimeSeries, error) {
 	if minimumRequiredSeries < 1 {
 		panic("minimumRequiredSeries cannot be negative or 0")
 	}
@@ -472,7 +667,9 @@ func firstTrace(it *trace.TraceIterator) (*cloudtrace.Trace, error) {
 // WaitForMetric looks for the given metrics in the backend and returns it if it
 // exists. An error is returned otherwise. This function will retry "no data"
 // errors a fixed number of times. This is useful because it takes time for
-// monitoring data to become visible after it has been uploaded.
+// monitoring data to become visible after it has been uploaded. To look for
+// the metric under a monitored resource other than the VM's own, use
+// WaitForMetricSeriesWithOpts with Lookup set.
 func WaitForMetric(ctx context.Context, logger *log.Logger, vm *VM, metric string, window time.Duration, extraFilters []string, isPrometheus bool) (*monitoringpb.TimeSeries, error) {
 	series, err := WaitForMetricSeries(ctx, logger, vm, metric, window, extraFilters, isPrometheus, 1)
 	if err != nil {
@@ -487,8 +684,86 @@ func WaitForMetric(ctx context.Context, logger *log.Logger, vm *VM, metric strin
 // errors a fixed number of times. This is useful because it takes time for
 // monitoring data to become visible after it has been uploaded.
 func WaitForMetricSeries(ctx context.Context, logger *log.Logger, vm *VM, metric string, window time.Duration, extraFilters []string, isPrometheus bool, minimumRequiredSeries int) ([]*monitoringpb.TimeSeries, error) {
-	for attempt := 1; attempt <= QueryMaxAttempts; attempt++ {
-		it := lookupMetric(ctx, logger, vm, metric, window, extraFilters, isPrometheus)
+	return WaitForMetricSeriesWithOpts(ctx, logger, vm, metric, window, extraFilters, isPrometheus, WaitForMetricSeriesOpts{
+		MaxAttempts:           QueryMaxAttempts,
+		Backoff:               queryBackoffDuration,
+		MinimumRequiredSeries: minimumRequiredSeries,
+	})
+}
+
+// prometheusTargetResourceLabels are the Prometheus labels that Cloud
+// Monitoring stores as labels of the prometheus_target monitored resource
+// rather than as metric labels.
+var prometheusTargetResourceLabels = []string{"project_id", "location", "cluster", "namespace", "job", "instance"}
+
+// PrometheusLabelFilter returns a Cloud Monitoring filter that matches
+// Prometheus-domain time series whose label k has the value v. It handles
+// labels like "job" and "instance", which end up as resource labels, as well
+// as ordinary metric labels. The result can be passed in the extraFilters of
+// WaitForMetric and similar functions, along with isPrometheus=true.
+func PrometheusLabelFilter(k, v string) string {
+	if slices.Contains(prometheusTargetResourceLabels, k) {
+		return fmt.Sprintf("resource.labels.%s = %q", k, v)
+	}
+	return fmt.Sprintf("metric.labels.%s = %q", k, v)
+}
+
+// prometheusLabelFilters returns a PrometheusLabelFilter for each of the
+// given labels, sorted by label name so that the result is deterministic.
+func prometheusLabelFilters(labels map[string]string) []string {
+	var filters []string
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		filters = append(filters, PrometheusLabelFilter(k, labels[k]))
+	}
+	return filters
+}
+
+// WaitForPrometheusMetric is like WaitForMetricSeries for a Prometheus-domain
+// metric, but only matches time series with all of the given Prometheus
+// labels, e.g. {"job": "my-exporter"}. This disambiguates series from
+// multiple exporters scraped on the same VM. It waits for at least minSeries
+// matching series.
+func WaitForPrometheusMetric(ctx context.Context, logger *log.Logger, vm *VM, metric string, window time.Duration, labels map[string]string, minSeries int) ([]*monitoringpb.TimeSeries, error) {
+	return WaitForMetricSeries(ctx, logger, vm, metric, window, prometheusLabelFilters(labels), true, max(minSeries, 1))
+}
+
+// WaitForMetricSeriesOpts configures WaitForMetricSeriesWithOpts. The zero
+// value behaves like WaitForMetricSeries.
+type WaitForMetricSeriesOpts struct {
+	// The number of times to look up the metric before giving up. If 0,
+	// QueryMaxAttempts is used.
+	MaxAttempts int
+
+	// How long to wait between attempts. If 0, the same backoff as
+	// WaitForMetricSeries is used.
+	Backoff time.Duration
+
+	// The minimum number of non-empty series to wait for. If 0, it waits for
+	// at least one series.
+	MinimumRequiredSeries int
+
+	// Which monitored resource to look for series under. If unset, the VM's
+	// own series are found as usual.
+	Lookup LookupOptions
+}
+
+// WaitForMetricSeriesWithOpts is like WaitForMetricSeries, but lets the caller
+// choose how many times and how often to retry, e.g. to wait longer for
+// metrics that are slow to appear after a cold agent install, or to fail fast
+// on metrics that should already be there.
+func WaitForMetricSeriesWithOpts(ctx context.Context, logger *log.Logger, vm *VM, metric string, window time.Duration, extraFilters []string, isPrometheus bool, opts WaitForMetricSeriesOpts) ([]*monitoringpb.TimeSeries, error) {
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = QueryMaxAttempts
+	}
+	backoffDuration := opts.Backoff
+	if backoffDuration == 0 {
+		backoffDuration = queryBackoffDuration
+	}
+	minimumRequiredSeries := max(opts.MinimumRequiredSeries, 1)
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		it := lookupMetricWithOptions(ctx, logger, vm, metric, window, extraFilters, isPrometheus, opts.Lookup)
 		tsList, err := nonEmptySeriesList(logger, it, minimumRequiredSeries)
 
 		if tsList != nil && err == nil {
@@ -503,177 +778,1401 @@ func WaitForMetricSeries(ctx context.Context, logger *log.Logger, vm *VM, metric
 		// 1. the lookup succeeded but found no data
 		// 2. the lookup hit a retriable error. This case happens very rarely.
 		logger.Printf("nonEmptySeriesList check(metric=%q, extraFilters=%v): request_error=%v, retrying (%d/%d)...",
-			metric, extraFilters, err, attempt, QueryMaxAttempts)
+			metric, extraFilters, err, attempt, maxAttempts)
 
-		time.Sleep(queryBackoffDuration)
+		time.Sleep(backoffDuration)
 	}
 
-	return nil, fmt.Errorf("WaitForMetricSeries(metric=%s, extraFilters=%v) failed: %s", metric, extraFilters, exhaustedRetriesSuffix)
-}
-
-type WaitForTraceOptions struct {
-	// Trailing time window to include in the query, measured from now.
-	Window time.Duration
-
-	// These are filter terms, for the filter field of:
-	// https://cloud.google.com/trace/docs/reference/v2/rpc/google.devtools.cloudtrace.v1#listtracesrequest
-	// WaitForTraces also adds a filter on the VM's instance ID automatically.
-	Filters []string
+	return nil, &ExhaustedRetriesError{
+		Op:       fmt.Sprintf("WaitForMetricSeries(metric=%s, extraFilters=%v)", metric, extraFilters),
+		Target:   metric,
+		Attempts: maxAttempts,
+	}
 }
 
-// Temporary overload for WaitForTrace.
-// TODO: Switch all callers to WaitForTrace and delete this.
-func WaitForTraceDeprecated(ctx context.Context, logger *log.Logger, vm *VM, window time.Duration) (*cloudtrace.Trace, error) {
-	return WaitForTrace(ctx, logger, vm, WaitForTraceOptions{Window: window})
+// pointTimestamps returns the sorted, deduplicated end times of all points in
+// the given time series.
+func pointTimestamps(tsList []*monitoringpb.TimeSeries) []time.Time {
+	var timestamps []time.Time
+	for _, series := range tsList {
+		for _, point := range series.GetPoints() {
+			timestamps = append(timestamps, point.GetInterval().GetEndTime().AsTime())
+		}
+	}
+	slices.SortFunc(timestamps, time.Time.Compare)
+	return slices.CompactFunc(timestamps, time.Time.Equal)
 }
 
-// WaitForTrace looks for any trace from the given VM in the backend and returns
-// it if it exists. An error is returned otherwise. This function will retry
-// "no data" errors a fixed number of times. This is useful because it takes
-// time for trace data to become visible after it has been uploaded.
-//
-// Only the ProjectId and TraceId fields are populated. To get other fields,
-// including spans, call traceClient.GetTrace with the TraceID returned from
-// this function.
-func WaitForTrace(ctx context.Context, logger *log.Logger, vm *VM, options WaitForTraceOptions) (*cloudtrace.Trace, error) {
-	for attempt := 1; attempt <= TraceQueryMaxAttempts; attempt++ {
-		it := lookupTrace(ctx, vm, options)
-		trace, err := firstTrace(it)
-		if trace != nil && err == nil {
-			return trace, nil
+// maxGapAcrossReload looks at the given sorted point timestamps and returns
+// the largest gap between consecutive points before reloadTime (the baseline
+// reporting interval) and the largest gap between consecutive points from the
+// last point before reloadTime onwards.
+func maxGapAcrossReload(timestamps []time.Time, reloadTime time.Time) (baseline time.Duration, gap time.Duration, err error) {
+	lastBefore := -1
+	for i, ts := range timestamps {
+		if !ts.After(reloadTime) {
+			lastBefore = i
 		}
-		if err != nil && !isRetriableLookupError(err) {
-			return nil, fmt.Errorf("WaitForTrace() failed: %v", err)
+	}
+	if lastBefore < 1 {
+		return 0, 0, fmt.Errorf("need at least 2 points before the reload at %v, got timestamps %v", reloadTime, timestamps)
+	}
+	if lastBefore == len(timestamps)-1 {
+		return 0, 0, fmt.Errorf("no points after the reload at %v, got timestamps %v", reloadTime, timestamps)
+	}
+	for i := 1; i < len(timestamps); i++ {
+		d := timestamps[i].Sub(timestamps[i-1])
+		if i <= lastBefore {
+			baseline = max(baseline, d)
+		} else {
+			gap = max(gap, d)
 		}
-		logger.Printf("firstTrace check(): empty, retrying (%d/%d)...",
-			attempt, TraceQueryMaxAttempts)
-		time.Sleep(time.Duration(traceQueryDerate) * queryBackoffDuration)
 	}
-	return nil, fmt.Errorf("WaitForTrace() failed: %s", exhaustedRetriesSuffix)
-}
-
-// IsExhaustedRetriesMetricError returns true if the given error is an
-// "exhausted retries" error returned from WaitForMetric.
-func IsExhaustedRetriesMetricError(err error) bool {
-	return err != nil && strings.HasSuffix(err.Error(), exhaustedRetriesSuffix)
+	return baseline, gap, nil
 }
 
-// AssertMetricMissing looks for data of a metric and returns success if
-// no data is found. To consider possible transient errors while querying
-// the backend we make queryMaxAttemptsMetricMissing query attempts.
-func AssertMetricMissing(ctx context.Context, logger *log.Logger, vm *VM, metric string, isPrometheus bool, window time.Duration) error {
-	descriptorNotFoundErrCount := 0
-	for attempt := 1; attempt <= queryMaxAttemptsMetricMissing; attempt++ {
-		it := lookupMetric(ctx, logger, vm, metric, window, nil, isPrometheus)
-		series, err := nonEmptySeriesList(logger, it, 1)
-		found := len(series) > 0
-		logger.Printf("nonEmptySeriesList check(metric=%q): err=%v, found=%v, attempt (%d/%d)",
-			metric, err, found, attempt, queryMaxAttemptsMetricMissing)
-
-		if err == nil {
-			if found {
-				return fmt.Errorf("AssertMetricMissing(metric=%q): %v failed: unexpectedly found data for metric", metric, err)
-			}
-			// Success
-			return nil
+// waitForPointTimestamps polls the given metric until it has at least
+// minPoints points after the given time, and returns the timestamps of all
+// points in the window.
+func waitForPointTimestamps(ctx context.Context, logger *log.Logger, vm *VM, metric string, window time.Duration, isPrometheus bool, after time.Time, minPoints int) ([]time.Time, error) {
+	for attempt := 1; attempt <= QueryMaxAttempts; attempt++ {
+		it := lookupMetric(ctx, logger, vm, metric, window+time.Since(after), nil, isPrometheus)
+		tsList, err := nonEmptySeriesList(logger, it, 1)
+		if err != nil && !isRetriableLookupError(err) {
+			return nil, err
 		}
-		if !isRetriableLookupError(err) {
-			return fmt.Errorf("AssertMetricMissing(metric=%q): %v", metric, err)
+		timestamps := pointTimestamps(tsList)
+		count := 0
+		for _, ts := range timestamps {
+			if ts.After(after) {
+				count++
+			}
 		}
-
-		// prometheus.googleapis.com/* domain metrics are created on first write, and may not be immediately queryable.
-		// The error doesn't always look the same, hopefully looking for Code() == NotFound will catch all variations.
-		myStatus, ok := status.FromError(err)
-		if ok && isPrometheus && myStatus.Code() == codes.NotFound {
-			descriptorNotFoundErrCount += 1
+		if count >= minPoints {
+			return timestamps, nil
 		}
+		logger.Printf("waitForPointTimestamps(metric=%q): found %d points after %v, want %d, retrying (%d/%d)...",
+			metric, count, after, minPoints, attempt, QueryMaxAttempts)
 		time.Sleep(queryBackoffDuration)
 	}
-	if !isPrometheus {
-		return fmt.Errorf("AssertMetricMissing(metric=%q): failed: no successful queries to the backend", metric)
+	return nil, &ExhaustedRetriesError{
+		Op:       fmt.Sprintf("waitForPointTimestamps(metric=%q)", metric),
+		Target:   metric,
+		Attempts: QueryMaxAttempts,
 	}
+}
 
-	if descriptorNotFoundErrCount != queryMaxAttemptsMetricMissing {
-		return fmt.Errorf("AssertMetricMissing(metric=%q): failed: atleast one query failed with something other than a NOT_FOUND error", metric)
+// AssertNoGapAcrossConfigReload checks that the given metric keeps being
+// reported at its usual interval while reloadFunc reloads the agent's config.
+//
+// It first waits for at least two points of the metric within the trailing
+// window to establish the baseline reporting interval, then calls reloadFunc,
+// then waits for two more points. An error is returned if any gap between
+// points from the reload onwards is more than twice the baseline interval.
+func AssertNoGapAcrossConfigReload(ctx context.Context, logger *log.Logger, vm *VM, metric string, reloadFunc func() error, window time.Duration, isPrometheus bool) error {
+	// Two points are needed before the reload to establish a baseline interval.
+	if _, err := waitForPointTimestamps(ctx, logger, vm, metric, window, isPrometheus, time.Now().Add(-window), 2); err != nil {
+		return fmt.Errorf("AssertNoGapAcrossConfigReload(metric=%q): before reload: %v", metric, err)
+	}
+	reloadTime := time.Now()
+	if err := reloadFunc(); err != nil {
+		return fmt.Errorf("AssertNoGapAcrossConfigReload(metric=%q): reload failed: %v", metric, err)
+	}
+	timestamps, err := waitForPointTimestamps(ctx, logger, vm, metric, window, isPrometheus, reloadTime, 2)
+	if err != nil {
+		return fmt.Errorf("AssertNoGapAcrossConfigReload(metric=%q): after reload: %v", metric, err)
+	}
+	baseline, gap, err := maxGapAcrossReload(timestamps, reloadTime)
+	if err != nil {
+		return fmt.Errorf("AssertNoGapAcrossConfigReload(metric=%q): %v", metric, err)
+	}
+	logger.Printf("AssertNoGapAcrossConfigReload(metric=%q): baseline interval %v, largest gap across reload %v", metric, baseline, gap)
+	if gap > 2*baseline {
+		return fmt.Errorf("AssertNoGapAcrossConfigReload(metric=%q): found a %v gap across the reload at %v, more than twice the baseline interval of %v", metric, gap, reloadTime, baseline)
 	}
-
-	// Success
 	return nil
 }
 
-// findMatchingLogs looks in the logging backend for logs matching the given query,
-// over the trailing time interval specified by the given window.
-// Returns all the matching log entries found, or an error if the lookup failed.
-func findMatchingLogs(ctx context.Context, logger *log.Logger, vm *VM, logNameRegex string, window time.Duration, query string) ([]*cloudlogging.Entry, error) {
-	start := time.Now().Add(-window)
+// TimeToFirstMetric waits for the given metric to have a point newer than
+// since, and returns the wall-clock time from since until that point was
+// observed in the backend. since is typically the time of an event that
+// should make the agent start reporting the metric, like installing the agent
+// or starting an application. window is how far back before since to look
+// for points.
+//
+// The result includes ingestion delay and is only as precise as the polling
+// interval, queryBackoffDuration.
+func TimeToFirstMetric(ctx context.Context, logger *log.Logger, vm *VM, metric string, since time.Time, window time.Duration, isPrometheus bool) (time.Duration, error) {
+	if _, err := waitForPointTimestamps(ctx, logger, vm, metric, window, isPrometheus, since, 1); err != nil {
+		return 0, fmt.Errorf("TimeToFirstMetric(metric=%q): %v", metric, err)
+	}
+	latency := time.Since(since)
+	logger.Printf("TimeToFirstMetric(metric=%q): first point after %v observed %v later", metric, since, latency)
+	return latency, nil
+}
 
-	t := start.Format(time.RFC3339)
-	filter := fmt.Sprintf(`logName=~"projects/%s/logs/%s" AND resource.labels.instance_id="%d" AND timestamp > "%s"`, vm.Project, logNameRegex, vm.ID, t)
-	if query != "" {
-		filter += fmt.Sprintf(` AND %s`, query)
+// pointValue returns the value of the given point as a float64. Only int64
+// and double values are supported; other types are returned as 0.
+func pointValue(point *monitoringpb.Point) float64 {
+	switch v := point.GetValue().GetValue().(type) {
+	case *monitoringpb.TypedValue_Int64Value:
+		return float64(v.Int64Value)
+	case *monitoringpb.TypedValue_DoubleValue:
+		return v.DoubleValue
 	}
-	logger.Println(filter)
+	return 0
+}
 
-	logClient, err := logClients.new(vm.Project)
-	if err != nil {
-		return nil, fmt.Errorf("findMatchingLogs() failed to obtain logClient for project %v: %v", vm.Project, err)
+// latestTotal returns the sum over all the given time series of each series'
+// most recent point value. The monitoring API returns points newest first.
+func latestTotal(tsList []*monitoringpb.TimeSeries) float64 {
+	var total float64
+	for _, series := range tsList {
+		if len(series.GetPoints()) > 0 {
+			total += pointValue(series.GetPoints()[0])
+		}
 	}
-	it := logClient.Entries(ctx, logadmin.Filter(filter))
+	return total
+}
 
-	var matchingLogs []*cloudlogging.Entry
-	// Loop through the iterator printing out each matching log entry.
-	// It is helpful for debugging to print out all matches into the logs.
-	for {
-		entry, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
+// agentCollectorService is the service that runs the agent's OpenTelemetry
+// Collector, which is the process that sheds load under overload.
+const agentCollectorService = "google-cloud-ops-agent-opentelemetry-collector"
+
+// serviceStatus describes the state of a service on a VM.
+type serviceStatus struct {
+	// The state as reported by the OS: systemd's ActiveState on Linux, such
+	// as "active" or "activating", or the Get-Service status on Windows, such
+	// as "Running" or "StartPending".
+	State   string
+	Running bool
+	// How many times the service has been restarted automatically. Always 0
+	// on Windows.
+	Restarts int
+}
+
+// getServiceStatus looks up the status of the given service on the given VM.
+var getServiceStatus = func(ctx context.Context, logger *log.Logger, vm *VM, service string) (serviceStatus, error) {
+	if IsWindows(vm.ImageSpec) {
+		output, err := RunRemotely(ctx, logger, vm, fmt.Sprintf("(Get-Service -Name %s).Status", powershellQuote(service)))
 		if err != nil {
-			return nil, err
+			return serviceStatus{}, err
 		}
-		logger.Printf("Found matching log entry: %v", entry)
-		matchingLogs = append(matchingLogs, entry)
+		state := strings.TrimSpace(output.Stdout)
+		return serviceStatus{State: state, Running: state == "Running"}, nil
 	}
-	return matchingLogs, nil
+	output, err := RunRemotely(ctx, logger, vm, fmt.Sprintf("systemctl show --property=ActiveState,NRestarts %s", service))
+	if err != nil {
+		return serviceStatus{}, err
+	}
+	var status serviceStatus
+	for _, line := range strings.Split(output.Stdout, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "ActiveState":
+			status.State = value
+			status.Running = value == "active"
+		case "NRestarts":
+			if status.Restarts, err = strconv.Atoi(value); err != nil {
+				return serviceStatus{}, fmt.Errorf("could not parse NRestarts from %q: %v", output.Stdout, err)
+			}
+		}
+	}
+	return status, nil
 }
 
-// WaitForLog looks in the logging backend for a log matching the given query,
-// over the trailing time interval specified by the given window.
-// Returns an error if the log could not be found after LogQueryMaxAttempts retries.
-func WaitForLog(ctx context.Context, logger *log.Logger, vm *VM, logNameRegex string, window time.Duration, query string) error {
-	_, err := QueryLog(ctx, logger, vm, logNameRegex, window, query, LogQueryMaxAttempts)
-	return err
-}
+// AssertAgentLoadShedding checks that the agent sheds load gracefully when
+// overloaded. dropMetric is a cumulative self-metric that counts dropped or
+// refused telemetry, for example
+// "agent.googleapis.com/agent/monitoring/point_count" with extraFilters
+// selecting failed responses, or an otelcol_processor_refused_* metric.
+// overloadFunc should send the agent more telemetry than it can export, e.g.
+// with GenerateSyntheticLoad.
+//
+// Returns an error unless dropMetric increases after calling overloadFunc,
+// and the agent's collector stays running without restarting throughout.
+func AssertAgentLoadShedding(ctx context.Context, logger *log.Logger, vm *VM, dropMetric string, extraFilters []string, overloadFunc func() error) error {
+	const window = 10 * time.Minute
+	statusBefore, err := getServiceStatus(ctx, logger, vm, agentCollectorService)
+	if err != nil {
+		return fmt.Errorf("AssertAgentLoadShedding(): could not check the agent's status: %v", err)
+	}
+	if !statusBefore.Running {
+		return fmt.Errorf("AssertAgentLoadShedding(): %v is not running before the overload", agentCollectorService)
+	}
 
-func shouldRetryHasMatchingLog(err error) bool {
-	// Logging API queries can hit quota, especially when multiple people are running tests.
-	return strings.Contains(err.Error(), "Quota") ||
-		// Rarely, a log query fails due to internal errors in the logging API.
-		strings.Contains(err.Error(), "Internal error encountered")
-}
+	// The drop counter may not exist yet if nothing has been dropped so far,
+	// in which case its baseline is 0.
+	tsList, err := nonEmptySeriesList(logger, lookupMetric(ctx, logger, vm, dropMetric, window, extraFilters, false), 1)
+	if err != nil && !isRetriableLookupError(err) {
+		return fmt.Errorf("AssertAgentLoadShedding(metric=%q): %v", dropMetric, err)
+	}
+	baseline := latestTotal(tsList)
+	logger.Printf("AssertAgentLoadShedding(metric=%q): baseline drop count is %v", dropMetric, baseline)
 
-// QueryLog looks in the logging backend for a log matching the given query,
-// over the trailing time interval specified by the given window.
-// Returns the first log entry found, or an error if the log could not be
-// found after some retries.
-func QueryLog(ctx context.Context, logger *log.Logger, vm *VM, logNameRegex string, window time.Duration, query string, maxAttempts int) (*cloudlogging.Entry, error) {
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		matchingLogs, err := findMatchingLogs(ctx, logger, vm, logNameRegex, window, query)
-		found := len(matchingLogs) > 0
-		if err == nil && found {
-			// Success.
-			return matchingLogs[0], nil
+	if err := overloadFunc(); err != nil {
+		return fmt.Errorf("AssertAgentLoadShedding(): overload failed: %v", err)
+	}
+
+	increased := false
+	for attempt := 1; attempt <= QueryMaxAttempts; attempt++ {
+		tsList, err := nonEmptySeriesList(logger, lookupMetric(ctx, logger, vm, dropMetric, window, extraFilters, false), 1)
+		if err != nil && !isRetriableLookupError(err) {
+			return fmt.Errorf("AssertAgentLoadShedding(metric=%q): %v", dropMetric, err)
 		}
-		logger.Printf("Query returned found=%t, matchingLogs=%v, err=%v, attempt=%d", found, matchingLogs, err, attempt)
-		if err != nil && !shouldRetryHasMatchingLog(err) {
-			// A non-retryable error.
-			return nil, fmt.Errorf("QueryLog() failed: %v", err)
+		if total := latestTotal(tsList); total > baseline {
+			logger.Printf("AssertAgentLoadShedding(metric=%q): drop count increased from %v to %v", dropMetric, baseline, total)
+			increased = true
+			break
+		}
+		logger.Printf("AssertAgentLoadShedding(metric=%q): drop count has not increased from %v, retrying (%d/%d)...",
+			dropMetric, baseline, attempt, QueryMaxAttempts)
+		time.Sleep(queryBackoffDuration)
+	}
+	if !increased {
+		return &ExhaustedRetriesError{
+			Op:       fmt.Sprintf("AssertAgentLoadShedding(metric=%q)", dropMetric),
+			Target:   dropMetric,
+			Attempts: QueryMaxAttempts,
+			Detail:   "drop count never increased",
 		}
-		// found was false, or we hit a retryable error.
-		time.Sleep(logQueryBackoffDuration)
 	}
-	return nil, fmt.Errorf("QueryLog() failed: %s not found, exhausted retries", logNameRegex)
-}
+
+	statusAfter, err := getServiceStatus(ctx, logger, vm, agentCollectorService)
+	if err != nil {
+		return fmt.Errorf("AssertAgentLoadShedding(): could not check the agent's status: %v", err)
+	}
+	if !statusAfter.Running {
+		return fmt.Errorf("AssertAgentLoadShedding(): %v stopped running during the overload", agentCollectorService)
+	}
+	if statusAfter.Restarts != statusBefore.Restarts {
+		return fmt.Errorf("AssertAgentLoadShedding(): %v restarted %d times during the overload", agentCollectorService, statusAfter.Restarts-statusBefore.Restarts)
+	}
+	return nil
+}
+
+// ProcessSample is the resource usage of one process at one point in time,
+// as returned by SampleProcessUsage.
+type ProcessSample struct {
+	// When the sample was taken.
+	Time time.Time
+	PID  int
+	// The process name: the executable's name, truncated to 15 characters on
+	// Linux (see /proc/[pid]/comm) and without ".exe" on Windows.
+	Name string
+	// CPU usage since the previous sample, as a percentage of one core.
+	CPUPercent float64
+	// Resident set size (working set on Windows), in bytes.
+	RSSBytes int64
+}
+
+// processStat is one process's line of output from takeProcessSnapshot.
+type processStat struct {
+	PID  int
+	Name string
+	// Cumulative CPU time, in units of processSnapshot.TicksPerSecond.
+	CPUTicks int64
+	RSSBytes int64
+}
+
+// processSnapshot is the parsed output of takeProcessSnapshot.
+type processSnapshot struct {
+	// A monotonic clock on the VM, in seconds.
+	Uptime         float64
+	TicksPerSecond float64
+	Processes      []processStat
+}
+
+// parseProcessSnapshot parses the output of the commands in
+// takeProcessSnapshot: a line with "<uptime> <ticks per second>", followed
+// by a "<pid> <cpu ticks> <rss bytes> <name>" line for each process.
+func parseProcessSnapshot(stdout string) (processSnapshot, error) {
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	header := strings.Fields(lines[0])
+	if len(header) != 2 {
+		return processSnapshot{}, fmt.Errorf("could not parse process snapshot header from %q", lines[0])
+	}
+	var snapshot processSnapshot
+	var err error
+	if snapshot.Uptime, err = strconv.ParseFloat(header[0], 64); err != nil {
+		return processSnapshot{}, fmt.Errorf("could not parse uptime from %q: %v", lines[0], err)
+	}
+	if snapshot.TicksPerSecond, err = strconv.ParseFloat(header[1], 64); err != nil || snapshot.TicksPerSecond <= 0 {
+		return processSnapshot{}, fmt.Errorf("could not parse ticks per second from %q", lines[0])
+	}
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+		fields := strings.SplitN(line, " ", 4)
+		if len(fields) != 4 {
+			return processSnapshot{}, fmt.Errorf("could not parse process from %q", line)
+		}
+		var p processStat
+		p.Name = fields[3]
+		if p.PID, err = strconv.Atoi(fields[0]); err != nil {
+			return processSnapshot{}, fmt.Errorf("could not parse PID from %q: %v", line, err)
+		}
+		if p.CPUTicks, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return processSnapshot{}, fmt.Errorf("could not parse CPU time from %q: %v", line, err)
+		}
+		if p.RSSBytes, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+			return processSnapshot{}, fmt.Errorf("could not parse memory usage from %q: %v", line, err)
+		}
+		snapshot.Processes = append(snapshot.Processes, p)
+	}
+	return snapshot, nil
+}
+
+// takeProcessSnapshot lists the cumulative CPU time and current memory usage
+// of every process on the VM.
+var takeProcessSnapshot = func(ctx context.Context, logger *log.Logger, vm *VM) (processSnapshot, error) {
+	var cmd string
+	if IsWindows(vm.ImageSpec) {
+		// Format with the invariant culture so that numbers never contain
+		// a decimal comma. TotalProcessorTime is null for processes that
+		// can't be inspected, which are skipped.
+		cmd = `$inv = [Globalization.CultureInfo]::InvariantCulture
+[string]::Format($inv, '{0} {1}', [Diagnostics.Stopwatch]::GetTimestamp() / [Diagnostics.Stopwatch]::Frequency, [TimeSpan]::TicksPerSecond)
+foreach ($p in Get-Process) {
+  if ($p.TotalProcessorTime -eq $null) { continue }
+  [string]::Format($inv, '{0} {1} {2} {3}', $p.Id, $p.TotalProcessorTime.Ticks, $p.WorkingSet64, $p.ProcessName)
+}`
+	} else {
+		// Read /proc directly because `ps -o %cpu` reports the average
+		// over each process's lifetime. The name in /proc/[pid]/stat is
+		// in parentheses and may contain spaces; utime, stime and rss are
+		// the 12th, 13th and 22nd fields after it.
+		cmd = `echo "$(cut -d' ' -f1 /proc/uptime) $(getconf CLK_TCK)"
+pagesize=$(getconf PAGESIZE)
+for d in /proc/[0-9]*; do
+  stat=$(cat "$d/stat" 2>/dev/null) || continue
+  name=${stat#*(}
+  name=${name%)*}
+  set -- ${stat##*) }
+  echo "${d#/proc/} $((${12} + ${13})) $((${22} * pagesize)) $name"
+done`
+	}
+	// Don't use RunRemotely's logger for the output, which lists every
+	// process on the VM.
+	output, err := RunRemotely(ctx, log.New(io.Discard, "", 0), vm, cmd)
+	if err != nil {
+		return processSnapshot{}, err
+	}
+	return parseProcessSnapshot(output.Stdout)
+}
+
+// processSamples returns a sample for each process in cur whose name matches
+// re, with its CPU usage computed since prev. A process that isn't in prev
+// started since then, so all of its CPU time counts.
+func processSamples(prev, cur processSnapshot, re *regexp.Regexp, now time.Time) []ProcessSample {
+	prevTicks := make(map[int]int64)
+	for _, p := range prev.Processes {
+		prevTicks[p.PID] = p.CPUTicks
+	}
+	elapsed := cur.Uptime - prev.Uptime
+	var samples []ProcessSample
+	for _, p := range cur.Processes {
+		if !re.MatchString(p.Name) {
+			continue
+		}
+		ticks := p.CPUTicks - prevTicks[p.PID]
+		if ticks < 0 {
+			// The PID was reused by a new process.
+			ticks = p.CPUTicks
+		}
+		var cpuPercent float64
+		if elapsed > 0 {
+			cpuPercent = float64(ticks) / cur.TicksPerSecond / elapsed * 100
+		}
+		samples = append(samples, ProcessSample{
+			Time:       now,
+			PID:        p.PID,
+			Name:       p.Name,
+			CPUPercent: cpuPercent,
+			RSSBytes:   p.RSSBytes,
+		})
+	}
+	return samples
+}
+
+// SampleProcessUsage measures the CPU and memory usage of every process on
+// the VM whose name matches processNameRegex, e.g. "^(fluent-bit|otelopscol)$",
+// every interval for the given duration. Each process gets its own sample
+// at each interval, so that usage of an agent made of several processes can
+// be checked per process or summed over the samples that share a Time.
+// Returns an error, along with the samples so far, if the usage cannot be
+// sampled or if no matching process is running at some interval.
+func SampleProcessUsage(ctx context.Context, logger *log.Logger, vm *VM, processNameRegex string, duration, interval time.Duration) ([]ProcessSample, error) {
+	re, err := regexp.Compile(processNameRegex)
+	if err != nil {
+		return nil, fmt.Errorf("SampleProcessUsage(): invalid process name regex %q: %v", processNameRegex, err)
+	}
+	prev, err := takeProcessSnapshot(ctx, logger, vm)
+	if err != nil {
+		return nil, fmt.Errorf("SampleProcessUsage(): could not list processes: %v", err)
+	}
+	deadline := time.Now().Add(duration)
+	var samples []ProcessSample
+	for n := 1; ; n++ {
+		select {
+		case <-ctx.Done():
+			return samples, fmt.Errorf("SampleProcessUsage(): %v", ctx.Err())
+		case <-time.After(interval):
+		}
+		cur, err := takeProcessSnapshot(ctx, logger, vm)
+		if err != nil {
+			return samples, fmt.Errorf("SampleProcessUsage(): could not list processes: %v", err)
+		}
+		batch := processSamples(prev, cur, re, time.Now())
+		if len(batch) == 0 {
+			return samples, fmt.Errorf("SampleProcessUsage(): no process matching %q was running at sample %d", processNameRegex, n)
+		}
+		for _, s := range batch {
+			logger.Printf("SampleProcessUsage(): sample %d: %v (pid %d): CPU %.1f%%, memory %d bytes", n, s.Name, s.PID, s.CPUPercent, s.RSSBytes)
+		}
+		samples = append(samples, batch...)
+		prev = cur
+		if !time.Now().Add(interval).Before(deadline) {
+			return samples, nil
+		}
+	}
+}
+
+// agentCollectorProcess matches the name of the agent's collector process,
+// as reported by takeProcessSnapshot.
+var agentCollectorProcess = regexp.MustCompile("^otelopscol$")
+
+// AssertAgentResourceBudget samples the CPU and memory usage of the agent's
+// collector every sampleInterval for the given duration, like
+// SampleProcessUsage. Returns an error as soon as a sample exceeds
+// maxCPUPercent (as a percentage of one core) or maxMemoryBytes, or if the
+// usage cannot be sampled.
+func AssertAgentResourceBudget(ctx context.Context, logger *log.Logger, vm *VM, maxCPUPercent, maxMemoryBytes float64, sampleInterval, duration time.Duration) error {
+	prev, err := takeProcessSnapshot(ctx, logger, vm)
+	if err != nil {
+		return fmt.Errorf("AssertAgentResourceBudget(): could not list processes: %v", err)
+	}
+	deadline := time.Now().Add(duration)
+	for sample := 1; ; sample++ {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("AssertAgentResourceBudget(): %v", ctx.Err())
+		case <-time.After(sampleInterval):
+		}
+		cur, err := takeProcessSnapshot(ctx, logger, vm)
+		if err != nil {
+			return fmt.Errorf("AssertAgentResourceBudget(): could not list processes: %v", err)
+		}
+		batch := processSamples(prev, cur, agentCollectorProcess, time.Now())
+		if len(batch) == 0 {
+			return fmt.Errorf("AssertAgentResourceBudget(): %v is not running at sample %d", agentCollectorService, sample)
+		}
+		var cpuPercent, memoryBytes float64
+		for _, s := range batch {
+			cpuPercent += s.CPUPercent
+			memoryBytes += float64(s.RSSBytes)
+		}
+		logger.Printf("AssertAgentResourceBudget(): sample %d: CPU %.1f%%, memory %.0f bytes", sample, cpuPercent, memoryBytes)
+		if cpuPercent > maxCPUPercent {
+			return fmt.Errorf("AssertAgentResourceBudget(): CPU usage of %.1f%% in sample %d exceeds the budget of %.1f%%", cpuPercent, sample, maxCPUPercent)
+		}
+		if memoryBytes > maxMemoryBytes {
+			return fmt.Errorf("AssertAgentResourceBudget(): memory usage of %.0f bytes in sample %d exceeds the budget of %.0f bytes", memoryBytes, sample, maxMemoryBytes)
+		}
+		prev = cur
+		if !time.Now().Add(sampleInterval).Before(deadline) {
+			return nil
+		}
+	}
+}
+
+// WaitForMetricValue waits for the given metric to show up in the backend
+// and returns its most recent value. If the metric has several time series,
+// the most recent values of all of them are summed. Only int64 and double
+// metrics are supported.
+func WaitForMetricValue(ctx context.Context, logger *log.Logger, vm *VM, metric string, window time.Duration, extraFilters []string, isPrometheus bool) (float64, error) {
+	tsList, err := WaitForMetricSeries(ctx, logger, vm, metric, window, extraFilters, isPrometheus, 1)
+	if err != nil {
+		return 0, err
+	}
+	value := latestTotal(tsList)
+	logger.Printf("WaitForMetricValue(metric=%q, vm=%v): value=%v", metric, vm.Name, value)
+	return value, nil
+}
+
+// AssertMetricResourceLabels waits for the given metric from the given VM,
+// like WaitForMetricSeries, and then checks that every series found has a
+// monitored resource with all of the given labels and values, e.g.
+// {"zone": vm.Zone}. Labels that aren't in want are ignored. This catches
+// regressions in how metrics are mapped to monitored resources, which checks
+// on metric values alone miss.
+func AssertMetricResourceLabels(ctx context.Context, logger *log.Logger, vm *VM, metric string, window time.Duration, isPrometheus bool, want map[string]string) error {
+	tsList, err := WaitForMetricSeries(ctx, logger, vm, metric, window, nil, isPrometheus, 1)
+	if err != nil {
+		return err
+	}
+	var mismatches []string
+	for _, series := range tsList {
+		got := series.GetResource().GetLabels()
+		for _, k := range slices.Sorted(maps.Keys(want)) {
+			if v, ok := got[k]; !ok {
+				mismatches = append(mismatches, fmt.Sprintf("resource of type %q has no label %q, want %q", series.GetResource().GetType(), k, want[k]))
+			} else if v != want[k] {
+				mismatches = append(mismatches, fmt.Sprintf("resource of type %q has label %s=%q, want %q", series.GetResource().GetType(), k, v, want[k]))
+			}
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("AssertMetricResourceLabels(metric=%q, vm=%v): %s", metric, vm.Name, strings.Join(mismatches, "; "))
+	}
+	logger.Printf("AssertMetricResourceLabels(metric=%q, vm=%v): all %d series have resource labels %v", metric, vm.Name, len(tsList), want)
+	return nil
+}
+
+// generateSyntheticLoad starts a synthetic load generator on a VM.
+var generateSyntheticLoad = GenerateSyntheticLoad
+
+// AgentVersionComparisonOptions configures AssertMetricAgreesAcrossAgentVersions.
+type AgentVersionComparisonOptions struct {
+	// Required. The metric to compare between the two VMs.
+	Metric string
+	// Optional. Extra filters to narrow down which series of Metric to compare.
+	ExtraFilters []string
+	// Optional. Set if Metric is a Prometheus metric.
+	IsPrometheus bool
+	// Required. The rates of synthetic logs and metrics to generate on each
+	// VM. See GenerateSyntheticLoad.
+	LogsPerSec, MetricsPerSec int
+	// Required. How long to generate synthetic load for before comparing.
+	LoadDuration time.Duration
+	// Optional. The largest allowed difference between the two values,
+	// relative to the larger of the two. For example, 0.05 allows the values
+	// to differ by up to 5%. Defaults to 0, i.e. the values must be equal.
+	Tolerance float64
+}
+
+// metricValuesAgree returns whether the two given values are within the
+// given relative tolerance of each other.
+func metricValuesAgree(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// AssertMetricAgreesAcrossAgentVersions runs the same synthetic workload on
+// two VMs running different agent versions, for example the released agent
+// on oldVM and a candidate build on newVM, then checks that both agents
+// report the same value for the given metric, within the given tolerance.
+//
+// Only Linux VMs are supported, because the workload comes from
+// GenerateSyntheticLoad.
+func AssertMetricAgreesAcrossAgentVersions(ctx context.Context, logger *log.Logger, oldVM, newVM *VM, options AgentVersionComparisonOptions) (err error) {
+	// Start the load on both VMs back to back so that the workloads overlap
+	// as much as possible.
+	for _, vm := range []*VM{oldVM, newVM} {
+		cleanup, loadErr := generateSyntheticLoad(ctx, logger, vm, options.LogsPerSec, options.MetricsPerSec, options.LoadDuration)
+		if loadErr != nil {
+			return fmt.Errorf("AssertMetricAgreesAcrossAgentVersions(): could not start load on %v: %v", vm.Name, loadErr)
+		}
+		defer func() {
+			if cleanupErr := cleanup(); cleanupErr != nil && err == nil {
+				err = fmt.Errorf("AssertMetricAgreesAcrossAgentVersions(): could not stop load on %v: %v", vm.Name, cleanupErr)
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(options.LoadDuration):
+	}
+
+	// Look back far enough to cover the whole workload.
+	window := options.LoadDuration + 10*time.Minute
+	oldValue, err := WaitForMetricValue(ctx, logger, oldVM, options.Metric, window, options.ExtraFilters, options.IsPrometheus)
+	if err != nil {
+		return fmt.Errorf("AssertMetricAgreesAcrossAgentVersions(metric=%q): on %v: %v", options.Metric, oldVM.Name, err)
+	}
+	newValue, err := WaitForMetricValue(ctx, logger, newVM, options.Metric, window, options.ExtraFilters, options.IsPrometheus)
+	if err != nil {
+		return fmt.Errorf("AssertMetricAgreesAcrossAgentVersions(metric=%q): on %v: %v", options.Metric, newVM.Name, err)
+	}
+	logger.Printf("AssertMetricAgreesAcrossAgentVersions(metric=%q): %v reported %v, %v reported %v",
+		options.Metric, oldVM.Name, oldValue, newVM.Name, newValue)
+	if !metricValuesAgree(oldValue, newValue, options.Tolerance) {
+		return fmt.Errorf("AssertMetricAgreesAcrossAgentVersions(metric=%q): %v reported %v but %v reported %v, which differ by more than the tolerance of %v%%",
+			options.Metric, oldVM.Name, oldValue, newVM.Name, newValue, options.Tolerance*100)
+	}
+	return nil
+}
+
+// listMIGInstances lists the members of a Managed Instance Group.
+var listMIGInstances = ListManagedInstanceGroupInstances
+
+// WaitForMetricFromAllMIGInstances looks for the given metric from every
+// instance in the given VM's Managed Instance Group, and returns one matching
+// time series per instance, keyed by instance ID. An error is returned if any
+// instance has still not reported the metric after QueryMaxAttempts attempts.
+func WaitForMetricFromAllMIGInstances(ctx context.Context, logger *log.Logger, migVM *ManagedInstanceGroupVM, metric string, window time.Duration, isPrometheus bool) (map[int64]*monitoringpb.TimeSeries, error) {
+	instances, err := listMIGInstances(ctx, logger, migVM)
+	if err != nil {
+		return nil, fmt.Errorf("WaitForMetricFromAllMIGInstances(metric=%q): %v", metric, err)
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("WaitForMetricFromAllMIGInstances(metric=%q): no instances found in %v", metric, migVM.ManagedInstanceGroupName())
+	}
+
+	found := make(map[int64]*monitoringpb.TimeSeries)
+	for attempt := 1; attempt <= QueryMaxAttempts; attempt++ {
+		var missing []ManagedInstance
+		for _, instance := range instances {
+			if _, ok := found[instance.ID]; !ok {
+				missing = append(missing, instance)
+			}
+		}
+
+		// Query each remaining instance concurrently.
+		series := make([]*monitoringpb.TimeSeries, len(missing))
+		errs := make([]error, len(missing))
+		var wg sync.WaitGroup
+		for i, instance := range missing {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				instanceVM := &VM{Name: instance.Name, Project: migVM.Project, ID: instance.ID}
+				it := lookupMetric(ctx, logger, instanceVM, metric, window, nil, isPrometheus)
+				tsList, err := nonEmptySeriesList(logger, it, 1)
+				if len(tsList) > 0 {
+					series[i] = tsList[0]
+				}
+				errs[i] = err
+			}()
+		}
+		wg.Wait()
+
+		var stillMissing []string
+		for i, instance := range missing {
+			if errs[i] != nil && !isRetriableLookupError(errs[i]) {
+				return nil, fmt.Errorf("WaitForMetricFromAllMIGInstances(metric=%q, instance=%v): %v", metric, instance.Name, errs[i])
+			}
+			if series[i] == nil {
+				stillMissing = append(stillMissing, instance.Name)
+				continue
+			}
+			found[instance.ID] = series[i]
+		}
+		if len(stillMissing) == 0 {
+			// Success.
+			logger.Printf("Successfully found metric=%q for all %d instances", metric, len(instances))
+			return found, nil
+		}
+		logger.Printf("WaitForMetricFromAllMIGInstances(metric=%q): missing data from instances %v, retrying (%d/%d)...",
+			metric, stillMissing, attempt, QueryMaxAttempts)
+
+		time.Sleep(queryBackoffDuration)
+	}
+
+	return nil, &ExhaustedRetriesError{
+		Op:       fmt.Sprintf("WaitForMetricFromAllMIGInstances(metric=%s)", metric),
+		Target:   metric,
+		Attempts: QueryMaxAttempts,
+	}
+}
+
+type WaitForTraceOptions struct {
+	// Trailing time window to include in the query, measured from now.
+	Window time.Duration
+
+	// These are filter terms, for the filter field of:
+	// https://cloud.google.com/trace/docs/reference/v2/rpc/google.devtools.cloudtrace.v1#listtracesrequest
+	// WaitForTraces also adds a filter on the VM's instance ID automatically.
+	Filters []string
+
+	// The minimum number of spans to wait for the trace to have. If set,
+	// WaitForTrace returns the trace with its spans populated, like
+	// WaitForTraceWithSpans. If 0, WaitForTrace returns as soon as any trace
+	// is found, and WaitForTraceWithSpans waits for at least one span.
+	MinSpans int
+}
+
+// Temporary overload for WaitForTrace.
+// TODO: Switch all callers to WaitForTrace and delete this.
+func WaitForTraceDeprecated(ctx context.Context, logger *log.Logger, vm *VM, window time.Duration) (*cloudtrace.Trace, error) {
+	return WaitForTrace(ctx, logger, vm, WaitForTraceOptions{Window: window})
+}
+
+// WaitForTrace looks for any trace from the given VM in the backend and returns
+// it if it exists. An error is returned otherwise. This function will retry
+// "no data" errors a fixed number of times. This is useful because it takes
+// time for trace data to become visible after it has been uploaded.
+//
+// Only the ProjectId and TraceId fields are populated, unless
+// options.MinSpans is set, in which case this waits for the trace to have that
+// many spans and returns it fully populated, like WaitForTraceWithSpans.
+func WaitForTrace(ctx context.Context, logger *log.Logger, vm *VM, options WaitForTraceOptions) (*cloudtrace.Trace, error) {
+	for attempt := 1; attempt <= TraceQueryMaxAttempts; attempt++ {
+		trace, err := lookupFirstTrace(ctx, vm, options)
+		if trace != nil && err == nil {
+			if options.MinSpans > 0 {
+				return waitForTraceSpans(ctx, logger, trace.ProjectId, trace.TraceId, options.MinSpans)
+			}
+			return trace, nil
+		}
+		if err != nil && !isRetriableLookupError(err) {
+			return nil, fmt.Errorf("WaitForTrace() failed: %v", err)
+		}
+		logger.Printf("firstTrace check(): empty, retrying (%d/%d)...",
+			attempt, TraceQueryMaxAttempts)
+		time.Sleep(time.Duration(traceQueryDerate) * queryBackoffDuration)
+	}
+	return nil, &ExhaustedRetriesError{
+		Op:       "WaitForTrace()",
+		Target:   "trace from VM " + vm.Name,
+		Attempts: TraceQueryMaxAttempts,
+	}
+}
+
+// WaitForTraceByID looks for the trace with the given ID in the given project
+// and returns it, including its spans, once it exists. Like WaitForTrace, this
+// function retries "not found" errors a fixed number of times.
+func WaitForTraceByID(ctx context.Context, logger *log.Logger, project, traceID string) (*cloudtrace.Trace, error) {
+	req := &cloudtrace.GetTraceRequest{ProjectId: project, TraceId: traceID}
+	var trace *cloudtrace.Trace
+	err := Poll(ctx, logger, PollConfig{
+		Description: fmt.Sprintf("WaitForTraceByID(traceID=%q)", traceID),
+		MaxAttempts: TraceQueryMaxAttempts,
+		Backoff:     time.Duration(traceQueryDerate) * queryBackoffDuration,
+		IsRetriable: isRetriableLookupError,
+	}, func() (bool, error) {
+		var err error
+		trace, err = getTrace(ctx, req)
+		return err == nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+// WaitForTraceWithSpans is like WaitForTrace, but returns the trace with its
+// spans populated. Once a trace has been found, it calls GetTrace until the
+// trace has at least options.MinSpans spans, because the spans of a trace
+// can become visible some time after the trace itself.
+//
+// Because Cloud Trace quota is so low, both steps are retried at most
+// TraceQueryMaxAttempts times, traceQueryDerate backoff durations apart, so
+// this function takes at most about twice as long as WaitForTrace.
+func WaitForTraceWithSpans(ctx context.Context, logger *log.Logger, vm *VM, options WaitForTraceOptions) (*cloudtrace.Trace, error) {
+	options.MinSpans = max(options.MinSpans, 1)
+	return WaitForTrace(ctx, logger, vm, options)
+}
+
+// waitForTraceSpans calls GetTrace for the given trace until it has at least
+// minSpans spans.
+func waitForTraceSpans(ctx context.Context, logger *log.Logger, project, traceID string, minSpans int) (*cloudtrace.Trace, error) {
+	req := &cloudtrace.GetTraceRequest{ProjectId: project, TraceId: traceID}
+	var trace *cloudtrace.Trace
+	err := Poll(ctx, logger, PollConfig{
+		Description: fmt.Sprintf("WaitForTrace(traceID=%q, minSpans=%d)", traceID, minSpans),
+		MaxAttempts: TraceQueryMaxAttempts,
+		Backoff:     time.Duration(traceQueryDerate) * queryBackoffDuration,
+		IsRetriable: isRetriableLookupError,
+	}, func() (bool, error) {
+		var err error
+		trace, err = getTrace(ctx, req)
+		return err == nil && len(trace.GetSpans()) >= minSpans, err
+	})
+	var exhausted *ExhaustedRetriesError
+	if errors.As(err, &exhausted) {
+		exhausted.Target = traceID
+		exhausted.Detail = fmt.Sprintf("failed to find %d spans", minSpans)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+// ExhaustedRetriesError is returned by the WaitFor* functions, QueryLog and
+// Poll when what they wait for never shows up before they run out of
+// attempts, as opposed to when querying the backend fails. Its message ends
+// in exhaustedRetriesSuffix.
+type ExhaustedRetriesError struct {
+	// Op is the function that gave up, with its arguments, e.g.
+	// "WaitForMetricSeries(metric=foo, extraFilters=[])".
+	Op string
+	// Target names what was waited for, such as a metric type, a log name
+	// pattern or a trace ID.
+	Target string
+	// Attempts is how many attempts were made.
+	Attempts int
+	// Detail optionally says what was still missing after the last attempt.
+	Detail string
+	// LastErr is the retriable error returned by the last attempt, if any.
+	LastErr error
+}
+
+func (e *ExhaustedRetriesError) Error() string {
+	msg := e.Op + " failed: "
+	if e.Detail != "" {
+		msg += e.Detail + ", "
+	}
+	if e.LastErr != nil {
+		msg += fmt.Sprintf("last err=%v, ", e.LastErr)
+	}
+	return msg + exhaustedRetriesSuffix
+}
+
+func (e *ExhaustedRetriesError) Unwrap() error {
+	return e.LastErr
+}
+
+// IsExhaustedRetriesMetricError returns true if the given error is an
+// *ExhaustedRetriesError, e.g. one returned from WaitForMetric.
+//
+// Deprecated: errors whose message merely ends in "exhausted retries" are
+// also accepted for now, for callers that wrap errors with %v instead of %w.
+// This fallback will be removed; use errors.As with *ExhaustedRetriesError.
+func IsExhaustedRetriesMetricError(err error) bool {
+	var exhausted *ExhaustedRetriesError
+	if errors.As(err, &exhausted) {
+		return true
+	}
+	return err != nil && strings.HasSuffix(err.Error(), exhaustedRetriesSuffix)
+}
+
+// PollConfig configures Poll.
+type PollConfig struct {
+	// Description names what is being waited for, e.g. "WaitForFoo(id=1)".
+	// It prefixes Poll's log lines and errors.
+	Description string
+	// MaxAttempts is the maximum number of times to call the predicate.
+	MaxAttempts int
+	// Backoff is how long to wait between attempts.
+	Backoff time.Duration
+	// IsRetriable reports whether an error returned by the predicate should
+	// be retried. If nil, all errors are retried.
+	IsRetriable func(error) bool
+}
+
+// Poll calls fn until it reports that it is done, up to cfg.MaxAttempts times
+// and cfg.Backoff apart, logging each unsuccessful attempt. It stops early
+// with an error if fn returns an error that cfg.IsRetriable rejects, or if ctx
+// is done. If all attempts are used up, it returns an *ExhaustedRetriesError.
+//
+// New code that needs to wait for something should use this rather than
+// writing its own retry loop.
+func Poll(ctx context.Context, logger *log.Logger, cfg PollConfig, fn func() (done bool, err error)) error {
+	var lastErr error
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		done, err := fn()
+		if err == nil && done {
+			return nil
+		}
+		if err != nil && cfg.IsRetriable != nil && !cfg.IsRetriable(err) {
+			return fmt.Errorf("%s failed: %v", cfg.Description, err)
+		}
+		lastErr = err
+		logger.Printf("%s: not done yet, err=%v, retrying (%d/%d)...",
+			cfg.Description, err, attempt, cfg.MaxAttempts)
+		if attempt == cfg.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s failed: %v (last err=%v)", cfg.Description, ctx.Err(), lastErr)
+		case <-time.After(cfg.Backoff):
+		}
+	}
+	return &ExhaustedRetriesError{
+		Op:       cfg.Description,
+		Attempts: cfg.MaxAttempts,
+		LastErr:  lastErr,
+	}
+}
+
+// AssertMetricMissing looks for data of a metric and returns success if
+// no data is found. To consider possible transient errors while querying
+// the backend we make queryMaxAttemptsMetricMissing query attempts.
+func AssertMetricMissing(ctx context.Context, logger *log.Logger, vm *VM, metric string, isPrometheus bool, window time.Duration) error {
+	descriptorNotFoundErrCount := 0
+	for attempt := 1; attempt <= queryMaxAttemptsMetricMissing; attempt++ {
+		it := lookupMetric(ctx, logger, vm, metric, window, nil, isPrometheus)
+		series, err := nonEmptySeriesList(logger, it, 1)
+		found := len(series) > 0
+		logger.Printf("nonEmptySeriesList check(metric=%q): err=%v, found=%v, attempt (%d/%d)",
+			metric, err, found, attempt, queryMaxAttemptsMetricMissing)
+
+		if err == nil {
+			if found {
+				return fmt.Errorf("AssertMetricMissing(metric=%q): %v failed: unexpectedly found data for metric", metric, err)
+			}
+			// Success
+			return nil
+		}
+		if !isRetriableLookupError(err) {
+			return fmt.Errorf("AssertMetricMissing(metric=%q): %v", metric, err)
+		}
+
+		// prometheus.googleapis.com/* domain metrics are created on first write, and may not be immediately queryable.
+		// The error doesn't always look the same, hopefully looking for Code() == NotFound will catch all variations.
+		myStatus, ok := status.FromError(err)
+		if ok && isPrometheus && myStatus.Code() == codes.NotFound {
+			descriptorNotFoundErrCount += 1
+		}
+		time.Sleep(queryBackoffDuration)
+	}
+	if !isPrometheus {
+		return fmt.Errorf("AssertMetricMissing(metric=%q): failed: no successful queries to the backend", metric)
+	}
+
+	if descriptorNotFoundErrCount != queryMaxAttemptsMetricMissing {
+		return fmt.Errorf("AssertMetricMissing(metric=%q): failed: atleast one query failed with something other than a NOT_FOUND error", metric)
+	}
+
+	// Success
+	return nil
+}
+
+// WaitForMetricGone polls the given metric until its newest point is older
+// than freshness, i.e. until the VM has clearly stopped reporting it, e.g.
+// after the agent is stopped. Unlike AssertMetricMissing, which checks once
+// that there is no data at all in a window, this tolerates points from before
+// the collection stopped. freshness should be comfortably longer than the
+// metric's reporting interval plus ingestion delay, so that a metric that is
+// still being collected is never mistaken for a stopped one.
+func WaitForMetricGone(ctx context.Context, logger *log.Logger, vm *VM, metric string, freshness time.Duration, isPrometheus bool) error {
+	return Poll(ctx, logger, PollConfig{
+		Description: fmt.Sprintf("WaitForMetricGone(metric=%q)", metric),
+		MaxAttempts: QueryMaxAttempts,
+		Backoff:     queryBackoffDuration,
+		IsRetriable: isRetriableLookupError,
+	}, func() (bool, error) {
+		// Look back further than freshness so that the age of the newest
+		// point can be logged.
+		it := lookupMetric(ctx, logger, vm, metric, 2*freshness, nil, isPrometheus)
+		tsList, err := nonEmptySeriesList(logger, it, 1)
+		if err != nil {
+			return false, err
+		}
+		timestamps := pointTimestamps(tsList)
+		if len(timestamps) == 0 {
+			logger.Printf("WaitForMetricGone(metric=%q): no points in the last %v", metric, 2*freshness)
+			return true, nil
+		}
+		newest := timestamps[len(timestamps)-1]
+		age := time.Since(newest)
+		logger.Printf("WaitForMetricGone(metric=%q): newest point at %v is %v old", metric, newest, age)
+		return age > freshness, nil
+	})
+}
+
+// vmMetricTypePrefixes are the metric domains that ListVMMetricTypes scans.
+// They are the domains the agent writes metrics from the VM itself to;
+// prometheus.googleapis.com metrics are written to a different resource type.
+var vmMetricTypePrefixes = []string{
+	"agent.googleapis.com/",
+	"workload.googleapis.com/",
+}
+
+// listMetricTypes lists the types of the metric descriptors in the given
+// project that start with the given prefix.
+var listMetricTypes = func(ctx context.Context, project, prefix string) ([]string, error) {
+	it := monClient.ListMetricDescriptors(ctx, &monitoringpb.ListMetricDescriptorsRequest{
+		Name:   "projects/" + project,
+		Filter: fmt.Sprintf("metric.type = starts_with(%q)", prefix),
+	})
+	var types []string
+	for {
+		descriptor, err := it.Next()
+		if err == iterator.Done {
+			return types, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, descriptor.GetType())
+	}
+}
+
+// ListVMMetricTypes returns the sorted types of all agent.googleapis.com and
+// workload.googleapis.com metrics that the given VM has reported within the
+// trailing window.
+//
+// There is no way to list the metric types of a single VM directly, so this
+// queries every matching metric descriptor in the VM's project once. That is
+// a few hundred queries, so use it sparingly.
+func ListVMMetricTypes(ctx context.Context, logger *log.Logger, vm *VM, window time.Duration) ([]string, error) {
+	var found []string
+	for _, prefix := range vmMetricTypePrefixes {
+		types, err := listMetricTypes(ctx, vm.Project, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("ListVMMetricTypes(): could not list %s metric descriptors: %v", prefix, err)
+		}
+		for _, metric := range types {
+			series, err := nonEmptySeriesList(logger, lookupMetric(ctx, logger, vm, metric, window, nil, false), 1)
+			if err != nil && !isRetriableLookupError(err) {
+				return nil, fmt.Errorf("ListVMMetricTypes(metric=%q): %v", metric, err)
+			}
+			if len(series) > 0 {
+				found = append(found, metric)
+			}
+		}
+	}
+	slices.Sort(found)
+	return found, nil
+}
+
+// AssertOnlyExpectedMetrics checks that every metric type that the given VM
+// has reported within the trailing window, as found by ListVMMetricTypes, is
+// in the allowed list. Returns an error naming the unexpected metric types
+// otherwise. Allowed metric types that are absent are not an error.
+func AssertOnlyExpectedMetrics(ctx context.Context, logger *log.Logger, vm *VM, allowed []string, window time.Duration) error {
+	types, err := ListVMMetricTypes(ctx, logger, vm, window)
+	if err != nil {
+		return fmt.Errorf("AssertOnlyExpectedMetrics(): %v", err)
+	}
+	var unexpected []string
+	for _, metric := range types {
+		if !slices.Contains(allowed, metric) {
+			unexpected = append(unexpected, metric)
+		}
+	}
+	if len(unexpected) > 0 {
+		return fmt.Errorf("AssertOnlyExpectedMetrics(): found %d unexpected metric types: %v", len(unexpected), unexpected)
+	}
+	return nil
+}
+
+// getMetricDescriptor fetches the descriptor of the given metric type in the
+// given project.
+var getMetricDescriptor = func(ctx context.Context, project, metric string) (*metricpb.MetricDescriptor, error) {
+	return monClient.GetMetricDescriptor(ctx, &monitoringpb.GetMetricDescriptorRequest{
+		Name: fmt.Sprintf("projects/%s/metricDescriptors/%s", project, metric),
+	})
+}
+
+// GetMetricDescriptor returns the descriptor of the given metric type in the
+// VM's project. Descriptors of workload.googleapis.com metrics are created
+// when the metric is first written, so this retries while the descriptor
+// can't be found.
+func GetMetricDescriptor(ctx context.Context, logger *log.Logger, vm *VM, metric string) (*metricpb.MetricDescriptor, error) {
+	for attempt := 1; attempt <= QueryMaxAttempts; attempt++ {
+		descriptor, err := getMetricDescriptor(ctx, vm.Project, metric)
+		if err == nil {
+			return descriptor, nil
+		}
+		if !isRetriableLookupError(err) {
+			return nil, fmt.Errorf("GetMetricDescriptor(metric=%q): %v", metric, err)
+		}
+		logger.Printf("GetMetricDescriptor(metric=%q): request_error=%v, retrying (%d/%d)...",
+			metric, err, attempt, QueryMaxAttempts)
+
+		time.Sleep(queryBackoffDuration)
+	}
+	return nil, &ExhaustedRetriesError{
+		Op:       fmt.Sprintf("GetMetricDescriptor(metric=%q)", metric),
+		Target:   metric,
+		Attempts: QueryMaxAttempts,
+	}
+}
+
+// AssertMetricDescriptor checks that the descriptor of the given metric type,
+// as returned by GetMetricDescriptor, has the given kind and unit. This
+// catches receivers changing e.g. a gauge to a cumulative metric, which the
+// data-based assertions don't notice.
+func AssertMetricDescriptor(ctx context.Context, logger *log.Logger, vm *VM, metric string, wantKind metricpb.MetricDescriptor_MetricKind, wantUnit string) error {
+	descriptor, err := GetMetricDescriptor(ctx, logger, vm, metric)
+	if err != nil {
+		return fmt.Errorf("AssertMetricDescriptor(): %v", err)
+	}
+	var mismatches []string
+	if got := descriptor.GetMetricKind(); got != wantKind {
+		mismatches = append(mismatches, fmt.Sprintf("kind is %v, want %v", got, wantKind))
+	}
+	if got := descriptor.GetUnit(); got != wantUnit {
+		mismatches = append(mismatches, fmt.Sprintf("unit is %q, want %q", got, wantUnit))
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("AssertMetricDescriptor(metric=%q): %s", metric, strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+// deleteMetricDescriptor deletes the descriptor of the given metric type in
+// the given project.
+var deleteMetricDescriptor = func(ctx context.Context, project, metric string) error {
+	return monClient.DeleteMetricDescriptor(ctx, &monitoringpb.DeleteMetricDescriptorRequest{
+		Name: fmt.Sprintf("projects/%s/metricDescriptors/%s", project, metric),
+	})
+}
+
+// DeleteMetricDescriptor deletes the descriptor of the given metric type in
+// the VM's project, along with all of its data. This resets the state left
+// behind by previous test runs, e.g. before AssertMetricMissing. A descriptor
+// that does not exist is not an error. Only descriptors of user-defined
+// metrics, such as workload.googleapis.com metrics, can be deleted.
+func DeleteMetricDescriptor(ctx context.Context, vm *VM, metricType string) error {
+	if err := deleteMetricDescriptor(ctx, vm.Project, metricType); err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("DeleteMetricDescriptor(metric=%q): %v", metricType, err)
+	}
+	return nil
+}
+
+// DeleteMetricDescriptorsMatching deletes the descriptors of all metric types
+// in the given project that start with the given prefix, like
+// DeleteMetricDescriptor. The prefix must not be empty, to avoid deleting
+// every user-defined metric in the project by accident.
+func DeleteMetricDescriptorsMatching(ctx context.Context, project, prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("DeleteMetricDescriptorsMatching(): prefix must not be empty")
+	}
+	types, err := listMetricTypes(ctx, project, prefix)
+	if err != nil {
+		return fmt.Errorf("DeleteMetricDescriptorsMatching(prefix=%q): could not list metric descriptors: %v", prefix, err)
+	}
+	var errs error
+	for _, metric := range types {
+		if err := deleteMetricDescriptor(ctx, project, metric); err != nil && status.Code(err) != codes.NotFound {
+			errs = multierr.Append(errs, fmt.Errorf("metric %q: %v", metric, err))
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("DeleteMetricDescriptorsMatching(prefix=%q): %w", prefix, errs)
+	}
+	return nil
+}
+
+// findMatchingLogs looks in the logging backend for logs matching the given query,
+// over the trailing time interval specified by the given window.
+// Returns all the matching log entries found, or an error if the lookup failed.
+func findMatchingLogs(ctx context.Context, logger *log.Logger, vm *VM, logNameRegex string, window time.Duration, query string) ([]*cloudlogging.Entry, error) {
+	start := time.Now().Add(-window)
+
+	t := start.Format(time.RFC3339)
+	filter := fmt.Sprintf(`logName=~"projects/%s/logs/%s" AND resource.labels.instance_id="%d" AND timestamp > "%s"`, vm.Project, logNameRegex, vm.ID, t)
+	if query != "" {
+		filter += fmt.Sprintf(` AND %s`, query)
+	}
+	logger.Println(filter)
+
+	logClient, err := logClients.new(vm.Project)
+	if err != nil {
+		return nil, fmt.Errorf("findMatchingLogs() failed to obtain logClient for project %v: %v", vm.Project, err)
+	}
+	it := logClient.Entries(ctx, logadmin.Filter(filter))
+
+	var matchingLogs []*cloudlogging.Entry
+	// Loop through the iterator printing out each matching log entry.
+	// It is helpful for debugging to print out all matches into the logs.
+	for {
+		entry, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		logger.Printf("Found matching log entry: %v", entry)
+		matchingLogs = append(matchingLogs, entry)
+	}
+	return matchingLogs, nil
+}
+
+// WaitForLog looks in the logging backend for a log matching the given query,
+// over the trailing time interval specified by the given window.
+// Returns an error if the log could not be found after LogQueryMaxAttempts retries.
+func WaitForLog(ctx context.Context, logger *log.Logger, vm *VM, logNameRegex string, window time.Duration, query string) error {
+	_, err := QueryLog(ctx, logger, vm, logNameRegex, window, query, LogQueryMaxAttempts)
+	return err
+}
+
+func shouldRetryHasMatchingLog(err error) bool {
+	// Logging API queries can hit quota, especially when multiple people are running tests.
+	return strings.Contains(err.Error(), "Quota") ||
+		// Rarely, a log query fails due to internal errors in the logging API.
+		strings.Contains(err.Error(), "Internal error encountered")
+}
+
+// QueryLog looks in the logging backend for a log matching the given query,
+// over the trailing time interval specified by the given window.
+// Returns the first log entry found, or an error if the log could not be
+// found after some retries.
+func QueryLog(ctx context.Context, logger *log.Logger, vm *VM, logNameRegex string, window time.Duration, query string, maxAttempts int) (*cloudlogging.Entry, error) {
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		matchingLogs, err := findMatchingLogs(ctx, logger, vm, logNameRegex, window, query)
+		found := len(matchingLogs) > 0
+		if err == nil && found {
+			// Success.
+			return matchingLogs[0], nil
+		}
+		logger.Printf("Query returned found=%t, matchingLogs=%v, err=%v, attempt=%d", found, matchingLogs, err, attempt)
+		if err != nil && !shouldRetryHasMatchingLog(err) {
+			// A non-retryable error.
+			return nil, fmt.Errorf("QueryLog() failed: %v", err)
+		}
+		// found was false, or we hit a retryable error.
+		time.Sleep(logQueryBackoffDuration)
+	}
+	return nil, &ExhaustedRetriesError{
+		Op:       "QueryLog()",
+		Target:   logNameRegex,
+		Attempts: maxAttempts,
+		Detail:   logNameRegex + " not found",
+	}
+}
+
+// QueryLogs is like QueryLog, but waits until at least minEntries log entries
+// match the given query, and returns all of them, sorted by timestamp.
+func QueryLogs(ctx context.Context, logger *log.Logger, vm *VM, logNameRegex string, window time.Duration, query string, maxAttempts, minEntries int) ([]*cloudlogging.Entry, error) {
+	minEntries = max(minEntries, 1)
+	var matchingLogs []*cloudlogging.Entry
+	err := Poll(ctx, logger, PollConfig{
+		Description: "QueryLogs()",
+		MaxAttempts: maxAttempts,
+		Backoff:     logQueryBackoffDuration,
+		IsRetriable: shouldRetryHasMatchingLog,
+	}, func() (bool, error) {
+		var err error
+		matchingLogs, err = findMatchingLogs(ctx, logger, vm, logNameRegex, window, query)
+		return err == nil && len(matchingLogs) >= minEntries, err
+	})
+	var exhausted *ExhaustedRetriesError
+	if errors.As(err, &exhausted) {
+		exhausted.Target = logNameRegex
+		exhausted.Detail = fmt.Sprintf("found %d of %d entries for %s", len(matchingLogs), minEntries, logNameRegex)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sortLogEntries(matchingLogs)
+	return matchingLogs, nil
+}
+
+// sortLogEntries sorts the given log entries by timestamp, keeping entries
+// with the same timestamp in the order the backend returned them.
+func sortLogEntries(entries []*cloudlogging.Entry) {
+	slices.SortStableFunc(entries, func(a, b *cloudlogging.Entry) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+}
+
+// logPayloadText returns the payload of the given log entry as text: text
+// payloads as they are, and structured payloads as JSON.
+func logPayloadText(entry *cloudlogging.Entry) string {
+	switch p := entry.Payload.(type) {
+	case string:
+		return p
+	case *structpb.Struct:
+		if payload, err := protojson.Marshal(p); err == nil {
+			return string(payload)
+		}
+	}
+	return fmt.Sprint(entry.Payload)
+}
+
+// AssertLogOrder checks that, in the given log entries (e.g. as returned by
+// QueryLogs), the first entry whose payload contains substrA comes before the
+// first entry whose payload contains substrB.
+func AssertLogOrder(entries []*cloudlogging.Entry, substrA, substrB string) error {
+	indexA, indexB := -1, -1
+	for i, entry := range entries {
+		text := logPayloadText(entry)
+		if indexA < 0 && strings.Contains(text, substrA) {
+			indexA = i
+		}
+		if indexB < 0 && strings.Contains(text, substrB) {
+			indexB = i
+		}
+	}
+	switch {
+	case indexA < 0:
+		return fmt.Errorf("AssertLogOrder(): no log entry contains %q", substrA)
+	case indexB < 0:
+		return fmt.Errorf("AssertLogOrder(): no log entry contains %q", substrB)
+	case indexA >= indexB:
+		return fmt.Errorf("AssertLogOrder(): log entry containing %q (at %v) does not come before the one containing %q (at %v)",
+			substrA, entries[indexA].Timestamp, substrB, entries[indexB].Timestamp)
+	}
+	return nil
+}
+
+// TextPayloadError is returned by QueryLogJSON when the matching log entry has
+// a text payload instead of a structured one.
+type TextPayloadError struct {
+	Text string
+}
+
+func (e *TextPayloadError) Error() string {
+	return fmt.Sprintf("log entry has a text payload, not a JSON payload: %q", e.Text)
+}
+
+// QueryLogJSON is like QueryLog, but instead of returning the log entry, it
+// unmarshals the entry's JSON payload into out, which should be a pointer to
+// a struct or map, as with json.Unmarshal. Returns a *TextPayloadError if the
+// entry has a text payload.
+func QueryLogJSON(ctx context.Context, logger *log.Logger, vm *VM, logNameRegex string, window time.Duration, query string, maxAttempts int, out interface{}) error {
+	entry, err := queryLog(ctx, logger, vm, logNameRegex, window, query, maxAttempts)
+	if err != nil {
+		return err
+	}
+	var payload []byte
+	switch p := entry.Payload.(type) {
+	case *structpb.Struct:
+		payload, err = protojson.Marshal(p)
+		if err != nil {
+			return fmt.Errorf("QueryLogJSON() could not marshal payload %v: %v", p, err)
+		}
+	case string:
+		return &TextPayloadError{Text: p}
+	default:
+		return fmt.Errorf("QueryLogJSON(): log entry has a payload of unsupported type %T: %v", p, p)
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return fmt.Errorf("QueryLogJSON() could not unmarshal payload %s: %v", payload, err)
+	}
+	return nil
+}
+
+// queryLog is QueryLog.
+var queryLog = QueryLog
+
+// parseLogTrace splits the trace field of a log entry, which looks like
+// "projects/<project>/traces/<trace ID>", into its project and trace ID.
+func parseLogTrace(trace string) (project string, traceID string, err error) {
+	parts := strings.Split(trace, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[1] == "" || parts[2] != "traces" || parts[3] == "" {
+		return "", "", fmt.Errorf("malformed trace field %q, want projects/<project>/traces/<trace ID>", trace)
+	}
+	return parts[1], parts[3], nil
+}
+
+// AssertLogTraceCorrelation looks for a log matching the given query, like
+// QueryLog, and checks that it is correlated with a trace. Returns the ID of
+// the trace that the log entry refers to.
+//
+// If the log entry's trace is in the VM's project, this also waits for the
+// trace itself to show up in Cloud Trace. Traces in other projects are not
+// looked up, since the test may not have access to them.
+func AssertLogTraceCorrelation(ctx context.Context, logger *log.Logger, vm *VM, logNameRegex string, window time.Duration, query string) (traceID string, err error) {
+	entry, err := queryLog(ctx, logger, vm, logNameRegex, window, query, LogQueryMaxAttempts)
+	if err != nil {
+		return "", fmt.Errorf("AssertLogTraceCorrelation(log=%q): %v", query, err)
+	}
+	if entry.Trace == "" {
+		return "", fmt.Errorf("AssertLogTraceCorrelation(log=%q): log entry has no trace: %v", query, entry)
+	}
+	project, traceID, err := parseLogTrace(entry.Trace)
+	if err != nil {
+		return "", fmt.Errorf("AssertLogTraceCorrelation(log=%q): %v", query, err)
+	}
+	logger.Printf("Log entry refers to trace %v and span %q", entry.Trace, entry.SpanID)
+	if project != vm.Project {
+		logger.Printf("Not looking up trace %v, which is outside of project %v", traceID, vm.Project)
+		return traceID, nil
+	}
+	if _, err := WaitForTraceByID(ctx, logger, project, traceID); err != nil {
+		return "", fmt.Errorf("AssertLogTraceCorrelation(log=%q): %v", query, err)
+	}
+	return traceID, nil
+}
 
 // QueryAllLogs looks in the logging backend for logs matching the given query,
 // over the trailing time interval specified by the given window.
@@ -717,6 +2216,9 @@ func AssertLogMissing(ctx context.Context, logger *log.Logger, vm *VM, logNameRe
 type CommandOutput struct {
 	Stdout string
 	Stderr string
+	// The exit code of the command, or -1 if it did not run to completion.
+	// For commands run over ssh, 255 usually means that ssh itself failed.
+	ExitCode int
 }
 
 type ThreadSafeWriter struct {
@@ -730,12 +2232,54 @@ func (writer *ThreadSafeWriter) Write(p []byte) (int, error) {
 	return writer.guarded.Write(p)
 }
 
+// lineWriter is an io.Writer that calls onLine with each complete line written
+// to it, without its line ending. lineWriters that share mu never call onLine
+// at the same time, so that lines from stdout and stderr don't interleave.
+type lineWriter struct {
+	mu      *sync.Mutex
+	onLine  func(line string)
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.partial[:i])
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush calls onLine with the last line, if it didn't end with a newline.
+func (w *lineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.emit(w.partial)
+		w.partial = nil
+	}
+}
+
+func (w *lineWriter) emit(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onLine(string(bytes.TrimSuffix(line, []byte("\r"))))
+}
+
 // runCommand invokes a binary and waits until it finishes. Returns the stdout
 // and stderr, and an error if the binary had a nonzero exit code.
 // args is a slice containing the binary to invoke along with all its arguments,
 // e.g. {"echo", "hello"}.
 // env is a map containing environment variables to set for the command.
 func runCommand(ctx context.Context, logger *log.Logger, stdin io.Reader, args []string, env map[string]string) (CommandOutput, error) {
+	return runCommandStreaming(ctx, logger, stdin, args, env, nil)
+}
+
+// runCommandStreaming is like runCommand, but also calls onLine, if it is not
+// nil, with each line of stdout and stderr as soon as the binary writes it.
+func runCommandStreaming(ctx context.Context, logger *log.Logger, stdin io.Reader, args []string, env map[string]string, onLine func(line string)) (CommandOutput, error) {
 	var output CommandOutput
 	if len(args) < 1 {
 		return output, fmt.Errorf("runCommand() needs a nonempty argument slice, got %v", args)
@@ -749,8 +2293,19 @@ func runCommand(ctx context.Context, logger *log.Logger, stdin io.Reader, args [
 	var interleavedBuilder strings.Builder
 
 	interleavedWriter := &ThreadSafeWriter{guarded: &interleavedBuilder}
-	cmd.Stdout = io.MultiWriter(&stdoutBuilder, interleavedWriter)
-	cmd.Stderr = io.MultiWriter(&stderrBuilder, interleavedWriter)
+	stdoutWriters := []io.Writer{&stdoutBuilder, interleavedWriter}
+	stderrWriters := []io.Writer{&stderrBuilder, interleavedWriter}
+	var lineWriters []*lineWriter
+	if onLine != nil {
+		var onLineMu sync.Mutex
+		stdoutLines := &lineWriter{mu: &onLineMu, onLine: onLine}
+		stderrLines := &lineWriter{mu: &onLineMu, onLine: onLine}
+		stdoutWriters = append(stdoutWriters, stdoutLines)
+		stderrWriters = append(stderrWriters, stderrLines)
+		lineWriters = append(lineWriters, stdoutLines, stderrLines)
+	}
+	cmd.Stdout = io.MultiWriter(stdoutWriters...)
+	cmd.Stderr = io.MultiWriter(stderrWriters...)
 	if len(env) > 0 {
 		cmd.Env = os.Environ()
 		for k, v := range env {
@@ -758,7 +2313,16 @@ func runCommand(ctx context.Context, logger *log.Logger, stdin io.Reader, args [
 		}
 	}
 
+	start := time.Now()
 	err := cmd.Run()
+	for _, w := range lineWriters {
+		w.flush()
+	}
+	if recorder, ok := ctx.Value(commandRecorderKey).(*CommandRecorder); ok {
+		if recordErr := recorder.record(args, start, cmd.ProcessState.ExitCode(), interleavedBuilder.String(), err); recordErr != nil {
+			logger.Printf("Could not record command %v: %v", args, recordErr)
+		}
+	}
 
 	if err != nil {
 		err = fmt.Errorf("Command failed: %v\n%v\nstdout+stderr: %s", args, err, interleavedBuilder.String())
@@ -769,10 +2333,83 @@ func runCommand(ctx context.Context, logger *log.Logger, stdin io.Reader, args [
 
 	output.Stdout = stdoutBuilder.String()
 	output.Stderr = stderrBuilder.String()
+	output.ExitCode = cmd.ProcessState.ExitCode()
 
 	return output, err
 }
 
+// maxRecordedOutputBytes is how much of a command's output a CommandRecord
+// keeps. Longer output is truncated from the front, since the end is usually
+// what explains a failure.
+const maxRecordedOutputBytes = 4096
+
+// CommandRecord is the structured record of a single command, written as one
+// line of JSON by a CommandRecorder.
+type CommandRecord struct {
+	Command         []string  `json:"command"`
+	StartTime       time.Time `json:"start_time"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	// The exit code of the command, or -1 if it did not run to completion.
+	ExitCode int `json:"exit_code"`
+	// The error running the command, if any, without its output.
+	Error string `json:"error,omitempty"`
+	// The interleaved stdout and stderr of the command, truncated to the
+	// last maxRecordedOutputBytes bytes.
+	Output          string `json:"output"`
+	OutputTruncated bool   `json:"output_truncated,omitempty"`
+}
+
+// CommandRecorder writes a CommandRecord, as a line of JSON, for every
+// command run with a context returned by WithCommandRecorder. This includes
+// commands run by RunGcloud and RunRemotely. The resulting JSON-lines file
+// can be analyzed to find the slowest or flakiest commands across a suite.
+//
+// A CommandRecorder is safe to share between concurrent commands.
+type CommandRecorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewCommandRecorder returns a CommandRecorder that writes to w. To record
+// next to the other logs of a test, pass
+// logger.ToFile("commands.jsonl").Writer() for the DirectoryLogger returned by
+// SetupLogger.
+func NewCommandRecorder(w io.Writer) *CommandRecorder {
+	return &CommandRecorder{w: w}
+}
+
+// record writes a CommandRecord for the given command.
+func (r *CommandRecorder) record(args []string, start time.Time, exitCode int, output string, runErr error) error {
+	record := CommandRecord{
+		Command:         args,
+		StartTime:       start,
+		DurationSeconds: time.Since(start).Seconds(),
+		ExitCode:        exitCode,
+		Output:          output,
+	}
+	if runErr != nil {
+		record.Error = runErr.Error()
+	}
+	if len(record.Output) > maxRecordedOutputBytes {
+		record.Output = record.Output[len(record.Output)-maxRecordedOutputBytes:]
+		record.OutputTruncated = true
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(append(line, '\n'))
+	return err
+}
+
+// WithCommandRecorder returns a context that records every command run with
+// it, e.g. by RunGcloud or RunRemotely, to the given CommandRecorder.
+func WithCommandRecorder(ctx context.Context, recorder *CommandRecorder) context.Context {
+	return context.WithValue(ctx, commandRecorderKey, recorder)
+}
+
 // getGcloudConfigDir returns the current gcloud configuration directory.
 func getGcloudConfigDir(ctx context.Context) (string, error) {
 	out, err := RunGcloud(ctx, log.New(io.Discard, "", 0), "", []string{"info", "--format=value[terminator=''](config.paths.global_config_dir)"})
@@ -810,6 +2447,8 @@ func SetupGcloudConfigDir(ctx context.Context, directory string) error {
 
 const (
 	gcloudConfigDirKey = "__gcloud_config_dir__"
+	gcloudTrackKey     = "__gcloud_track__"
+	commandRecorderKey = "__command_recorder__"
 )
 
 // WithGcloudConfigDir returns a context that records the desired value of the
@@ -819,6 +2458,52 @@ func WithGcloudConfigDir(ctx context.Context, directory string) context.Context
 	return context.WithValue(ctx, gcloudConfigDirKey, directory)
 }
 
+// gcloudTracks are the release tracks accepted by WithGcloudTrack. "ga"
+// means no track prefix at all.
+var gcloudTracks = []string{"alpha", "beta", "ga"}
+
+// WithGcloudTrack returns a context that records the desired gcloud release
+// track, which must be one of "alpha", "beta" or "ga" (case-insensitive).
+// Invoking RunGcloud with that context will run the command in that track,
+// unless its arguments already start with a track.
+func WithGcloudTrack(ctx context.Context, track string) (context.Context, error) {
+	track = strings.ToLower(track)
+	if !slices.Contains(gcloudTracks, track) {
+		return nil, fmt.Errorf("invalid gcloud release track %q, want one of %v", track, gcloudTracks)
+	}
+	return context.WithValue(ctx, gcloudTrackKey, track), nil
+}
+
+// gcloudTrack returns the release track recorded by WithGcloudTrack, or "ga"
+// if there is none.
+func gcloudTrack(ctx context.Context) string {
+	if track := ctx.Value(gcloudTrackKey); track != nil {
+		return track.(string)
+	}
+	return "ga"
+}
+
+// gcloudTrackArgs prepends the release track recorded in ctx to the given
+// gcloud arguments, unless it is GA or the arguments already start with a
+// track.
+func gcloudTrackArgs(ctx context.Context, args []string) []string {
+	track := gcloudTrack(ctx)
+	if track == "ga" || (len(args) > 0 && slices.Contains(gcloudTracks, args[0])) {
+		return args
+	}
+	return append([]string{track}, args...)
+}
+
+// createTrack returns the release track to use for commands that create VMs.
+// These need at least "beta" for --max-run-duration, but can be opted into
+// "alpha" with WithGcloudTrack.
+func createTrack(ctx context.Context) string {
+	if gcloudTrack(ctx) == "alpha" {
+		return "alpha"
+	}
+	return "beta"
+}
+
 // RunGcloud invokes a gcloud binary from runfiles and waits until it finishes.
 // Returns the stdout and stderr and an error if the binary had a nonzero exit
 // code. args is a slice containing the arguments to pass to gcloud.
@@ -828,12 +2513,51 @@ func WithGcloudConfigDir(ctx context.Context, directory string) context.Context
 // Various pros/cons of shelling out to gcloud vs using the Compute API are discussed here:
 // http://go/sdi-gcloud-vs-api
 func RunGcloud(ctx context.Context, logger *log.Logger, stdin string, args []string) (CommandOutput, error) {
+	args = gcloudTrackArgs(ctx, args)
 	logger.Printf("Running command: gcloud %v", args)
 	env := make(map[string]string)
 	if configDir := ctx.Value(gcloudConfigDirKey); configDir != nil {
 		env["CLOUDSDK_CONFIG"] = configDir.(string)
 	}
-	return runCommand(ctx, logger, strings.NewReader(stdin), append([]string{gcloudPath}, args...), env)
+	if gcloudObserver == nil {
+		return runCommand(ctx, logger, strings.NewReader(stdin), append([]string{gcloudPath}, args...), env)
+	}
+	start := time.Now()
+	output, err := runCommand(ctx, logger, strings.NewReader(stdin), append([]string{gcloudPath}, args...), env)
+	gcloudObserver(slices.Clone(args), time.Since(start), err)
+	return output, err
+}
+
+// gcloudObserver, if not nil, is called by RunGcloud after each command.
+// See SetGcloudObserver.
+var gcloudObserver func(args []string, duration time.Duration, err error)
+
+// SetGcloudObserver registers a function that RunGcloud calls after each
+// gcloud command finishes, with the command's arguments (not including the
+// gcloud binary itself), how long it took and the error it returned, if any.
+// This is meant for tracking how long gcloud operations take across a run,
+// e.g. to export the latency of "compute instances create" to a monitoring
+// system. Pass nil to remove the observer.
+//
+// The observer is called concurrently when gcloud commands run in parallel,
+// so it must be safe for concurrent use. Call this before starting any tests
+// that run gcloud, e.g. in TestMain.
+func SetGcloudObserver(observer func(args []string, duration time.Duration, err error)) {
+	gcloudObserver = observer
+}
+
+// RunGcloudJSON invokes gcloud with the given arguments plus --format=json,
+// and parses its output into out, which should be a pointer to a value that
+// encoding/json can unmarshal into.
+func RunGcloudJSON(ctx context.Context, logger *log.Logger, args []string, out any) error {
+	output, err := runGcloud(ctx, logger, "", append(slices.Clone(args), "--format=json"))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(output.Stdout), out); err != nil {
+		return fmt.Errorf("could not parse JSON from %q: %v", output.Stdout, err)
+	}
+	return nil
 }
 
 var (
@@ -882,9 +2606,44 @@ func RunRemotely(ctx context.Context, logger *log.Logger, vm *VM, command string
 	return RunRemotelyStdin(ctx, logger, vm, nil, command)
 }
 
+// runGcloud and runRemotely are RunGcloud and RunRemotely, for the helpers
+// whose unit tests swap in fakes for the commands they run.
+var (
+	runGcloud   = RunGcloud
+	runRemotely = RunRemotely
+)
+
 // RunRemotelyStdin is just like RunRemotely but it accepts an io.Reader
 // for what data to pass in over standard input to the command.
 func RunRemotelyStdin(ctx context.Context, logger *log.Logger, vm *VM, stdin io.Reader, command string) (_ CommandOutput, err error) {
+	// stdin can't be replayed, so only commands without it are retried.
+	return runRemotelyOverSSH(ctx, logger, vm, stdin, command, nil, retryTransientSSHErrors && stdin == nil)
+}
+
+// RunRemotelyRetryTransient is just like RunRemotely, but it retries the
+// command, a few times with a short backoff, if ssh fails to connect to the
+// VM in one of the ways listed in transientSSHErrors. These happen
+// intermittently, especially right after a VM has become reachable. A command
+// that ran and exited with a nonzero code is never retried, but a command
+// whose connection dropped partway through the key exchange might in rare
+// cases have started, so only use this for commands that are safe to run
+// more than once. See also SetRetryTransientSSHErrors.
+func RunRemotelyRetryTransient(ctx context.Context, logger *log.Logger, vm *VM, command string) (CommandOutput, error) {
+	return runRemotelyOverSSH(ctx, logger, vm, nil, command, nil, true)
+}
+
+// RunRemotelyStreaming is just like RunRemotely but it also calls onLine with
+// each line of the command's stdout and stderr as soon as it arrives, e.g. to
+// watch the progress of a long-running command. onLine is never called
+// concurrently with itself. The full output is still returned at the end.
+func RunRemotelyStreaming(ctx context.Context, logger *log.Logger, vm *VM, command string, onLine func(line string)) (CommandOutput, error) {
+	return runRemotelyOverSSH(ctx, logger, vm, nil, command, onLine, false)
+}
+
+// runRemotelyOverSSH implements RunRemotelyStdin, RunRemotelyRetryTransient
+// and RunRemotelyStreaming. If retryTransient is set, it retries the command
+// when ssh fails with a transient error.
+func runRemotelyOverSSH(ctx context.Context, logger *log.Logger, vm *VM, stdin io.Reader, command string, onLine func(line string), retryTransient bool) (_ CommandOutput, err error) {
 	logger.Printf("Running command remotely: %v", command)
 	defer func() {
 		if err != nil {
@@ -903,12 +2662,196 @@ func RunRemotelyStdin(ctx context.Context, logger *log.Logger, vm *VM, stdin io.
 	//    This means that they pile up and need to be deleted periodically.
 	// 2. We saw a variety of flaky issues when using gcloud, see b/171810719#comment6.
 	//    "gcloud compute ssh" does not work reliably when run concurrently with itself.
+	host, options, env := sshTarget(ctx, vm)
 	args := []string{"ssh"}
-	args = append(args, sshUserName+"@"+vm.IPAddress)
+	args = append(args, sshUserName+"@"+host)
 	args = append(args, "-oIdentityFile="+privateKeyFile)
 	args = append(args, sshOptions...)
+	args = append(args, options...)
 	args = append(args, wrappedCommand)
-	return runCommand(ctx, logger, stdin, args, nil)
+	if !retryTransient {
+		return runCommandStreaming(ctx, logger, stdin, args, env, onLine)
+	}
+	return retryTransientSSH(ctx, logger, func() (CommandOutput, error) {
+		return runCommandStreaming(ctx, logger, stdin, args, env, onLine)
+	})
+}
+
+// transientSSHErrors are the messages that ssh prints when it fails to
+// connect to a VM in a way that is usually resolved by trying again, e.g.
+// "kex_exchange_identification: read: Connection reset by peer" or
+// "ssh: connect to host 10.0.0.2 port 22: Connection refused". All of them
+// happen before the command starts, unlike e.g. "Connection to 10.0.0.2
+// closed by remote host", which is not retried.
+var transientSSHErrors = []string{
+	"kex_exchange_identification:",
+	"ssh_exchange_identification:",
+	"Connection timed out during banner exchange",
+	"ssh: connect to host",
+}
+
+var (
+	// Whether RunRemotely and RunRemotelyStdin (without stdin) behave like
+	// RunRemotelyRetryTransient.
+	retryTransientSSHErrors = false
+	// transientSSHMaxAttempts is how many times in total retryTransientSSH
+	// runs a command.
+	transientSSHMaxAttempts = 3
+	// transientSSHBackoffDuration is how long retryTransientSSH waits between
+	// attempts.
+	transientSSHBackoffDuration = 5 * time.Second
+)
+
+// SetRetryTransientSSHErrors configures whether RunRemotely, and
+// RunRemotelyStdin when given no stdin, retry commands that fail with a
+// transient ssh error, like RunRemotelyRetryTransient does. This is off by
+// default, because it applies to every command, including ones that are not
+// safe to run twice.
+func SetRetryTransientSSHErrors(retry bool) {
+	retryTransientSSHErrors = retry
+}
+
+// isTransientSSHError returns whether a command failed because ssh could not
+// connect to the VM, according to transientSSHErrors. ssh exits with 255 when
+// it fails itself, whereas any other exit code comes from the command.
+func isTransientSSHError(output CommandOutput, err error) bool {
+	if err == nil || output.ExitCode != 255 {
+		return false
+	}
+	for _, msg := range transientSSHErrors {
+		if strings.Contains(output.Stderr, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryTransientSSH calls run up to transientSSHMaxAttempts times, for as
+// long as it fails with a transient ssh error.
+func retryTransientSSH(ctx context.Context, logger *log.Logger, run func() (CommandOutput, error)) (CommandOutput, error) {
+	var output CommandOutput
+	var err error
+	for attempt := 1; attempt <= transientSSHMaxAttempts; attempt++ {
+		output, err = run()
+		if !isTransientSSHError(output, err) {
+			return output, err
+		}
+		if attempt == transientSSHMaxAttempts {
+			break
+		}
+		logger.Printf("ssh failed with a transient error, retrying (%d/%d)...", attempt, transientSSHMaxAttempts)
+		select {
+		case <-ctx.Done():
+			return output, ctx.Err()
+		case <-time.After(transientSSHBackoffDuration):
+		}
+	}
+	return output, err
+}
+
+// vmHost returns the host name or IP address to connect to the given VM at
+// directly: its ConnectHost if it has one, or else its IPAddress.
+func vmHost(vm *VM) string {
+	if vm.ConnectHost != "" {
+		return vm.ConnectHost
+	}
+	return vm.IPAddress
+}
+
+// sshTarget returns the host that ssh and scp should connect to for the given
+// VM, along with any extra options and environment variables they need.
+// Normally this is just the VM's IP address, or its ConnectHost if it has
+// one. With UseIAP, the connection is instead proxied through
+// "gcloud compute start-iap-tunnel", which uses the gcloud configuration
+// directory from ctx, if any.
+func sshTarget(ctx context.Context, vm *VM) (host string, options []string, env map[string]string) {
+	if !vm.UseIAP {
+		return vmHost(vm), nil, nil
+	}
+	proxyCommand := fmt.Sprintf("-oProxyCommand=%s compute start-iap-tunnel %s %%p --listen-on-stdin --project=%s --zone=%s --verbosity=warning",
+		gcloudPath, vm.Name, vm.Project, vm.Zone)
+	if configDir := ctx.Value(gcloudConfigDirKey); configDir != nil {
+		env = map[string]string{"CLOUDSDK_CONFIG": configDir.(string)}
+	}
+	// The host name is only used by ssh for display and host key checking,
+	// which is disabled by sshOptions.
+	return vm.Name, []string{proxyCommand}, env
+}
+
+var (
+	// retryExitCodeBackoffDuration is how long RunRemotelyRetryExitCodes
+	// waits between attempts.
+	retryExitCodeBackoffDuration = 10 * time.Second
+)
+
+// RunRemotelyRetryExitCodes runs a command on the provided VM like
+// RunRemotely, retrying it up to maxAttempts times in total as long as it
+// exits with one of retryCodes. This is for tools that signal transient
+// failures with a particular exit code, e.g. apt-get exiting with 100 while
+// another process holds the dpkg lock.
+//
+// Returns as soon as the command succeeds or fails with any other exit code.
+func RunRemotelyRetryExitCodes(ctx context.Context, logger *log.Logger, vm *VM, command string, retryCodes []int, maxAttempts int) (CommandOutput, error) {
+	var output CommandOutput
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		output, err = runRemotely(ctx, logger, vm, command)
+		if err == nil || !slices.Contains(retryCodes, output.ExitCode) {
+			return output, err
+		}
+		logger.Printf("RunRemotelyRetryExitCodes(): command exited with retryable code %d, retrying (%d/%d)...",
+			output.ExitCode, attempt, maxAttempts)
+		if attempt < maxAttempts {
+			select {
+			case <-ctx.Done():
+				return output, ctx.Err()
+			case <-time.After(retryExitCodeBackoffDuration):
+			}
+		}
+	}
+	return output, fmt.Errorf("RunRemotelyRetryExitCodes() failed after %d attempts: %w", maxAttempts, err)
+}
+
+// linuxUserNameRegexp matches the usernames that useradd accepts by default,
+// none of which need quoting in a shell.
+var linuxUserNameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// runAsUserCommand returns the command for RunRemotelyAsUser to run over ssh.
+func runAsUserCommand(imageSpec, user, command string) (string, error) {
+	if IsWindows(imageSpec) {
+		return "", errors.New("RunRemotelyAsUser() is not supported on Windows")
+	}
+	if !linuxUserNameRegexp.MatchString(user) {
+		return "", fmt.Errorf("invalid username %q", user)
+	}
+	// Quote the command so that it is interpreted by the target user's shell
+	// and not ours.
+	quoted := shellQuote(command)
+	// -H sets $HOME to the target user's home directory. The rest of the
+	// environment is reset by sudo as usual, so set any variables the command
+	// needs inside the command itself.
+	return fmt.Sprintf("sudo -u %s -H bash -c %s", user, quoted), nil
+}
+
+// RunRemotelyAsUser runs a shell command on the provided Linux VM as the given
+// local user, which must already exist. Use it for tests where the agent
+// behaves differently depending on the permissions of the user it runs as.
+// Windows is not supported.
+func RunRemotelyAsUser(ctx context.Context, logger *log.Logger, vm *VM, user, command string) (CommandOutput, error) {
+	wrappedCommand, err := runAsUserCommand(vm.ImageSpec, user, command)
+	if err != nil {
+		return CommandOutput{}, err
+	}
+	return RunRemotely(ctx, logger, vm, wrappedCommand)
+}
+
+// vmTransfersBucket returns the GCS bucket to transfer files to the given VM
+// through: VMOptions.TransfersBucket if it was set, or else the global default.
+func vmTransfersBucket(vm *VM) string {
+	if vm.TransfersBucket != "" {
+		return vm.TransfersBucket
+	}
+	return transfersBucket
 }
 
 // UploadContent takes an io.Reader and uploads its contents as a file to a
@@ -916,10 +2859,12 @@ func RunRemotelyStdin(ctx context.Context, logger *log.Logger, vm *VM, stdin io.
 //
 // In order for this function to work, the currently active application default
 // credentials (GOOGLE_APPLICATION_CREDENTIALS) need to be able to upload to
-// fileTransferBucket, and also the role running on the remote VM needs to be
-// given permission to read from that bucket. This was accomplished by adding
-// the "Compute Engine default service account" for PROJECT as
-// a "Storage Object Viewer" and "Storage Object Creator" on the bucket.
+// the VM's transfers bucket (see vmTransfersBucket), and also the role running
+// on the remote VM needs to be given permission to read from that bucket. For
+// the default bucket, this was accomplished by adding the "Compute Engine
+// default service account" for PROJECT as a "Storage Object Viewer" and
+// "Storage Object Creator" on the bucket. Buckets set with
+// VMOptions.TransfersBucket need the same permissions.
 //
 // When making changes to this function, please run gce_testing_test.go (manually).
 func UploadContent(ctx context.Context, logger *log.Logger, vm *VM, content io.Reader, remotePath string) (err error) {
@@ -928,7 +2873,7 @@ func UploadContent(ctx context.Context, logger *log.Logger, vm *VM, content io.R
 			logger.Printf("Uploading file finished with err=%v", err)
 		}
 	}()
-	object := storageClient.Bucket(transfersBucket).Object(path.Join(vm.Name, remotePath))
+	object := storageClient.Bucket(vmTransfersBucket(vm)).Object(path.Join(vm.Name, remotePath))
 	writer := object.NewWriter(ctx)
 	// We mainly use UploadContent for scripts, which are small relative to the
 	// default ChunkSize of 16 MB.
@@ -943,28 +2888,276 @@ func UploadContent(ctx context.Context, logger *log.Logger, vm *VM, content io.R
 	if err != nil {
 		return fmt.Errorf("UploadContent() could not write data into storage object: %v", err)
 	}
-	// Make sure to clean up the object once we're done with it.
-	// Note: if the preceding io.Copy() or writer.Close() fails, the object will
-	// not be uploaded and there is no need to delete it:
-	// https://cloud.google.com/storage/docs/resumable-uploads#introduction
-	// (note that the go client libraries use resumable uploads).
+	// Make sure to clean up the object once we're done with it.
+	// Note: if the preceding io.Copy() or writer.Close() fails, the object will
+	// not be uploaded and there is no need to delete it:
+	// https://cloud.google.com/storage/docs/resumable-uploads#introduction
+	// (note that the go client libraries use resumable uploads).
+	defer func() {
+		deleteErr := object.Delete(ctx)
+		if deleteErr != nil {
+			err = fmt.Errorf("UploadContent() finished with err=%v, then cleanup of %v finished with err=%v", err, object.ObjectName(), deleteErr)
+		}
+	}()
+
+	objectPath := fmt.Sprintf("gs://%s/%s", object.BucketName(), object.ObjectName())
+	return gcloudStorageCopyOnVM(ctx, logger, vm, objectPath, remotePath)
+}
+
+// gcloudStorageCopyOnVM runs `gcloud storage cp` on the given VM to copy
+// between a local path on the VM and a GCS object, in either direction. On
+// Linux, the copy runs with sudo so that any path can be read or written.
+func gcloudStorageCopyOnVM(ctx context.Context, logger *log.Logger, vm *VM, from, to string) error {
+	if err := InstallGcloudIfNeeded(ctx, logger, vm); err != nil {
+		return err
+	}
+	gcloudCmd := fmt.Sprintf("sudo gcloud storage cp %s %s", shellQuote(from), shellQuote(to))
+	if IsWindows(vm.ImageSpec) {
+		gcloudCmd = fmt.Sprintf("gcloud storage cp %s %s", powershellQuote(from), powershellQuote(to))
+	}
+	_, err := RunRemotely(ctx, logger, vm, gcloudCmd)
+	return err
+}
+
+// writeTarGz writes the contents of localDir to w as a gzipped tarball, with
+// paths relative to localDir. File modes and symlinks are preserved.
+func writeTarGz(w io.Writer, localDir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	return multierr.Combine(err, tw.Close(), gz.Close())
+}
+
+// writeZip writes the contents of localDir to w as a zip archive, with paths
+// relative to localDir. Expand-Archive can't create symlinks, so they are
+// rejected.
+func writeZip(w io.Writer, localDir string) error {
+	zw := zip.NewWriter(w)
+	err := filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file or directory, which is not supported on Windows", p)
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			header.Name += "/"
+			_, err = zw.CreateHeader(header)
+			return err
+		}
+		header.Method = zip.Deflate
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(fw, f)
+		return err
+	})
+	return multierr.Combine(err, zw.Close())
+}
+
+// extractArchiveCommand returns the command that UploadDirectory runs on the
+// VM to extract the archive at archivePath into remoteDir and then delete
+// the archive.
+func extractArchiveCommand(imageSpec, archivePath, remoteDir string) string {
+	if IsWindows(imageSpec) {
+		return fmt.Sprintf("$ErrorActionPreference = 'Stop'\nExpand-Archive -Path %s -DestinationPath %s -Force\nRemove-Item -Path %s",
+			powershellQuote(archivePath), powershellQuote(remoteDir), powershellQuote(archivePath))
+	}
+	// The archive is owned by root because gcloudStorageCopyOnVM runs with
+	// sudo. Don't restore the local owners, who may not exist on the VM.
+	return fmt.Sprintf("sudo mkdir -p %s && sudo tar --no-same-owner -xzf %s -C %s && sudo rm -f %s",
+		shellQuote(remoteDir), shellQuote(archivePath), shellQuote(remoteDir), shellQuote(archivePath))
+}
+
+// UploadDirectory copies the contents of the local directory localDir into
+// remoteDir on the given VM, creating remoteDir if needed. The directory is
+// uploaded as a single archive, which is faster than uploading each file with
+// UploadContent: a gzipped tarball that is extracted with tar on Linux, which
+// preserves file modes and symlinks, or a zip file that is extracted with
+// Expand-Archive on Windows, which doesn't support symlinks.
+//
+// The archive goes through the VM's transfers bucket like UploadContent, so
+// the same permissions are needed, and both the object and the archive on
+// the VM are deleted afterwards.
+func UploadDirectory(ctx context.Context, logger *log.Logger, vm *VM, localDir, remoteDir string) error {
+	write, archivePath := writeTarGz, "/tmp/"+uuid.NewString()+".tar.gz"
+	if IsWindows(vm.ImageSpec) {
+		write, archivePath = writeZip, `C:\tmp\`+uuid.NewString()+".zip"
+	}
+	// Stream the archive into the upload instead of buffering it in memory.
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(write(pw, localDir))
+	}()
+	err := UploadContent(ctx, logger, vm, pr, archivePath)
+	// Unblock the writer if the upload stopped reading early.
+	pr.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("UploadDirectory(%v) could not upload the archive: %v", localDir, err)
+	}
+	if _, err := RunRemotely(ctx, logger, vm, extractArchiveCommand(vm.ImageSpec, archivePath, remoteDir)); err != nil {
+		return fmt.Errorf("UploadDirectory(%v) could not extract the archive into %v: %v", localDir, remoteDir, err)
+	}
+	return nil
+}
+
+// CopyBetweenVMs copies the file at srcPath on srcVM to dstPath on dstVM. The
+// copy is byte-for-byte, so it works for binary files, and either VM may run
+// Linux or Windows.
+//
+// The file goes through a single object in srcVM's transfers bucket (see
+// vmTransfersBucket), which is uploaded from srcVM, downloaded onto dstVM and
+// then deleted. This means that the role running on srcVM needs to be a
+// "Storage Object Creator" on that bucket, the role running on dstVM needs to
+// be a "Storage Object Viewer" on it, and the application default
+// credentials need to be able to delete objects from it. For the default
+// bucket and the "Compute Engine default service account", this is already
+// the case (see UploadContent).
+func CopyBetweenVMs(ctx context.Context, logger *log.Logger, srcVM *VM, srcPath string, dstVM *VM, dstPath string) (err error) {
+	defer func() {
+		if err != nil {
+			logger.Printf("CopyBetweenVMs(%v:%v -> %v:%v) finished with err=%v", srcVM.Name, srcPath, dstVM.Name, dstPath, err)
+		}
+	}()
+	object := storageClient.Bucket(vmTransfersBucket(srcVM)).Object(path.Join(srcVM.Name, "copy-"+uuid.NewString()))
+	objectPath := fmt.Sprintf("gs://%s/%s", object.BucketName(), object.ObjectName())
+	logger.Printf("Copying %v on VM %v to %v on VM %v through %v", srcPath, srcVM.Name, dstPath, dstVM.Name, objectPath)
+	if err := gcloudStorageCopyOnVM(ctx, logger, srcVM, srcPath, objectPath); err != nil {
+		return fmt.Errorf("CopyBetweenVMs() could not upload %v from VM %v: %v", srcPath, srcVM.Name, err)
+	}
+	// Unlike in UploadContent, the object was written by srcVM rather than
+	// by this process, so it is only known to exist once that succeeded.
+	defer func() {
+		if deleteErr := object.Delete(ctx); deleteErr != nil {
+			err = fmt.Errorf("CopyBetweenVMs() finished with err=%v, then cleanup of %v finished with err=%v", err, object.ObjectName(), deleteErr)
+		}
+	}()
+	if err := gcloudStorageCopyOnVM(ctx, logger, dstVM, objectPath, dstPath); err != nil {
+		return fmt.Errorf("CopyBetweenVMs() could not download %v onto VM %v: %v", dstPath, dstVM.Name, err)
+	}
+	return nil
+}
+
+// remotePathNeedsRoot returns whether writing to the given path on a Linux VM
+// requires root. Relative paths (which resolve to the ssh user's home
+// directory) and paths under /tmp or the ssh user's home directory can be
+// written to directly; everything else is assumed to need sudo.
+func remotePathNeedsRoot(remotePath string) bool {
+	if !path.IsAbs(remotePath) {
+		return false
+	}
+	cleaned := path.Clean(remotePath)
+	for _, dir := range []string{"/tmp", "/home/" + sshUserName} {
+		if strings.HasPrefix(cleaned, dir+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// SCPToVM copies the file at localPath on the local disk to remotePath on the
+// given VM.
+//
+// On Linux, the file is streamed over scp using the same ssh key and options
+// as RunRemotely, so it is never fully loaded into memory and no GCS bucket is
+// involved. If remotePath is not writable by the ssh user, the file is copied
+// to a temporary location first and then moved into place with sudo.
+// On Windows, this falls back to UploadContent.
+//
+// Prefer this over UploadContent for large files such as agent binaries, or
+// when the VM's project has no access to the transfers bucket. UploadContent
+// is still the better choice for small, generated content that isn't already
+// on the local disk.
+func SCPToVM(ctx context.Context, logger *log.Logger, vm *VM, localPath, remotePath string) (err error) {
 	defer func() {
-		deleteErr := object.Delete(ctx)
-		if deleteErr != nil {
-			err = fmt.Errorf("UploadContent() finished with err=%v, then cleanup of %v finished with err=%v", err, object.ObjectName(), deleteErr)
+		if err != nil {
+			logger.Printf("SCPToVM(%v -> %v) finished with err=%v", localPath, remotePath, err)
 		}
 	}()
+	if IsWindows(vm.ImageSpec) {
+		f, err := os.Open(localPath)
+		if err != nil {
+			return fmt.Errorf("SCPToVM() could not open %v: %v", localPath, err)
+		}
+		defer f.Close()
+		return UploadContent(ctx, logger, vm, f, remotePath)
+	}
 
-	if err := InstallGcloudIfNeeded(ctx, logger, vm); err != nil {
-		return err
+	destination := remotePath
+	if remotePathNeedsRoot(remotePath) {
+		destination = "/tmp/" + uuid.NewString()
 	}
-	objectPath := fmt.Sprintf("gs://%s/%s", object.BucketName(), object.ObjectName())
-	gcloudCmd := fmt.Sprintf("gcloud storage cp '%s' '%s'", objectPath, remotePath)
-	if IsWindows(vm.ImageSpec) {
-		_, err = RunRemotely(ctx, logger, vm, gcloudCmd)
+	host, options, env := sshTarget(ctx, vm)
+	args := []string{"scp"}
+	args = append(args, "-oIdentityFile="+privateKeyFile)
+	args = append(args, sshOptions...)
+	args = append(args, options...)
+	args = append(args, localPath, sshUserName+"@"+scpHost(host)+":"+destination)
+	logger.Printf("Copying %v to %v on VM %v", localPath, remotePath, vm.Name)
+	if _, err := runCommand(ctx, logger, nil, args, env); err != nil {
 		return err
 	}
-	_, err = RunRemotely(ctx, logger, vm, fmt.Sprintf("sudo %s", gcloudCmd))
+	if destination != remotePath {
+		_, err = RunRemotely(ctx, logger, vm, fmt.Sprintf("sudo mv %s %s", shellQuote(destination), shellQuote(remotePath)))
+	}
 	return err
 }
 
@@ -972,20 +3165,220 @@ func UploadContent(ctx context.Context, logger *log.Logger, vm *VM, content io.R
 // the remote VM
 func RetrieveContent(ctx context.Context, logger *log.Logger, vm *VM, remotePath string) (content string, err error) {
 	if IsWindows(vm.ImageSpec) {
-		out, err := RunRemotely(ctx, logger, vm, fmt.Sprintf("Get-Content -Path '%s' -Raw", remotePath))
+		out, err := RunRemotely(ctx, logger, vm, fmt.Sprintf("Get-Content -Path %s -Raw", powershellQuote(remotePath)))
 		return out.Stdout, err
 	}
 	out, err := RunRemotely(ctx, logger, vm, "sudo cat "+remotePath)
 	return out.Stdout, err
 }
 
+// RetrieveBinaryContent retrieves the raw bytes of the file at the given path
+// on the remote VM. Unlike RetrieveContent, the result is byte-for-byte
+// identical to the remote file, which makes it suitable for binary artifacts
+// like core dumps.
+//
+// The file is base64-encoded on the VM and streamed back over stdout, so its
+// size is not limited by the maximum length of a remote command.
+func RetrieveBinaryContent(ctx context.Context, logger *log.Logger, vm *VM, remotePath string) ([]byte, error) {
+	cmd := fmt.Sprintf("sudo base64 -w0 %s", shellQuote(remotePath))
+	if IsWindows(vm.ImageSpec) {
+		cmd = fmt.Sprintf("[Convert]::ToBase64String([IO.File]::ReadAllBytes(%s))", powershellQuote(remotePath))
+	}
+	// Don't use RunRemotely's logger for the output, which could be huge.
+	out, err := RunRemotely(ctx, log.New(io.Discard, "", 0), vm, cmd)
+	if err != nil {
+		logger.Printf("RetrieveBinaryContent(%v) failed: %v", remotePath, err)
+		return nil, err
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(out.Stdout))
+	if err != nil {
+		return nil, fmt.Errorf("RetrieveBinaryContent() could not decode contents of %v: %v", remotePath, err)
+	}
+	logger.Printf("Retrieved %d bytes from %v", len(content), remotePath)
+	return content, nil
+}
+
+// agentServices are the services that make up the Ops Agent. They have the
+// same names on Linux (as systemd units) and on Windows.
+var agentServices = []string{
+	"google-cloud-ops-agent",
+	"google-cloud-ops-agent-fluent-bit",
+	agentCollectorService,
+}
+
+// diagnosticsScript returns a script that gathers the files that
+// CollectDiagnostics collects from the given VM into an archive, along with
+// the path of that archive on the VM.
+func diagnosticsScript(vm *VM) (script string, remoteArchive string) {
+	id := uuid.NewString()
+	if IsWindows(vm.ImageSpec) {
+		remoteArchive = `C:\tmp\diagnostics-` + id + ".zip"
+		script = fmt.Sprintf(`$ErrorActionPreference = 'Continue'
+$dir = 'C:\tmp\diagnostics-%s'
+New-Item -ItemType Directory -Path $dir | Out-Null
+wevtutil epl System "$dir\System.evtx"
+wevtutil epl Application "$dir\Application.evtx"
+Get-Service google-cloud-ops-agent* | Format-Table -AutoSize | Out-File "$dir\services.txt"
+Copy-Item -Recurse -Path 'C:\Program Files\Google\Cloud Operations\Ops Agent\config' -Destination "$dir\config"
+Copy-Item -Recurse -Path 'C:\ProgramData\Google\Cloud Operations\Ops Agent\log' -Destination "$dir\log"
+$ErrorActionPreference = 'Stop'
+Compress-Archive -Path "$dir\*" -DestinationPath '%s'
+Remove-Item -Recurse -Force $dir
+`, id, remoteArchive)
+		return script, remoteArchive
+	}
+
+	remoteArchive = "/tmp/diagnostics-" + id + ".tar.gz"
+	// Each file is collected on a best-effort basis, since a failed test may
+	// not have gotten far enough for all of them to exist.
+	script = fmt.Sprintf(`dir=$(mktemp -d)
+cp %s "$dir/" || true
+for service in %s; do
+  journalctl -u "$service" --no-pager > "$dir/$service.journal.txt" || true
+done
+systemctl --failed --no-pager > "$dir/systemctl-failed.txt" || true
+cp -r /etc/google-cloud-ops-agent "$dir/config" || true
+cp -r /run/google-cloud-ops-agent-* "$dir/" || true
+tar -czf %s -C "$dir" .
+rm -rf "$dir"
+`, SyslogLocation(vm.ImageSpec), strings.Join(agentServices, " "), remoteArchive)
+	return script, remoteArchive
+}
+
+// CollectDiagnostics gathers a standard set of artifacts for triaging a
+// failed test from the given VM and writes them to an archive in destDir.
+// On Linux, these are the system log, the journals of the Ops Agent services,
+// the agent's configuration files and the list of failed systemd units. On
+// Windows, these are the System and Application event logs, the state of the
+// Ops Agent services, and the agent's configuration and log directories.
+func CollectDiagnostics(ctx context.Context, logger *log.Logger, vm *VM, destDir string) error {
+	script, remoteArchive := diagnosticsScript(vm)
+	if _, err := RunScriptRemotely(ctx, logger, vm, script, nil, nil); err != nil {
+		return fmt.Errorf("CollectDiagnostics() could not gather diagnostics on VM %v: %w", vm.Name, err)
+	}
+	content, err := RetrieveBinaryContent(ctx, logger, vm, remoteArchive)
+	if err != nil {
+		return fmt.Errorf("CollectDiagnostics() could not retrieve diagnostics from VM %v: %w", vm.Name, err)
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("CollectDiagnostics() could not create %v: %w", destDir, err)
+	}
+	archiveName := "diagnostics-" + vm.Name + ".tar.gz"
+	if IsWindows(vm.ImageSpec) {
+		archiveName = "diagnostics-" + vm.Name + ".zip"
+	}
+	localPath := filepath.Join(destDir, archiveName)
+	if err := os.WriteFile(localPath, content, 0644); err != nil {
+		return fmt.Errorf("CollectDiagnostics() could not write %v: %w", localPath, err)
+	}
+	logger.Printf("Wrote diagnostics for VM %v to %v", vm.Name, localPath)
+	return nil
+}
+
+// EventLogEntry is an entry in a Windows event log, as returned by
+// GetWindowsEventLog.
+type EventLogEntry struct {
+	// The name of the event's provider, e.g. "Service Control Manager".
+	ProviderName string
+	// The event ID, which identifies the kind of event within its provider.
+	ID int
+	// The display name of the event's level, e.g. "Error" or "Information".
+	Level       string
+	TimeCreated time.Time
+	Message     string
+}
+
+// windowsEventLogCommand returns a powershell command that prints the events
+// in the given log from the last since as a JSON array.
+func windowsEventLogCommand(logName string, since time.Duration) string {
+	// Get-WinEvent fails when no events match, which isn't an error here.
+	// @() and -InputObject make sure that the result is an array even when
+	// there is just one event, and TimeCreated is formatted explicitly because
+	// ConvertTo-Json's date format differs between powershell versions.
+	return fmt.Sprintf(`$ErrorActionPreference = 'Stop'
+try {
+  $events = Get-WinEvent -FilterHashtable @{LogName=%s; StartTime=(Get-Date).AddSeconds(-%d)}
+} catch {
+  if ($_.FullyQualifiedErrorId -notmatch 'NoMatchingEventsFound') { throw }
+  $events = @()
+}
+ConvertTo-Json -Compress -InputObject @($events | Select-Object ProviderName, Id, LevelDisplayName, @{Name='TimeCreated'; Expression={$_.TimeCreated.ToUniversalTime().ToString('o')}}, Message)`,
+		powershellQuote(logName), int(math.Ceil(since.Seconds())))
+}
+
+// parseWindowsEventLog parses the output of windowsEventLogCommand.
+func parseWindowsEventLog(stdout string) ([]EventLogEntry, error) {
+	var raw []struct {
+		ProviderName     string
+		ID               int `json:"Id"`
+		LevelDisplayName string
+		TimeCreated      time.Time
+		Message          string
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(stdout)), &raw); err != nil {
+		return nil, fmt.Errorf("could not parse JSON from %q: %v", stdout, err)
+	}
+	entries := make([]EventLogEntry, 0, len(raw))
+	for _, r := range raw {
+		entries = append(entries, EventLogEntry{
+			ProviderName: r.ProviderName,
+			ID:           r.ID,
+			Level:        r.LevelDisplayName,
+			TimeCreated:  r.TimeCreated,
+			Message:      r.Message,
+		})
+	}
+	return entries, nil
+}
+
+// GetWindowsEventLog returns the entries that were written to the given
+// Windows event log, e.g. "System" or "Application", within the last since.
+// It is the Windows counterpart of reading SyslogLocation on Linux, and
+// returns an error if the VM isn't running Windows.
+func GetWindowsEventLog(ctx context.Context, logger *log.Logger, vm *VM, logName string, since time.Duration) ([]EventLogEntry, error) {
+	if !IsWindows(vm.ImageSpec) {
+		return nil, fmt.Errorf("GetWindowsEventLog(logName=%q) failed: VM %v is not running Windows (image spec %q)", logName, vm.Name, vm.ImageSpec)
+	}
+	output, err := RunRemotely(ctx, logger, vm, windowsEventLogCommand(logName, since))
+	if err != nil {
+		return nil, fmt.Errorf("GetWindowsEventLog(logName=%q) failed: %v", logName, err)
+	}
+	entries, err := parseWindowsEventLog(output.Stdout)
+	if err != nil {
+		return nil, fmt.Errorf("GetWindowsEventLog(logName=%q) failed: %v", logName, err)
+	}
+	return entries, nil
+}
+
+// shellQuote quotes s as a single word for a POSIX shell by wrapping it in
+// single quotes, inside which nothing is special except the single quote
+// itself. Each of those is replaced by a sequence that closes the quotes,
+// adds a backslash-escaped quote and reopens them.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// powershellSingleQuotes are the characters that powershell accepts as a
+// single quote, which includes some typographic quotes.
+var powershellSingleQuotes = []string{"'", "\u2018", "\u2019", "\u201a", "\u201b"}
+
+// powershellQuote is the powershell equivalent of shellQuote. It wraps s in
+// single quotes, inside which nothing is special except single quotes, which
+// are escaped by doubling them.
+func powershellQuote(s string) string {
+	for _, q := range powershellSingleQuotes {
+		s = strings.ReplaceAll(s, q, q+q)
+	}
+	return "'" + s + "'"
+}
+
 // envVarMapToBashPrefix converts a map of env variable name to value into a string
 // suitable for passing to bash as a way to set those variables. The environment values
-// are wrapped in quotes. Example output: `VAR1='foo' VAR2='bar' `
+// are quoted with shellQuote. Example output: `VAR1='foo' VAR2='bar' `
 func envVarMapToBashPrefix(env map[string]string) string {
 	var builder strings.Builder
 	for key, value := range env {
-		fmt.Fprintf(&builder, "%s='%s' ", key, value)
+		fmt.Fprintf(&builder, "%s=%s ", key, shellQuote(value))
 	}
 	return builder.String()
 }
@@ -996,7 +3389,7 @@ func envVarMapToBashPrefix(env map[string]string) string {
 func envVarMapToPowershellPrefix(env map[string]string) string {
 	var builder strings.Builder
 	for key, value := range env {
-		fmt.Fprintf(&builder, "$env:%s='%s'\n", key, value)
+		fmt.Fprintf(&builder, "$env:%s=%s\n", key, powershellQuote(value))
 	}
 	return builder.String()
 }
@@ -1004,7 +3397,8 @@ func envVarMapToPowershellPrefix(env map[string]string) string {
 // RunScriptRemotely runs a script on the given VM.
 // The script should be a shell script for a Linux VM and powershell for a Windows VM.
 // env is a map containing environment variables to provide to the script as it runs.
-// The environment variables and the flags will be wrapped in quotes.
+// The environment variables and the flags will be quoted, so they may contain
+// any characters, including quotes.
 // This function is necessary to handle long commands, particularly on Windows,
 // since there is a length limit on the commands you can pass to RunRemotely:
 // powershell will complain if its -EncodedCommand parameter is too long.
@@ -1013,11 +3407,33 @@ func envVarMapToPowershellPrefix(env map[string]string) string {
 // This will cause a broader class of errors to be reported as an error (nonzero exit code)
 // by powershell.
 func RunScriptRemotely(ctx context.Context, logger *log.Logger, vm *VM, scriptContents string, flags []string, env map[string]string) (CommandOutput, error) {
-	var quotedFlags []string
-	for _, flag := range flags {
-		quotedFlags = append(quotedFlags, fmt.Sprintf("'%s'", flag))
+	if IsWindows(vm.ImageSpec) {
+		return RunScriptRemotelyStdin(ctx, logger, vm, scriptContents, nil, flags, env)
+	}
+	scriptPath := uuid.NewString() + ".sh"
+	// Write the script contents to <UUID>.sh, then tell bash to execute it with -x
+	// to print each line as it runs.
+	// Use a UUID for the script name in case RunScriptRemotely is being called
+	// concurrently on the same VM.
+	//
+	// This takes a single RunRemotely() call by passing the script in over
+	// stdin. To pass data to the script's own stdin instead, use
+	// RunScriptRemotelyStdin, which needs two calls.
+	//
+	// To test changes to this command, please run gce_testing_test.go (manually).
+	return RunRemotelyStdin(ctx, logger, vm, strings.NewReader(scriptContents), "cat - > "+scriptPath+" && sudo "+envVarMapToBashPrefix(env)+"bash -x "+scriptPath+" "+quoteScriptFlags(flags, shellQuote))
+}
+
+// RunScriptRemotelyStdin is just like RunScriptRemotely but it accepts an
+// io.Reader for what data to pass in over standard input to the script.
+// On Linux, this uploads the script and runs it in two separate steps, so it is
+// slightly slower than RunScriptRemotely.
+func RunScriptRemotelyStdin(ctx context.Context, logger *log.Logger, vm *VM, scriptContents string, stdin io.Reader, flags []string, env map[string]string) (CommandOutput, error) {
+	quote := shellQuote
+	if IsWindows(vm.ImageSpec) {
+		quote = powershellQuote
 	}
-	flagsStr := strings.Join(quotedFlags, " ")
+	flagsStr := quoteScriptFlags(flags, quote)
 
 	if IsWindows(vm.ImageSpec) {
 		// Use a UUID for the script name in case RunScriptRemotely is being
@@ -1032,20 +3448,60 @@ func RunScriptRemotely(ctx context.Context, logger *log.Logger, vm *VM, scriptCo
 		// script seems to work around this completely.
 		//
 		// To test changes to this command, please run gce_testing_test.go (manually).
-		return RunRemotely(ctx, logger, vm, envVarMapToPowershellPrefix(env)+"powershell -File "+scriptPath+" "+flagsStr)
+		return RunRemotelyStdin(ctx, logger, vm, stdin, envVarMapToPowershellPrefix(env)+"powershell -File "+scriptPath+" "+flagsStr)
 	}
-	scriptPath := uuid.NewString() + ".sh"
 	// Write the script contents to <UUID>.sh, then tell bash to execute it with -x
-	// to print each line as it runs.
-	// Use a UUID for the script name in case RunScriptRemotely is being called
-	// concurrently on the same VM.
-	//
-	// Note: if we ever decide to support a stdin parameter to this function, we can
-	// accomplish that by splitting the below command into two RunRemotely() calls:
-	// one to put scriptContents into a file and another to execute the script.
+	// to print each line as it runs, with stdin wired to the script.
 	//
 	// To test changes to this command, please run gce_testing_test.go (manually).
-	return RunRemotelyStdin(ctx, logger, vm, strings.NewReader(scriptContents), "cat - > "+scriptPath+" && sudo "+envVarMapToBashPrefix(env)+"bash -x "+scriptPath+" "+flagsStr)
+	scriptPath := uuid.NewString() + ".sh"
+	if _, err := RunRemotelyStdin(ctx, logger, vm, strings.NewReader(scriptContents), "cat - > "+scriptPath); err != nil {
+		return CommandOutput{}, fmt.Errorf("could not upload script: %w", err)
+	}
+	return RunRemotelyStdin(ctx, logger, vm, stdin, "sudo "+envVarMapToBashPrefix(env)+"bash -x "+scriptPath+" "+flagsStr)
+}
+
+// quoteScriptFlags quotes each of the given flags with quote, which is
+// shellQuote or powershellQuote, and joins them into a single string to pass
+// to a script.
+func quoteScriptFlags(flags []string, quote func(string) string) string {
+	var quotedFlags []string
+	for _, flag := range flags {
+		quotedFlags = append(quotedFlags, quote(flag))
+	}
+	return strings.Join(quotedFlags, " ")
+}
+
+// gcloudDictDelimiters are the candidate delimiters for gcloudDictFlagValue.
+var gcloudDictDelimiters = []string{",", ";", "|", "~", "@@"}
+
+// gcloudDictFlagValue converts a map of key-value pairs into a form that
+// gcloud will accept as the value of a dictionary flag like --metadata. This
+// is the same as MapToCommaSeparatedList, unless some key or value contains a
+// comma, in which case a different delimiter is chosen and declared using
+// gcloud's "^DELIM^" escaping syntax (see `gcloud topic escaping`).
+func gcloudDictFlagValue(mapping map[string]string) (string, error) {
+	for _, delim := range gcloudDictDelimiters {
+		clash := false
+		for k, v := range mapping {
+			if strings.Contains(k, delim) || strings.Contains(v, delim) {
+				clash = true
+				break
+			}
+		}
+		if clash {
+			continue
+		}
+		var elems []string
+		for k, v := range mapping {
+			elems = append(elems, k+"="+v)
+		}
+		if delim == "," {
+			return strings.Join(elems, ","), nil
+		}
+		return "^" + delim + "^" + strings.Join(elems, delim), nil
+	}
+	return "", errors.New("could not find a delimiter that doesn't appear in any key or value")
 }
 
 // MapToCommaSeparatedList converts a map of key-value pairs into a form that
@@ -1110,7 +3566,35 @@ func prepareSLES(ctx context.Context, logger *log.Logger, vm *VM) error {
 	return err
 }
 
-func addFrameworkMetadata(imageSpec string, inputMetadata map[string]string) (map[string]string, error) {
+// windowsSSHSysprepCmd installs the ssh server on Windows VMs. It has to run
+// during sysprep so that the VM is ssh-able once it has booted.
+const windowsSSHSysprepCmd = "googet -noconfirm=true install google-compute-engine-ssh"
+
+// checkScriptOptions returns an error if options.StartupScript or
+// options.SysprepScript is set on an OS that doesn't support it.
+func checkScriptOptions(options VMOptions) error {
+	if IsWindows(options.ImageSpec) && options.StartupScript != "" {
+		return errors.New("VMOptions.StartupScript is not supported on Windows. Use VMOptions.SysprepScript instead")
+	}
+	if !IsWindows(options.ImageSpec) && options.SysprepScript != "" {
+		return errors.New("VMOptions.SysprepScript is only supported on Windows. Use VMOptions.StartupScript instead")
+	}
+	return nil
+}
+
+// addFrameworkMetadata returns the metadata to create a VM with: the given
+// options.Metadata plus the keys that this library needs, such as ssh-keys.
+// options.StartupScript and options.SysprepScript are merged into the
+// framework's own startup logic.
+func addFrameworkMetadata(options VMOptions) (map[string]string, error) {
+	if err := checkScriptOptions(options); err != nil {
+		return nil, err
+	}
+	if err := checkNoFrameworkMetadataKeys(options.ImageSpec, slices.Collect(maps.Keys(options.Metadata))); err != nil {
+		return nil, err
+	}
+	imageSpec := options.ImageSpec
+	inputMetadata := options.Metadata
 	metadataCopy := make(map[string]string)
 
 	// Set serial-port-logging-enable to true by default to help diagnose startup
@@ -1121,17 +3605,11 @@ func addFrameworkMetadata(imageSpec string, inputMetadata map[string]string) (ma
 		metadataCopy[k] = v
 	}
 
-	if _, ok := metadataCopy["enable-oslogin"]; ok {
-		return nil, errors.New("the 'enable-oslogin' metadata key is reserved for framework use")
-	}
 	// We manage our own ssh keys, so we don't need OS Login. For a while, it
 	// worked to leave it enabled anyway, but one day that broke (b/181867249).
 	// Disabling OS Login fixed the issue.
 	metadataCopy["enable-oslogin"] = "false"
 
-	if _, ok := metadataCopy["ssh-keys"]; ok {
-		return nil, errors.New("the 'ssh-keys' metadata key is reserved for framework use")
-	}
 	publicKey, err := os.ReadFile(publicKeyFile)
 	if err != nil {
 		return nil, fmt.Errorf("could not read local public key file %v: %v", publicKeyFile, err)
@@ -1140,18 +3618,18 @@ func addFrameworkMetadata(imageSpec string, inputMetadata map[string]string) (ma
 
 	if IsWindows(imageSpec) {
 		// From https://cloud.google.com/compute/docs/connect/windows-ssh#create_vm
-		if _, ok := metadataCopy["sysprep-specialize-script-cmd"]; ok {
-			return nil, errors.New("you cannot pass a sysprep script for Windows instances because they are needed to enable ssh-ing. Instead, wait for the instance to be ready and then run things with RunRemotely() or RunScriptRemotely()")
-		}
-		metadataCopy["sysprep-specialize-script-cmd"] = "googet -noconfirm=true install google-compute-engine-ssh"
-
-		if _, ok := metadataCopy["enable-windows-ssh"]; ok {
-			return nil, errors.New("the 'enable-windows-ssh' metadata key is reserved for framework use")
+		metadataCopy["sysprep-specialize-script-cmd"] = windowsSSHSysprepCmd
+		if options.SysprepScript != "" {
+			// Only run the caller's script if ssh was installed successfully.
+			metadataCopy["sysprep-specialize-script-cmd"] += " && " + options.SysprepScript
 		}
 		metadataCopy["enable-windows-ssh"] = "TRUE"
 	} else {
-		if _, ok := metadataCopy["startup-script"]; ok {
-			return nil, errors.New("the 'startup-script' metadata key is reserved for future use. Instead, wait for the instance to be ready and then run things with RunRemotely() or RunScriptRemotely()")
+		// The framework doesn't need a startup script of its own on Linux (ssh
+		// keys are injected through the ssh-keys metadata key instead), so the
+		// caller's script is used as-is.
+		if options.StartupScript != "" {
+			metadataCopy["startup-script"] = options.StartupScript
 		}
 	}
 	return metadataCopy, nil
@@ -1178,26 +3656,11 @@ func installErr(pkg string, imageSpec string) error {
 // gcloudFlagsFromImageSpec returns the flags used in
 // `gcloud compute instances create` to specify the desired image.
 func gcloudFlagsFromImageSpec(imageSpec string) ([]string, error) {
-	delim := ""
-	if strings.Contains(imageSpec, ":") {
-		delim = ":"
-	} else if strings.Contains(imageSpec, "=") {
-		delim = "="
-	} else {
-		return nil, fmt.Errorf("invalid imageSpec: %s", imageSpec)
-	}
-
-	s := strings.Split(imageSpec, delim)
-	flags := []string{
-		"--image-project=" + s[0],
-	}
-	switch delim {
-	case ":":
-		flags = append(flags, "--image-family="+s[1])
-	case "=":
-		flags = append(flags, "--image="+s[1])
+	spec, err := ParseImageSpec(imageSpec)
+	if err != nil {
+		return nil, err
 	}
-	return flags, nil
+	return spec.gcloudFlags(), nil
 }
 
 // getReleaseInfo returns the value of the requested variable in /etc/os-release.
@@ -1211,16 +3674,24 @@ func getReleaseInfo(ctx context.Context, logger *log.Logger, vm *VM, name string
 func getOS(ctx context.Context, logger *log.Logger, vm *VM) (*OS, error) {
 	if IsWindows(vm.ImageSpec) {
 		return &OS{
-			ID: "windows",
+			ID:     "windows",
+			Family: OSFamilyWindows,
 		}, nil
 	}
 	id_output, err := getReleaseInfo(ctx, logger, vm, "ID")
 	if err != nil {
 		return nil, err
 	}
+	// ID_LIKE is optional, and unset on distros that are not derived from
+	// another one (e.g. Debian), in which case this is just empty.
+	id_like_output, err := getReleaseInfo(ctx, logger, vm, "ID_LIKE")
+	if err != nil {
+		return nil, err
+	}
 
 	return &OS{
-		ID: id_output.Stdout,
+		ID:     id_output.Stdout,
+		Family: osFamily(id_output.Stdout, id_like_output.Stdout),
 	}, nil
 }
 
@@ -1231,11 +3702,22 @@ func createVMFromVMOptions(options VMOptions) *VM {
 		Name:      options.Name,
 		Network:   os.Getenv("NETWORK_NAME"),
 		Zone:      options.Zone,
+
+		TransfersBucket: options.TransfersBucket,
+		PreferIPv6:      options.PreferIPv6,
+		UseIAP:          options.UseIAP || os.Getenv("USE_IAP") == "true",
+		ConnectHost:     options.ConnectHost,
+		TimeToLive:      options.TimeToLive,
+	}
+	if vm.TimeToLive == "" && !options.Spot {
+		// Spot VMs can't have a TimeToLive, but they are deleted when
+		// preempted anyway.
+		vm.TimeToLive = defaultTimeToLive
 	}
 	if vm.Name == "" {
 		// The VM name needs to adhere to these restrictions:
 		// https://cloud.google.com/compute/docs/naming-resources#resource-name-format
-		vm.Name = fmt.Sprintf("%s-%s", sandboxPrefix, uuid.New())
+		vm.Name = fmt.Sprintf("%s-%s", sandboxPrefixForServiceAccount(vmServiceAccount(options)), uuid.New())
 	}
 	if vm.Project == "" {
 		vm.Project = os.Getenv("PROJECT")
@@ -1256,12 +3738,205 @@ func createVMFromVMOptions(options VMOptions) *VM {
 	if vm.MachineType == "" {
 		vm.MachineType = "e2-standard-4"
 		if IsARM(vm.ImageSpec) {
-			vm.MachineType = "t2a-standard-4"
+			vm.MachineType = armMachineTypeCandidates(options)[0]
 		}
 	}
 	return vm
 }
 
+// defaultARMMachineTypes are the machine types tried, in order, for ARM VMs
+// whose machine type was not chosen by the caller. Not every zone has all of
+// them, so CreateInstance picks the first one available in its zone.
+// Overridden by DEFAULT_ARM_MACHINE_TYPES if that environment variable is set.
+var defaultARMMachineTypes = []string{"t2a-standard-4", "c4a-standard-4"}
+
+// armMachineTypeCandidates returns the machine types to try, in order, for an
+// ARM VM created with the given options whose machine type was not chosen by
+// the caller.
+func armMachineTypeCandidates(options VMOptions) []string {
+	defaults := defaultARMMachineTypes
+	if env := os.Getenv("DEFAULT_ARM_MACHINE_TYPES"); env != "" {
+		defaults = strings.Split(env, ",")
+	}
+	var candidates []string
+	for _, machineType := range append([]string{options.DefaultARMMachineType}, defaults...) {
+		machineType = strings.TrimSpace(machineType)
+		if machineType != "" && !slices.Contains(candidates, machineType) {
+			candidates = append(candidates, machineType)
+		}
+	}
+	if len(candidates) == 0 {
+		return defaultARMMachineTypes
+	}
+	return candidates
+}
+
+// usesDefaultARMMachineType returns whether a VM created with the given
+// options gets one of the default ARM machine types.
+func usesDefaultARMMachineType(options VMOptions) bool {
+	return IsARM(options.ImageSpec) && options.MachineType == "" && os.Getenv("INSTANCE_SIZE") == ""
+}
+
+// validateMachineTypeInZone checks that the given machine type exists in the
+// given zone, so that a typo or an unavailable machine type produces a clear
+// error before the much slower instance creation is attempted.
+//
+// This is best-effort: an error is only returned if gcloud reports that the
+// machine type was not found. Any other failure (e.g. quota or permission
+// errors) is logged and ignored, leaving it to the creation itself to fail or
+// succeed.
+func validateMachineTypeInZone(ctx context.Context, logger *log.Logger, project, zone, machineType string) error {
+	_, err := runGcloud(ctx, logger, "", []string{
+		"compute", "machine-types", "describe", machineType,
+		"--project=" + project,
+		"--zone=" + zone,
+		"--format=value(name)",
+	})
+	if err == nil {
+		return nil
+	}
+	if strings.Contains(err.Error(), "was not found") {
+		return fmt.Errorf("machine type %q is not available in zone %v of project %v; set a different MachineType (or INSTANCE_SIZE) or zone", machineType, zone, project)
+	}
+	logger.Printf("Could not validate machine type %q in zone %v, continuing anyway: %v", machineType, zone, err)
+	return nil
+}
+
+// pickMachineTypeInZone returns the first of the given machine types that
+// validateMachineTypeInZone accepts.
+func pickMachineTypeInZone(ctx context.Context, logger *log.Logger, project, zone string, candidates []string) (string, error) {
+	var errs error
+	for _, machineType := range candidates {
+		err := validateMachineTypeInZone(ctx, logger, project, zone, machineType)
+		if err == nil {
+			return machineType, nil
+		}
+		errs = multierr.Append(errs, err)
+	}
+	return "", fmt.Errorf("none of the machine types %v are available in zone %v: %w", candidates, zone, errs)
+}
+
+// machineTypeInZone returns the machine type that a VM created with the given
+// options gets in options.Zone, after checking that it is available there.
+// For an ARM VM whose machine type was not chosen by the caller, that is the
+// first of the default ARM machine types that the zone has.
+func machineTypeInZone(ctx context.Context, logger *log.Logger, options VMOptions) (string, error) {
+	vm := createVMFromVMOptions(options)
+	if usesDefaultARMMachineType(options) {
+		return pickMachineTypeInZone(ctx, logger, vm.Project, vm.Zone, armMachineTypeCandidates(options))
+	}
+	if err := validateMachineTypeInZone(ctx, logger, vm.Project, vm.Zone, vm.MachineType); err != nil {
+		return "", err
+	}
+	return vm.MachineType, nil
+}
+
+// PackageRepo describes a package repository for ConfigureAptRepo,
+// ConfigureYumRepo and ConfigureZypperRepo.
+type PackageRepo struct {
+	// Name identifies the repository. For apt, it names the file in
+	// /etc/apt/sources.list.d. For yum and zypper, it is the repository ID
+	// (alias); when disabling a yum repository it may be a glob like "rhui-*".
+	Name string
+	// URL is the base URL of the repository. Only needed when adding it.
+	URL string
+	// Apt only. The suite and components, e.g. "bookworm main". When
+	// disabling, entries for this suite are also removed from
+	// /etc/apt/sources.list.
+	Suite string
+	// Optional. The URL of the key that the repository is signed with. If
+	// empty, signatures are not checked.
+	GPGKeyURL string
+}
+
+// ConfigureAptRepo adds the given repository to the VM if enabled is true,
+// and removes it otherwise. Adding overwrites any previous definition of a
+// repository with the same name, and removing a repository that does not
+// exist is not an error, so this can be called repeatedly. Callers need to
+// run "apt-get update" for the change to take effect.
+func ConfigureAptRepo(ctx context.Context, logger *log.Logger, vm *VM, repo PackageRepo, enabled bool) error {
+	listFile := fmt.Sprintf("/etc/apt/sources.list.d/%s.list", repo.Name)
+	var cmd string
+	if enabled {
+		options := "[trusted=yes] "
+		if repo.GPGKeyURL != "" {
+			keyring := fmt.Sprintf("/usr/share/keyrings/%s.gpg", repo.Name)
+			cmd = fmt.Sprintf("curl -fsSL %s | sudo gpg --dearmor --yes -o %s && ", shellQuote(repo.GPGKeyURL), shellQuote(keyring))
+			options = fmt.Sprintf("[signed-by=%s] ", keyring)
+		}
+		cmd += fmt.Sprintf("echo %s | sudo tee %s", shellQuote(fmt.Sprintf("deb %s%s %s", options, repo.URL, repo.Suite)), shellQuote(listFile))
+	} else {
+		cmd = fmt.Sprintf("sudo rm -f %s", shellQuote(listFile))
+		if repo.Suite != "" {
+			suite := strings.Fields(repo.Suite)[0]
+			cmd += fmt.Sprintf(" && sudo sed --in-place --regexp-extended 's/deb[^ ]* [^ ]+ %s .*//' /etc/apt/sources.list", suite)
+		}
+	}
+	if _, err := runRemotely(ctx, logger, vm, cmd); err != nil {
+		return fmt.Errorf("ConfigureAptRepo(name=%q, enabled=%v) failed: %w", repo.Name, enabled, err)
+	}
+	return nil
+}
+
+// ConfigureYumRepo adds the given repository to the VM if enabled is true,
+// and disables it otherwise. If repo.URL is empty, an existing repository is
+// enabled instead of a new one being added. Disabling a repository that does
+// not exist is not an error, so this can be called repeatedly.
+func ConfigureYumRepo(ctx context.Context, logger *log.Logger, vm *VM, repo PackageRepo, enabled bool) error {
+	repoFile := fmt.Sprintf("/etc/yum.repos.d/%s.repo", repo.Name)
+	var cmd string
+	switch {
+	case enabled && repo.URL != "":
+		gpg := "gpgcheck=0"
+		if repo.GPGKeyURL != "" {
+			gpg = "gpgcheck=1\ngpgkey=" + repo.GPGKeyURL
+		}
+		content := fmt.Sprintf("[%s]\nname=%s\nbaseurl=%s\nenabled=1\n%s", repo.Name, repo.Name, repo.URL, gpg)
+		cmd = fmt.Sprintf("echo %s | sudo tee %s", shellQuote(content), shellQuote(repoFile))
+	case enabled:
+		cmd = fmt.Sprintf(`sudo yum -y install dnf-plugins-core && sudo yum config-manager --enable %s`, shellQuote(repo.Name))
+	default:
+		// Avoid the repository being disabled while installing the
+		// config-manager plugin, in case it is the one that is broken.
+		cmd = fmt.Sprintf(`sudo rm -f %s && sudo yum -y --disablerepo=%s install dnf-plugins-core && sudo yum config-manager --disable %s`,
+			shellQuote(repoFile), shellQuote(repo.Name), shellQuote(repo.Name))
+	}
+	if _, err := runRemotely(ctx, logger, vm, cmd); err != nil {
+		if !enabled && strings.Contains(err.Error(), "No matching repo") {
+			return nil
+		}
+		return fmt.Errorf("ConfigureYumRepo(name=%q, enabled=%v) failed: %w", repo.Name, enabled, err)
+	}
+	return nil
+}
+
+// ConfigureZypperRepo adds the given repository to the VM if enabled is true,
+// and removes it otherwise. Adding a repository whose alias already exists,
+// or removing one that does not exist, is not an error, so this can be called
+// repeatedly.
+func ConfigureZypperRepo(ctx context.Context, logger *log.Logger, vm *VM, repo PackageRepo, enabled bool) error {
+	var cmd string
+	if enabled {
+		if repo.GPGKeyURL != "" {
+			cmd = fmt.Sprintf("sudo rpm --import %s && sudo zypper --non-interactive addrepo --refresh %s %s", shellQuote(repo.GPGKeyURL), shellQuote(repo.URL), shellQuote(repo.Name))
+		} else {
+			cmd = fmt.Sprintf("sudo zypper --non-interactive addrepo --refresh --no-gpgcheck %s %s", shellQuote(repo.URL), shellQuote(repo.Name))
+		}
+	} else {
+		cmd = fmt.Sprintf("sudo zypper --non-interactive removerepo %s", shellQuote(repo.Name))
+	}
+	if _, err := runRemotely(ctx, logger, vm, cmd); err != nil {
+		if enabled && strings.Contains(err.Error(), "already exists") {
+			return nil
+		}
+		if !enabled && strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return fmt.Errorf("ConfigureZypperRepo(name=%q, enabled=%v) failed: %w", repo.Name, enabled, err)
+	}
+	return nil
+}
+
 func verifyVMCreation(ctx context.Context, logger *log.Logger, vm *VM) error {
 	if err := waitForStart(ctx, logger, vm); err != nil {
 		return err
@@ -1297,8 +3972,7 @@ func verifyVMCreation(ctx context.Context, logger *log.Logger, vm *VM) error {
 
 	// Removing flaky rhui repositories due to b/265341502
 	if IsRHEL(vm.ImageSpec) {
-		if _, err := RunRemotely(ctx,
-			logger, vm, `sudo yum -y --disablerepo=rhui-* install dnf-plugins-core && sudo yum config-manager --disable "rhui-*"`); err != nil {
+		if err := ConfigureYumRepo(ctx, logger, vm, PackageRepo{Name: "rhui-*"}, false); err != nil {
 			return fmt.Errorf("disabling flaky repos failed: %w", err)
 		}
 	}
@@ -1310,7 +3984,7 @@ func verifyVMCreation(ctx context.Context, logger *log.Logger, vm *VM) error {
 		}
 
 		// TODO(b/434754681): DLVM image still refers to the non-existent bullseye-backports repo.
-		if _, err := RunRemotely(ctx, logger, vm, "sudo sed --in-place --regexp-extended 's/deb[^ ]* [^ ]+ bullseye-backports .*//' /etc/apt/sources.list"); err != nil {
+		if err := ConfigureAptRepo(ctx, logger, vm, PackageRepo{Name: "bullseye-backports", Suite: "bullseye-backports"}, false); err != nil {
 			return fmt.Errorf("attemptCreateInstance() failed to remove bullseye-backports repo: %v", err)
 		}
 	}
@@ -1326,46 +4000,201 @@ func verifyVMCreation(ctx context.Context, logger *log.Logger, vm *VM) error {
 	return nil
 }
 
-func additionalCreateInstanceArgs(options VMOptions, vm *VM) ([]string, error) {
-	args := []string{}
-	newMetadata, err := addFrameworkMetadata(vm.ImageSpec, options.Metadata)
+// vmServiceAccount returns the email of the service account that a VM created
+// with the given options runs as, or "" for the default service account.
+func vmServiceAccount(options VMOptions) string {
+	if options.ServiceAccountEmail != "" {
+		return options.ServiceAccountEmail
+	}
+	return os.Getenv("SERVICE_EMAIL")
+}
+
+// sandboxPrefixForServiceAccount returns the prefix for the names of VMs that
+// run as the given service account.
+func sandboxPrefixForServiceAccount(email string) string {
+	// This prefix is needed for builds running as build-and-test-external
+	// because that service account is only allowed to interact with VMs whose
+	// names start with "github-" to isolate them from our release builds.
+	// go/sdi-kokoro-security
+	if strings.Contains(email, "build-and-test-external@") && !strings.HasPrefix(sandboxPrefix, "github-") {
+		return "github-" + sandboxPrefix
+	}
+	return sandboxPrefix
+}
+
+func additionalCreateInstanceArgs(options VMOptions, vm *VM) ([]string, error) {
+	args := []string{}
+	newMetadata, err := addFrameworkMetadata(options)
+	if err != nil {
+		return nil, fmt.Errorf("additionalCreateInstanceArgs() could not construct valid metadata: %v", err)
+	}
+	newLabels, err := addFrameworkLabels(options.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("additionalCreateInstanceArgs() could not construct valid labels: %v", err)
+	}
+
+	image_flags, err := gcloudFlagsFromImageSpec(vm.ImageSpec)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, image_flags...)
+	if len(newMetadata) > 0 {
+		// The --metadata flag can't be empty, so we have to have a special case
+		// to omit the flag completely when the newMetadata map is empty.
+		metadataValue, err := gcloudDictFlagValue(newMetadata)
+		if err != nil {
+			return nil, fmt.Errorf("additionalCreateInstanceArgs() could not construct valid metadata: %v", err)
+		}
+		args = append(args, "--metadata="+metadataValue)
+	}
+	if len(newLabels) > 0 {
+		args = append(args, "--labels="+MapToCommaSeparatedList(newLabels))
+	}
+	if email := vmServiceAccount(options); email != "" {
+		args = append(args, "--service-account="+email)
+	}
+	if len(options.Scopes) > 0 {
+		args = append(args, "--scopes="+strings.Join(options.Scopes, ","))
+	}
+	if internalIP := os.Getenv("USE_INTERNAL_IP"); internalIP == "true" {
+		// Don't assign an external IP address. This is to avoid using up
+		// a very limited budget of external IPv4 addresses. The instances
+		// will talk to the external internet by routing through a Cloud NAT
+		// gateway that is configured in our testing project.
+		args = append(args, "--no-address")
+	}
+	provisioningFlags, err := gcloudFlagsForProvisioningModel(vm.TimeToLive, options.Spot)
+	if err != nil {
+		return nil, fmt.Errorf("additionalCreateInstanceArgs() could not choose a provisioning model: %v", err)
+	}
+	args = append(args, provisioningFlags...)
+	gpuFlags, err := gcloudFlagsForGPUs(vm.MachineType, options.GPUType, options.GPUCount)
+	if err != nil {
+		return nil, fmt.Errorf("additionalCreateInstanceArgs() could not attach GPUs: %v", err)
+	}
+	args = append(args, gpuFlags...)
+	tagFlags, err := gcloudFlagsForTags(options.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("additionalCreateInstanceArgs() could not apply network tags: %v", err)
+	}
+	args = append(args, tagFlags...)
+	args = append(args, options.ExtraCreateArguments...)
+
+	return args, nil
+}
+
+// gcloudFlagsForTags returns the flags used in `gcloud compute instances
+// create` (or `instance-templates create`) to give the VM the given network
+// tags.
+func gcloudFlagsForTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	if valid, err := areTagsValid(tags); !valid {
+		return nil, err
+	}
+	return []string{"--tags=" + strings.Join(tags, ",")}, nil
+}
+
+// builtInDefaultTimeToLive is the TimeToLive given to VMs whose VMOptions
+// don't set one, unless DEFAULT_VM_TTL says otherwise. It is comfortably
+// longer than SuggestedTimeout so that it never cuts a test short.
+const builtInDefaultTimeToLive = "4h"
+
+// defaultTimeToLive is the TimeToLive given to VMs whose VMOptions don't set
+// one. It is empty if such VMs should not get a TimeToLive at all.
+// It is set in init() from DEFAULT_VM_TTL.
+var defaultTimeToLive = builtInDefaultTimeToLive
+
+// gcloudDurationRegex matches the durations accepted by
+// gcloud's --max-run-duration, like "90m", "4h" or "1d12h".
+var gcloudDurationRegex = regexp.MustCompile(`^(?:(\d+)d)?(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s)?$`)
+
+// parseGcloudDuration converts a duration accepted by gcloud's
+// --max-run-duration into a time.Duration.
+func parseGcloudDuration(value string) (time.Duration, error) {
+	match := gcloudDurationRegex.FindStringSubmatch(value)
+	if value == "" || match == nil {
+		return 0, fmt.Errorf("invalid duration %q: want a duration like \"4h\" or \"1d\"", value)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %v", value, err)
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
+
+// parseDefaultTimeToLive returns the default TimeToLive configured by the
+// given DEFAULT_VM_TTL value. An empty value selects
+// builtInDefaultTimeToLive, and "none" disables the default. Any other value
+// must be longer than SuggestedTimeout, so that VMs aren't deleted out from
+// under the tests using them.
+func parseDefaultTimeToLive(value string) (string, error) {
+	switch value {
+	case "":
+		return builtInDefaultTimeToLive, nil
+	case "none":
+		return "", nil
+	}
+	d, err := parseGcloudDuration(value)
 	if err != nil {
-		return nil, fmt.Errorf("additionalCreateInstanceArgs() could not construct valid metadata: %v", err)
+		return "", fmt.Errorf("DEFAULT_VM_TTL: %v", err)
 	}
-	newLabels, err := addFrameworkLabels(options.Labels)
-	if err != nil {
-		return nil, fmt.Errorf("additionalCreateInstanceArgs() could not construct valid labels: %v", err)
+	if d <= SuggestedTimeout {
+		return "", fmt.Errorf("DEFAULT_VM_TTL=%q must be longer than SuggestedTimeout (%v)", value, SuggestedTimeout)
 	}
+	return value, nil
+}
 
-	image_flags, err := gcloudFlagsFromImageSpec(vm.ImageSpec)
-	if err != nil {
-		return nil, err
+// gcloudFlagsForProvisioningModel returns the flags needed to create a VM
+// that is deleted after the given timeToLive (if any), or a Spot VM if spot
+// is set. The two can't be combined.
+func gcloudFlagsForProvisioningModel(timeToLive string, spot bool) ([]string, error) {
+	if timeToLive != "" && spot {
+		return nil, fmt.Errorf("Spot and TimeToLive can't be set together: TimeToLive needs the STANDARD provisioning model, got TimeToLive=%q", timeToLive)
 	}
-	args = append(args, image_flags...)
-	if len(newMetadata) > 0 {
-		// The --metadata flag can't be empty, so we have to have a special case
-		// to omit the flag completely when the newMetadata map is empty.
-		args = append(args, "--metadata="+MapToCommaSeparatedList(newMetadata))
+	if timeToLive != "" {
+		return []string{"--max-run-duration=" + timeToLive, "--instance-termination-action=DELETE", "--provisioning-model=STANDARD"}, nil
 	}
-	if len(newLabels) > 0 {
-		args = append(args, "--labels="+MapToCommaSeparatedList(newLabels))
+	if spot {
+		return []string{"--provisioning-model=SPOT", "--instance-termination-action=DELETE"}, nil
 	}
-	if email := os.Getenv("SERVICE_EMAIL"); email != "" {
-		args = append(args, "--service-account="+email)
+	return nil, nil
+}
+
+// builtInGPUMachineFamilies are the machine families whose machine types
+// come with GPUs already attached.
+var builtInGPUMachineFamilies = []string{"a2", "a3", "a4", "g2", "g4"}
+
+// gcloudFlagsForGPUs returns the flags needed to attach the given number of
+// GPUs of the given type to a VM of the given machine type, or nil if no GPUs
+// are requested.
+func gcloudFlagsForGPUs(machineType, gpuType string, gpuCount int) ([]string, error) {
+	if gpuType == "" && gpuCount == 0 {
+		return nil, nil
 	}
-	if internalIP := os.Getenv("USE_INTERNAL_IP"); internalIP == "true" {
-		// Don't assign an external IP address. This is to avoid using up
-		// a very limited budget of external IPv4 addresses. The instances
-		// will talk to the external internet by routing through a Cloud NAT
-		// gateway that is configured in our testing project.
-		args = append(args, "--no-address")
+	if gpuType == "" || gpuCount <= 0 {
+		return nil, fmt.Errorf("GPUType and GPUCount must be set together, got GPUType=%q, GPUCount=%d", gpuType, gpuCount)
 	}
-	if options.TimeToLive != "" {
-		args = append(args, "--max-run-duration="+options.TimeToLive, "--instance-termination-action=DELETE", "--provisioning-model=STANDARD")
+	family, _, _ := strings.Cut(machineType, "-")
+	if slices.Contains(builtInGPUMachineFamilies, family) {
+		return nil, fmt.Errorf("machine type %q already comes with GPUs; leave GPUType and GPUCount unset", machineType)
 	}
-	args = append(args, options.ExtraCreateArguments...)
-
-	return args, nil
+	if family != "n1" {
+		return nil, fmt.Errorf("GPUs can only be attached to N1 machine types, got %q; set MachineType to e.g. \"n1-standard-4\"", machineType)
+	}
+	return []string{
+		fmt.Sprintf("--accelerator=type=%s,count=%d", gpuType, gpuCount),
+		// VMs with GPUs can't be live migrated.
+		"--maintenance-policy=TERMINATE",
+	}, nil
 }
 
 // attemptCreateInstance creates a VM instance and waits for it to be ready.
@@ -1381,8 +4210,7 @@ func attemptCreateInstance(ctx context.Context, logger *log.Logger, options VMOp
 	}
 
 	args := []string{
-		// "beta" is needed for --max-run-duration.
-		"beta", "compute", "instances", "create", vm.Name,
+		createTrack(ctx), "compute", "instances", "create", vm.Name,
 		"--project=" + vm.Project,
 		"--zone=" + vm.Zone,
 		"--machine-type=" + vm.MachineType,
@@ -1425,7 +4253,7 @@ func attemptCreateInstance(ctx context.Context, logger *log.Logger, options VMOp
 
 	logger.Printf("Instance Log: %v", instanceLogURL(vm))
 
-	ipAddress, err := extractIPAddress(output.Stdout)
+	ipAddress, err := extractIPAddress(output.Stdout, vm.PreferIPv6)
 	if err != nil {
 		return nil, err
 	}
@@ -1443,6 +4271,72 @@ func attemptCreateInstance(ctx context.Context, logger *log.Logger, options VMOp
 	return vm, nil
 }
 
+// managedInstanceGroupCreateArgs returns the gcloud arguments to create the
+// given VM's (empty) Managed Instance Group. A regional group spreads its
+// instances across the given zones, or across zones that Compute Engine
+// chooses if there are none.
+func managedInstanceGroupCreateArgs(migVM *ManagedInstanceGroupVM, zones []string) []string {
+	args := []string{
+		"compute", "instance-groups", "managed", "create", migVM.ManagedInstanceGroupName(),
+		"--project=" + migVM.Project,
+		migVM.locationFlag(),
+		"--size=0",
+		"--template=" + migVM.InstanceTemplateName(),
+		"--format=json",
+	}
+	if migVM.Region != "" && len(zones) > 0 {
+		args = append(args, "--zones="+strings.Join(zones, ","))
+	}
+	return args
+}
+
+// regionalMIGZones returns the zones from ZONES that are in the given region,
+// for a regional Managed Instance Group to spread its instances across. It
+// returns nil if there are fewer than two, in which case Compute Engine
+// chooses the zones instead, so that the group still spans several.
+func regionalMIGZones(region string) []string {
+	var zones []string
+	for _, zone := range zonePicker.Zones() {
+		if zoneRegion(zone) == region {
+			zones = append(zones, zone)
+		}
+	}
+	if len(zones) < 2 {
+		return nil
+	}
+	return zones
+}
+
+// managedInstanceZone returns the zone that the given VM was created in by
+// its regional Managed Instance Group.
+func managedInstanceZone(ctx context.Context, logger *log.Logger, migVM *ManagedInstanceGroupVM) (string, error) {
+	instances, err := listMIGInstances(ctx, logger, migVM)
+	if err != nil {
+		return "", err
+	}
+	for _, instance := range instances {
+		if instance.Name == migVM.Name {
+			return instance.Zone, nil
+		}
+	}
+	return "", fmt.Errorf("could not find instance %v in %v", migVM.Name, migVM.ManagedInstanceGroupName())
+}
+
+// newManagedInstanceGroupVM returns the ManagedInstanceGroupVM that
+// attemptCreateManagedInstanceGroupVM creates for the given options.
+func newManagedInstanceGroupVM(options VMOptions) *ManagedInstanceGroupVM {
+	migVM := &ManagedInstanceGroupVM{
+		VM: createVMFromVMOptions(options),
+	}
+	// The group recreates an instance that deletes itself, so the default
+	// TimeToLive would only churn instances without bounding anything.
+	migVM.TimeToLive = options.TimeToLive
+	if options.Regional {
+		migVM.Region = zoneRegion(migVM.Zone)
+	}
+	return migVM
+}
+
 // attemptCreateManagedInstanceGroupVM creates an individual VM instance in a Managed Instance Group
 // and waits for it to be ready.
 // Returns a ManagedInstanceGroupVM object or an error (never both). The caller is responsible for
@@ -1450,16 +4344,13 @@ func attemptCreateInstance(ctx context.Context, logger *log.Logger, options VMOp
 func attemptCreateManagedInstanceGroupVM(ctx context.Context, logger *log.Logger, options VMOptions) (migVmToReturn *ManagedInstanceGroupVM, errToReturn error) {
 	// We need a shorter uuid here to add suffixes and not go over the 63 character limit
 	// for resource names.
-	options.Name = fmt.Sprintf("%s-%s", sandboxPrefix, uuid.NewString()[:30])
+	options.Name = fmt.Sprintf("%s-%s", sandboxPrefixForServiceAccount(vmServiceAccount(options)), uuid.NewString()[:30])
 
-	migVM := &ManagedInstanceGroupVM{
-		VM: createVMFromVMOptions(options),
-	}
+	migVM := newManagedInstanceGroupVM(options)
 
 	// Step #1 : Create vm instance template
 	createTemplateArgs := []string{
-		// "beta" is needed for --max-run-duration.
-		"beta", "compute", "instance-templates", "create", migVM.InstanceTemplateName(),
+		createTrack(ctx), "compute", "instance-templates", "create", migVM.InstanceTemplateName(),
 		"--project=" + migVM.Project,
 		"--machine-type=" + migVM.MachineType,
 		"--network=" + migVM.Network,
@@ -1491,16 +4382,11 @@ func attemptCreateManagedInstanceGroupVM(ctx context.Context, logger *log.Logger
 	}()
 
 	// Step #2 : Create empty Managed Instance Group using template.
-	createMIGArgs := []string{
-		"compute", "instance-groups", "managed", "create", migVM.ManagedInstanceGroupName(),
-		"--project=" + migVM.Project,
-		"--zone=" + migVM.Zone,
-		"--size=0",
-		"--template=" + migVM.InstanceTemplateName(),
-		"--format=json",
+	var distributionZones []string
+	if migVM.Region != "" {
+		distributionZones = regionalMIGZones(migVM.Region)
 	}
-
-	output, err = RunGcloud(ctx, logger, "", createMIGArgs)
+	output, err = RunGcloud(ctx, logger, "", managedInstanceGroupCreateArgs(migVM, distributionZones))
 	if err != nil {
 		return nil, err
 	}
@@ -1510,7 +4396,7 @@ func attemptCreateManagedInstanceGroupVM(ctx context.Context, logger *log.Logger
 		"compute", "instance-groups", "managed", "create-instance", migVM.ManagedInstanceGroupName(),
 		"--instance=" + migVM.Name,
 		"--project=" + migVM.Project,
-		"--zone=" + migVM.Zone,
+		migVM.locationFlag(),
 		"--format=json",
 	}
 
@@ -1520,19 +4406,15 @@ func attemptCreateManagedInstanceGroupVM(ctx context.Context, logger *log.Logger
 	}
 
 	// Step #4 : Wait until Managed Instance Group is stable with a 300s timeout.
-	waitUntilStableArgs := []string{
-		"compute", "instance-groups", "managed", "wait-until", migVM.ManagedInstanceGroupName(),
-		"--stable",
-		"--timeout=300",
-		"--project=" + migVM.Project,
-		"--zone=" + migVM.Zone,
-		"--format=json",
-	}
-
-	output, err = RunGcloud(ctx, logger, "", waitUntilStableArgs)
-	if err != nil {
+	if err := waitForManagedInstanceGroupStable(ctx, logger, migVM); err != nil {
 		return nil, err
 	}
+	if migVM.Region != "" {
+		// A regional group chooses which of its zones to create the VM in.
+		if migVM.Zone, err = managedInstanceZone(ctx, logger, migVM); err != nil {
+			return nil, err
+		}
+	}
 
 	// Step #5 : Query newly created VM metadata.
 	listVMArgs := []string{
@@ -1557,7 +4439,7 @@ func attemptCreateManagedInstanceGroupVM(ctx context.Context, logger *log.Logger
 
 	logger.Printf("Instance Log: %v", instanceLogURL(migVM.VM))
 
-	ipAddress, err := extractIPAddress(output.Stdout)
+	ipAddress, err := extractIPAddress(output.Stdout, migVM.PreferIPv6)
 	if err != nil {
 		return nil, err
 	}
@@ -1580,49 +4462,70 @@ func IsSLESVM(vm *VM) bool {
 }
 
 func IsSUSEVM(vm *VM) bool {
+	if vm.OS.Family != "" {
+		return vm.OS.IsSUSELike()
+	}
 	return vm.OS.ID == "opensuse" || vm.OS.ID == "opensuse-leap" || IsSLESVM(vm)
 }
 
 func IsSUSEImageSpec(imageSpec string) bool {
-	return strings.HasPrefix(imageSpec, "suse-") || strings.HasPrefix(imageSpec, "opensuse-") || strings.Contains(imageSpec, "sles-")
+	return unparsedImageSpec(imageSpec).IsSUSE()
 }
 
 func IsCentOS(imageSpec string) bool {
-	return strings.HasPrefix(imageSpec, "centos-cloud")
+	return unparsedImageSpec(imageSpec).IsCentOS()
 }
 
 func IsRHEL(imageSpec string) bool {
-	return strings.HasPrefix(imageSpec, "rhel-")
+	return unparsedImageSpec(imageSpec).IsRHEL()
+}
+
+// IsRHELLikeVM returns whether the VM runs RHEL or a distro compatible with
+// it. It uses the OS family reported by the VM itself when it is known, and
+// falls back to matching the image spec otherwise.
+func IsRHELLikeVM(vm *VM) bool {
+	if vm.OS.Family != "" {
+		return vm.OS.IsRHELLike()
+	}
+	s := unparsedImageSpec(vm.ImageSpec)
+	return s.IsRHEL() || s.IsRocky() || s.IsCentOS()
 }
 
 func isRHEL9(imageSpec string) bool {
-	return strings.Contains(imageSpec, "rhel-9") || strings.Contains(imageSpec, "rocky-linux-9") || strings.Contains(imageSpec, "almalinux-9")
+	return unparsedImageSpec(imageSpec).isRHEL9()
 }
 
 func isRHEL7SAPHA(imageSpec string) bool {
-	return strings.Contains(imageSpec, "rhel-7") && strings.HasPrefix(imageSpec, "rhel-sap-cloud")
+	return unparsedImageSpec(imageSpec).isRHEL7SAPHA()
 }
 
 func IsDLVMImage(imageSpec string) bool {
-	return strings.HasPrefix(imageSpec, "ml-images")
+	return unparsedImageSpec(imageSpec).IsDLVM()
 }
 
 func IsRocky(imageSpec string) bool {
-	return strings.Contains(imageSpec, "rocky-linux-") || strings.Contains(imageSpec, "almalinux-")
+	return unparsedImageSpec(imageSpec).IsRocky()
 }
 
 func IsRpm(imageSpec string) bool {
-	return IsRHEL(imageSpec) || IsSUSEImageSpec(imageSpec) || IsRocky(imageSpec) || IsCentOS(imageSpec)
+	return unparsedImageSpec(imageSpec).IsRpm()
 }
 
 func IsARM(imageSpec string) bool {
-	// At the time of writing, all ARM images and image families on GCE
-	// contain "arm64" (and none contain "aarch" nor "arm" without the "64").
-	return strings.Contains(imageSpec, "arm64")
+	return unparsedImageSpec(imageSpec).IsARM()
 }
 
 func IsDebianBased(imageSpec string) bool {
-	return strings.Contains(imageSpec, "debian") || strings.Contains(imageSpec, "ubuntu")
+	return unparsedImageSpec(imageSpec).IsDebianBased()
+}
+
+// IsDebianBasedVM is like IsDebianBased, but uses the OS family reported by
+// the VM itself when it is known.
+func IsDebianBasedVM(vm *VM) bool {
+	if vm.OS.Family != "" {
+		return vm.OS.IsDebianLike()
+	}
+	return IsDebianBased(vm.ImageSpec)
 }
 
 func IsOpsAgentUAPPlugin() bool {
@@ -1631,17 +4534,91 @@ func IsOpsAgentUAPPlugin() bool {
 	return ok && value != ""
 }
 
+// isZoneResourceExhaustedError returns whether the given error means that the
+// zone the VM was being created in does not have enough resources (usually of
+// the requested machine type) available right now.
+func isZoneResourceExhaustedError(err error) bool {
+	return strings.Contains(err.Error(), "currently unavailable") ||
+		strings.Contains(err.Error(), "ZONE_RESOURCE_POOL_EXHAUSTED") ||
+		strings.Contains(err.Error(), "does not have enough resources available")
+}
+
+// permanentGcloudErrors are substrings of gcloud errors that retrying the
+// same command won't fix. These take precedence over transientGcloudErrors,
+// since e.g. a permission error may mention a quota that the caller isn't
+// allowed to use.
+var permanentGcloudErrors = []string{
+	"Permission denied",
+	"PERMISSION_DENIED",
+	// gcloud reports missing IAM permissions as e.g.
+	// "Required 'compute.instances.create' permission for ...".
+	"' permission for ",
+	"Invalid value",
+	"INVALID_ARGUMENT",
+	"invalid argument",
+	"not found",
+}
+
+// transientGcloudErrors are substrings of gcloud errors that are worth
+// retrying.
+var transientGcloudErrors = []string{
+	// Creating and deleting VMs can hit quota, especially when re-running
+	// presubmits, or when multiple people are running tests.
+	"Quota",
+	// Rarely, commands fail due to internal errors in the compute API.
+	"Internal error",
+	// This error is a consequence of running gcloud concurrently, which is
+	// actually unsupported. In the absence of a better fix, just retry such
+	// errors.
+	"database is locked",
+	// GCE sometimes responds with 502 or 503 errors. Retry these (and other
+	// 50x errors for good measure).
+	"Error 50",
+}
+
+// classifyGcloudError returns whether the given error from a gcloud command
+// is transient, so that running the command again may succeed. Errors that
+// match none of the known patterns are treated as permanent.
+func classifyGcloudError(err error) (retriable bool) {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, pattern := range permanentGcloudErrors {
+		if strings.Contains(msg, pattern) {
+			return false
+		}
+	}
+	for _, pattern := range transientGcloudErrors {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	// Instance creation can also fail due to service unavailability or a
+	// zone running out of resources.
+	return isZoneResourceExhaustedError(err)
+}
+
+// isSpotCapacityUnavailableError returns whether the given error means that
+// a Spot VM could not be created because Compute Engine has no spare capacity
+// for it right now, or that it was preempted before it became ready.
+func isSpotCapacityUnavailableError(err error, options VMOptions) bool {
+	return options.Spot &&
+		(isZoneResourceExhaustedError(err) ||
+			strings.Contains(err.Error(), "PREEMPTIBLE") ||
+			strings.Contains(err.Error(), "preempted"))
+}
+
+// defaultMaxSpotAttempts is how many times CreateInstance tries to create a
+// Spot VM before falling back to a standard one, unless
+// VMOptions.MaxSpotAttempts says otherwise.
+const defaultMaxSpotAttempts = 3
+
 func shouldRetryCreateVM(err error, options VMOptions) bool {
-	// VM creation can hit quota, especially when re-running presubmits,
-	// or when multple people are running tests.
-	return strings.Contains(err.Error(), "Quota") ||
-		// Rarely, instance creation fails due to internal errors in the compute API.
-		strings.Contains(err.Error(), "Internal error") ||
-		// Instance creation can also fail due to service unavailability.
-		strings.Contains(err.Error(), "currently unavailable") ||
-		// This error is a consequence of running gcloud concurrently, which is actually
-		// unsupported. In the absence of a better fix, just retry such errors.
-		strings.Contains(err.Error(), "database is locked") ||
+	return classifyGcloudError(err) ||
+		// Spot VMs are only created when there is spare capacity, and
+		// CreateInstance eventually falls back to a standard VM.
+		isSpotCapacityUnavailableError(err, options) ||
 		// windows-*-core instances sometimes fail to be ssh-able: b/305721001
 		(IsWindowsCore(options.ImageSpec) && strings.Contains(err.Error(), windowsStartupFailedMessage)) ||
 		// SLES instances sometimes fail to be ssh-able: b/186426190
@@ -1664,18 +4641,84 @@ func CreateInstance(origCtx context.Context, logger *log.Logger, options VMOptio
 	// immediately.
 	// If retriable errors happen quickly, there will be more than 3 attempts.
 	// If retriable errors happen slowly, there will still be at least 3 attempts.
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
 	ctx, cancel := context.WithTimeout(origCtx, 3*vmInitTimeout)
 	defer cancel()
 
+	maxSpotAttempts := options.MaxSpotAttempts
+	if maxSpotAttempts <= 0 {
+		maxSpotAttempts = defaultMaxSpotAttempts
+	}
+
 	var vm *VM
+	// Zones that ran out of resources during a previous attempt. Later attempts
+	// will try other zones instead, if the caller didn't ask for a specific one.
+	var exhaustedZones []string
+	// Attempts that failed because no Spot capacity was available. Once there
+	// are maxSpotAttempts of them, later attempts create a standard VM instead.
+	spotFailures := 0
+	// The machine type to use in each zone tried so far, or why there is none,
+	// so that it is only checked against a zone once rather than on every
+	// attempt. Like exhaustedZones, zones without the machine type are skipped
+	// if the caller didn't ask for a specific one.
+	machineTypes := map[string]string{}
+	machineTypeErrs := map[string]error{}
 	createFunc := func() error {
 		attemptCtx, cancel := context.WithTimeout(ctx, vmInitTimeout)
 		defer cancel()
 
+		attemptOptions := options
+		for {
+			if options.Zone == "" {
+				withoutMachineType := slices.Collect(maps.Keys(machineTypeErrs))
+				attemptOptions.Zone = zonePicker.NextExcluding(append(withoutMachineType, exhaustedZones...)...)
+				if machineTypeErrs[attemptOptions.Zone] != nil {
+					// Every zone is excluded. A zone that ran out of resources
+					// may have recovered since, but one without the machine
+					// type won't have.
+					attemptOptions.Zone = zonePicker.NextExcluding(withoutMachineType...)
+				}
+			}
+			if err := machineTypeErrs[attemptOptions.Zone]; err != nil {
+				// No zone has the machine type.
+				return backoff.Permanent(err)
+			}
+			if _, ok := machineTypes[attemptOptions.Zone]; ok {
+				break
+			}
+			machineType, err := machineTypeInZone(attemptCtx, logger, attemptOptions)
+			if err != nil && options.Zone != "" {
+				return backoff.Permanent(err)
+			}
+			if err != nil {
+				logger.Printf("Zone %v can't create the VM, will try a different zone: %v", attemptOptions.Zone, err)
+				machineTypeErrs[attemptOptions.Zone] = err
+				continue
+			}
+			machineTypes[attemptOptions.Zone] = machineType
+			break
+		}
+		attemptOptions.MachineType = machineTypes[attemptOptions.Zone]
+		if attemptOptions.Spot && spotFailures >= maxSpotAttempts {
+			attemptOptions.Spot = false
+		}
+
 		var err error
-		vm, err = attemptCreateInstance(attemptCtx, logger, options)
+		vm, err = attemptCreateInstance(attemptCtx, logger, attemptOptions)
 
-		if err != nil && !shouldRetryCreateVM(err, options) {
+		if err != nil && options.Zone == "" && isZoneResourceExhaustedError(err) {
+			logger.Printf("Zone %v is out of resources, will try a different zone", attemptOptions.Zone)
+			exhaustedZones = append(exhaustedZones, attemptOptions.Zone)
+		}
+		if err != nil && isSpotCapacityUnavailableError(err, attemptOptions) {
+			spotFailures++
+			if spotFailures == maxSpotAttempts {
+				logger.Printf("No Spot capacity after %d attempts, will fall back to a standard VM", spotFailures)
+			}
+		}
+		if err != nil && !shouldRetryCreateVM(err, attemptOptions) {
 			err = backoff.Permanent(err)
 		}
 		// Returning a non-permanent error triggers retries.
@@ -1689,8 +4732,208 @@ func CreateInstance(origCtx context.Context, logger *log.Logger, options VMOptio
 	return vm, nil
 }
 
+// disk is the subset of the output of "gcloud compute disks describe" that
+// AttachToExistingVM needs.
+type disk struct {
+	// This is the URL of the image that the disk was created from.
+	SourceImage string
+}
+
+// imageSpecFromSourceImage converts the URL of the image a disk was created
+// from, e.g. ".../projects/debian-cloud/global/images/debian-12-bookworm-v20240110",
+// into an image spec like "debian-cloud=debian-12-bookworm-v20240110".
+func imageSpecFromSourceImage(sourceImage string) (string, error) {
+	parts := strings.Split(sourceImage, "/")
+	for i := 0; i+4 < len(parts); i++ {
+		if parts[i] == "projects" && parts[i+2] == "global" && parts[i+3] == "images" {
+			return parts[i+1] + "=" + parts[i+4], nil
+		}
+	}
+	return "", fmt.Errorf("could not find the image project and name in source image %q", sourceImage)
+}
+
+// vmFromInstance fills in a VM for the given instance, as described by
+// "gcloud compute instances describe". ImageSpec and OS are left for the
+// caller to fill in, since they need more calls to determine.
+func vmFromInstance(project, zone, name string, inst instance) (*VM, error) {
+	if inst.Status != "RUNNING" {
+		return nil, fmt.Errorf("instance %v has status %v, want RUNNING", name, inst.Status)
+	}
+	info, err := toInstanceInfo(inst)
+	if err != nil {
+		return nil, err
+	}
+	vm := &VM{
+		Name:        name,
+		Project:     project,
+		Zone:        zone,
+		MachineType: info.MachineType,
+		ID:          info.ID,
+		UseIAP:      os.Getenv("USE_IAP") == "true",
+	}
+	if len(info.NetworkInterfaces) > 0 {
+		vm.Network = info.NetworkInterfaces[0].Network
+	}
+	if d := inst.Scheduling.MaxRunDuration; d != nil && inst.Scheduling.InstanceTerminationAction == "DELETE" {
+		if _, err := strconv.ParseInt(d.Seconds, 10, 64); err != nil {
+			return nil, fmt.Errorf("could not parse the max run duration of instance %v: %v", name, err)
+		}
+		vm.TimeToLive = d.Seconds + "s"
+	}
+	if !vm.UseIAP {
+		if vm.IPAddress, err = instanceIPAddress(inst, false); err != nil {
+			return nil, err
+		}
+	}
+	return vm, nil
+}
+
+// bootDiskName returns the short name of the given instance's boot disk, or
+// "" if it has none.
+func bootDiskName(inst instance) string {
+	for _, d := range inst.Disks {
+		if d.Boot {
+			return path.Base(d.Source)
+		}
+	}
+	return ""
+}
+
+// AttachToExistingVM returns a VM for an already running instance, e.g. one
+// kept by SetKeepVMsOnFailure(true) during a previous run, so that it can be
+// reused instead of creating a new one. The instance must have been created
+// with this library's ssh keys.
+//
+// The VM's ImageSpec is worked out from the image its boot disk was created
+// from, which is an image name rather than the image family the VM may have
+// been created from. If that fails, it is left empty, which means the VM is
+// treated as a Linux VM.
+//
+// The caller is responsible for deleting the VM, if it wants it deleted.
+func AttachToExistingVM(ctx context.Context, logger *log.Logger, project, zone, name string) (*VM, error) {
+	var inst instance
+	if err := RunGcloudJSON(ctx, logger, []string{
+		"compute", "instances", "describe", name,
+		"--project=" + project,
+		"--zone=" + zone,
+	}, &inst); err != nil {
+		return nil, fmt.Errorf("AttachToExistingVM(%v) failed: %w", name, err)
+	}
+	vm, err := vmFromInstance(project, zone, name, inst)
+	if err != nil {
+		return nil, fmt.Errorf("AttachToExistingVM(%v) failed: %v", name, err)
+	}
+
+	// This is best-effort, so just warn and proceed if it fails.
+	if diskName := bootDiskName(inst); diskName != "" {
+		var d disk
+		err := RunGcloudJSON(ctx, logger, []string{
+			"compute", "disks", "describe", diskName,
+			"--project=" + project,
+			"--zone=" + zone,
+		}, &d)
+		if err == nil {
+			vm.ImageSpec, err = imageSpecFromSourceImage(d.SourceImage)
+		}
+		if err != nil {
+			logger.Printf("Could not determine the image spec of VM %v: %v", name, err)
+		}
+	}
+
+	if err := waitForStart(ctx, logger, vm); err != nil {
+		return nil, fmt.Errorf("AttachToExistingVM(%v) failed: %v", name, err)
+	}
+	osInfo, err := getOS(ctx, logger, vm)
+	if err != nil {
+		return nil, fmt.Errorf("AttachToExistingVM(%v) failed: %v", name, err)
+	}
+	vm.OS = *osInfo
+	logger.Printf("Attached to VM: %#v", vm)
+	return vm, nil
+}
+
+// attachToExistingVM is AttachToExistingVM.
+var attachToExistingVM = AttachToExistingVM
+
+// isInstanceNotFoundError returns whether the given error, returned from
+// AttachToExistingVM, means that there is no instance with that name.
+func isInstanceNotFoundError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "was not found")
+}
+
+// CreateInstancesParallelism is the maximum number of VMs that CreateInstances
+// creates at the same time.
+var CreateInstancesParallelism = 8
+
+// createInstance is CreateInstance.
+var createInstance = CreateInstance
+
+// deleteInstance is DeleteInstance.
+var deleteInstance = DeleteInstance
+
+// collectDiagnostics is CollectDiagnostics.
+var collectDiagnostics = CollectDiagnostics
+
+// CreateInstances launches a new VM instance for each of the given options,
+// like CreateInstance does (including its retries), but creates up to
+// CreateInstancesParallelism of them concurrently.
+// Returns the VMs in the same order as optsList, or the combined errors of all
+// VMs that could not be created (never both). If any VM could not be created,
+// the ones that were are deleted before returning. The caller is responsible
+// for deleting the VMs if (and only if) the returned error is nil.
+func CreateInstances(ctx context.Context, logger *log.Logger, optsList []VMOptions) ([]*VM, error) {
+	vms := make([]*VM, len(optsList))
+	createErrs := make([]error, len(optsList))
+	slots := make(chan struct{}, max(CreateInstancesParallelism, 1))
+	var wg sync.WaitGroup
+	for i, options := range optsList {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			vm, err := createInstance(ctx, logger, options)
+			if err != nil {
+				createErrs[i] = fmt.Errorf("CreateInstances() could not create a VM with image %v: %w", options.ImageSpec, err)
+				return
+			}
+			vms[i] = vm
+		}()
+	}
+	wg.Wait()
+
+	err := multierr.Combine(createErrs...)
+	if err == nil {
+		return vms, nil
+	}
+
+	// Don't leak the VMs that were created.
+	deleteErrs := make([]error, len(vms))
+	for i, vm := range vms {
+		if vm == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if deleteErr := deleteInstance(ctx, logger, vm); deleteErr != nil {
+				deleteErrs[i] = fmt.Errorf("CreateInstances() could not clean up VM %v: %w", vm.Name, deleteErr)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil, multierr.Append(err, multierr.Combine(deleteErrs...))
+}
+
 // CreateManagedInstanceGroupVM launches a new Managed Instance Group VM instance based on the given options.
 // Also waits for the instance to be reachable over ssh.
+//
+// Unlike CreateInstance, the instance doesn't get the default TimeToLive,
+// because the group would just recreate it once it deleted itself. Groups
+// and instance templates leaked by crashed tests are instead bounded by
+// CleanupOrphanedMIGResources, which deletes them once they are older than a
+// given age, so run it periodically in projects that use this.
 // Returns a ManagedInstanceGroupVM object or an error (never both). The caller is responsible for
 // deleting the ManagedInstanceGroupVM if (and only if) the returned error is nil.
 func CreateManagedInstanceGroupVM(origCtx context.Context, logger *log.Logger, options VMOptions) (*ManagedInstanceGroupVM, error) {
@@ -1699,29 +4942,264 @@ func CreateManagedInstanceGroupVM(origCtx context.Context, logger *log.Logger, o
 	// immediately.
 	// If retriable errors happen quickly, there will be more than 3 attempts.
 	// If retriable errors happen slowly, there will still be at least 3 attempts.
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	if spec, _ := ParseImageSpec(options.ImageSpec); spec.Snapshot() != "" {
+		return nil, fmt.Errorf("CreateManagedInstanceGroupVM() does not support snapshot image spec %q: instance templates can't be created from snapshots", options.ImageSpec)
+	}
 	ctx, cancel := context.WithTimeout(origCtx, 3*vmInitTimeout)
 	defer cancel()
 
 	var migVM *ManagedInstanceGroupVM
+	// Zones that ran out of resources during a previous attempt. Later attempts
+	// will try other zones instead, if the caller didn't ask for a specific one.
+	var exhaustedZones []string
 	createFunc := func() error {
 		attemptCtx, cancel := context.WithTimeout(ctx, vmInitTimeout)
 		defer cancel()
 
+		attemptOptions := options
+		if attemptOptions.Zone == "" {
+			attemptOptions.Zone = zonePicker.NextExcluding(exhaustedZones...)
+		}
+
 		var err error
-		migVM, err = attemptCreateManagedInstanceGroupVM(attemptCtx, logger, options)
+		migVM, err = attemptCreateManagedInstanceGroupVM(attemptCtx, logger, attemptOptions)
+
+		if err != nil && options.Zone == "" && isZoneResourceExhaustedError(err) {
+			logger.Printf("Zone %v is out of resources, will try a different zone", attemptOptions.Zone)
+			exhaustedZones = append(exhaustedZones, attemptOptions.Zone)
+		}
+		if err != nil && !shouldRetryCreateManagedInstanceGroupVM(err, options) {
+			err = backoff.Permanent(err)
+		}
+		// Returning a non-permanent error triggers retries.
+		return err
+	}
+	backoffPolicy := backoff.WithContext(backoff.NewConstantBackOff(time.Minute), ctx)
+	if err := backoff.Retry(createFunc, backoffPolicy); err != nil {
+		return nil, err
+	}
+	logger.Printf("Managed Instance Group VM is ready: %#v", migVM.VM)
+	return migVM, nil
+}
+
+// ManagedInstance is a member of a Managed Instance Group.
+type ManagedInstance struct {
+	Name string
+	ID   int64
+	// The zone that the instance is in, which differs between the instances
+	// of a regional Managed Instance Group.
+	Zone string
+	// The instance status, e.g. "RUNNING".
+	Status string
+}
+
+// parseManagedInstances parses the output of
+// `gcloud compute instance-groups managed list-instances --format=json`.
+func parseManagedInstances(stdout string) ([]ManagedInstance, error) {
+	var raw []struct {
+		ID             string
+		Instance       string
+		InstanceStatus string
+	}
+	if err := json.Unmarshal([]byte(stdout), &raw); err != nil {
+		return nil, fmt.Errorf("could not parse JSON from %q: %v", stdout, err)
+	}
+	var instances []ManagedInstance
+	for _, r := range raw {
+		id, err := strconv.ParseInt(r.ID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse instance ID %q for instance %v: %v", r.ID, r.Instance, err)
+		}
+		instances = append(instances, ManagedInstance{
+			// Instance is a URL ending in ".../zones/<zone>/instances/<name>".
+			Name:   path.Base(r.Instance),
+			ID:     id,
+			Zone:   path.Base(path.Dir(path.Dir(r.Instance))),
+			Status: r.InstanceStatus,
+		})
+	}
+	return instances, nil
+}
+
+// ListManagedInstanceGroupInstances lists the instances that are currently
+// members of the given VM's Managed Instance Group.
+func ListManagedInstanceGroupInstances(ctx context.Context, logger *log.Logger, migVM *ManagedInstanceGroupVM) ([]ManagedInstance, error) {
+	output, err := RunGcloud(ctx, logger, "", []string{
+		"compute", "instance-groups", "managed", "list-instances", migVM.ManagedInstanceGroupName(),
+		"--project=" + migVM.Project,
+		migVM.locationFlag(),
+		"--format=json",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing instances of %v: %w", migVM.ManagedInstanceGroupName(), err)
+	}
+	return parseManagedInstances(output.Stdout)
+}
+
+// waitForManagedInstanceGroupStable waits, for up to 300s, until every
+// instance in the given VM's Managed Instance Group has been created or
+// deleted as needed and is running.
+func waitForManagedInstanceGroupStable(ctx context.Context, logger *log.Logger, migVM *ManagedInstanceGroupVM) error {
+	_, err := runGcloud(ctx, logger, "", []string{
+		"compute", "instance-groups", "managed", "wait-until", migVM.ManagedInstanceGroupName(),
+		"--stable",
+		"--timeout=300",
+		"--project=" + migVM.Project,
+		migVM.locationFlag(),
+		"--format=json",
+	})
+	return err
+}
+
+// ResizeManagedInstanceGroup sets the number of instances in the given VM's
+// Managed Instance Group to size, and waits until the group is stable again.
+//
+// Shrinking the group may delete the instance that migVM itself refers to,
+// and instances created when growing it get new names and IDs. In
+// particular, resizing to 0 and back up replaces every instance. Call
+// ListMIGInstances after each resize instead of reusing earlier results.
+func ResizeManagedInstanceGroup(ctx context.Context, logger *log.Logger, migVM *ManagedInstanceGroupVM, size int) error {
+	if size < 0 {
+		return fmt.Errorf("ResizeManagedInstanceGroup(size=%d) failed: size must not be negative", size)
+	}
+	if _, err := runGcloud(ctx, logger, "", []string{
+		"compute", "instance-groups", "managed", "resize", migVM.ManagedInstanceGroupName(),
+		"--size=" + strconv.Itoa(size),
+		"--project=" + migVM.Project,
+		migVM.locationFlag(),
+		"--format=json",
+	}); err != nil {
+		return fmt.Errorf("ResizeManagedInstanceGroup(size=%d) failed: %v", size, err)
+	}
+	if err := waitForManagedInstanceGroupStable(ctx, logger, migVM); err != nil {
+		return fmt.Errorf("ResizeManagedInstanceGroup(size=%d) failed waiting for %v to become stable: %v", size, migVM.ManagedInstanceGroupName(), err)
+	}
+	return nil
+}
+
+// ListMIGInstances returns a VM for each instance that is currently a member
+// of the given VM's Managed Instance Group. The instances must all be
+// running, which is the case once ResizeManagedInstanceGroup returns.
+//
+// The VMs share migVM's OS, ImageSpec and connection settings, since they
+// all come from the same instance template. Their IDs are looked up afresh,
+// so they reflect any instances replaced by ResizeManagedInstanceGroup.
+func ListMIGInstances(ctx context.Context, logger *log.Logger, migVM *ManagedInstanceGroupVM) ([]*VM, error) {
+	members, err := listMIGInstances(ctx, logger, migVM)
+	if err != nil {
+		return nil, fmt.Errorf("ListMIGInstances() failed: %v", err)
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+	var names, zones []string
+	for _, member := range members {
+		names = append(names, "'"+member.Name+"'")
+		if member.Zone != "" && !slices.Contains(zones, member.Zone) {
+			zones = append(zones, member.Zone)
+		}
+	}
+	if len(zones) == 0 {
+		zones = []string{migVM.Zone}
+	}
+	output, err := runGcloud(ctx, logger, "", []string{
+		"compute", "instances", "list",
+		"--filter=name=( " + strings.Join(names, " ") + " )",
+		"--project=" + migVM.Project,
+		"--zones=" + strings.Join(zones, ","),
+		"--format=json",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ListMIGInstances() failed: %v", err)
+	}
+	var instances []instance
+	if err := json.Unmarshal([]byte(output.Stdout), &instances); err != nil {
+		return nil, fmt.Errorf("ListMIGInstances() could not parse JSON from %q: %v", output.Stdout, err)
+	}
+	byName := make(map[string]instance, len(instances))
+	for _, inst := range instances {
+		byName[inst.Name] = inst
+	}
 
-		if err != nil && !shouldRetryCreateManagedInstanceGroupVM(err, options) {
-			err = backoff.Permanent(err)
+	var vms []*VM
+	for _, member := range members {
+		inst, ok := byName[member.Name]
+		if !ok {
+			return nil, fmt.Errorf("ListMIGInstances() could not find instance %v of %v", member.Name, migVM.ManagedInstanceGroupName())
 		}
-		// Returning a non-permanent error triggers retries.
-		return err
+		zone := member.Zone
+		if zone == "" {
+			zone = migVM.Zone
+		}
+		vm, err := vmFromInstance(migVM.Project, zone, member.Name, inst)
+		if err != nil {
+			return nil, fmt.Errorf("ListMIGInstances() failed: %v", err)
+		}
+		vm.Network = migVM.Network
+		vm.ImageSpec = migVM.ImageSpec
+		vm.OS = migVM.OS
+		vm.TransfersBucket = migVM.TransfersBucket
+		vm.PreferIPv6 = migVM.PreferIPv6
+		vm.UseIAP = migVM.UseIAP
+		vm.TimeToLive = migVM.TimeToLive
+		if !vm.UseIAP {
+			if vm.IPAddress, err = instanceIPAddress(inst, vm.PreferIPv6); err != nil {
+				return nil, fmt.Errorf("ListMIGInstances() failed: %v", err)
+			}
+		}
+		vms = append(vms, vm)
 	}
-	backoffPolicy := backoff.WithContext(backoff.NewConstantBackOff(time.Minute), ctx)
-	if err := backoff.Retry(createFunc, backoffPolicy); err != nil {
-		return nil, err
+	return vms, nil
+}
+
+// RunRemotelyOnMIGParallelism is the maximum number of instances that
+// RunRemotelyOnMIG runs a command on at once.
+var RunRemotelyOnMIGParallelism = 8
+
+// RunRemotelyOnMIG runs the given command with RunRemotely on every instance
+// that is currently a member of the given VM's Managed Instance Group, on up
+// to RunRemotelyOnMIGParallelism of them concurrently.
+// Returns the output from each instance, keyed by instance name, along with
+// the combined errors of all instances where the command failed. A failure on
+// one instance does not stop the command on the others, and their output is
+// returned either way.
+func RunRemotelyOnMIG(ctx context.Context, logger *log.Logger, migVM *ManagedInstanceGroupVM, command string) (map[string]CommandOutput, error) {
+	vms, err := ListMIGInstances(ctx, logger, migVM)
+	if err != nil {
+		return nil, fmt.Errorf("RunRemotelyOnMIG() failed: %v", err)
+	}
+	if len(vms) == 0 {
+		return nil, fmt.Errorf("RunRemotelyOnMIG() failed: no instances found in %v", migVM.ManagedInstanceGroupName())
+	}
+
+	outputs := make([]CommandOutput, len(vms))
+	errs := make([]error, len(vms))
+	slots := make(chan struct{}, max(RunRemotelyOnMIGParallelism, 1))
+	var wg sync.WaitGroup
+	for i, vm := range vms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			output, err := runRemotely(ctx, logger, vm, command)
+			outputs[i] = output
+			if err != nil {
+				errs[i] = fmt.Errorf("RunRemotelyOnMIG() failed on instance %v: %w", vm.Name, err)
+			}
+		}()
 	}
-	logger.Printf("Managed Instance Group VM is ready: %#v", migVM.VM)
-	return migVM, nil
+	wg.Wait()
+
+	results := make(map[string]CommandOutput, len(vms))
+	for i, vm := range vms {
+		results[vm.Name] = outputs[i]
+	}
+	return results, multierr.Combine(errs...)
 }
 
 // DescribeVMDisk queries the VM disk information.
@@ -1768,13 +5246,9 @@ func SetEnvironmentVariables(ctx context.Context, logger *log.Logger, vm *VM, en
 	for key, value := range envVariables {
 		override += fmt.Sprintf(`Environment="%s=%s"\n`, key, value)
 	}
-	for _, service := range []string{
-		"google-cloud-ops-agent",
-		"google-cloud-ops-agent-fluent-bit",
-		"google-cloud-ops-agent-opentelemetry-collector",
-	} {
+	for _, service := range agentServices {
 		dir := fmt.Sprintf("/etc/systemd/system/%s.service.d", service)
-		cmd := fmt.Sprintf(`sudo mkdir -p %s && echo -e '%s' | sudo tee %s/override.conf`, dir, override, dir)
+		cmd := fmt.Sprintf(`sudo mkdir -p %s && echo -e %s | sudo tee %s/override.conf`, dir, shellQuote(override), dir)
 		if _, err := RunRemotely(ctx, logger, vm, cmd); err != nil {
 			return err
 		}
@@ -1785,21 +5259,55 @@ func SetEnvironmentVariables(ctx context.Context, logger *log.Logger, vm *VM, en
 	return err
 }
 
-func handleDeleteError(err error, attempt int) error {
-	if err == nil {
-		return nil
+var windowsTimezoneRegexp = regexp.MustCompile(`^[A-Za-z0-9 ().+\-]+$`)
+
+// setTimezoneCommand returns the command to set the timezone of a VM with the
+// given image spec. On Linux, tz must be an IANA timezone name like
+// "America/New_York". On Windows, tz must be a Windows timezone ID like
+// "Eastern Standard Time".
+func setTimezoneCommand(imageSpec, tz string) (string, error) {
+	if IsWindows(imageSpec) {
+		if !windowsTimezoneRegexp.MatchString(tz) {
+			return "", fmt.Errorf("invalid Windows timezone ID %q", tz)
+		}
+		return fmt.Sprintf("Set-TimeZone -Id '%s'", tz), nil
 	}
-	// VM deletion can hit quota, especially when re-running presubmits,
-	// or when multple people are running tests. Retry errors by returning
-	// them directly.
-	if strings.Contains(err.Error(), "Quota") {
-		return err
+	// LoadLocation also accepts "" and "Local", which are meaningless on the VM.
+	if tz == "" || tz == "Local" || strings.ContainsAny(tz, `'"`) {
+		return "", fmt.Errorf("invalid timezone name %q", tz)
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return "", fmt.Errorf("invalid timezone name %q: %v", tz, err)
 	}
-	// GCE sometimes responds with 502 or 503 errors. Retry these errors
-	// (and other 50x errors for good measure), by returning them directly.
-	if strings.Contains(err.Error(), "Error 50") {
+	return fmt.Sprintf("sudo timedatectl set-timezone '%s'", tz), nil
+}
+
+// SetTimezone sets the system timezone of the given VM. On Linux, tz must be
+// an IANA timezone name like "America/New_York". On Windows, tz must be a
+// Windows timezone ID like "Eastern Standard Time".
+//
+// Changing the timezone affects how the agent and other programs on the VM
+// format local timestamps, so assertions that parse timestamps out of logs
+// may need to account for the offset. Call ResetTimezoneToUTC to undo this.
+func SetTimezone(ctx context.Context, logger *log.Logger, vm *VM, tz string) error {
+	cmd, err := setTimezoneCommand(vm.ImageSpec, tz)
+	if err != nil {
 		return err
 	}
+	_, err = RunRemotely(ctx, logger, vm, cmd)
+	return err
+}
+
+// ResetTimezoneToUTC sets the system timezone of the given VM back to UTC,
+// which is the default on GCE images.
+func ResetTimezoneToUTC(ctx context.Context, logger *log.Logger, vm *VM) error {
+	return SetTimezone(ctx, logger, vm, "UTC")
+}
+
+func handleDeleteError(err error, attempt int) error {
+	if err == nil {
+		return nil
+	}
 	// "not found" can happen when a previous attempt actually did delete
 	// the VM but there was some communication problem along the way.
 	// Consider that a successful deletion. Only do this when there has
@@ -1807,6 +5315,10 @@ func handleDeleteError(err error, attempt int) error {
 	if strings.Contains(err.Error(), "not found") && attempt > 1 {
 		return nil
 	}
+	// Retry transient errors by returning them directly.
+	if classifyGcloudError(err) {
+		return err
+	}
 	// Wrap other errors in backoff.Permanent() to avoid retrying those.
 	return backoff.Permanent(err)
 }
@@ -1815,23 +5327,34 @@ func handleDeleteError(err error, attempt int) error {
 // Does nothing if the VM was already deleted.
 // Uses the passed-in context to extract the gcloud configuration directory,
 // but uses a separate background context with timeout for the actual deletion
-// to ensure it completes even if the test context is cancelled.
+// to ensure it completes even if the test context is cancelled. This is what
+// cleanup code should normally use, so that VMs don't leak when a test times
+// out. To let cancellation abort the deletion instead, use DeleteInstanceCtx.
 func DeleteInstance(ctx context.Context, logger *log.Logger, vm *VM) error {
+	deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Minute)
+	defer cancel()
+	return DeleteInstanceCtx(deleteCtx, logger, vm)
+}
+
+// DeleteInstanceCtx is like DeleteInstance, but honors ctx: if ctx is
+// cancelled or its deadline passes, the deletion is abandoned and the error
+// is returned, even if that leaves the VM running until its TimeToLive. This
+// is meant for a test runner that is shutting down, e.g. on SIGTERM, and would
+// rather exit promptly than finish its cleanup.
+func DeleteInstanceCtx(ctx context.Context, logger *log.Logger, vm *VM) error {
 	if vm.AlreadyDeleted {
 		logger.Printf("VM %v was already deleted, skipping delete.", vm.Name)
 		return nil
 	}
-	configDir := ctx.Value(gcloudConfigDirKey)
-	deleteCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
-	if configDir != nil {
-		deleteCtx = WithGcloudConfigDir(deleteCtx, configDir.(string))
+	// backoff.Retry always makes the first attempt, even if ctx is done.
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("DeleteInstanceCtx() of VM %v abandoned: %v", vm.Name, err)
 	}
-	backoffPolicy := backoff.WithContext(backoff.WithMaxRetries(backoff.NewConstantBackOff(30*time.Second), 10), deleteCtx)
+	backoffPolicy := backoff.WithContext(backoff.WithMaxRetries(backoff.NewConstantBackOff(30*time.Second), 10), ctx)
 	attempt := 0
 	tryDelete := func() error {
 		attempt++
-		_, err := RunGcloud(deleteCtx, logger, "",
+		_, err := RunGcloud(ctx, logger, "",
 			[]string{
 				"compute", "instances", "delete",
 				"--project=" + vm.Project,
@@ -1848,6 +5371,34 @@ func DeleteInstance(ctx context.Context, logger *log.Logger, vm *VM) error {
 	return err
 }
 
+// DeleteInstanceAsync starts deleting the given VM instance in the background
+// and returns a channel that receives the result of the deletion, which is the
+// same as what DeleteInstance would return. The VM must not be used until the
+// result has been received.
+//
+// Deleting many VMs this way is much faster than calling DeleteInstance on
+// each of them in turn. The returned channels must still be waited on, for
+// example with WaitForDeletions, before the test binary exits; otherwise the
+// deletions can be cut short and the VMs will leak until their TimeToLive.
+func DeleteInstanceAsync(ctx context.Context, logger *log.Logger, vm *VM) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		defer close(result)
+		result <- DeleteInstance(ctx, logger, vm)
+	}()
+	return result
+}
+
+// WaitForDeletions waits for all of the given deletions started by
+// DeleteInstanceAsync to finish, and returns all of their errors combined.
+func WaitForDeletions(deletions ...<-chan error) error {
+	var err error
+	for _, deletion := range deletions {
+		err = multierr.Append(err, <-deletion)
+	}
+	return err
+}
+
 // DeleteManagedInstanceGroupVM deletes the given Managed Instance Group VM instance synchronously.
 // Does nothing if the Managed Instance Group VM was already deleted.
 // Uses the passed-in context to extract the gcloud configuration directory,
@@ -1872,7 +5423,7 @@ func DeleteManagedInstanceGroupVM(ctx context.Context, logger *log.Logger, migVM
 			[]string{
 				"compute", "instance-groups", "managed", "delete", migVM.ManagedInstanceGroupName(),
 				"--project=" + migVM.Project,
-				"--zone=" + migVM.Zone,
+				migVM.locationFlag(),
 				"--quiet",
 			})
 		if err == nil {
@@ -1904,6 +5455,150 @@ func DeleteManagedInstanceGroupVM(ctx context.Context, logger *log.Logger, migVM
 	return err
 }
 
+// StaleResource is a leftover resource found by ReapStaleResources.
+type StaleResource struct {
+	// The gcloud resource group, e.g. "firewall-rules".
+	Kind string
+	Name string
+	// The zone of zonal resources like managed instance groups. Empty for
+	// global resources.
+	Zone string
+	// The region of regional resources like regional managed instance
+	// groups. Empty for zonal and global resources.
+	Region  string
+	Created time.Time
+}
+
+// staleResourceKinds are the kinds of global resources that this library (or
+// tests using it) creates with sandbox-prefixed names.
+var staleResourceKinds = []string{"firewall-rules", "instance-templates", "snapshots"}
+
+// sandboxNameRegexp matches names that start with a sandboxPrefix.
+var sandboxNameRegexp = regexp.MustCompile(`^(github-)?test-\d{8}-`)
+
+// parseStaleResources parses the output of
+// `gcloud compute <kind> list --format=json` and returns the resources with
+// sandbox-prefixed names that were created before cutoff.
+func parseStaleResources(kind, stdout string, cutoff time.Time) ([]StaleResource, error) {
+	var raw []struct {
+		Name              string
+		CreationTimestamp string
+		// The URL of the zone, for zonal resources.
+		Zone string
+		// The URL of the region, for regional resources.
+		Region string
+	}
+	if err := json.Unmarshal([]byte(stdout), &raw); err != nil {
+		return nil, fmt.Errorf("could not parse JSON from %q: %v", stdout, err)
+	}
+	var stale []StaleResource
+	for _, r := range raw {
+		if !sandboxNameRegexp.MatchString(r.Name) {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, r.CreationTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse creation timestamp %q of %v %v: %v", r.CreationTimestamp, kind, r.Name, err)
+		}
+		if created.Before(cutoff) {
+			resource := StaleResource{Kind: kind, Name: r.Name, Created: created}
+			if r.Zone != "" {
+				resource.Zone = path.Base(r.Zone)
+			}
+			if r.Region != "" {
+				resource.Region = path.Base(r.Region)
+			}
+			stale = append(stale, resource)
+		}
+	}
+	return stale, nil
+}
+
+// ReapStaleResources deletes firewall rules, instance templates, and snapshots
+// in the given project that were left behind by earlier test runs, for
+// example because the test binary crashed before cleaning them up. Only
+// resources whose names start with a sandbox prefix like the one this library
+// gives to VMs, and that were created more than olderThan ago, are deleted.
+//
+// Returns the resources that were deleted. Failing to delete one resource
+// does not stop the others from being deleted; all errors are returned
+// combined.
+func ReapStaleResources(ctx context.Context, logger *log.Logger, project string, olderThan time.Duration) ([]StaleResource, error) {
+	cutoff := time.Now().Add(-olderThan)
+	var deleted []StaleResource
+	var err error
+	for _, kind := range staleResourceKinds {
+		reaped, reapErr := reapStaleResourcesOfKind(ctx, logger, project, kind, cutoff)
+		deleted = append(deleted, reaped...)
+		err = multierr.Append(err, reapErr)
+	}
+	return deleted, err
+}
+
+// reapStaleResourcesOfKind deletes the resources of the given kind in the
+// given project that have sandbox-prefixed names and were created before
+// cutoff, and returns the ones it deleted. kind is the gcloud resource group,
+// which may span several words, like "instance-groups managed".
+func reapStaleResourcesOfKind(ctx context.Context, logger *log.Logger, project, kind string, cutoff time.Time) ([]StaleResource, error) {
+	kindArgs := append([]string{"compute"}, strings.Fields(kind)...)
+	output, err := RunGcloud(ctx, logger, "", append(slices.Clone(kindArgs),
+		"list",
+		"--project="+project,
+		"--format=json(name,creationTimestamp,zone,region)",
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error listing %v: %w", kind, err)
+	}
+	stale, err := parseStaleResources(kind, output.Stdout, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []StaleResource
+	var deleteErrs error
+	for _, resource := range stale {
+		logger.Printf("Deleting stale %v %v, created at %v", kind, resource.Name, resource.Created)
+		deleteArgs := append(slices.Clone(kindArgs),
+			"delete", resource.Name,
+			"--project="+project,
+			"--quiet",
+		)
+		if resource.Zone != "" {
+			deleteArgs = append(deleteArgs, "--zone="+resource.Zone)
+		}
+		if resource.Region != "" {
+			deleteArgs = append(deleteArgs, "--region="+resource.Region)
+		}
+		if _, deleteErr := RunGcloud(ctx, logger, "", deleteArgs); deleteErr != nil {
+			deleteErrs = multierr.Append(deleteErrs, fmt.Errorf("error deleting %v %v: %w", kind, resource.Name, deleteErr))
+			continue
+		}
+		deleted = append(deleted, resource)
+	}
+	return deleted, deleteErrs
+}
+
+// CleanupOrphanedMIGResources deletes managed instance groups and instance
+// templates in the given project that were left behind by earlier test runs,
+// for example because a test crashed between creating an instance template
+// and its managed instance group, so DeleteManagedInstanceGroupVM was never
+// called. Leaked templates count against the project's quota. Only resources
+// whose names start with a sandbox prefix, and that were created more than
+// olderThan ago, are deleted.
+//
+// The managed instance groups are deleted first, since an instance template
+// can't be deleted while a group still uses it. Failing to delete one
+// resource does not stop the others from being deleted; all errors are
+// returned combined.
+func CleanupOrphanedMIGResources(ctx context.Context, logger *log.Logger, project string, olderThan time.Duration) error {
+	cutoff := time.Now().Add(-olderThan)
+	var err error
+	for _, kind := range []string{"instance-groups managed", "instance-templates"} {
+		_, reapErr := reapStaleResourcesOfKind(ctx, logger, project, kind, cutoff)
+		err = multierr.Append(err, reapErr)
+	}
+	return err
+}
+
 // StopInstance shuts down a VM instance.
 func StopInstance(ctx context.Context, logger *log.Logger, vm *VM) error {
 	_, err := RunGcloud(ctx, logger, "",
@@ -1961,7 +5656,7 @@ func StartInstance(ctx context.Context, logger *log.Logger, vm *VM) error {
 		return err
 	}
 
-	ipAddress, err := extractIPAddress(output.Stdout)
+	ipAddress, err := extractIPAddress(output.Stdout, vm.PreferIPv6)
 	if err != nil {
 		return err
 	}
@@ -1980,16 +5675,127 @@ func RestartInstance(ctx context.Context, logger *log.Logger, vm *VM) error {
 	return StartInstance(ctx, logger, vm)
 }
 
+// guestBootID returns a value that changes every time the VM's OS boots.
+func guestBootID(ctx context.Context, logger *log.Logger, vm *VM) (string, error) {
+	cmd := "cat /proc/sys/kernel/random/boot_id"
+	if IsWindows(vm.ImageSpec) {
+		cmd = "(Get-CimInstance Win32_OperatingSystem).LastBootUpTime.ToString('o')"
+	}
+	output, err := RunRemotely(ctx, logger, vm, cmd)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output.Stdout), nil
+}
+
+// waitForGuestReboot waits for the VM to finish starting up after a reboot
+// that was started while its boot ID was oldBootID. Checking the boot ID
+// keeps us from mistaking the VM for rebooted while it is still shutting down.
+func waitForGuestReboot(ctx context.Context, logger *log.Logger, vm *VM, oldBootID string) error {
+	ctx, cancel := context.WithTimeout(ctx, vmInitTimeout)
+	defer cancel()
+
+	hasRebooted := func() error {
+		if err := waitForStart(ctx, logger, vm); err != nil {
+			return backoff.Permanent(err)
+		}
+		bootID, err := guestBootID(ctx, logger, vm)
+		if err != nil {
+			return err
+		}
+		if bootID == oldBootID {
+			return fmt.Errorf("VM %v has not rebooted yet", vm.Name)
+		}
+		return nil
+	}
+	backoffPolicy := backoff.WithContext(backoff.NewConstantBackOff(vmInitBackoffDuration), ctx)
+	return backoff.Retry(hasRebooted, backoffPolicy)
+}
+
+// refreshIPAddress looks up the VM's IP address again and stores it in
+// vm.IPAddress.
+func refreshIPAddress(ctx context.Context, logger *log.Logger, vm *VM) error {
+	output, err := RunGcloud(ctx, logger, "", []string{
+		"compute", "instances", "list",
+		"--filter=name=( '" + vm.Name + "' ... )",
+		"--project=" + vm.Project,
+		"--zones=" + vm.Zone,
+		"--format=json",
+	})
+	if err != nil {
+		return err
+	}
+	ipAddress, err := extractIPAddress(output.Stdout, vm.PreferIPv6)
+	if err != nil {
+		return err
+	}
+	vm.IPAddress = ipAddress
+	return nil
+}
+
+// RebootInGuest reboots the VM from inside its OS, with "sudo reboot" on Linux
+// or "Restart-Computer -Force" on Windows, and waits for it to start up again.
+// Unlike RestartInstance, which stops and starts the VM through the Compute
+// Engine API, this exercises the guest's own shutdown and boot sequence.
+func RebootInGuest(ctx context.Context, logger *log.Logger, vm *VM) error {
+	bootID, err := guestBootID(ctx, logger, vm)
+	if err != nil {
+		return fmt.Errorf("RebootInGuest() could not read the boot ID of VM %v: %w", vm.Name, err)
+	}
+
+	rebootCmd := "sudo reboot"
+	if IsWindows(vm.ImageSpec) {
+		rebootCmd = "Restart-Computer -Force"
+	}
+	// The VM often drops the ssh connection before the command returns, so an
+	// error here is expected and only logged.
+	if _, err := RunRemotely(ctx, logger, vm, rebootCmd); err != nil {
+		logger.Printf("RebootInGuest(): %q failed with err=%v, which is expected if the VM dropped the connection", rebootCmd, err)
+	}
+
+	err = waitForGuestReboot(ctx, logger, vm, bootID)
+	if err == nil {
+		return nil
+	}
+	// A reboot from inside the guest normally keeps the same IP address, so
+	// only look it up again if the VM can't be reached at the old one.
+	logger.Printf("RebootInGuest(): VM %v did not come back up at %v: %v. Looking up its IP address again.", vm.Name, vm.IPAddress, err)
+	if refreshErr := refreshIPAddress(ctx, logger, vm); refreshErr != nil {
+		return fmt.Errorf("RebootInGuest() failed: %v. Looking up the IP address again also failed: %v", err, refreshErr)
+	}
+	if err := waitForGuestReboot(ctx, logger, vm, bootID); err != nil {
+		return fmt.Errorf("RebootInGuest() failed: %w", err)
+	}
+	return nil
+}
+
+// grpcurlBucket is where the grpcurl release archives are mirrored.
+const grpcurlBucket = "gs://ops-agents-public-buckets-vendored-deps/mirrored-content/grpcurl/v1.8.6"
+
+// grpcurlArchive returns the name of the grpcurl release archive that matches
+// both the OS and the architecture of the given image.
+func grpcurlArchive(imageSpec string) string {
+	arch := "x86_64"
+	if IsARM(imageSpec) {
+		arch = "arm64"
+	}
+	if IsWindows(imageSpec) {
+		return "grpcurl_1.8.6_windows_" + arch + ".zip"
+	}
+	return "grpcurl_1.8.6_linux_" + arch + ".tar.gz"
+}
+
 // InstallGrpcurlIfNeeded installs grpcurl on instances that don't already have
 // it installed.
 func InstallGrpcurlIfNeeded(ctx context.Context, logger *log.Logger, vm *VM) error {
+	archive := grpcurlArchive(vm.ImageSpec)
 	if IsWindows(vm.ImageSpec) {
 		if _, err := RunRemotely(ctx, logger, vm, "Get-Command grpcurl"); err == nil {
 			return nil
 		}
 
 		logger.Printf("grpcurl not found, installing it...")
-		installCmd := `gcloud storage cp gs://ops-agents-public-buckets-vendored-deps/mirrored-content/grpcurl/v1.8.6/grpcurl_1.8.6_windows_x86_64.zip C:\agentPlugin;Expand-Archive -Path "C:\agentPlugin\grpcurl_1.8.6_windows_x86_64.zip" -DestinationPath "C:\" -Force;ls "C:\"`
+		installCmd := fmt.Sprintf(`gcloud storage cp %s/%s C:\agentPlugin;Expand-Archive -Path "C:\agentPlugin\%s" -DestinationPath "C:\" -Force;ls "C:\"`, grpcurlBucket, archive, archive)
 
 		_, err := RunRemotely(ctx, logger, vm, installCmd)
 		return err
@@ -2001,12 +5807,7 @@ func InstallGrpcurlIfNeeded(ctx context.Context, logger *log.Logger, vm *VM) err
 
 	logger.Printf("grpcurl not found, installing it...")
 
-	arch := "x86_64"
-	if IsARM(vm.ImageSpec) {
-		arch = "arm64"
-	}
-
-	installCmd := fmt.Sprintf("sudo gcloud storage cp gs://ops-agents-public-buckets-vendored-deps/mirrored-content/grpcurl/v1.8.6/grpcurl_1.8.6_linux_%s.tar.gz /tmp/agentPlugin && sudo tar -xzf /tmp/agentPlugin/grpcurl_1.8.6_linux_%s.tar.gz --no-overwrite-dir -C /usr/local/bin", arch, arch)
+	installCmd := fmt.Sprintf("sudo gcloud storage cp %s/%s /tmp/agentPlugin && sudo tar -xzf /tmp/agentPlugin/%s --no-overwrite-dir -C /usr/local/bin", grpcurlBucket, archive, archive)
 	installCmd = `set -ex
 ` + installCmd
 	_, err := RunRemotely(ctx, logger, vm, installCmd)
@@ -2031,6 +5832,16 @@ func verifyGcloudInstallation(ctx context.Context, logger *log.Logger, vm *VM) e
 	return err
 }
 
+// gcloudLinuxTarball returns the name of the gcloud CLI release tarball for
+// Linux that matches the architecture of the given image.
+func gcloudLinuxTarball(imageSpec string) string {
+	arch := "x86_64"
+	if IsARM(imageSpec) {
+		arch = "arm"
+	}
+	return "google-cloud-cli-561.0.0-linux-" + arch + ".tar.gz"
+}
+
 // InstallGcloudIfNeeded installs gcloud cli on instances that don't already have
 // it installed. This is only currently the case for some old versions of SUSE.
 func InstallGcloudIfNeeded(ctx context.Context, logger *log.Logger, vm *VM) error {
@@ -2052,11 +5863,7 @@ func InstallGcloudIfNeeded(ctx context.Context, logger *log.Logger, vm *VM) erro
 		return installErr("gcloud", vm.OS.ID)
 	}
 
-	gcloudArch := "x86_64"
-	if IsARM(vm.ImageSpec) {
-		gcloudArch = "arm"
-	}
-	gcloudPkg := "google-cloud-cli-561.0.0-linux-" + gcloudArch + ".tar.gz"
+	gcloudPkg := gcloudLinuxTarball(vm.ImageSpec)
 	installFromTarball := `
 curl -O https://dl.google.com/dl/cloudsdk/channels/rapid/downloads/` + gcloudPkg + `
 INSTALL_DIR="$(readlink --canonicalize .)"
@@ -2081,8 +5888,10 @@ sudo ${INSTALL_DIR}/google-cloud-sdk/bin/gcloud components update --quiet
 sudo ln -s ${INSTALL_DIR}/google-cloud-sdk/bin/gcloud /usr/bin/gcloud
 `
 	// b/308962066: The GCloud CLI ARM Linux tarballs do not have bundled Python
-	// and the GCloud CLI requires Python >= 3.8. Install Python311 for ARM VMs
-	if IsARM(vm.ImageSpec) {
+	// and the GCloud CLI requires Python >= 3.8. Install Python311 for ARM VMs.
+	// Windows VMs never get here, but check anyway since this is all specific
+	// to SUSE on ARM.
+	if IsARM(vm.ImageSpec) && !IsWindows(vm.ImageSpec) {
 		pythonBin := "/usr/bin/python3.11"
 		// This is what's used on openSUSE.
 		repoSetupCmd := "sudo zypper --non-interactive refresh"
@@ -2138,14 +5947,28 @@ sudo chmod a+x /usr/bin/gcloud
 // documented here:
 // http://cloud/compute/docs/reference/rest/v1/instances
 type instance struct {
+	Name              string
 	ID                string
+	Status            string
+	CreationTimestamp string
+	// These are URLs of the machine type and zone.
+	MachineType       string
+	Zone              string
 	NetworkInterfaces []struct {
+		Name string
+		// This is the URL of the network.
+		Network string
 		// This is the internal IP address.
 		NetworkIP     string
 		AccessConfigs []struct {
 			// This is the external IP address.
 			NatIP string
 		}
+		// Only present on dual-stack network interfaces.
+		Ipv6AccessConfigs []struct {
+			// This is the external IPv6 address.
+			ExternalIpv6 string
+		}
 	}
 	Metadata struct {
 		Items []struct {
@@ -2153,6 +5976,25 @@ type instance struct {
 			Value string
 		}
 	}
+	Labels     map[string]string
+	Scheduling struct {
+		// Only present if the VM has a max run duration.
+		MaxRunDuration *struct {
+			Seconds string
+		}
+		// Either "STOP" or "DELETE".
+		InstanceTerminationAction string
+	}
+	Disks []struct {
+		DeviceName string
+		// This is the URL of the disk.
+		Source string
+		Boot   bool
+		// Either "PERSISTENT" or "SCRATCH".
+		Type string
+		// Either "READ_WRITE" or "READ_ONLY".
+		Mode string
+	}
 }
 
 // extractSingleInstances parses the input serialized JSON description of a
@@ -2178,16 +6020,32 @@ func extractSingleInstance(stdout string) (instance, error) {
 // firewall settings set up for the project we use on Kokoro. Here is a
 // drawing of my best understanding of the situation when trying to connect
 // to VMs in various ways: http://go/sdi-testing-network-drawing
-func extractIPAddress(stdout string) (string, error) {
+//
+// If preferIPv6 is set and the VM has an external IPv6 address, that is
+// returned instead of either IPv4 address.
+func extractIPAddress(stdout string, preferIPv6 bool) (string, error) {
 	instance, err := extractSingleInstance(stdout)
 	if err != nil {
 		return "", err
 	}
+	return instanceIPAddress(instance, preferIPv6)
+}
 
+// instanceIPAddress is like extractIPAddress, but for an already parsed
+// instance.
+func instanceIPAddress(instance instance, preferIPv6 bool) (string, error) {
 	if len(instance.NetworkInterfaces) == 0 {
 		return "", fmt.Errorf("empty NetworkInterfaces list in %#v", instance)
 	}
 
+	if preferIPv6 {
+		for _, accessConfig := range instance.NetworkInterfaces[0].Ipv6AccessConfigs {
+			if accessConfig.ExternalIpv6 != "" {
+				return accessConfig.ExternalIpv6, nil
+			}
+		}
+	}
+
 	if os.Getenv("USE_INTERNAL_IP") == "true" {
 		internalIP := instance.NetworkInterfaces[0].NetworkIP
 		if internalIP == "" {
@@ -2206,36 +6064,248 @@ func extractIPAddress(stdout string) (string, error) {
 	return externalIP, nil
 }
 
+// scpHost returns the host part of an scp "user@host:path" argument for the
+// given IP address. Unlike ssh, scp needs IPv6 addresses to be bracketed so
+// that their colons aren't mistaken for the one before the path.
+func scpHost(ipAddress string) string {
+	if strings.Contains(ipAddress, ":") {
+		return "[" + ipAddress + "]"
+	}
+	return ipAddress
+}
+
 // ExtractID pulls the instance ID out of the stdout from a gcloud create/start
 // command with --format=json.
 func extractID(stdout string) (int64, error) {
 	instance, err := extractSingleInstance(stdout)
 	if err != nil {
-		return 0, err
+		return 0, err
+	}
+	return strconv.ParseInt(instance.ID, 10, 64)
+}
+
+// InstanceInfo describes a VM as reported by the Compute Engine API.
+type InstanceInfo struct {
+	ID int64
+	// Status is the lifecycle state of the VM, e.g. "RUNNING" or "TERMINATED".
+	Status            string
+	CreationTimestamp time.Time
+	// MachineType is the short name of the machine type, e.g. "e2-standard-4".
+	MachineType string
+	// Zone is the short name of the zone, e.g. "us-central1-b".
+	Zone              string
+	NetworkInterfaces []NetworkInterfaceInfo
+	Metadata          map[string]string
+	Labels            map[string]string
+	Disks             []DiskInfo
+}
+
+// NetworkInterfaceInfo describes one of a VM's network interfaces.
+type NetworkInterfaceInfo struct {
+	Name string
+	// Network is the short name of the network, e.g. "default".
+	Network    string
+	InternalIP string
+	// ExternalIPs holds the external IPv4 addresses of the interface, if any.
+	ExternalIPs []string
+	// ExternalIPv6s holds the external IPv6 addresses of the interface, if
+	// any. These are only present on dual-stack interfaces.
+	ExternalIPv6s []string
+}
+
+// DiskInfo describes one of the disks attached to a VM.
+type DiskInfo struct {
+	DeviceName string
+	// Source is the short name of the disk.
+	Source string
+	Boot   bool
+	// Type is either "PERSISTENT" or "SCRATCH".
+	Type string
+	// Mode is either "READ_WRITE" or "READ_ONLY".
+	Mode string
+}
+
+// toInstanceInfo converts the parsed output of "gcloud compute instances
+// describe" into an InstanceInfo.
+func toInstanceInfo(inst instance) (*InstanceInfo, error) {
+	info := &InstanceInfo{
+		Status:      inst.Status,
+		MachineType: path.Base(inst.MachineType),
+		Zone:        path.Base(inst.Zone),
+		Metadata:    make(map[string]string),
+		// The labels key is omitted entirely when the VM has no labels.
+		Labels: make(map[string]string),
+	}
+	var err error
+	if info.ID, err = strconv.ParseInt(inst.ID, 10, 64); err != nil {
+		return nil, fmt.Errorf("could not parse instance ID %q: %v", inst.ID, err)
+	}
+	if info.CreationTimestamp, err = time.Parse(time.RFC3339, inst.CreationTimestamp); err != nil {
+		return nil, fmt.Errorf("could not parse creation timestamp %q: %v", inst.CreationTimestamp, err)
+	}
+	for _, networkInterface := range inst.NetworkInterfaces {
+		interfaceInfo := NetworkInterfaceInfo{
+			Name:       networkInterface.Name,
+			Network:    path.Base(networkInterface.Network),
+			InternalIP: networkInterface.NetworkIP,
+		}
+		for _, accessConfig := range networkInterface.AccessConfigs {
+			if accessConfig.NatIP != "" {
+				interfaceInfo.ExternalIPs = append(interfaceInfo.ExternalIPs, accessConfig.NatIP)
+			}
+		}
+		for _, accessConfig := range networkInterface.Ipv6AccessConfigs {
+			if accessConfig.ExternalIpv6 != "" {
+				interfaceInfo.ExternalIPv6s = append(interfaceInfo.ExternalIPv6s, accessConfig.ExternalIpv6)
+			}
+		}
+		info.NetworkInterfaces = append(info.NetworkInterfaces, interfaceInfo)
+	}
+	for _, item := range inst.Metadata.Items {
+		info.Metadata[item.Key] = item.Value
+	}
+	maps.Copy(info.Labels, inst.Labels)
+	for _, disk := range inst.Disks {
+		info.Disks = append(info.Disks, DiskInfo{
+			DeviceName: disk.DeviceName,
+			Source:     path.Base(disk.Source),
+			Boot:       disk.Boot,
+			Type:       disk.Type,
+			Mode:       disk.Mode,
+		})
+	}
+	return info, nil
+}
+
+// DescribeInstance fetches the current state of the given VM from the Compute
+// Engine API, e.g. to check that its Status is "RUNNING" or to find out how
+// old it is.
+func DescribeInstance(ctx context.Context, logger *log.Logger, vm *VM) (*InstanceInfo, error) {
+	var inst instance
+	err := RunGcloudJSON(ctx, logger, []string{
+		"compute", "instances", "describe", vm.Name,
+		"--project=" + vm.Project,
+		"--zone=" + vm.Zone,
+	}, &inst)
+	if err != nil {
+		return nil, fmt.Errorf("error describing VM %v: %w", vm.Name, err)
+	}
+	return toInstanceInfo(inst)
+}
+
+// FetchMetadata retrieves the instance metadata for the given VM.
+func FetchMetadata(ctx context.Context, logger *log.Logger, vm *VM) (map[string]string, error) {
+	info, err := DescribeInstance(ctx, logger, vm)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching metadata for VM %v: %w", vm.Name, err)
+	}
+	return info.Metadata, nil
+}
+
+// FetchLabels retrieves the labels of the given VM, including those added by
+// addFrameworkLabels().
+// Returns an empty map if the VM has no labels.
+func FetchLabels(ctx context.Context, logger *log.Logger, vm *VM) (map[string]string, error) {
+	output, err := runGcloud(ctx, logger, "", []string{
+		"compute", "instances", "describe", vm.Name,
+		"--project=" + vm.Project,
+		"--zone=" + vm.Zone,
+		"--format=json(labels)",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching labels for VM %v: %w", vm.Name, err)
+	}
+	var inst struct {
+		Labels map[string]string
+	}
+	if err := json.Unmarshal([]byte(output.Stdout), &inst); err != nil {
+		return nil, fmt.Errorf("error fetching labels for VM %v: could not parse JSON from %q: %v", vm.Name, output.Stdout, err)
+	}
+	if inst.Labels == nil {
+		return map[string]string{}, nil
+	}
+	return inst.Labels, nil
+}
+
+// frameworkMetadataKeys returns the metadata keys that addFrameworkMetadata()
+// manages on VMs with the given image. Tests must not change them, or the VM
+// could stop being ssh-able.
+func frameworkMetadataKeys(imageSpec string) []string {
+	keys := []string{"enable-oslogin", "ssh-keys"}
+	if IsWindows(imageSpec) {
+		return append(keys, "sysprep-specialize-script-cmd", "enable-windows-ssh")
+	}
+	return append(keys, "startup-script")
+}
+
+// frameworkMetadataKeyOptions are the VMOptions fields to use instead of the
+// framework metadata keys that have one.
+var frameworkMetadataKeyOptions = map[string]string{
+	"startup-script":                "VMOptions.StartupScript",
+	"sysprep-specialize-script-cmd": "VMOptions.SysprepScript",
+}
+
+// checkNoFrameworkMetadataKeys returns an error if any of the given keys is
+// reserved for framework use on VMs with the given image.
+func checkNoFrameworkMetadataKeys(imageSpec string, keys []string) error {
+	reserved := frameworkMetadataKeys(imageSpec)
+	for _, key := range keys {
+		if !slices.Contains(reserved, key) {
+			continue
+		}
+		if option, ok := frameworkMetadataKeyOptions[key]; ok {
+			return fmt.Errorf("the '%s' metadata key is reserved for framework use. Use %s instead", key, option)
+		}
+		return fmt.Errorf("the '%s' metadata key is reserved for framework use", key)
+	}
+	return nil
+}
+
+// SetMetadata adds the given metadata to an existing VM, overwriting the
+// values of any keys that are already set. The keys used by the framework,
+// like "ssh-keys" and "startup-script", are reserved.
+func SetMetadata(ctx context.Context, logger *log.Logger, vm *VM, kv map[string]string) error {
+	if len(kv) == 0 {
+		return nil
+	}
+	if err := checkNoFrameworkMetadataKeys(vm.ImageSpec, slices.Collect(maps.Keys(kv))); err != nil {
+		return fmt.Errorf("SetMetadata(): %v", err)
+	}
+	metadataValue, err := gcloudDictFlagValue(kv)
+	if err != nil {
+		return fmt.Errorf("SetMetadata() could not construct valid metadata: %v", err)
 	}
-	return strconv.ParseInt(instance.ID, 10, 64)
-}
-
-// FetchMetadata retrieves the instance metadata for the given VM.
-func FetchMetadata(ctx context.Context, logger *log.Logger, vm *VM) (map[string]string, error) {
-	output, err := RunGcloud(ctx, logger, "", []string{
-		"compute", "instances", "describe", vm.Name,
+	if _, err := RunGcloud(ctx, logger, "", []string{
+		"compute", "instances", "add-metadata", vm.Name,
 		"--project=" + vm.Project,
 		"--zone=" + vm.Zone,
-		"--format=json(metadata)",
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error fetching metadata for VM %v: %w", vm.Name, err)
+		"--metadata=" + metadataValue,
+	}); err != nil {
+		return fmt.Errorf("error setting metadata for VM %v: %w", vm.Name, err)
 	}
-	var inst instance
-	if err := json.Unmarshal([]byte(output.Stdout), &inst); err != nil {
-		return nil, fmt.Errorf("could not parse JSON from %q: %v", output.Stdout, err)
+	return nil
+}
+
+// RemoveMetadata removes the given metadata keys from an existing VM. Keys
+// that are not set are ignored. The keys used by the framework, like
+// "ssh-keys" and "startup-script", are reserved.
+func RemoveMetadata(ctx context.Context, logger *log.Logger, vm *VM, keys []string) error {
+	if len(keys) == 0 {
+		return nil
 	}
-	metadata := make(map[string]string)
-	for _, item := range inst.Metadata.Items {
-		metadata[item.Key] = item.Value
+	if err := checkNoFrameworkMetadataKeys(vm.ImageSpec, keys); err != nil {
+		return fmt.Errorf("RemoveMetadata(): %v", err)
+	}
+	// gcloud succeeds without changing anything if none of the keys are set.
+	if _, err := RunGcloud(ctx, logger, "", []string{
+		"compute", "instances", "remove-metadata", vm.Name,
+		"--project=" + vm.Project,
+		"--zone=" + vm.Zone,
+		"--keys=" + strings.Join(keys, ","),
+	}); err != nil {
+		return fmt.Errorf("error removing metadata from VM %v: %w", vm.Name, err)
 	}
-	return metadata, nil
+	return nil
 }
 
 const (
@@ -2334,15 +6404,295 @@ func waitForStartLinux(ctx context.Context, logger *log.Logger, vm *VM) error {
 	return nil
 }
 
+// serviceStateBackoffDuration is how long WaitForServiceState waits between
+// polls.
+var serviceStateBackoffDuration = 5 * time.Second
+
+// WaitForServiceState polls the given service on the given VM until it
+// reaches desiredState or the timeout expires. On Linux, desiredState is one
+// of systemd's active states, as printed by "systemctl is-active", such as
+// "active" or "inactive". On Windows, it is a Get-Service status such as "Running" or
+// "Stopped".
+//
+// On timeout, the returned error includes the last observed state.
+func WaitForServiceState(ctx context.Context, logger *log.Logger, vm *VM, serviceName, desiredState string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	lastState := "unknown"
+	isInDesiredState := func() error {
+		status, err := getServiceStatus(ctx, logger, vm, serviceName)
+		if err != nil {
+			return err
+		}
+		lastState = status.State
+		if status.State != desiredState {
+			return fmt.Errorf("service %s is %q, want %q", serviceName, status.State, desiredState)
+		}
+		return nil
+	}
+
+	backoffPolicy := backoff.WithContext(backoff.NewConstantBackOff(serviceStateBackoffDuration), ctx)
+	if err := backoff.Retry(isInDesiredState, backoffPolicy); err != nil {
+		return fmt.Errorf("WaitForServiceState(service=%s, desiredState=%q) timed out after %v, last observed state was %q: %v",
+			serviceName, desiredState, timeout, lastState, err)
+	}
+	return nil
+}
+
+// agentServiceStartTimeout is how long ApplyAgentConfig waits for each of the
+// Ops Agent's services to come back up after a restart.
+const agentServiceStartTimeout = 3 * time.Minute
+
+// agentConfigPath returns the path of the Ops Agent's user configuration
+// file on a VM with the given image spec.
+func agentConfigPath(imageSpec string) string {
+	if IsWindows(imageSpec) {
+		return `C:\Program Files\Google\Cloud Operations\Ops Agent\config\config.yaml`
+	}
+	return "/etc/google-cloud-ops-agent/config.yaml"
+}
+
+// restartAgentCommand returns the command that restarts all of the Ops
+// Agent's services on a VM with the given image spec. The other services
+// depend on google-cloud-ops-agent, so restarting it restarts them too.
+func restartAgentCommand(imageSpec string) string {
+	if IsWindows(imageSpec) {
+		return "Restart-Service -Name google-cloud-ops-agent -Force"
+	}
+	return "sudo systemctl restart google-cloud-ops-agent"
+}
+
+// runningServiceState returns the state that WaitForServiceState should wait
+// for to know that a service is running on a VM with the given image spec.
+func runningServiceState(imageSpec string) string {
+	if IsWindows(imageSpec) {
+		return "Running"
+	}
+	return "active"
+}
+
+// logAgentServiceErrors logs what the Ops Agent's services have reported
+// since the given time, to explain why they failed to start: their journals
+// on Linux, or the Application event log entries from them on Windows. This
+// is best-effort, so failures are only logged.
+func logAgentServiceErrors(ctx context.Context, logger *log.Logger, vm *VM, since time.Time) {
+	if IsWindows(vm.ImageSpec) {
+		entries, err := GetWindowsEventLog(ctx, logger, vm, "Application", time.Since(since))
+		if err != nil {
+			logger.Printf("Could not get the Ops Agent's event log entries: %v", err)
+			return
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.ProviderName, "google-cloud-ops-agent") {
+				logger.Printf("%v %v [%v]: %v", entry.TimeCreated.Format(time.RFC3339), entry.ProviderName, entry.Level, entry.Message)
+			}
+		}
+		return
+	}
+	for _, service := range agentServices {
+		// RunRemotely logs the output.
+		cmd := fmt.Sprintf("sudo journalctl -u %s --no-pager --since=@%d", service, since.Unix())
+		if _, err := RunRemotely(ctx, logger, vm, cmd); err != nil {
+			logger.Printf("Could not get the journal of %v: %v", service, err)
+		}
+	}
+}
+
+// waitForAgentServices waits for each of the Ops Agent's services to be
+// running, for up to timeout each.
+func waitForAgentServices(ctx context.Context, logger *log.Logger, vm *VM, timeout time.Duration) error {
+	for _, service := range agentServices {
+		if err := WaitForServiceState(ctx, logger, vm, service, runningServiceState(vm.ImageSpec), timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyAgentConfig replaces the Ops Agent's user configuration file on the
+// given VM with configContents, restarts the agent and waits for all of its
+// services to be running again. If they don't come up, it logs what they
+// reported since the restart, e.g. config validation errors, before
+// returning an error.
+func ApplyAgentConfig(ctx context.Context, logger *log.Logger, vm *VM, configContents string) error {
+	configPath := agentConfigPath(vm.ImageSpec)
+	if err := UploadContent(ctx, logger, vm, strings.NewReader(configContents), configPath); err != nil {
+		return fmt.Errorf("ApplyAgentConfig() could not upload the config to %v: %v", configPath, err)
+	}
+	restartTime := time.Now()
+	_, restartErr := RunRemotely(ctx, logger, vm, restartAgentCommand(vm.ImageSpec))
+	if restartErr == nil {
+		restartErr = waitForAgentServices(ctx, logger, vm, agentServiceStartTimeout)
+	}
+	if restartErr != nil {
+		logAgentServiceErrors(ctx, logger, vm, restartTime)
+		return fmt.Errorf("ApplyAgentConfig() could not restart the agent with the new config: %v", restartErr)
+	}
+	return nil
+}
+
+// openPortBackoffDuration is how long WaitForOpenPort waits between polls.
+var openPortBackoffDuration = 2 * time.Second
+
+// isPortListening returns whether some process on the given VM is listening
+// on the given TCP port. It uses ss (or netstat, where ss is unavailable) on
+// Linux and Test-NetConnection on Windows.
+var isPortListening = func(ctx context.Context, logger *log.Logger, vm *VM, port int) (bool, error) {
+	if IsWindows(vm.ImageSpec) {
+		output, err := RunRemotely(ctx, logger, vm, fmt.Sprintf("(Test-NetConnection -ComputerName localhost -Port %d -WarningAction SilentlyContinue).TcpTestSucceeded", port))
+		if err != nil {
+			return false, err
+		}
+		return strings.EqualFold(strings.TrimSpace(output.Stdout), "True"), nil
+	}
+	// Both commands print one line per matching listening socket, and nothing
+	// if there are none.
+	cmd := fmt.Sprintf(`ss -Hltn 'sport = :%d' 2>/dev/null || netstat -ltn | awk '$4 ~ /:%d$/'`, port, port)
+	output, err := RunRemotely(ctx, logger, vm, cmd)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output.Stdout) != "", nil
+}
+
+// WaitForOpenPort polls the given VM until some process on it is listening on
+// the given TCP port, or the timeout expires.
+func WaitForOpenPort(ctx context.Context, logger *log.Logger, vm *VM, port int, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// backoff.Retry returns the context's error once the timeout expires, so
+	// keep the last probe error to say why the port never opened.
+	var lastErr error
+	isOpen := func() error {
+		listening, err := isPortListening(ctx, logger, vm, port)
+		if err == nil && !listening {
+			err = fmt.Errorf("nothing is listening on port %d", port)
+		}
+		if err != nil {
+			lastErr = err
+		}
+		return err
+	}
+
+	backoffPolicy := backoff.WithContext(backoff.NewConstantBackOff(openPortBackoffDuration), ctx)
+	if err := backoff.Retry(isOpen, backoffPolicy); err != nil {
+		if lastErr != nil {
+			err = lastErr
+		}
+		return fmt.Errorf("WaitForOpenPort(port=%d) timed out after %v: %v", port, timeout, err)
+	}
+	return nil
+}
+
+// HTTPGetFromVM issues an HTTP GET request for the given URL from inside the
+// given VM, using curl on Linux and Invoke-WebRequest on Windows. This is
+// useful for endpoints that are only reachable from the VM itself, such as
+// the agent's self-telemetry endpoints on localhost.
+//
+// Responses with non-2xx status codes are not treated as errors; err is only
+// set if the request could not be made at all.
+func HTTPGetFromVM(ctx context.Context, logger *log.Logger, vm *VM, url string) (statusCode int, body string, err error) {
+	// Both commands print the response body, then a newline, then the status
+	// code.
+	var cmd string
+	if IsWindows(vm.ImageSpec) {
+		cmd = fmt.Sprintf(`try {
+  $response = Invoke-WebRequest -UseBasicParsing -Uri %s
+  $code = [int]$response.StatusCode
+  $body = $response.Content
+} catch [System.Net.WebException] {
+  if ($_.Exception.Response -eq $null) { throw }
+  $code = [int]$_.Exception.Response.StatusCode
+  $body = (New-Object System.IO.StreamReader($_.Exception.Response.GetResponseStream())).ReadToEnd()
+}
+[Console]::Out.Write($body)
+[Console]::Out.Write("`+"`"+`n$code")`, powershellQuote(url))
+	} else {
+		cmd = fmt.Sprintf(`curl -sS -w '\n%%{http_code}' %s`, shellQuote(url))
+	}
+	output, err := RunRemotely(ctx, logger, vm, cmd)
+	if err != nil {
+		return 0, "", fmt.Errorf("HTTPGetFromVM(url=%s) failed: %w", url, err)
+	}
+	statusCode, body, err = parseHTTPGetOutput(output.Stdout)
+	if err != nil {
+		return 0, "", fmt.Errorf("HTTPGetFromVM(url=%s) failed: %w", url, err)
+	}
+	return statusCode, body, nil
+}
+
+// parseHTTPGetOutput splits the output of the commands run by HTTPGetFromVM
+// into the response's status code and body.
+func parseHTTPGetOutput(stdout string) (int, string, error) {
+	stdout = strings.TrimRight(stdout, "\r\n")
+	i := strings.LastIndex(stdout, "\n")
+	statusCode, err := strconv.Atoi(strings.TrimSpace(stdout[i+1:]))
+	if err != nil {
+		return 0, "", fmt.Errorf("could not parse status code from output %q: %v", stdout, err)
+	}
+	if i < 0 {
+		return statusCode, "", nil
+	}
+	return statusCode, strings.TrimSuffix(stdout[:i], "\r"), nil
+}
+
 // waitForStart waits for the given VM to be ready to accept remote commands.
 //
 // Note that this does not mean that the VM is fully initialized. We don't have
 // a good way to tell when the VM is fully initialized.
+//
+// If the VM never becomes ready, its serial port output is dumped into the
+// logger to help diagnose why.
 func waitForStart(ctx context.Context, logger *log.Logger, vm *VM) error {
+	var err error
 	if IsWindows(vm.ImageSpec) {
-		return waitForStartWindows(ctx, logger, vm)
+		err = waitForStartWindows(ctx, logger, vm)
+	} else {
+		err = waitForStartLinux(ctx, logger, vm)
+	}
+	if err != nil {
+		dumpSerialPortOutput(ctx, logger, vm)
+	}
+	return err
+}
+
+// GetSerialPortOutput returns the output that the given VM has written to the
+// given serial port. Port 1 holds the console output, which includes boot and
+// startup messages.
+func GetSerialPortOutput(ctx context.Context, logger *log.Logger, vm *VM, port int) (string, error) {
+	// Don't log the output here, since it can be very long. Callers can log
+	// it if they need to.
+	output, err := RunGcloud(ctx, log.New(io.Discard, "", 0), "", []string{
+		"compute", "instances", "get-serial-port-output", vm.Name,
+		"--project=" + vm.Project,
+		"--zone=" + vm.Zone,
+		fmt.Sprintf("--port=%d", port),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting serial port %d output for VM %v: %w", port, vm.Name, err)
+	}
+	return output.Stdout, nil
+}
+
+// dumpSerialPortOutput writes the VM's console output into the logger. It is
+// best-effort: failures are logged and otherwise ignored.
+func dumpSerialPortOutput(ctx context.Context, logger *log.Logger, vm *VM) {
+	// The passed-in context has likely expired by now, so use a fresh one,
+	// keeping the gcloud configuration directory.
+	configDir := ctx.Value(gcloudConfigDirKey)
+	dumpCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if configDir != nil {
+		dumpCtx = WithGcloudConfigDir(dumpCtx, configDir.(string))
 	}
-	return waitForStartLinux(ctx, logger, vm)
+	output, err := GetSerialPortOutput(dumpCtx, logger, vm, 1)
+	if err != nil {
+		logger.Printf("Unable to retrieve serial port output: %v", err)
+		return
+	}
+	logger.Printf("Serial port 1 output for VM %v:\n%s", vm.Name, output)
 }
 
 // logLocation returns a string pointing to the test log. When this test is run
@@ -2392,6 +6742,12 @@ type VMOptions struct {
 	// Example Image Specs:
 	// Image Family / Project: `<project>:<family>`
 	// Specific Image / Project: `<project>=<image>``
+	// Image self-link: `projects/<project>/global/images/<image>`, optionally
+	// with the https://www.googleapis.com/compute/v1/ prefix, or
+	// `projects/<project>/global/images/family/<family>`
+	// Disk snapshot (not for managed instance groups), along with the image
+	// its disk was created from: `source-snapshot=<snapshot>,from=<image spec>`
+	// See ParseImageSpec.
 	ImageSpec string
 	// Optional. Set this to a duration like "3h" or "1d" to configure the VM to
 	// be automatically deleted after the specified amount of time. This is
@@ -2400,39 +6756,208 @@ type VMOptions struct {
 	// crashes before calling DeleteInstance(), and besides DeleteInstance() can
 	// fail. Calling DeleteInstance() is still recommended even if your code sets
 	// a TimeToLive to free up VM resources as soon as possible.
+	// If missing, the default comes from DEFAULT_VM_TTL, or is "4h" if that is
+	// unset. Spot VMs and Managed Instance Group VMs don't get the default.
 	TimeToLive string
+	// Optional. Set this to create a Spot VM, which costs much less than a
+	// standard VM but can be preempted at any time, and may not be available
+	// at all when Compute Engine is short on capacity. A preempted VM is
+	// deleted, so the test using it fails and must be rerun, and its
+	// DeleteInstance() call fails too. This suits short tests that are cheap
+	// to rerun, like nightly runs, better than long or release-blocking ones.
+	// When no Spot capacity is available, CreateInstance falls back to a
+	// standard VM after MaxSpotAttempts attempts. Can't be combined with
+	// TimeToLive, which needs the standard provisioning model.
+	Spot bool
+	// Optional. How many attempts CreateInstance makes to create a Spot VM
+	// before falling back to a standard VM. If missing, the default is 3.
+	MaxSpotAttempts int
 	// Optional. If missing, a random name will be generated.
 	Name string
 	// Optional. If missing, the environment variable PROJECT will be used.
 	Project string
 	// Optional. If missing, the environment variable ZONE will be used.
 	Zone string
-	// Optional.
+	// Optional. The keys used by the framework, like "ssh-keys" and
+	// "startup-script", are reserved.
 	Metadata map[string]string
-	// Optional.
+	// Optional. Linux only. A startup script to run each time the VM boots.
+	// It is merged with any startup logic that the framework needs.
+	StartupScript string
+	// Optional. Windows only. A cmd command to run during sysprep, after the
+	// framework has installed the ssh server.
+	SysprepScript string
+	// Optional. The "kokoro_build_id" label is reserved.
 	Labels map[string]string
-	// Optional. If missing, the default is e2-standard-4.
+	// Optional. If missing, the default is e2-standard-4, or the first
+	// available of the default ARM machine types for ARM images.
 	// Overridden by INSTANCE_SIZE if that environment variable is set.
 	MachineType string
+	// Optional. ARM only. A machine type to try before the default ARM
+	// machine types (see DEFAULT_ARM_MACHINE_TYPES) when neither MachineType
+	// nor INSTANCE_SIZE is set.
+	DefaultARMMachineType string
 	// Optional. If missing, the default is 'global'.
 	ImageFamilyScope string
+	// Optional. The type of GPU to attach, for example "nvidia-tesla-t4".
+	// Must be set together with GPUCount. GPUs can only be attached to N1
+	// machine types; other GPU machine families like A2 and G2 come with
+	// their GPUs built in and need neither field.
+	GPUType string
+	// Optional. How many GPUs of GPUType to attach.
+	GPUCount int
+	// Optional. The GCS bucket that UploadContent transfers files to the VM
+	// through. If missing, TRANSFERS_BUCKET or its default is used. The
+	// application default credentials need to be able to create and delete
+	// objects in the bucket, and the VM's service account needs to be able to
+	// read them, e.g. with the "Storage Object Creator" and "Storage Object
+	// Viewer" roles respectively. Set this when the VM's service account can't
+	// read the default bucket, e.g. because the VM is in a different project.
+	TransfersBucket string
+	// Optional. Set this to ssh to the VM over its external IPv6 address,
+	// falling back to IPv4 if it doesn't have one. The VM only gets an IPv6
+	// address on a dual-stack subnet, with e.g. "--stack-type=IPV4_IPV6" in
+	// ExtraCreateArguments.
+	PreferIPv6 bool
+	// Optional. Network tags to give the VM when it is created, so that
+	// firewall rules that target them (like the one from
+	// CreateEgressDenyRule) apply from the moment it boots, unlike tags added
+	// later with AddTagToVm. A "--tags" flag in ExtraCreateArguments takes
+	// precedence over these.
+	Tags []string
+	// Optional. Set this to ssh to the VM through an Identity-Aware Proxy TCP
+	// tunnel (gcloud compute start-iap-tunnel) instead of connecting to its IP
+	// address directly. Also enabled for all VMs by USE_IAP. The VM's network
+	// must allow ingress on port 22 from IAP's range, 35.235.240.0/20.
+	UseIAP bool
+	// Optional. A host name, e.g. from internal DNS or a bastion's ssh
+	// config, to ssh to the VM at instead of the IP address that Compute
+	// Engine reports for it. This only changes how the VM is reached;
+	// queries for its metrics and logs still use its instance ID. Ignored
+	// when UseIAP is set.
+	ConnectHost string
+	// Optional. SetupVM only. Set this together with Name, Project and Zone
+	// to reuse a running VM with that name, e.g. one kept by a previous failed
+	// run, instead of creating a new one. A VM is only created if there is no
+	// VM with that name. See AttachToExistingVM.
+	AttachIfExists bool
+	// Optional. SetupVM only. By default, a VM that SetupVM attached to
+	// because of AttachIfExists is left running at the end of the test so
+	// that it can be attached to again. Set this to delete it like a newly
+	// created VM instead.
+	DeleteAttachedVM bool
+	// Optional. CreateManagedInstanceGroupVM only. Set this to create a
+	// regional Managed Instance Group, which spreads its instances across the
+	// zones from ZONES that are in the same region as the zone picked for the
+	// VM (or across zones that Compute Engine chooses, if ZONES has fewer than
+	// two there). The VM itself may end up in any of those zones. Can't be
+	// combined with Zone.
+	Regional bool
+	// Optional. The email of the service account for the VM to run as, e.g.
+	// a restricted one to exercise IAM-denied code paths. If missing,
+	// SERVICE_EMAIL or the project's default service account is used.
+	ServiceAccountEmail string
+	// Optional. The OAuth scopes to give the VM's service account, passed to
+	// gcloud as --scopes. If missing, gcloud's default scopes are used.
+	Scopes []string
 	// Optional. If provided, these arguments are appended on to the end
 	// of the "gcloud compute instances create" command.
 	ExtraCreateArguments []string
 }
 
-// SetupVM creates a new VM according to the given options.
+// reservedLabelKeys are the labels that the framework sets itself, so
+// VMOptions.Labels can't.
+var reservedLabelKeys = []string{"kokoro_build_id"}
+
+// Validate checks the options for mistakes that would otherwise only surface
+// partway through creating a VM, as a confusing gcloud error. CreateInstance
+// and CreateManagedInstanceGroupVM call it before doing anything else.
+func (options VMOptions) Validate() error {
+	if _, err := gcloudFlagsFromImageSpec(options.ImageSpec); err != nil {
+		return fmt.Errorf("invalid VMOptions: %v", err)
+	}
+	if options.TimeToLive != "" {
+		if _, err := parseGcloudDuration(options.TimeToLive); err != nil {
+			return fmt.Errorf("invalid VMOptions.TimeToLive: %v", err)
+		}
+	}
+	if _, err := gcloudFlagsForProvisioningModel(options.TimeToLive, options.Spot); err != nil {
+		return fmt.Errorf("invalid VMOptions: %v", err)
+	}
+	if err := checkNoFrameworkMetadataKeys(options.ImageSpec, slices.Collect(maps.Keys(options.Metadata))); err != nil {
+		return fmt.Errorf("invalid VMOptions.Metadata: %v", err)
+	}
+	if err := checkScriptOptions(options); err != nil {
+		return fmt.Errorf("invalid VMOptions: %v", err)
+	}
+	if options.Regional && options.Zone != "" {
+		return fmt.Errorf("invalid VMOptions: Regional and Zone can't be set together: a regional Managed Instance Group chooses the zones of its instances, got Zone=%q", options.Zone)
+	}
+	for _, key := range reservedLabelKeys {
+		if _, ok := options.Labels[key]; ok {
+			return fmt.Errorf("invalid VMOptions.Labels: the %q key is reserved for framework use", key)
+		}
+	}
+	if _, err := areTagsValid(options.Tags); err != nil {
+		return fmt.Errorf("invalid VMOptions.Tags: %v", err)
+	}
+	if options.ServiceAccountEmail != "" && !serviceAccountEmailRegex.MatchString(options.ServiceAccountEmail) {
+		return fmt.Errorf("invalid VMOptions.ServiceAccountEmail %q: want an email address like name@project.iam.gserviceaccount.com", options.ServiceAccountEmail)
+	}
+	for _, scope := range options.Scopes {
+		if scope == "" || strings.Contains(scope, ",") {
+			return fmt.Errorf("invalid VMOptions.Scopes: scope %q must be nonempty and cannot contain commas", scope)
+		}
+	}
+	return nil
+}
+
+// serviceAccountEmailRegex loosely matches a service account email address.
+var serviceAccountEmailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// SetupVM creates a new VM according to the given options, or attaches to an
+// existing one if options.AttachIfExists is set.
 // If VM creation fails, it will abort the test.
-// At the end of the test, the VM will be cleaned up.
+// At the end of the test, the VM will be cleaned up, unless the test failed
+// and SetKeepVMsOnFailure(true) was called, or the VM was attached to and
+// options.DeleteAttachedVM is not set.
 func SetupVM(ctx context.Context, t *testing.T, logger *log.Logger, options VMOptions) *VM {
 	t.Helper()
+	return setupVM(ctx, t, logger, options)
+}
+
+// setupVM implements SetupVM. It takes a testing.TB so that its cleanup can be
+// exercised with a fake test.
+func setupVM(ctx context.Context, t testing.TB, logger *log.Logger, options VMOptions) *VM {
+	t.Helper()
 
-	vm, err := CreateInstance(ctx, logger, options)
+	vm, attached, err := attachOrCreateInstance(ctx, logger, options)
 	if err != nil {
 		t.Fatalf("SetupVM() error creating instance: %v", err)
 	}
 	t.Cleanup(func() {
-		if err := DeleteInstance(ctx, logger, vm); err != nil {
+		if t.Failed() {
+			// Put the diagnostics next to the logs from SetupLogger.
+			destDir := path.Join(logRootDir, strings.Replace(t.Name(), "/", "_", -1))
+			if err := collectDiagnostics(ctx, logger, vm, destDir); err != nil {
+				t.Logf("SetupVM() could not collect diagnostics: %v", err)
+			}
+		}
+		if keepVMsOnFailure.Load() && t.Failed() {
+			keptVMs.Store(true)
+			t.Logf("SetupVM() keeping instance %v for debugging because the test failed. Connect with:\n  %v", vm.Name, sshCommandForDebugging(vm))
+			if vm.TimeToLive == "" {
+				t.Logf("SetupVM() instance %v has no TimeToLive and must be deleted by hand", vm.Name)
+			} else {
+				t.Logf("SetupVM() instance %v will be deleted automatically after %v", vm.Name, vm.TimeToLive)
+			}
+			return
+		}
+		if attached && !options.DeleteAttachedVM {
+			t.Logf("SetupVM() leaving attached instance %v running", vm.Name)
+			return
+		}
+		if err := deleteInstance(ctx, logger, vm); err != nil {
 			t.Errorf("SetupVM() error deleting instance: %v", err)
 		}
 	})
@@ -2441,6 +6966,37 @@ func SetupVM(ctx context.Context, t *testing.T, logger *log.Logger, options VMOp
 	return vm
 }
 
+// attachOrCreateInstance attaches to the VM named by options if
+// options.AttachIfExists is set and that VM exists, and creates a new VM
+// otherwise. It also returns whether it attached to an existing VM.
+func attachOrCreateInstance(ctx context.Context, logger *log.Logger, options VMOptions) (*VM, bool, error) {
+	if options.AttachIfExists {
+		if options.Name == "" || options.Project == "" || options.Zone == "" {
+			return nil, false, errors.New("VMOptions.AttachIfExists requires Name, Project and Zone to be set")
+		}
+		vm, err := attachToExistingVM(ctx, logger, options.Project, options.Zone, options.Name)
+		if err == nil {
+			return vm, true, nil
+		}
+		if !isInstanceNotFoundError(err) {
+			return nil, false, err
+		}
+		logger.Printf("VM %v does not exist yet, creating it", options.Name)
+	}
+	vm, err := createInstance(ctx, logger, options)
+	return vm, false, err
+}
+
+// sshCommandForDebugging returns an ssh command line that a human can run to
+// connect to the given VM using this library's ssh keys.
+func sshCommandForDebugging(vm *VM) string {
+	if vm.UseIAP {
+		return fmt.Sprintf("gcloud compute ssh %s --project=%s --zone=%s --tunnel-through-iap --ssh-key-file=%s -- -l %s",
+			vm.Name, vm.Project, vm.Zone, privateKeyFile, sshUserName)
+	}
+	return fmt.Sprintf("ssh -oIdentityFile=%s %s@%s", privateKeyFile, sshUserName, vmHost(vm))
+}
+
 // SetupManagedInstanceGroupVM creates an individual VM instance in a Managed Instance Group according to the given options.
 // If the ManagedInstanceGroupVM creation fails, it will abort the test.
 // At the end of the test, the ManagedInstanceGroupVM will be cleaned up.
@@ -2461,20 +7017,207 @@ func SetupManagedInstanceGroupVM(ctx context.Context, t *testing.T, logger *log.
 	return migVM
 }
 
-// RunForEachImage runs a subtest for each image defined in IMAGE_SPECS.
-func RunForEachImage(t *testing.T, testBody func(t *testing.T, imageSpec string)) {
+// MockBackendOptions specifies settings when creating a mock backend VM via
+// SetupMockBackendVM().
+type MockBackendOptions struct {
+	// Required. Settings for the backend VM itself. ImageSpec must be a Linux
+	// image. The backend's own network tag is added to Tags.
+	VMOptions VMOptions
+	// Required. Local path to the receiver binary to run on the backend VM.
+	// It must be built for the backend VM's OS and architecture.
+	BinaryPath string
+	// Optional. Arguments to pass to the receiver binary.
+	Args []string
+	// Optional. The TCP port that the receiver binary listens on. If missing,
+	// the default is 4318, the standard OTLP/HTTP port.
+	Port int
+}
+
+// scpToVM is SCPToVM.
+var scpToVM = SCPToVM
+
+const (
+	mockBackendRemotePath = "/usr/local/bin/mock-backend"
+	// mockBackendStartTimeout is how long setupMockBackend waits for the mock
+	// backend to listen on its port.
+	mockBackendStartTimeout = 2 * time.Minute
+)
+
+// setupMockBackend implements SetupMockBackendVM. Cleanup of every created
+// resource is registered with the cleanup function as soon as it exists.
+func setupMockBackend(ctx context.Context, logger *log.Logger, options MockBackendOptions, cleanup func(func() error)) (*VM, string, error) {
+	if options.BinaryPath == "" {
+		return nil, "", errors.New("MockBackendOptions.BinaryPath must be set")
+	}
+	if IsWindows(options.VMOptions.ImageSpec) {
+		return nil, "", fmt.Errorf("mock backend VMs must run Linux, got image spec %q", options.VMOptions.ImageSpec)
+	}
+	port := options.Port
+	if port == 0 {
+		port = 4318
+	}
+
+	// The firewall rule applies to instances with this network tag, and the
+	// tag name doubles as the rule name.
+	tag := fmt.Sprintf("%s-backend-%s", sandboxPrefix, uuid.NewString()[:8])
+	vmOptions := options.VMOptions
+	vmOptions.Tags = append(slices.Clone(vmOptions.Tags), tag)
+
+	vm, err := createInstance(ctx, logger, vmOptions)
+	if err != nil {
+		return nil, "", err
+	}
+	cleanup(func() error { return deleteInstance(ctx, logger, vm) })
+
+	if err := scpToVM(ctx, logger, vm, options.BinaryPath, mockBackendRemotePath); err != nil {
+		return nil, "", err
+	}
+	var quotedArgs []string
+	for _, arg := range options.Args {
+		quotedArgs = append(quotedArgs, shellQuote(arg))
+	}
+	runCmd := fmt.Sprintf("sudo chmod a+x %s && nohup sudo %s %s > /tmp/mock-backend.log 2>&1 &",
+		mockBackendRemotePath, mockBackendRemotePath, strings.Join(quotedArgs, " "))
+	if _, err := runRemotely(ctx, logger, vm, runCmd); err != nil {
+		return nil, "", fmt.Errorf("could not start mock backend: %w", err)
+	}
+	if err := WaitForOpenPort(ctx, logger, vm, port, mockBackendStartTimeout); err != nil {
+		return nil, "", fmt.Errorf("mock backend did not start: %w", err)
+	}
+
+	// Agent VMs can be in any subnet of the network, and the subnets of a
+	// custom-mode network can use any private range, so let in all of them.
+	subnets, err := runGcloud(ctx, logger, "", []string{
+		"compute", "networks", "subnets", "list",
+		"--project=" + vm.Project,
+		"--network=" + vm.Network,
+		"--format=value(ipCidrRange)",
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("could not list the subnets of network %v: %w", vm.Network, err)
+	}
+	sourceRanges := strings.Fields(subnets.Stdout)
+	if len(sourceRanges) == 0 {
+		return nil, "", fmt.Errorf("network %v has no subnets", vm.Network)
+	}
+	if _, err := runGcloud(ctx, logger, "", []string{
+		"compute", "firewall-rules", "create", tag,
+		"--project=" + vm.Project,
+		"--network=" + vm.Network,
+		"--direction=INGRESS",
+		fmt.Sprintf("--allow=tcp:%d", port),
+		"--source-ranges=" + strings.Join(sourceRanges, ","),
+		"--target-tags=" + tag,
+	}); err != nil {
+		return nil, "", fmt.Errorf("could not open port %d on the mock backend: %w", port, err)
+	}
+	cleanup(func() error {
+		_, err := runGcloud(ctx, logger, "", []string{
+			"compute", "firewall-rules", "delete", tag,
+			"--project=" + vm.Project,
+			"--quiet",
+		})
+		return err
+	})
+
+	// Agent VMs reach the backend over the VPC network, so use its internal IP
+	// address regardless of USE_INTERNAL_IP.
+	output, err := runGcloud(ctx, logger, "", []string{
+		"compute", "instances", "describe", vm.Name,
+		"--project=" + vm.Project,
+		"--zone=" + vm.Zone,
+		"--format=value(networkInterfaces[0].networkIP)",
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("could not look up the internal IP of the mock backend: %w", err)
+	}
+	internalIP := strings.TrimSpace(output.Stdout)
+	if internalIP == "" {
+		return nil, "", fmt.Errorf("empty internal IP for mock backend VM %v", vm.Name)
+	}
+	return vm, fmt.Sprintf("http://%s:%d", internalIP, port), nil
+}
+
+// SetupMockBackendVM creates a new VM that runs the given receiver binary, for
+// use as a backend that agents on other VMs send telemetry to. Once the binary
+// is listening, returns the VM and the URL that agents should send to, such as
+// "http://10.128.0.5:4318". Only VMs in the same network can reach the URL.
+// If setup fails, it will abort the test.
+// At the end of the test, the VM and its firewall rule will be cleaned up.
+func SetupMockBackendVM(ctx context.Context, t *testing.T, logger *log.Logger, options MockBackendOptions) (*VM, string) {
+	t.Helper()
+
+	cleanup := func(f func() error) {
+		t.Cleanup(func() {
+			if err := f(); err != nil {
+				t.Errorf("SetupMockBackendVM() error during cleanup: %v", err)
+			}
+		})
+	}
+	vm, backendURL, err := setupMockBackend(ctx, logger, options, cleanup)
+	if err != nil {
+		t.Fatalf("SetupMockBackendVM() error setting up mock backend: %v", err)
+	}
+	t.Logf("Mock backend %v is listening at %v", vm.Name, backendURL)
+	return vm, backendURL
+}
+
+// imageSpecsToRun returns the images defined in IMAGE_SPECS that tests should
+// run on.
+func imageSpecsToRun(t *testing.T, caller string) []string {
+	t.Helper()
 	imageSpecsEnv := os.Getenv("IMAGE_SPECS")
 	if imageSpecsEnv == "" {
-		t.Fatal("IMAGE_SPECS env variable must be nonempty for RunForEachImage.")
+		t.Fatalf("IMAGE_SPECS env variable must be nonempty for %s.", caller)
 	}
-	imageSpecs := strings.Split(imageSpecsEnv, ",")
-	for _, imageSpec := range imageSpecs {
-		imageSpec := imageSpec // https://golang.org/doc/faq#closures_and_goroutines
+	var imageSpecs []string
+	for _, imageSpec := range strings.Split(imageSpecsEnv, ",") {
 		// FIXME(b/406277901): Re-enable tests to run for the UAP plugin on the two images.
 		if IsOpsAgentUAPPlugin() && (IsWindows2016(imageSpec) || IsWindows2019(imageSpec)) {
 			continue
 		}
+		imageSpecs = append(imageSpecs, imageSpec)
+	}
+	return imageSpecs
+}
+
+// RunForEachImage runs a subtest for each image defined in IMAGE_SPECS.
+func RunForEachImage(t *testing.T, testBody func(t *testing.T, imageSpec string)) {
+	for _, imageSpec := range imageSpecsToRun(t, "RunForEachImage") {
+		imageSpec := imageSpec // https://golang.org/doc/faq#closures_and_goroutines
+		t.Run(imageSpec, func(t *testing.T) {
+			testBody(t, imageSpec)
+		})
+	}
+}
+
+// MaxParallelImages is the maximum number of subtests started by
+// RunForEachImageParallel that run at the same time, across all tests in the
+// package. Lower it to stay within quota when each subtest creates VMs. It
+// must be set before the first call to RunForEachImageParallel.
+var MaxParallelImages = 8
+
+var (
+	parallelImageSlotsOnce sync.Once
+	// Holds a value for each running subtest started by RunForEachImageParallel.
+	parallelImageSlots chan struct{}
+)
+
+// RunForEachImageParallel is like RunForEachImage, but the subtests for the
+// different images run in parallel with each other (and with other parallel
+// tests), up to MaxParallelImages at a time. testBody must not call
+// t.Parallel() itself. A subtest counts towards MaxParallelImages until its
+// cleanups, such as deleting the VMs created by SetupVM, have finished.
+func RunForEachImageParallel(t *testing.T, testBody func(t *testing.T, imageSpec string)) {
+	parallelImageSlotsOnce.Do(func() {
+		parallelImageSlots = make(chan struct{}, max(MaxParallelImages, 1))
+	})
+	for _, imageSpec := range imageSpecsToRun(t, "RunForEachImageParallel") {
 		t.Run(imageSpec, func(t *testing.T) {
+			t.Parallel()
+			parallelImageSlots <- struct{}{}
+			// Registered first so that it runs after all of testBody's cleanups.
+			t.Cleanup(func() { <-parallelImageSlots })
 			testBody(t, imageSpec)
 		})
 	}
@@ -2539,3 +7282,64 @@ func RemoveTagFromVm(ctx context.Context, logger *log.Logger, vm *VM, tags []str
 	}
 	return output, nil
 }
+
+// egressDenyRuleName returns the name of the firewall rule that
+// CreateEgressDenyRule creates in the given network. Firewall rule names are
+// unique per project, so the name includes the network.
+func egressDenyRuleName(network string) string {
+	name := DenyEgressTrafficTag + "-" + network
+	// Firewall rule names can be at most 63 characters long and can't end
+	// with a hyphen.
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// CreateEgressDenyRule creates a firewall rule in the given network that
+// blocks all outgoing IPv4 traffic from VMs tagged with DenyEgressTrafficTag.
+// Tag a VM with AddTagToVm to cut it off from the network, e.g. to test how
+// the agent buffers data while offline, and untag it with RemoveTagFromVm to
+// restore its access. It succeeds if the rule already exists.
+func CreateEgressDenyRule(ctx context.Context, logger *log.Logger, project, network string) error {
+	name := egressDenyRuleName(network)
+	_, err := runGcloud(ctx, logger, "", []string{
+		"compute", "firewall-rules", "create", name,
+		"--project=" + project,
+		"--network=" + network,
+		"--direction=EGRESS",
+		"--action=DENY",
+		"--rules=all",
+		"--destination-ranges=0.0.0.0/0",
+		// Take precedence over any rules that allow egress.
+		"--priority=0",
+		"--target-tags=" + DenyEgressTrafficTag,
+	})
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		logger.Printf("Firewall rule %s already exists", name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("CreateEgressDenyRule(network=%q) failed: %w", network, err)
+	}
+	return nil
+}
+
+// DeleteEgressDenyRule deletes the firewall rule created by
+// CreateEgressDenyRule. It succeeds if the rule doesn't exist.
+func DeleteEgressDenyRule(ctx context.Context, logger *log.Logger, project, network string) error {
+	name := egressDenyRuleName(network)
+	_, err := runGcloud(ctx, logger, "", []string{
+		"compute", "firewall-rules", "delete", name,
+		"--project=" + project,
+		"--quiet",
+	})
+	if err != nil && strings.Contains(err.Error(), "was not found") {
+		logger.Printf("Firewall rule %s was already deleted", name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("DeleteEgressDenyRule(network=%q) failed: %w", network, err)
+	}
+	return nil
+}
@@ -105,8 +105,9 @@ type testCase struct {
 
 	// An escape hatch to skip certain tests when run with RunScriptRemotely.
 	// This is for two reasons:
-	// 1. Some tests pass in data through stdin, which is (currently) not
-	//    supported by RunScriptRemotely.
+	// 1. Some tests pass in data through stdin, which is not supported by
+	//    RunScriptRemotely. These tests are still run with
+	//    RunScriptRemotelyStdin.
 	// 2. To work around a bug with Powershell -File, which ignores some kinds
 	//    of errors that it really shouldn't:
 	//    "With normal termination, the exit code is always 0."
@@ -347,6 +348,12 @@ func testRunRemotelyHelper(ctx context.Context, t *testing.T, logger *log.Logger
 				return gce.RunScriptRemotely(ctx, logger, vm, command, nil, nil)
 			},
 		},
+		{
+			name: "RunScriptRemotelyStdin",
+			runner: func(stdin io.Reader, command string) (gce.CommandOutput, error) {
+				return gce.RunScriptRemotelyStdin(ctx, logger, vm, command, stdin, nil, nil)
+			},
+		},
 	}
 
 	for _, runnerCase := range runners {
@@ -354,10 +361,19 @@ func testRunRemotelyHelper(ctx context.Context, t *testing.T, logger *log.Logger
 			logger.Printf("Starting test for %v", runnerCase.name)
 
 			for _, tc := range testCases {
-				if tc.skipRunScriptRemotely && runnerCase.name == "RunScriptRemotely" {
+				// RunScriptRemotelyStdin supports stdin, so it only needs to skip
+				// the tests that are skipped for other reasons.
+				if tc.skipRunScriptRemotely && (runnerCase.name == "RunScriptRemotely" ||
+					runnerCase.name == "RunScriptRemotelyStdin" && tc.stdin == nil) {
 					logger.Printf("Skipping test for command %q due to skipRunScriptRemotely", tc.command)
 					continue
 				}
+				// Each runner needs to read stdin from the beginning.
+				if seeker, ok := tc.stdin.(io.Seeker); ok {
+					if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+						t.Fatal(err)
+					}
+				}
 				output, err := runnerCase.runner(tc.stdin, tc.command)
 				if tc.fail {
 					if err == nil {
@@ -484,6 +500,68 @@ func TestUploadContent(t *testing.T) {
 	})
 }
 
+func TestRetrieveBinaryContent(t *testing.T) {
+	t.Parallel()
+	gce.RunForEachImage(t, func(t *testing.T, platform string) {
+		t.Parallel()
+
+		ctx, logger, vm := SetupLoggerAndVM(t, platform)
+
+		cases := [][]byte{
+			[]byte(""),
+			[]byte("goodbye\r\n"),
+			eachByte(),
+			randomBytes(t, 10_000_000),
+		}
+		path := "/test_retrieve_binary_content"
+
+		for _, data := range cases {
+			if err := gce.UploadContent(ctx, logger.ToMainLog(), vm, bytes.NewReader(data), path); err != nil {
+				t.Fatalf("Uploading %v bytes failed: %v", len(data), err)
+			}
+			retrieved, err := gce.RetrieveBinaryContent(ctx, logger.ToMainLog(), vm, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(retrieved, data) {
+				t.Errorf("retrieved %v bytes with MD5 %x, want %v bytes with MD5 %x", len(retrieved), md5.Sum(retrieved), len(data), md5.Sum(data))
+			}
+		}
+	})
+}
+
+func TestCopyBetweenVMs(t *testing.T) {
+	t.Parallel()
+	gce.RunForEachImage(t, func(t *testing.T, platform string) {
+		t.Parallel()
+
+		ctx, logger, srcVM := SetupLoggerAndVM(t, platform)
+		// Always copy to a Linux VM, so that Windows platforms exercise a
+		// mixed OS pair.
+		dstVM := gce.SetupVM(ctx, t, logger.ToFile("dst_VM_initialization.txt"), gce.VMOptions{
+			ImageSpec:  "debian-cloud:debian-12",
+			TimeToLive: "1h",
+		})
+
+		data := randomBytes(t, 10_000_000)
+		srcPath := "/test_copy_between_vms_src"
+		dstPath := "/test_copy_between_vms_dst"
+		if err := gce.UploadContent(ctx, logger.ToMainLog(), srcVM, bytes.NewReader(data), srcPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := gce.CopyBetweenVMs(ctx, logger.ToMainLog(), srcVM, srcPath, dstVM, dstPath); err != nil {
+			t.Fatal(err)
+		}
+		copied, err := gce.RetrieveBinaryContent(ctx, logger.ToMainLog(), dstVM, dstPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(copied, data) {
+			t.Errorf("copied %v bytes with MD5 %x, want %v bytes with MD5 %x", len(copied), md5.Sum(copied), len(data), md5.Sum(data))
+		}
+	})
+}
+
 func TestRunCommandEnvMerging(t *testing.T) {
 	// Set a unique environment variable
 	testKey := "TEST_ENV_VAR_FOR_GCE_TESTING"
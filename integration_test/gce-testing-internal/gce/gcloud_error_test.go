@@ -0,0 +1,126 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// Error strings below are abridged from real gcloud failures.
+var gcloudErrorTests = []struct {
+	name      string
+	err       string
+	retriable bool
+}{
+	{
+		name:      "quota",
+		err:       "ERROR: (gcloud.compute.instances.create) Could not fetch resource:\n - Quota 'CPUS' exceeded.  Limit: 2400.0 in region us-central1.",
+		retriable: true,
+	},
+	{
+		name:      "internal error",
+		err:       "ERROR: (gcloud.compute.instances.create) Could not fetch resource:\n - Internal error. Please try again or contact Google Support. (Code: '5A1B2C3D4E5F6')",
+		retriable: true,
+	},
+	{
+		name:      "zone exhausted",
+		err:       "ERROR: (gcloud.compute.instances.create) Could not fetch resource:\n - The zone 'projects/p/zones/us-central1-a' does not have enough resources available to fulfill the request.  Try a different zone, or try again later.",
+		retriable: true,
+	},
+	{
+		name:      "currently unavailable",
+		err:       "ERROR: (gcloud.compute.instances.create) Could not fetch resource:\n - A n2-standard-4 VM instance is currently unavailable in the us-central1-a zone.",
+		retriable: true,
+	},
+	{
+		name:      "database locked",
+		err:       "ERROR: gcloud crashed (OperationalError): database is locked",
+		retriable: true,
+	},
+	{
+		name:      "502",
+		err:       "ERROR: (gcloud.compute.instances.delete) HTTPError 502: Error 502 (Server Error)!!1",
+		retriable: true,
+	},
+	{
+		name:      "503",
+		err:       "ERROR: (gcloud.compute.instances.describe) Error 503 (Service Unavailable)",
+		retriable: true,
+	},
+	{
+		name: "permission denied",
+		err:  "ERROR: (gcloud.compute.instances.create) Could not fetch resource:\n - Required 'compute.instances.create' permission for 'projects/p/zones/us-central1-a/instances/vm'",
+	},
+	{
+		name: "permission denied mentioning quota",
+		err:  "ERROR: (gcloud.compute.regions.describe) PERMISSION_DENIED: Permission denied to get service [compute.googleapis.com] quota",
+	},
+	{
+		name: "invalid argument",
+		err:  "ERROR: (gcloud.compute.instances.create) Could not fetch resource:\n - Invalid value for field 'resource.machineType': 'zones/us-central1-a/machineTypes/n9-standard-4'.",
+	},
+	{
+		name: "not found",
+		err:  "ERROR: (gcloud.compute.instances.delete) Could not fetch resource:\n - The resource 'projects/p/zones/us-central1-a/instances/vm' was not found",
+	},
+	{
+		name: "unknown",
+		err:  "exit status 1",
+	},
+}
+
+func TestClassifyGcloudError(t *testing.T) {
+	if classifyGcloudError(nil) {
+		t.Error("classifyGcloudError(nil) = true; want false")
+	}
+	for _, tc := range gcloudErrorTests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifyGcloudError(errors.New(tc.err)); got != tc.retriable {
+				t.Errorf("classifyGcloudError(%q) = %v; want %v", tc.err, got, tc.retriable)
+			}
+			options := VMOptions{ImageSpec: "debian-cloud:debian-12"}
+			if got := shouldRetryCreateVM(errors.New(tc.err), options); got != tc.retriable {
+				t.Errorf("shouldRetryCreateVM(%q) = %v; want %v", tc.err, got, tc.retriable)
+			}
+		})
+	}
+}
+
+func TestHandleDeleteError(t *testing.T) {
+	if err := handleDeleteError(nil, 1); err != nil {
+		t.Errorf("handleDeleteError(nil) = %v; want nil", err)
+	}
+	for _, tc := range gcloudErrorTests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := handleDeleteError(errors.New(tc.err), 1)
+			var permanent *backoff.PermanentError
+			if err == nil {
+				t.Fatalf("handleDeleteError(%q, 1) = nil; want an error", tc.err)
+			}
+			if got := !errors.As(err, &permanent); got != tc.retriable {
+				t.Errorf("handleDeleteError(%q, 1) retriable = %v; want %v", tc.err, got, tc.retriable)
+			}
+		})
+	}
+
+	// A later attempt that finds the VM already gone succeeded earlier.
+	notFound := errors.New("ERROR: (gcloud.compute.instances.delete) The resource 'projects/p/zones/z/instances/vm' was not found")
+	if err := handleDeleteError(notFound, 2); err != nil {
+		t.Errorf("handleDeleteError(%q, 2) = %v; want nil", notFound, err)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSetGcloudObserver(t *testing.T) {
+	origPath := gcloudPath
+	t.Cleanup(func() {
+		gcloudPath = origPath
+		SetGcloudObserver(nil)
+	})
+	type call struct {
+		args     []string
+		duration time.Duration
+		err      error
+	}
+	var calls []call
+	SetGcloudObserver(func(args []string, duration time.Duration, err error) {
+		calls = append(calls, call{args, duration, err})
+	})
+	logger := log.New(io.Discard, "", 0)
+
+	gcloudPath = "true"
+	if _, err := RunGcloud(context.Background(), logger, "", []string{"compute", "instances", "create", "vm"}); err != nil {
+		t.Fatalf("RunGcloud() failed: %v", err)
+	}
+	gcloudPath = "false"
+	if _, err := RunGcloud(context.Background(), logger, "", []string{"compute", "instances", "delete", "vm"}); err == nil {
+		t.Fatal("RunGcloud() with a failing command succeeded, want an error")
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("observer was called %d times, want 2", len(calls))
+	}
+	if !slices.Equal(calls[0].args, []string{"compute", "instances", "create", "vm"}) || calls[0].err != nil || calls[0].duration <= 0 {
+		t.Errorf("first observed call = %+v, want the create command with a duration and no error", calls[0])
+	}
+	if !slices.Equal(calls[1].args, []string{"compute", "instances", "delete", "vm"}) || calls[1].err == nil {
+		t.Errorf("second observed call = %+v, want the delete command with an error", calls[1])
+	}
+
+	SetGcloudObserver(nil)
+	gcloudPath = "true"
+	if _, err := RunGcloud(context.Background(), logger, "", []string{"info"}); err != nil {
+		t.Fatalf("RunGcloud() without an observer failed: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Errorf("removed observer was called again: %+v", calls[2:])
+	}
+}
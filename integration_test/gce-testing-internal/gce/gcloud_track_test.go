@@ -0,0 +1,67 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWithGcloudTrackRejectsUnknownTrack(t *testing.T) {
+	if _, err := WithGcloudTrack(context.Background(), "gamma"); err == nil {
+		t.Error("WithGcloudTrack(\"gamma\") succeeded, want an error")
+	}
+}
+
+func TestGcloudTrackArgs(t *testing.T) {
+	withTrack := func(track string) context.Context {
+		ctx, err := WithGcloudTrack(context.Background(), track)
+		if err != nil {
+			t.Fatalf("WithGcloudTrack(%q) failed: %v", track, err)
+		}
+		return ctx
+	}
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		args []string
+		want []string
+	}{
+		{name: "unset", ctx: context.Background(), args: []string{"compute", "instances", "list"}, want: []string{"compute", "instances", "list"}},
+		{name: "GA", ctx: withTrack("GA"), args: []string{"compute", "instances", "list"}, want: []string{"compute", "instances", "list"}},
+		{name: "alpha", ctx: withTrack("alpha"), args: []string{"compute", "instances", "list"}, want: []string{"alpha", "compute", "instances", "list"}},
+		{name: "explicit track wins", ctx: withTrack("alpha"), args: []string{"beta", "compute", "instances", "list"}, want: []string{"beta", "compute", "instances", "list"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := gcloudTrackArgs(tc.ctx, tc.args); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("gcloudTrackArgs() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCreateTrack(t *testing.T) {
+	if got := createTrack(context.Background()); got != "beta" {
+		t.Errorf("createTrack() = %q with no track set, want beta", got)
+	}
+	ctx, err := WithGcloudTrack(context.Background(), "alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := createTrack(ctx); got != "alpha" {
+		t.Errorf("createTrack() = %q with alpha set, want alpha", got)
+	}
+}
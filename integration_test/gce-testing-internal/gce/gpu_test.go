@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGcloudFlagsForGPUs(t *testing.T) {
+	got, err := gcloudFlagsForGPUs("n1-standard-4", "nvidia-tesla-t4", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"--accelerator=type=nvidia-tesla-t4,count=2", "--maintenance-policy=TERMINATE"}
+	if !slices.Equal(got, want) {
+		t.Errorf("gcloudFlagsForGPUs() = %v; want %v", got, want)
+	}
+
+	if got, err := gcloudFlagsForGPUs("e2-standard-4", "", 0); err != nil || got != nil {
+		t.Errorf("gcloudFlagsForGPUs() with no GPUs = (%v, %v); want (nil, nil)", got, err)
+	}
+}
+
+func TestGcloudFlagsForGPUsErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		machineType string
+		gpuType     string
+		gpuCount    int
+		wantErr     string
+	}{
+		{
+			name:        "missing count",
+			machineType: "n1-standard-4",
+			gpuType:     "nvidia-tesla-t4",
+			wantErr:     "must be set together",
+		},
+		{
+			name:        "missing type",
+			machineType: "n1-standard-4",
+			gpuCount:    1,
+			wantErr:     "must be set together",
+		},
+		{
+			name:        "unsupported machine family",
+			machineType: "e2-standard-4",
+			gpuType:     "nvidia-tesla-t4",
+			gpuCount:    1,
+			wantErr:     "only be attached to N1",
+		},
+		{
+			name:        "built-in GPUs",
+			machineType: "a2-highgpu-1g",
+			gpuType:     "nvidia-tesla-a100",
+			gpuCount:    1,
+			wantErr:     "already comes with GPUs",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := gcloudFlagsForGPUs(tc.machineType, tc.gpuType, tc.gpuCount)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("gcloudFlagsForGPUs() error = %v; want an error mentioning %q", err, tc.wantErr)
+			}
+		})
+	}
+}
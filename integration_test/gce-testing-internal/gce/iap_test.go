@@ -0,0 +1,66 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSSHTarget(t *testing.T) {
+	vm := &VM{Name: "vm", Project: "p", Zone: "us-central1-a", IPAddress: "203.0.113.7"}
+	host, options, env := sshTarget(context.Background(), vm)
+	if host != "203.0.113.7" || len(options) != 0 || len(env) != 0 {
+		t.Errorf("sshTarget() = (%q, %v, %v), want the VM's IP address and nothing else", host, options, env)
+	}
+
+	vm.UseIAP = true
+	ctx := WithGcloudConfigDir(context.Background(), "/tmp/gcloud-config")
+	host, options, env = sshTarget(ctx, vm)
+	if host != "vm" {
+		t.Errorf("sshTarget() host = %q, want the VM's name", host)
+	}
+	if len(options) != 1 || !strings.HasPrefix(options[0], "-oProxyCommand=") ||
+		!strings.Contains(options[0], "compute start-iap-tunnel vm %p --listen-on-stdin --project=p --zone=us-central1-a") {
+		t.Errorf("sshTarget() options = %v, want an IAP tunnel ProxyCommand", options)
+	}
+	if env["CLOUDSDK_CONFIG"] != "/tmp/gcloud-config" {
+		t.Errorf("sshTarget() env = %v, want CLOUDSDK_CONFIG from the context", env)
+	}
+}
+
+func TestSSHTargetConnectHost(t *testing.T) {
+	vm := createVMFromVMOptions(VMOptions{ImageSpec: "debian-cloud:debian-12", Project: "p", Zone: "us-central1-a", ConnectHost: "vm.internal.example.com"})
+	vm.IPAddress = "203.0.113.7"
+	host, _, _ := sshTarget(context.Background(), vm)
+	if host != "vm.internal.example.com" {
+		t.Errorf("sshTarget() host = %q, want the VM's ConnectHost", host)
+	}
+	if got := sshCommandForDebugging(vm); !strings.HasSuffix(got, "@vm.internal.example.com") {
+		t.Errorf("sshCommandForDebugging() = %q, want it to connect to the VM's ConnectHost", got)
+	}
+	if vm.IPAddress != "203.0.113.7" {
+		t.Errorf("IPAddress = %q, want it unchanged", vm.IPAddress)
+	}
+}
+
+func TestUseIAPFromEnv(t *testing.T) {
+	t.Setenv("USE_IAP", "true")
+	vm := createVMFromVMOptions(VMOptions{ImageSpec: "debian-cloud:debian-12", Project: "p", Zone: "us-central1-a"})
+	if !vm.UseIAP {
+		t.Error("createVMFromVMOptions() with USE_IAP=true did not set UseIAP")
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ImageSpec identifies the image to create a VM from. See
+// VMOptions.ImageSpec for the supported formats.
+//
+// The zero value is not a valid ImageSpec; use ParseImageSpec to create one.
+type ImageSpec struct {
+	raw string
+	// For a self-link, the equivalent `<project>:<family>` or
+	// `<project>=<image>` spec, and for a snapshot, the spec of the image that
+	// the snapshot's disk was created from. The OS predicates look at it
+	// instead of raw.
+	name    string
+	project string
+	// Exactly one of family, image and snapshot is set for a parsed
+	// ImageSpec.
+	family   string
+	image    string
+	snapshot string
+}
+
+// sourceSnapshotPrefix starts an image spec that names a disk snapshot to
+// create the boot disk from, instead of an image.
+const sourceSnapshotPrefix = "source-snapshot="
+
+// snapshotSourceImageSeparator separates the snapshot in a snapshot image
+// spec from the spec of the image that the snapshot's disk was created from.
+// A snapshot's name says nothing about its OS, so the image is required.
+const snapshotSourceImageSeparator = ",from="
+
+var (
+	// imageSelfLinkRegex matches an image or image family self-link, with or
+	// without the API prefix.
+	imageSelfLinkRegex = regexp.MustCompile(`^(?:https://(?:www|compute)\.googleapis\.com/compute/(?:v1|beta|alpha)/)?projects/([^/]+)/global/images/(?:family/([^/]+)|([^/]+))$`)
+	// snapshotSelfLinkRegex matches a snapshot self-link, with or without
+	// the API prefix.
+	snapshotSelfLinkRegex = regexp.MustCompile(`^(?:https://(?:www|compute)\.googleapis\.com/compute/(?:v1|beta|alpha)/)?projects/([^/]+)/global/snapshots/([^/]+)$`)
+)
+
+// ParseImageSpec parses an image spec in one of these forms:
+//   - `<project>:<family>`
+//   - `<project>=<image>`
+//   - an image self-link like
+//     `https://www.googleapis.com/compute/v1/projects/<project>/global/images/<image>`,
+//     or `projects/<project>/global/images/family/<family>` for a family
+//   - `source-snapshot=<snapshot>,from=<image spec>`, where the snapshot is
+//     a name in the VM's project or a self-link, and the image spec, in one
+//     of the forms above, names the image that the snapshot's disk was
+//     created from, e.g.
+//     `source-snapshot=my-snapshot,from=windows-cloud:windows-2022`
+func ParseImageSpec(spec string) (ImageSpec, error) {
+	parsed := ImageSpec{raw: spec}
+	if rest, ok := strings.CutPrefix(spec, sourceSnapshotPrefix); ok {
+		snapshot, from, ok := strings.Cut(rest, snapshotSourceImageSeparator)
+		if !ok || from == "" {
+			return ImageSpec{}, fmt.Errorf("invalid imageSpec %q: want %s<snapshot>%s<image spec>, where the image spec names the image that the snapshot's disk was created from, so that its OS is known", spec, sourceSnapshotPrefix, snapshotSourceImageSeparator)
+		}
+		if snapshot == "" {
+			return ImageSpec{}, fmt.Errorf("invalid imageSpec %q: snapshot must be nonempty", spec)
+		}
+		source, err := ParseImageSpec(from)
+		if err != nil {
+			return ImageSpec{}, fmt.Errorf("invalid imageSpec %q: invalid source image: %v", spec, err)
+		}
+		if source.snapshot != "" {
+			return ImageSpec{}, fmt.Errorf("invalid imageSpec %q: the source image can't be a snapshot", spec)
+		}
+		parsed.name = source.osName()
+		if strings.Contains(snapshot, "/") {
+			m := snapshotSelfLinkRegex.FindStringSubmatch(snapshot)
+			if m == nil {
+				return ImageSpec{}, fmt.Errorf("invalid imageSpec %q: unrecognized snapshot self-link, want projects/<project>/global/snapshots/<snapshot>", spec)
+			}
+			parsed.project = m[1]
+		}
+		parsed.snapshot = snapshot
+		return parsed, nil
+	}
+	if strings.Contains(spec, "/") {
+		m := imageSelfLinkRegex.FindStringSubmatch(spec)
+		if m == nil {
+			return ImageSpec{}, fmt.Errorf("invalid imageSpec %q: unrecognized image self-link, want projects/<project>/global/images/<image> or projects/<project>/global/images/family/<family>", spec)
+		}
+		parsed.project, parsed.family, parsed.image = m[1], m[2], m[3]
+		if parsed.family != "" {
+			parsed.name = parsed.project + ":" + parsed.family
+		} else {
+			parsed.name = parsed.project + "=" + parsed.image
+		}
+		return parsed, nil
+	}
+	if project, family, ok := strings.Cut(spec, ":"); ok {
+		parsed.project, parsed.family = project, family
+	} else if project, image, ok := strings.Cut(spec, "="); ok {
+		parsed.project, parsed.image = project, image
+	} else {
+		return ImageSpec{}, fmt.Errorf("invalid imageSpec %q: want <project>:<family>, <project>=<image>, an image self-link or %s<snapshot>%s<image spec>", spec, sourceSnapshotPrefix, snapshotSourceImageSeparator)
+	}
+	if parsed.project == "" || (parsed.family == "" && parsed.image == "") {
+		return ImageSpec{}, fmt.Errorf("invalid imageSpec %q: project and family/image must be nonempty", spec)
+	}
+	return parsed, nil
+}
+
+// unparsedImageSpec wraps an image spec string without validating it. It
+// backs the free functions like IsWindows(), which have always accepted
+// arbitrary strings. Specs that do parse are parsed, so that the OS
+// predicates also work for self-links.
+func unparsedImageSpec(spec string) ImageSpec {
+	if parsed, err := ParseImageSpec(spec); err == nil {
+		return parsed
+	}
+	return ImageSpec{raw: spec}
+}
+
+// String returns the image spec in its original string form.
+func (s ImageSpec) String() string {
+	return s.raw
+}
+
+// Project returns the project that the image belongs to, or "" for a
+// snapshot named without a self-link.
+func (s ImageSpec) Project() string {
+	return s.project
+}
+
+// Family returns the image family, or "" if the spec names a specific image
+// or a snapshot.
+func (s ImageSpec) Family() string {
+	return s.family
+}
+
+// Image returns the specific image name, or "" if the spec names an image
+// family or a snapshot.
+func (s ImageSpec) Image() string {
+	return s.image
+}
+
+// Snapshot returns the snapshot name or self-link, or "" if the spec names an
+// image or image family.
+func (s ImageSpec) Snapshot() string {
+	return s.snapshot
+}
+
+// osName returns the string that the OS predicates below look at. For a
+// snapshot, that is the spec of the image that it was created from.
+func (s ImageSpec) osName() string {
+	if s.name != "" {
+		return s.name
+	}
+	return s.raw
+}
+
+// gcloudFlags returns the flags used in `gcloud compute instances create` to
+// specify the image.
+func (s ImageSpec) gcloudFlags() []string {
+	if s.snapshot != "" {
+		return []string{"--source-snapshot=" + s.snapshot}
+	}
+	flags := []string{
+		"--image-project=" + s.project,
+	}
+	if s.family != "" {
+		return append(flags, "--image-family="+s.family)
+	}
+	return append(flags, "--image="+s.image)
+}
+
+// IsWindows returns whether the image is a version of Windows (including
+// Microsoft SQL Server).
+func (s ImageSpec) IsWindows() bool {
+	return strings.HasPrefix(s.osName(), "windows-")
+}
+
+// IsWindowsCore returns whether the image is a version of Windows core.
+func (s ImageSpec) IsWindowsCore() bool {
+	return s.IsWindows() && strings.HasSuffix(s.osName(), "-core")
+}
+
+// IsWindows2016 returns whether the image is a Windows 2016 image.
+func (s ImageSpec) IsWindows2016() bool {
+	return s.IsWindows() && strings.Contains(s.osName(), "2016")
+}
+
+// IsWindows2019 returns whether the image is a Windows 2019 image.
+func (s ImageSpec) IsWindows2019() bool {
+	return s.IsWindows() && strings.Contains(s.osName(), "2019")
+}
+
+// OSKind returns "linux" or "windows".
+func (s ImageSpec) OSKind() string {
+	if s.IsWindows() {
+		return "windows"
+	}
+	return "linux"
+}
+
+// IsSUSE returns whether the image is a version of SLES or openSUSE.
+func (s ImageSpec) IsSUSE() bool {
+	return strings.HasPrefix(s.osName(), "suse-") || strings.HasPrefix(s.osName(), "opensuse-") || strings.Contains(s.osName(), "sles-")
+}
+
+// IsCentOS returns whether the image is a version of CentOS.
+func (s ImageSpec) IsCentOS() bool {
+	return strings.HasPrefix(s.osName(), "centos-cloud")
+}
+
+// IsRHEL returns whether the image is a version of RHEL.
+func (s ImageSpec) IsRHEL() bool {
+	return strings.HasPrefix(s.osName(), "rhel-")
+}
+
+func (s ImageSpec) isRHEL9() bool {
+	return strings.Contains(s.osName(), "rhel-9") || strings.Contains(s.osName(), "rocky-linux-9") || strings.Contains(s.osName(), "almalinux-9")
+}
+
+func (s ImageSpec) isRHEL7SAPHA() bool {
+	return strings.Contains(s.osName(), "rhel-7") && strings.HasPrefix(s.osName(), "rhel-sap-cloud")
+}
+
+// IsDLVM returns whether the image is a Deep Learning VM image.
+func (s ImageSpec) IsDLVM() bool {
+	return strings.HasPrefix(s.osName(), "ml-images")
+}
+
+// IsRocky returns whether the image is a version of Rocky Linux or AlmaLinux.
+func (s ImageSpec) IsRocky() bool {
+	return strings.Contains(s.osName(), "rocky-linux-") || strings.Contains(s.osName(), "almalinux-")
+}
+
+// IsRpm returns whether the image uses rpm packages.
+func (s ImageSpec) IsRpm() bool {
+	return s.IsRHEL() || s.IsSUSE() || s.IsRocky() || s.IsCentOS()
+}
+
+// IsARM returns whether the image is for ARM machines.
+func (s ImageSpec) IsARM() bool {
+	// At the time of writing, all ARM images and image families on GCE
+	// contain "arm64" (and none contain "aarch" nor "arm" without the "64").
+	return strings.Contains(s.osName(), "arm64")
+}
+
+// IsDebianBased returns whether the image is a version of Debian or Ubuntu.
+func (s ImageSpec) IsDebianBased() bool {
+	return strings.Contains(s.osName(), "debian") || strings.Contains(s.osName(), "ubuntu")
+}
@@ -0,0 +1,156 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseImageSpec(t *testing.T) {
+	tests := []struct {
+		spec         string
+		wantProject  string
+		wantFamily   string
+		wantImage    string
+		wantSnapshot string
+		wantFlags    []string
+		wantErr      bool
+	}{
+		{
+			spec:        "debian-cloud:debian-12",
+			wantProject: "debian-cloud",
+			wantFamily:  "debian-12",
+			wantFlags:   []string{"--image-project=debian-cloud", "--image-family=debian-12"},
+		},
+		{
+			spec:        "windows-cloud=windows-server-2022-dc-v20240415",
+			wantProject: "windows-cloud",
+			wantImage:   "windows-server-2022-dc-v20240415",
+			wantFlags:   []string{"--image-project=windows-cloud", "--image=windows-server-2022-dc-v20240415"},
+		},
+		{
+			spec:        "https://www.googleapis.com/compute/v1/projects/my-project/global/images/my-agent-image-v2",
+			wantProject: "my-project",
+			wantImage:   "my-agent-image-v2",
+			wantFlags:   []string{"--image-project=my-project", "--image=my-agent-image-v2"},
+		},
+		{
+			spec:        "projects/my-project/global/images/my-agent-image-v2",
+			wantProject: "my-project",
+			wantImage:   "my-agent-image-v2",
+			wantFlags:   []string{"--image-project=my-project", "--image=my-agent-image-v2"},
+		},
+		{
+			spec:        "https://compute.googleapis.com/compute/beta/projects/my-project/global/images/family/my-agent-images",
+			wantProject: "my-project",
+			wantFamily:  "my-agent-images",
+			wantFlags:   []string{"--image-project=my-project", "--image-family=my-agent-images"},
+		},
+		{
+			spec:         "source-snapshot=my-snapshot,from=debian-cloud:debian-12",
+			wantSnapshot: "my-snapshot",
+			wantFlags:    []string{"--source-snapshot=my-snapshot"},
+		},
+		{
+			spec:         "source-snapshot=projects/my-project/global/snapshots/my-snapshot,from=projects/windows-cloud/global/images/family/windows-2022",
+			wantProject:  "my-project",
+			wantSnapshot: "projects/my-project/global/snapshots/my-snapshot",
+			wantFlags:    []string{"--source-snapshot=projects/my-project/global/snapshots/my-snapshot"},
+		},
+		{spec: "debian-12", wantErr: true},
+		{spec: "source-snapshot=", wantErr: true},
+		{spec: "source-snapshot=my-snapshot", wantErr: true},
+		{spec: "source-snapshot=my-snapshot,from=", wantErr: true},
+		{spec: "source-snapshot=,from=debian-cloud:debian-12", wantErr: true},
+		{spec: "source-snapshot=my-snapshot,from=debian-12", wantErr: true},
+		{spec: "source-snapshot=a,from=source-snapshot=b,from=debian-cloud:debian-12", wantErr: true},
+		{spec: "source-snapshot=projects/my-project/zones/us-central1-a/disks/d,from=debian-cloud:debian-12", wantErr: true},
+		{spec: "projects/my-project/global/images/", wantErr: true},
+		{spec: "projects/my-project/zones/us-central1-a/disks/my-disk", wantErr: true},
+		{spec: "gs://my-bucket/disk.tar.gz", wantErr: true},
+		{spec: ":debian-12", wantErr: true},
+		{spec: "debian-cloud=", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			spec, err := ParseImageSpec(tc.spec)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ParseImageSpec(%q) = %#v; want error", tc.spec, spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec.String() != tc.spec || spec.Project() != tc.wantProject || spec.Family() != tc.wantFamily || spec.Image() != tc.wantImage || spec.Snapshot() != tc.wantSnapshot {
+				t.Errorf("ParseImageSpec(%q) = (%q, project=%q, family=%q, image=%q, snapshot=%q); want (%q, project=%q, family=%q, image=%q, snapshot=%q)",
+					tc.spec, spec.String(), spec.Project(), spec.Family(), spec.Image(), spec.Snapshot(),
+					tc.spec, tc.wantProject, tc.wantFamily, tc.wantImage, tc.wantSnapshot)
+			}
+			if flags := spec.gcloudFlags(); !slices.Equal(flags, tc.wantFlags) {
+				t.Errorf("ParseImageSpec(%q).gcloudFlags() = %v; want %v", tc.spec, flags, tc.wantFlags)
+			}
+		})
+	}
+}
+
+func TestImageSpecPredicates(t *testing.T) {
+	tests := []struct {
+		spec        string
+		windows     bool
+		arm         bool
+		debianBased bool
+		rpm         bool
+		wantOSKind  string
+	}{
+		{spec: "debian-cloud:debian-12", debianBased: true, wantOSKind: "linux"},
+		{spec: "debian-cloud:debian-12-arm64", debianBased: true, arm: true, wantOSKind: "linux"},
+		{spec: "rocky-linux-cloud:rocky-linux-9", rpm: true, wantOSKind: "linux"},
+		{spec: "suse-cloud:sles-15", rpm: true, wantOSKind: "linux"},
+		{spec: "windows-cloud:windows-2022-core", windows: true, wantOSKind: "windows"},
+		{spec: "projects/windows-cloud/global/images/family/windows-2022", windows: true, wantOSKind: "windows"},
+		{spec: "https://www.googleapis.com/compute/v1/projects/debian-cloud/global/images/debian-12-bookworm-arm64-v20240415", debianBased: true, arm: true, wantOSKind: "linux"},
+		{spec: "source-snapshot=my-snapshot,from=windows-cloud:windows-2022", windows: true, wantOSKind: "windows"},
+		{spec: "source-snapshot=windows-snapshot,from=debian-cloud:debian-12", debianBased: true, wantOSKind: "linux"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			spec, err := ParseImageSpec(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec.IsWindows() != tc.windows || IsWindows(tc.spec) != tc.windows {
+				t.Errorf("IsWindows(%q) = %v; want %v", tc.spec, spec.IsWindows(), tc.windows)
+			}
+			if spec.IsARM() != tc.arm || IsARM(tc.spec) != tc.arm {
+				t.Errorf("IsARM(%q) = %v; want %v", tc.spec, spec.IsARM(), tc.arm)
+			}
+			if spec.IsDebianBased() != tc.debianBased || IsDebianBased(tc.spec) != tc.debianBased {
+				t.Errorf("IsDebianBased(%q) = %v; want %v", tc.spec, spec.IsDebianBased(), tc.debianBased)
+			}
+			if spec.IsRpm() != tc.rpm || IsRpm(tc.spec) != tc.rpm {
+				t.Errorf("IsRpm(%q) = %v; want %v", tc.spec, spec.IsRpm(), tc.rpm)
+			}
+			if spec.OSKind() != tc.wantOSKind || OSKind(tc.spec) != tc.wantOSKind {
+				t.Errorf("OSKind(%q) = %v; want %v", tc.spec, spec.OSKind(), tc.wantOSKind)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import "testing"
+
+// dualStackInstanceJSON is trimmed gcloud --format=json output for a VM on a
+// dual-stack subnet.
+const dualStackInstanceJSON = `[
+  {
+    "id": "1234",
+    "networkInterfaces": [
+      {
+        "networkIP": "10.128.0.2",
+        "stackType": "IPV4_IPV6",
+        "accessConfigs": [{"name": "external-nat", "natIP": "203.0.113.7"}],
+        "ipv6AccessConfigs": [{"name": "external-ipv6", "externalIpv6": "2600:1900:4000:1::", "externalIpv6PrefixLength": 96}]
+      }
+    ]
+  }
+]`
+
+const ipv4OnlyInstanceJSON = `[
+  {
+    "id": "1234",
+    "networkInterfaces": [
+      {
+        "networkIP": "10.128.0.2",
+        "accessConfigs": [{"name": "external-nat", "natIP": "203.0.113.7"}]
+      }
+    ]
+  }
+]`
+
+func TestExtractIPAddress(t *testing.T) {
+	tests := []struct {
+		name          string
+		stdout        string
+		preferIPv6    bool
+		useInternalIP string
+		want          string
+	}{
+		{
+			name:   "dual stack",
+			stdout: dualStackInstanceJSON,
+			want:   "203.0.113.7",
+		},
+		{
+			name:       "dual stack preferring IPv6",
+			stdout:     dualStackInstanceJSON,
+			preferIPv6: true,
+			want:       "2600:1900:4000:1::",
+		},
+		{
+			name:       "IPv4 only preferring IPv6",
+			stdout:     ipv4OnlyInstanceJSON,
+			preferIPv6: true,
+			want:       "203.0.113.7",
+		},
+		{
+			name:          "IPv4 only preferring IPv6 with internal IP",
+			stdout:        ipv4OnlyInstanceJSON,
+			preferIPv6:    true,
+			useInternalIP: "true",
+			want:          "10.128.0.2",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("USE_INTERNAL_IP", tc.useInternalIP)
+			got, err := extractIPAddress(tc.stdout, tc.preferIPv6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("extractIPAddress(preferIPv6=%v) = %q; want %q", tc.preferIPv6, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScpHost(t *testing.T) {
+	if got := scpHost("203.0.113.7"); got != "203.0.113.7" {
+		t.Errorf("scpHost() = %q; want %q", got, "203.0.113.7")
+	}
+	if got := scpHost("2600:1900:4000:1::"); got != "[2600:1900:4000:1::]" {
+		t.Errorf("scpHost() = %q; want %q", got, "[2600:1900:4000:1::]")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeTB is a testing.TB whose test passes or fails as told, and which runs
+// its cleanups when finish is called.
+type fakeTB struct {
+	testing.TB
+	failed   bool
+	cleanups []func()
+	logs     []string
+	errors   []string
+}
+
+func (f *fakeTB) Helper()           {}
+func (f *fakeTB) Name() string      { return "TestFake" }
+func (f *fakeTB) Failed() bool      { return f.failed }
+func (f *fakeTB) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+
+func (f *fakeTB) Logf(format string, args ...any) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Fatalf(format string, args ...any) {
+	panic(fmt.Sprintf(format, args...))
+}
+
+// finish runs the cleanups in reverse order, like the testing package does.
+func (f *fakeTB) finish() {
+	for _, fn := range slices.Backward(f.cleanups) {
+		fn()
+	}
+}
+
+func TestSetKeepVMsOnFailure(t *testing.T) {
+	origKeep, origKept := keepVMsOnFailure.Load(), keptVMs.Load()
+	t.Cleanup(func() {
+		keepVMsOnFailure.Store(origKeep)
+		keptVMs.Store(origKept)
+	})
+	replaceForTest(t, &collectDiagnostics, func(context.Context, *log.Logger, *VM, string) error { return nil })
+	fakeAttachOrCreate(t, nil)
+	var deleted []string
+	replaceForTest(t, &deleteInstance, func(_ context.Context, _ *log.Logger, vm *VM) error {
+		deleted = append(deleted, vm.Name)
+		return nil
+	})
+	SetKeepVMsOnFailure(true)
+	logger := log.New(io.Discard, "", 0)
+
+	failing := &fakeTB{failed: true}
+	vm := setupVM(context.Background(), failing, logger, VMOptions{Name: "failing-vm", Project: "p", Zone: "us-central1-b"})
+	failing.finish()
+	if len(deleted) != 0 {
+		t.Errorf("SetupVM() deleted %v after a failed test, want the VM kept", deleted)
+	}
+	if !slices.ContainsFunc(failing.logs, func(l string) bool { return strings.Contains(l, sshCommandForDebugging(vm)) }) {
+		t.Errorf("SetupVM() logged %q after a failed test, want the ssh command %q", failing.logs, sshCommandForDebugging(vm))
+	}
+	if !keptVMs.Load() {
+		t.Error("SetupVM() kept a VM without recording it, so its ssh keys would be cleaned up")
+	}
+
+	passing := &fakeTB{}
+	setupVM(context.Background(), passing, logger, VMOptions{Name: "passing-vm", Project: "p", Zone: "us-central1-b"})
+	passing.finish()
+	if !slices.Equal(deleted, []string{"passing-vm"}) {
+		t.Errorf("SetupVM() deleted %v after a passing test, want [passing-vm]", deleted)
+	}
+	if len(passing.errors) != 0 {
+		t.Errorf("SetupVM() reported errors after a passing test: %v", passing.errors)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestFetchLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		// Recorded output of
+		// `gcloud compute instances describe --format=json(labels)`.
+		stdout string
+		want   map[string]string
+	}{
+		{
+			name: "labeled VM",
+			stdout: `{
+  "labels": {
+    "env": "test",
+    "kokoro_build_id": "a1b2c3"
+  }
+}
+`,
+			want: map[string]string{"env": "test", "kokoro_build_id": "a1b2c3"},
+		},
+		{
+			name:   "VM without labels",
+			stdout: "{}\n",
+			want:   map[string]string{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeRunGcloud(t, func(args []string) (CommandOutput, error) {
+				if !slices.Contains(args, "--format=json(labels)") {
+					t.Errorf("runGcloud() args = %v, want --format=json(labels)", args)
+				}
+				return CommandOutput{Stdout: tc.stdout}, nil
+			})
+
+			vm := &VM{Name: "vm", Project: "p", Zone: "us-central1-b"}
+			got, err := FetchLabels(context.Background(), log.New(io.Discard, "", 0), vm)
+			if err != nil {
+				t.Fatalf("FetchLabels() failed: %v", err)
+			}
+			if got == nil || !maps.Equal(got, tc.want) {
+				t.Errorf("FetchLabels() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
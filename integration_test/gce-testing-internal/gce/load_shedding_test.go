@@ -0,0 +1,110 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+// dropCountSeries returns a time series with a single point of the given
+// cumulative drop count.
+func dropCountSeries(count int64) *monitoringpb.TimeSeries {
+	return &monitoringpb.TimeSeries{
+		Points: []*monitoringpb.Point{{
+			Value: &monitoringpb.TypedValue{Value: &monitoringpb.TypedValue_Int64Value{Int64Value: count}},
+		}},
+	}
+}
+
+func TestAssertAgentLoadShedding(t *testing.T) {
+	const failedResponsesFilter = `metric.labels.response_code != "200"`
+	tests := []struct {
+		name string
+		// The drop count reported after the overload.
+		dropsAfter int64
+		// The status of the collector after the overload.
+		statusAfter serviceStatus
+		wantErr     bool
+	}{
+		{
+			name:        "drop counter increases",
+			dropsAfter:  500,
+			statusAfter: serviceStatus{Running: true, Restarts: 1},
+		},
+		{
+			name:        "drop counter unchanged",
+			dropsAfter:  100,
+			statusAfter: serviceStatus{Running: true, Restarts: 1},
+			wantErr:     true,
+		},
+		{
+			name:        "collector crashed",
+			dropsAfter:  500,
+			statusAfter: serviceStatus{Running: false, Restarts: 1},
+			wantErr:     true,
+		},
+		{
+			name:        "collector restarted",
+			dropsAfter:  500,
+			statusAfter: serviceStatus{Running: true, Restarts: 2},
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			overloaded := false
+			fakeListTimeSeries(t, func(req *monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+				if !strings.Contains(req.Filter, failedResponsesFilter) {
+					t.Errorf("ListTimeSeries() filter is %q, want it to contain %q", req.Filter, failedResponsesFilter)
+				}
+				if overloaded {
+					return []*monitoringpb.TimeSeries{dropCountSeries(tc.dropsAfter)}
+				}
+				return []*monitoringpb.TimeSeries{dropCountSeries(100)}
+			})
+			origStatus := getServiceStatus
+			t.Cleanup(func() { getServiceStatus = origStatus })
+			getServiceStatus = func(_ context.Context, _ *log.Logger, _ *VM, service string) (serviceStatus, error) {
+				if service != agentCollectorService {
+					t.Errorf("getServiceStatus() called for %q; want %q", service, agentCollectorService)
+				}
+				if overloaded {
+					return tc.statusAfter, nil
+				}
+				return serviceStatus{Running: true, Restarts: 1}, nil
+			}
+			overloadFunc := func() error {
+				overloaded = true
+				return nil
+			}
+
+			vm := &VM{Name: "vm", Project: "p", ID: 1234}
+			err := AssertAgentLoadShedding(context.Background(), log.New(io.Discard, "", 0), vm, "agent.googleapis.com/agent/monitoring/point_count", []string{failedResponsesFilter}, overloadFunc)
+			if tc.wantErr && err == nil {
+				t.Error("AssertAgentLoadShedding() unexpectedly succeeded")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("AssertAgentLoadShedding() failed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	cloudlogging "cloud.google.com/go/logging"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSortLogEntriesAndAssertLogOrder(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	structPayload, err := structpb.NewStruct(map[string]interface{}{"message": "receiver started"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []*cloudlogging.Entry{
+		{Timestamp: base.Add(2 * time.Second), Payload: "pipeline ready"},
+		{Timestamp: base, Payload: "config loaded"},
+		{Timestamp: base.Add(time.Second), Payload: structPayload},
+	}
+
+	sortLogEntries(entries)
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Timestamp.Before(entries[i-1].Timestamp) {
+			t.Fatalf("sortLogEntries() left entry %d before entry %d: %v", i-1, i, entries)
+		}
+	}
+
+	if err := AssertLogOrder(entries, "config loaded", "pipeline ready"); err != nil {
+		t.Errorf("AssertLogOrder() failed: %v", err)
+	}
+	if err := AssertLogOrder(entries, "config loaded", "receiver started"); err != nil {
+		t.Errorf("AssertLogOrder() with a structured payload failed: %v", err)
+	}
+	if err := AssertLogOrder(entries, "pipeline ready", "config loaded"); err == nil || !strings.Contains(err.Error(), "does not come before") {
+		t.Errorf("AssertLogOrder() error = %v, want an ordering error", err)
+	}
+	if err := AssertLogOrder(entries, "config loaded", "shutting down"); err == nil || !strings.Contains(err.Error(), `no log entry contains "shutting down"`) {
+		t.Errorf("AssertLogOrder() error = %v, want a missing entry error", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+func TestResourceFilters(t *testing.T) {
+	vm := &VM{Name: "vm", ID: 1234}
+	tests := []struct {
+		name         string
+		isPrometheus bool
+		opts         LookupOptions
+		want         []string
+	}{
+		{
+			name: "default",
+			want: []string{`resource.labels.instance_id = "1234"`},
+		},
+		{
+			name:         "default prometheus",
+			isPrometheus: true,
+			want:         []string{`resource.labels.namespace = "1234/vm"`},
+		},
+		{
+			name: "type only",
+			opts: LookupOptions{ResourceType: "gce_instance"},
+			want: []string{`resource.type = "gce_instance"`, `resource.labels.instance_id = "1234"`},
+		},
+		{
+			name: "type and labels",
+			opts: LookupOptions{ResourceType: "generic_node", ResourceLabels: map[string]string{"node_id": "vm", "location": "us-central1-a"}},
+			want: []string{`resource.type = "generic_node"`, `resource.labels.location = "us-central1-a"`, `resource.labels.node_id = "vm"`},
+		},
+		{
+			name:         "labels replace prometheus namespace",
+			isPrometheus: true,
+			opts:         LookupOptions{ResourceLabels: map[string]string{"namespace": "custom"}},
+			want:         []string{`resource.labels.namespace = "custom"`},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := resourceFilters(vm, tc.isPrometheus, tc.opts); !slices.Equal(got, tc.want) {
+				t.Errorf("resourceFilters() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWaitForMetricSeriesWithLookupOptions(t *testing.T) {
+	var filter string
+	fakeListTimeSeries(t, func(req *monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+		filter = req.GetFilter()
+		return []*monitoringpb.TimeSeries{seriesWithPoints(time.Now())}
+	})
+	vm := &VM{Name: "vm", Project: "p", ID: 1234}
+	opts := WaitForMetricSeriesOpts{Lookup: LookupOptions{ResourceType: "generic_node", ResourceLabels: map[string]string{"node_id": "vm"}}}
+	if _, err := WaitForMetricSeriesWithOpts(context.Background(), log.New(io.Discard, "", 0), vm, "workload.googleapis.com/m", time.Minute, []string{`metric.labels.k = "v"`}, false, opts); err != nil {
+		t.Fatalf("WaitForMetricSeriesWithOpts() failed: %v", err)
+	}
+	want := `metric.type = "workload.googleapis.com/m" AND resource.type = "generic_node" AND resource.labels.node_id = "vm" AND metric.labels.k = "v"`
+	if filter != want {
+		t.Errorf("filter = %q, want %q", filter, want)
+	}
+}
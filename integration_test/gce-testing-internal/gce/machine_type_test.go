@@ -0,0 +1,136 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateMachineTypeInZone(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	for _, tc := range []struct {
+		name      string
+		gcloudErr error
+		wantErr   bool
+	}{
+		{name: "available", gcloudErr: nil},
+		{name: "not found", gcloudErr: errors.New("ERROR: (gcloud.compute.machine-types.describe) Could not fetch resource:\n - The resource 'projects/p/zones/us-central1-a/machineTypes/n9-standard-4' was not found"), wantErr: true},
+		{name: "permission denied", gcloudErr: errors.New("ERROR: (gcloud.compute.machine-types.describe) Could not fetch resource:\n - Required 'compute.machineTypes.get' permission")},
+		{name: "quota", gcloudErr: errors.New("ERROR: (gcloud.compute.machine-types.describe) Quota exceeded for quota metric 'Read requests'")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotArgs []string
+			fakeRunGcloud(t, func(args []string) (CommandOutput, error) {
+				gotArgs = args
+				return CommandOutput{}, tc.gcloudErr
+			})
+			err := validateMachineTypeInZone(context.Background(), logger, "p", "us-central1-a", "n9-standard-4")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("validateMachineTypeInZone() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), `"n9-standard-4" is not available in zone us-central1-a`) {
+				t.Errorf("validateMachineTypeInZone() error = %v, want it to name the machine type and zone", err)
+			}
+			if want := "compute machine-types describe n9-standard-4 --project=p --zone=us-central1-a"; !strings.HasPrefix(strings.Join(gotArgs, " "), want) {
+				t.Errorf("validateMachineTypeInZone() ran gcloud %v, want it to start with %q", gotArgs, want)
+			}
+		})
+	}
+}
+
+func TestARMMachineTypeCandidates(t *testing.T) {
+	t.Setenv("DEFAULT_ARM_MACHINE_TYPES", "")
+	if got, want := armMachineTypeCandidates(VMOptions{}), []string{"t2a-standard-4", "c4a-standard-4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("armMachineTypeCandidates() = %v, want %v", got, want)
+	}
+	if got, want := armMachineTypeCandidates(VMOptions{DefaultARMMachineType: "c4a-standard-4"}), []string{"c4a-standard-4", "t2a-standard-4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("armMachineTypeCandidates() with DefaultARMMachineType = %v, want %v", got, want)
+	}
+
+	t.Setenv("DEFAULT_ARM_MACHINE_TYPES", "c4a-standard-8, t2a-standard-8")
+	if got, want := armMachineTypeCandidates(VMOptions{}), []string{"c4a-standard-8", "t2a-standard-8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("armMachineTypeCandidates() with DEFAULT_ARM_MACHINE_TYPES = %v, want %v", got, want)
+	}
+}
+
+func TestPickMachineTypeInZone(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	available := map[string]bool{"c4a-standard-4": true}
+	fakeRunGcloud(t, func(args []string) (CommandOutput, error) {
+		if available[args[3]] {
+			return CommandOutput{}, nil
+		}
+		return CommandOutput{}, errors.New("The resource 'projects/p/zones/z/machineTypes/" + args[3] + "' was not found")
+	})
+
+	got, err := pickMachineTypeInZone(context.Background(), logger, "p", "z", []string{"t2a-standard-4", "c4a-standard-4"})
+	if err != nil || got != "c4a-standard-4" {
+		t.Errorf("pickMachineTypeInZone() = (%q, %v), want c4a-standard-4", got, err)
+	}
+
+	available = nil
+	if _, err := pickMachineTypeInZone(context.Background(), logger, "p", "z", []string{"t2a-standard-4", "c4a-standard-4"}); err == nil {
+		t.Error("pickMachineTypeInZone() succeeded with no available machine types, want an error")
+	}
+}
+
+func TestCreateInstanceTriesOtherZonesForMachineType(t *testing.T) {
+	picker, err := newZonePicker("us-central1-a,us-central1-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaceForTest(t, &zonePicker, picker)
+	logger := log.New(io.Discard, "", 0)
+
+	var checkedZones []string
+	fakeRunGcloud(t, func(args []string) (CommandOutput, error) {
+		var zone string
+		for _, arg := range args {
+			if z, ok := strings.CutPrefix(arg, "--zone="); ok {
+				zone = z
+			}
+		}
+		checkedZones = append(checkedZones, zone)
+		return CommandOutput{}, errors.New("The resource 'projects/p/zones/" + zone + "/machineTypes/n9-standard-4' was not found")
+	})
+
+	options := VMOptions{Project: "p", ImageSpec: "debian-cloud:debian-12", MachineType: "n9-standard-4"}
+	if _, err := CreateInstance(context.Background(), logger, options); err == nil {
+		t.Fatal("CreateInstance() succeeded without a zone that has the machine type, want an error")
+	}
+	slices.Sort(checkedZones)
+	if want := []string{"us-central1-a", "us-central1-b"}; !slices.Equal(checkedZones, want) {
+		t.Errorf("CreateInstance() checked the machine type in zones %v, want each of %v once", checkedZones, want)
+	}
+
+	// A zone that the caller asked for is the only one tried.
+	checkedZones = nil
+	options.Zone = "us-central1-b"
+	if _, err := CreateInstance(context.Background(), logger, options); err == nil {
+		t.Fatal("CreateInstance() succeeded in a zone without the machine type, want an error")
+	}
+	if want := []string{"us-central1-b"}; !slices.Equal(checkedZones, want) {
+		t.Errorf("CreateInstance() with a Zone checked the machine type in zones %v, want %v", checkedZones, want)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddFrameworkMetadataStartupScript(t *testing.T) {
+	metadata, err := addFrameworkMetadata(VMOptions{
+		ImageSpec:     "debian-cloud:debian-12",
+		Metadata:      map[string]string{"foo": "bar"},
+		StartupScript: "#!/bin/bash\necho hello, world",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := metadata["startup-script"]; got != "#!/bin/bash\necho hello, world" {
+		t.Errorf("startup-script = %q; want the caller's script", got)
+	}
+	if metadata["foo"] != "bar" || metadata["ssh-keys"] == "" || metadata["enable-oslogin"] != "false" {
+		t.Errorf("addFrameworkMetadata() = %v; want caller and framework keys to be kept", metadata)
+	}
+}
+
+func TestAddFrameworkMetadataSysprepScript(t *testing.T) {
+	metadata, err := addFrameworkMetadata(VMOptions{
+		ImageSpec:     "windows-cloud:windows-2022",
+		SysprepScript: "echo hello",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := windowsSSHSysprepCmd + " && echo hello"
+	if got := metadata["sysprep-specialize-script-cmd"]; got != want {
+		t.Errorf("sysprep-specialize-script-cmd = %q; want %q", got, want)
+	}
+}
+
+func TestAddFrameworkMetadataErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options VMOptions
+		wantErr string
+	}{
+		{
+			name:    "raw startup-script",
+			options: VMOptions{ImageSpec: "debian-cloud:debian-12", Metadata: map[string]string{"startup-script": "echo"}},
+			wantErr: "VMOptions.StartupScript",
+		},
+		{
+			name:    "raw sysprep script",
+			options: VMOptions{ImageSpec: "windows-cloud:windows-2022", Metadata: map[string]string{"sysprep-specialize-script-cmd": "echo"}},
+			wantErr: "VMOptions.SysprepScript",
+		},
+		{
+			name:    "raw ssh-keys",
+			options: VMOptions{ImageSpec: "debian-cloud:debian-12", Metadata: map[string]string{"ssh-keys": "me:key"}},
+			wantErr: "ssh-keys",
+		},
+		{
+			name:    "startup script on windows",
+			options: VMOptions{ImageSpec: "windows-cloud:windows-2022", StartupScript: "echo"},
+			wantErr: "not supported on Windows",
+		},
+		{
+			name:    "sysprep script on linux",
+			options: VMOptions{ImageSpec: "debian-cloud:debian-12", SysprepScript: "echo"},
+			wantErr: "only supported on Windows",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := addFrameworkMetadata(tc.options)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("addFrameworkMetadata() error = %v; want an error mentioning %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestGcloudDictFlagValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping map[string]string
+		want    string
+	}{
+		{
+			name:    "no commas",
+			mapping: map[string]string{"a": "1"},
+			want:    "a=1",
+		},
+		{
+			name:    "comma in value",
+			mapping: map[string]string{"a": "1,2"},
+			want:    "^;^a=1,2",
+		},
+		{
+			name:    "comma and semicolon in value",
+			mapping: map[string]string{"a": "echo 1,2; echo 3"},
+			want:    "^|^a=echo 1,2; echo 3",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := gcloudDictFlagValue(tc.mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("gcloudDictFlagValue(%v) = %q; want %q", tc.mapping, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckNoFrameworkMetadataKeys(t *testing.T) {
+	for _, imageSpec := range []string{"debian-cloud:debian-12", "windows-cloud:windows-2022"} {
+		if err := checkNoFrameworkMetadataKeys(imageSpec, []string{"foo", "agent-config"}); err != nil {
+			t.Errorf("checkNoFrameworkMetadataKeys(%q) = %v; want no error for non-reserved keys", imageSpec, err)
+		}
+		for _, key := range frameworkMetadataKeys(imageSpec) {
+			err := checkNoFrameworkMetadataKeys(imageSpec, []string{"foo", key})
+			if err == nil || !strings.Contains(err.Error(), key) {
+				t.Errorf("checkNoFrameworkMetadataKeys(%q, %q) = %v; want an error mentioning the key", imageSpec, key, err)
+			}
+		}
+	}
+
+	// Keys are only reserved on the OS that the framework uses them on.
+	if err := checkNoFrameworkMetadataKeys("windows-cloud:windows-2022", []string{"startup-script"}); err != nil {
+		t.Errorf("checkNoFrameworkMetadataKeys() = %v; want startup-script to be allowed on Windows", err)
+	}
+	if err := checkNoFrameworkMetadataKeys("debian-cloud:debian-12", []string{"enable-windows-ssh", "sysprep-specialize-script-cmd"}); err != nil {
+		t.Errorf("checkNoFrameworkMetadataKeys() = %v; want the Windows ssh keys to be allowed on Linux", err)
+	}
+}
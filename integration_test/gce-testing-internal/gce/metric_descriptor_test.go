@@ -0,0 +1,182 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAssertMetricDescriptor(t *testing.T) {
+	const metric = "workload.googleapis.com/apache.requests"
+	tests := []struct {
+		name     string
+		wantKind metricpb.MetricDescriptor_MetricKind
+		wantUnit string
+		// Substrings of the wanted error, if any.
+		wantErr []string
+	}{
+		{
+			name:     "matching descriptor",
+			wantKind: metricpb.MetricDescriptor_CUMULATIVE,
+			wantUnit: "1",
+		},
+		{
+			name:     "kind changed",
+			wantKind: metricpb.MetricDescriptor_GAUGE,
+			wantUnit: "1",
+			wantErr:  []string{"kind is CUMULATIVE, want GAUGE"},
+		},
+		{
+			name:     "kind and unit changed",
+			wantKind: metricpb.MetricDescriptor_GAUGE,
+			wantUnit: "By",
+			wantErr:  []string{"kind is CUMULATIVE, want GAUGE", `unit is "1", want "By"`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			origGet, origBackoff := getMetricDescriptor, queryBackoffDuration
+			t.Cleanup(func() {
+				getMetricDescriptor, queryBackoffDuration = origGet, origBackoff
+			})
+			queryBackoffDuration = time.Millisecond
+			calls := 0
+			getMetricDescriptor = func(_ context.Context, project, gotMetric string) (*metricpb.MetricDescriptor, error) {
+				calls++
+				if project != "p" || gotMetric != metric {
+					t.Errorf("getMetricDescriptor() called for (%q, %q); want (%q, %q)", project, gotMetric, "p", metric)
+				}
+				// The descriptor is only created when the metric is first written.
+				if calls <= 2 {
+					return nil, status.Error(codes.NotFound, "metric descriptor not found")
+				}
+				return &metricpb.MetricDescriptor{
+					Type:       metric,
+					MetricKind: metricpb.MetricDescriptor_CUMULATIVE,
+					ValueType:  metricpb.MetricDescriptor_INT64,
+					Unit:       "1",
+				}, nil
+			}
+
+			vm := &VM{Name: "vm", Project: "p", ID: 1234}
+			err := AssertMetricDescriptor(context.Background(), log.New(io.Discard, "", 0), vm, metric, tc.wantKind, tc.wantUnit)
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("AssertMetricDescriptor() failed: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("AssertMetricDescriptor() unexpectedly succeeded")
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("AssertMetricDescriptor() = %v; want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetMetricDescriptorPermanentError(t *testing.T) {
+	origGet := getMetricDescriptor
+	t.Cleanup(func() { getMetricDescriptor = origGet })
+	calls := 0
+	getMetricDescriptor = func(context.Context, string, string) (*metricpb.MetricDescriptor, error) {
+		calls++
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+
+	vm := &VM{Name: "vm", Project: "p", ID: 1234}
+	if _, err := GetMetricDescriptor(context.Background(), log.New(io.Discard, "", 0), vm, "agent.googleapis.com/agent/uptime"); err == nil {
+		t.Error("GetMetricDescriptor() unexpectedly succeeded")
+	}
+	if calls != 1 {
+		t.Errorf("GetMetricDescriptor() made %d requests; want 1", calls)
+	}
+}
+
+func TestDeleteMetricDescriptor(t *testing.T) {
+	origDelete := deleteMetricDescriptor
+	t.Cleanup(func() { deleteMetricDescriptor = origDelete })
+	vm := &VM{Name: "vm", Project: "p", ID: 1234}
+
+	var deleteErr error
+	deleteMetricDescriptor = func(_ context.Context, project, metric string) error {
+		if project != "p" || metric != "workload.googleapis.com/foo" {
+			t.Errorf("deleteMetricDescriptor() called for (%q, %q); want (p, workload.googleapis.com/foo)", project, metric)
+		}
+		return deleteErr
+	}
+	if err := DeleteMetricDescriptor(context.Background(), vm, "workload.googleapis.com/foo"); err != nil {
+		t.Errorf("DeleteMetricDescriptor() failed: %v", err)
+	}
+
+	deleteErr = status.Error(codes.NotFound, "metric descriptor not found")
+	if err := DeleteMetricDescriptor(context.Background(), vm, "workload.googleapis.com/foo"); err != nil {
+		t.Errorf("DeleteMetricDescriptor() of a missing descriptor failed: %v", err)
+	}
+
+	deleteErr = status.Error(codes.PermissionDenied, "permission denied")
+	if err := DeleteMetricDescriptor(context.Background(), vm, "workload.googleapis.com/foo"); err == nil {
+		t.Error("DeleteMetricDescriptor() unexpectedly succeeded")
+	}
+}
+
+func TestDeleteMetricDescriptorsMatching(t *testing.T) {
+	origList, origDelete := listMetricTypes, deleteMetricDescriptor
+	t.Cleanup(func() { listMetricTypes, deleteMetricDescriptor = origList, origDelete })
+
+	listMetricTypes = func(_ context.Context, project, prefix string) ([]string, error) {
+		if prefix != "workload.googleapis.com/test." {
+			t.Errorf("listMetricTypes() called with prefix %q; want workload.googleapis.com/test.", prefix)
+		}
+		return []string{"workload.googleapis.com/test.a", "workload.googleapis.com/test.b", "workload.googleapis.com/test.c"}, nil
+	}
+	var deleted []string
+	deleteMetricDescriptor = func(_ context.Context, _ string, metric string) error {
+		deleted = append(deleted, metric)
+		switch metric {
+		case "workload.googleapis.com/test.b":
+			// Deleted concurrently by someone else.
+			return status.Error(codes.NotFound, "metric descriptor not found")
+		case "workload.googleapis.com/test.c":
+			return status.Error(codes.Internal, "internal error")
+		}
+		return nil
+	}
+
+	err := DeleteMetricDescriptorsMatching(context.Background(), "p", "workload.googleapis.com/test.")
+	if len(deleted) != 3 {
+		t.Errorf("DeleteMetricDescriptorsMatching() deleted %v; want all 3 descriptors to be attempted", deleted)
+	}
+	if err == nil || !strings.Contains(err.Error(), "test.c") || strings.Contains(err.Error(), "test.b") {
+		t.Errorf("DeleteMetricDescriptorsMatching() error = %v; want only the failure for test.c", err)
+	}
+
+	if err := DeleteMetricDescriptorsMatching(context.Background(), "p", ""); err == nil {
+		t.Error("DeleteMetricDescriptorsMatching() with an empty prefix unexpectedly succeeded")
+	}
+}
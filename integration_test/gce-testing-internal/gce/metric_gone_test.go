@@ -0,0 +1,71 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+func TestWaitForMetricGone(t *testing.T) {
+	const freshness = time.Minute
+	logger := log.New(io.Discard, "", 0)
+	vm := &VM{Name: "vm", Project: "p", ID: 1}
+
+	calls := 0
+	fakeListTimeSeries(t, func(req *monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+		calls++
+		if window := req.GetInterval().GetEndTime().AsTime().Sub(req.GetInterval().GetStartTime().AsTime()); window != 2*freshness {
+			t.Errorf("lookup window = %v, want %v", window, 2*freshness)
+		}
+		now := time.Now()
+		if calls < 3 {
+			// Still being collected.
+			return []*monitoringpb.TimeSeries{seriesWithPoints(now.Add(-10*time.Second), now.Add(-70*time.Second))}
+		}
+		// Collection stopped 90 seconds ago.
+		return []*monitoringpb.TimeSeries{seriesWithPoints(now.Add(-90 * time.Second))}
+	})
+	if err := WaitForMetricGone(context.Background(), logger, vm, "workload.googleapis.com/m", freshness, false); err != nil {
+		t.Fatalf("WaitForMetricGone() failed: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("WaitForMetricGone() made %d lookups, want 3", calls)
+	}
+}
+
+func TestWaitForMetricGoneNoPoints(t *testing.T) {
+	fakeListTimeSeries(t, func(*monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+		return nil
+	})
+	if err := WaitForMetricGone(context.Background(), log.New(io.Discard, "", 0), &VM{Name: "vm"}, "m", time.Minute, false); err != nil {
+		t.Errorf("WaitForMetricGone() with no points failed: %v", err)
+	}
+}
+
+func TestWaitForMetricGoneStillCollected(t *testing.T) {
+	fakeListTimeSeries(t, func(*monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+		return []*monitoringpb.TimeSeries{seriesWithPoints(time.Now())}
+	})
+	err := WaitForMetricGone(context.Background(), log.New(io.Discard, "", 0), &VM{Name: "vm"}, "m", time.Minute, false)
+	if !IsExhaustedRetriesMetricError(err) {
+		t.Errorf("WaitForMetricGone() for a metric that is still collected = %v, want an exhausted retries error", err)
+	}
+}
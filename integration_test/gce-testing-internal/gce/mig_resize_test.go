@@ -0,0 +1,126 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeMIG pretends to be a Managed Instance Group for runGcloud and
+// listMIGInstances. Like a real one, it replaces every instance when it is
+// resized to 0 and back up.
+type fakeMIG struct {
+	nextID    int64
+	instances []ManagedInstance
+	commands  []string
+}
+
+func (f *fakeMIG) gcloud(args []string) (CommandOutput, error) {
+	f.commands = append(f.commands, strings.Join(args[:4], " "))
+	switch {
+	case args[3] == "resize":
+		var size int
+		if _, err := fmt.Sscanf(args[5], "--size=%d", &size); err != nil {
+			return CommandOutput{}, err
+		}
+		for len(f.instances) > size {
+			f.instances = f.instances[:len(f.instances)-1]
+		}
+		for len(f.instances) < size {
+			f.nextID++
+			f.instances = append(f.instances, ManagedInstance{Name: fmt.Sprintf("vm-mig-%d", f.nextID), ID: f.nextID, Status: "RUNNING"})
+		}
+	case args[2] == "list":
+		var out []string
+		for _, inst := range f.instances {
+			out = append(out, fmt.Sprintf(`{"name": %q, "id": "%d", "status": "RUNNING", "creationTimestamp": "2024-01-10T08:00:00.000-08:00", "networkInterfaces": [{"networkIP": "10.0.0.%d", "accessConfigs": [{"natIP": "203.0.113.%d"}]}]}`,
+				inst.Name, inst.ID, inst.ID, inst.ID))
+		}
+		return CommandOutput{Stdout: "[" + strings.Join(out, ",") + "]"}, nil
+	}
+	return CommandOutput{}, nil
+}
+
+func (f *fakeMIG) list(context.Context, *log.Logger, *ManagedInstanceGroupVM) ([]ManagedInstance, error) {
+	return f.instances, nil
+}
+
+func useFakeMIG(t *testing.T) *fakeMIG {
+	f := &fakeMIG{}
+	fakeRunGcloud(t, f.gcloud)
+	replaceForTest(t, &listMIGInstances, f.list)
+	return f
+}
+
+func instanceIDs(vms []*VM) []int64 {
+	var ids []int64
+	for _, vm := range vms {
+		ids = append(ids, vm.ID)
+	}
+	return ids
+}
+
+func TestResizeManagedInstanceGroup(t *testing.T) {
+	ctx := context.Background()
+	logger := log.New(io.Discard, "", 0)
+	f := useFakeMIG(t)
+	migVM := &ManagedInstanceGroupVM{VM: &VM{Name: "vm", Project: "p", Zone: "z", OS: OS{ID: "debian"}}}
+
+	if err := ResizeManagedInstanceGroup(ctx, logger, migVM, 2); err != nil {
+		t.Fatal(err)
+	}
+	wantCommands := []string{"compute instance-groups managed resize", "compute instance-groups managed wait-until"}
+	if !reflect.DeepEqual(f.commands, wantCommands) {
+		t.Errorf("ResizeManagedInstanceGroup() ran %v; want %v", f.commands, wantCommands)
+	}
+	vms, err := ListMIGInstances(ctx, logger, migVM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := instanceIDs(vms), []int64{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListMIGInstances() IDs = %v; want %v", got, want)
+	}
+	if vms[0].Name != "vm-mig-1" || vms[0].IPAddress != "203.0.113.1" || vms[0].OS != migVM.OS {
+		t.Errorf("ListMIGInstances()[0] = %+v; want vm-mig-1 at 203.0.113.1 with OS %v", vms[0], migVM.OS)
+	}
+
+	// Scaling to 0 and back up replaces every instance.
+	if err := ResizeManagedInstanceGroup(ctx, logger, migVM, 0); err != nil {
+		t.Fatal(err)
+	}
+	if vms, err := ListMIGInstances(ctx, logger, migVM); err != nil || len(vms) != 0 {
+		t.Errorf("ListMIGInstances() after resizing to 0 = %v, %v; want no instances", vms, err)
+	}
+	if err := ResizeManagedInstanceGroup(ctx, logger, migVM, 2); err != nil {
+		t.Fatal(err)
+	}
+	vms, err = ListMIGInstances(ctx, logger, migVM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := instanceIDs(vms), []int64{3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListMIGInstances() IDs after resizing to 0 and back = %v; want %v", got, want)
+	}
+
+	if err := ResizeManagedInstanceGroup(ctx, logger, migVM, -1); err == nil {
+		t.Error("ResizeManagedInstanceGroup(size=-1) succeeded; want error")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+func TestParseManagedInstances(t *testing.T) {
+	stdout := `[
+  {"currentAction": "NONE", "id": "111", "instance": "https://www.googleapis.com/compute/v1/projects/p/zones/z/instances/vm-a", "instanceStatus": "RUNNING"},
+  {"currentAction": "CREATING", "id": "222", "instance": "https://www.googleapis.com/compute/v1/projects/p/zones/z/instances/vm-b", "instanceStatus": "STAGING"}
+]`
+	instances, err := parseManagedInstances(stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ManagedInstance{
+		{Name: "vm-a", ID: 111, Zone: "z", Status: "RUNNING"},
+		{Name: "vm-b", ID: 222, Zone: "z", Status: "STAGING"},
+	}
+	if len(instances) != len(expected) {
+		t.Fatalf("parseManagedInstances() = %v; want %v", instances, expected)
+	}
+	for i := range expected {
+		if instances[i] != expected[i] {
+			t.Errorf("parseManagedInstances()[%d] = %v; want %v", i, instances[i], expected[i])
+		}
+	}
+
+	if _, err := parseManagedInstances(`[{"id": "not-a-number"}]`); err == nil {
+		t.Error("parseManagedInstances() with a non-numeric ID unexpectedly succeeded")
+	}
+}
+
+func TestWaitForMetricFromAllMIGInstances(t *testing.T) {
+	origListMIGInstances := listMIGInstances
+	t.Cleanup(func() { listMIGInstances = origListMIGInstances })
+	listMIGInstances = func(context.Context, *log.Logger, *ManagedInstanceGroupVM) ([]ManagedInstance, error) {
+		return []ManagedInstance{
+			{Name: "vm-a", ID: 111},
+			{Name: "vm-b", ID: 222},
+		}, nil
+	}
+
+	// The first query for each instance only succeeds for vm-a; vm-b starts
+	// reporting on its second query.
+	var mutex sync.Mutex
+	queries := make(map[string]int)
+	fakeListTimeSeries(t, func(req *monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+		mutex.Lock()
+		defer mutex.Unlock()
+		queries[req.Filter]++
+		if strings.Contains(req.Filter, `instance_id = "111"`) || queries[req.Filter] > 1 {
+			return []*monitoringpb.TimeSeries{seriesWithPoints(time.Now())}
+		}
+		return nil
+	})
+
+	migVM := &ManagedInstanceGroupVM{VM: &VM{Name: "mig", Project: "p", Zone: "z"}}
+	found, err := WaitForMetricFromAllMIGInstances(context.Background(), log.New(io.Discard, "", 0), migVM, "agent.googleapis.com/cpu/utilization", time.Minute, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{111, 222} {
+		if found[id] == nil {
+			t.Errorf("WaitForMetricFromAllMIGInstances() found no series for instance %d", id)
+		}
+	}
+	for filter, count := range queries {
+		if strings.Contains(filter, `instance_id = "111"`) && count != 1 {
+			t.Errorf("instance 111 was queried %d times after it reported the metric; want 1", count)
+		}
+	}
+}
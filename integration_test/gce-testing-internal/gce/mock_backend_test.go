@@ -0,0 +1,189 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeMockBackend replaces the operations used by setupMockBackend with fakes
+// that record the name of each step into the returned slice instead of doing
+// anything, and the arguments of each gcloud command into the other.
+func fakeMockBackend(t *testing.T, failStep string) (steps *[]string, gcloudArgs *[][]string) {
+	t.Helper()
+	var calls []string
+	var args [][]string
+	record := func(step string) error {
+		calls = append(calls, step)
+		if step == failStep {
+			return errors.New(step + " failed")
+		}
+		return nil
+	}
+	replaceForTest(t, &createInstance, func(_ context.Context, _ *log.Logger, options VMOptions) (*VM, error) {
+		if !slices.ContainsFunc(options.Tags, func(tag string) bool { return strings.Contains(tag, "-backend-") }) {
+			return nil, errors.New("createInstance called without the backend's tag")
+		}
+		return &VM{Name: "backend", Project: "p", Zone: "z", Network: "default"}, record("create")
+	})
+	replaceForTest(t, &deleteInstance, func(context.Context, *log.Logger, *VM) error {
+		return record("delete")
+	})
+	replaceForTest(t, &scpToVM, func(_ context.Context, _ *log.Logger, _ *VM, localPath, remotePath string) error {
+		return record("upload " + localPath)
+	})
+	fakeRunRemotely(t, func(context.Context, *VM, string) (CommandOutput, error) {
+		return CommandOutput{}, record("run")
+	})
+	replaceForTest(t, &isPortListening, func(context.Context, *log.Logger, *VM, int) (bool, error) {
+		return true, record("wait for port")
+	})
+	fakeRunGcloud(t, func(gcloudArgs []string) (CommandOutput, error) {
+		args = append(args, gcloudArgs)
+		step := strings.Join(gcloudArgs[:3], " ")
+		if step == "compute networks subnets" {
+			return CommandOutput{Stdout: "10.128.0.0/20\n172.16.0.0/24\n"}, record(step)
+		}
+		return CommandOutput{Stdout: "10.1.2.3\n"}, record(step)
+	})
+	return &calls, &args
+}
+
+func TestSetupMockBackend(t *testing.T) {
+	calls, gcloudArgs := fakeMockBackend(t, "")
+	var cleanups []func() error
+	options := MockBackendOptions{
+		VMOptions:  VMOptions{ImageSpec: "debian-cloud:debian-12"},
+		BinaryPath: "/path/to/receiver",
+	}
+	vm, backendURL, err := setupMockBackend(context.Background(), log.New(io.Discard, "", 0), options, func(f func() error) {
+		cleanups = append(cleanups, f)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Name != "backend" {
+		t.Errorf("setupMockBackend() returned VM %v; want backend", vm.Name)
+	}
+	if matched := regexp.MustCompile(`^http://10\.1\.2\.3:4318$`).MatchString(backendURL); !matched {
+		t.Errorf("setupMockBackend() returned URL %q; want http://10.1.2.3:4318", backendURL)
+	}
+	wantCalls := []string{
+		"create",
+		"upload /path/to/receiver",
+		"run",
+		"wait for port",
+		"compute networks subnets",
+		"compute firewall-rules create",
+		"compute instances describe",
+	}
+	if !slices.Equal(*calls, wantCalls) {
+		t.Errorf("setupMockBackend() ran steps %v; want %v", *calls, wantCalls)
+	}
+	// The firewall rule lets in every subnet of the network.
+	if firewallArgs := (*gcloudArgs)[1]; !slices.Contains(firewallArgs, "--source-ranges=10.128.0.0/20,172.16.0.0/24") {
+		t.Errorf("setupMockBackend() created the firewall rule with %v; want --source-ranges=10.128.0.0/20,172.16.0.0/24", firewallArgs)
+	}
+
+	*calls = nil
+	for _, cleanup := range slices.Backward(cleanups) {
+		if err := cleanup(); err != nil {
+			t.Error(err)
+		}
+	}
+	wantCleanups := []string{"compute firewall-rules delete", "delete"}
+	if !slices.Equal(*calls, wantCleanups) {
+		t.Errorf("setupMockBackend() cleanups ran steps %v; want %v", *calls, wantCleanups)
+	}
+}
+
+func TestSetupMockBackendFailure(t *testing.T) {
+	calls, _ := fakeMockBackend(t, "run")
+	var cleanups []func() error
+	options := MockBackendOptions{
+		VMOptions:  VMOptions{ImageSpec: "debian-cloud:debian-12"},
+		BinaryPath: "/path/to/receiver",
+		Port:       8080,
+	}
+	if _, _, err := setupMockBackend(context.Background(), log.New(io.Discard, "", 0), options, func(f func() error) {
+		cleanups = append(cleanups, f)
+	}); err == nil {
+		t.Fatal("setupMockBackend() unexpectedly succeeded")
+	}
+	wantCalls := []string{"create", "upload /path/to/receiver", "run"}
+	if !slices.Equal(*calls, wantCalls) {
+		t.Errorf("setupMockBackend() ran steps %v; want %v", *calls, wantCalls)
+	}
+	// Only the VM was created, so only it needs to be cleaned up.
+	if len(cleanups) != 1 {
+		t.Errorf("setupMockBackend() registered %d cleanups; want 1", len(cleanups))
+	}
+}
+
+func TestSetupMockBackendRejectsWindows(t *testing.T) {
+	calls, _ := fakeMockBackend(t, "")
+	options := MockBackendOptions{
+		VMOptions:  VMOptions{ImageSpec: "windows-cloud:windows-2022"},
+		BinaryPath: "/path/to/receiver.exe",
+	}
+	if _, _, err := setupMockBackend(context.Background(), log.New(io.Discard, "", 0), options, func(func() error) {}); err == nil {
+		t.Error("setupMockBackend() with a Windows image unexpectedly succeeded")
+	}
+	if len(*calls) != 0 {
+		t.Errorf("setupMockBackend() ran steps %v; want none", *calls)
+	}
+}
+
+func TestSetupMockBackendKeepsCallerTags(t *testing.T) {
+	fakeMockBackend(t, "")
+	var createArgs []string
+	wrapped := createInstance
+	replaceForTest(t, &createInstance, func(ctx context.Context, logger *log.Logger, options VMOptions) (*VM, error) {
+		args, err := additionalCreateInstanceArgs(options, &VM{ImageSpec: options.ImageSpec})
+		if err != nil {
+			return nil, err
+		}
+		createArgs = args
+		return wrapped(ctx, logger, options)
+	})
+
+	callerTags := []string{"http-server", DenyEgressTrafficTag}
+	options := MockBackendOptions{
+		VMOptions:  VMOptions{ImageSpec: "debian-cloud:debian-12", Tags: callerTags},
+		BinaryPath: "/path/to/receiver",
+	}
+	if _, _, err := setupMockBackend(context.Background(), log.New(io.Discard, "", 0), options, func(func() error) {}); err != nil {
+		t.Fatal(err)
+	}
+	var tagFlags []string
+	for _, arg := range createArgs {
+		if strings.HasPrefix(arg, "--tags=") {
+			tagFlags = append(tagFlags, arg)
+		}
+	}
+	if len(tagFlags) != 1 || !strings.HasPrefix(tagFlags[0], "--tags=http-server,"+DenyEgressTrafficTag+",") {
+		t.Errorf("setupMockBackend() created the backend with tag flags %v; want one --tags flag with the caller's tags and the backend's", tagFlags)
+	}
+	if !slices.Equal(options.VMOptions.Tags, []string{"http-server", DenyEgressTrafficTag}) {
+		t.Errorf("setupMockBackend() modified the caller's Tags to %v", options.VMOptions.Tags)
+	}
+}
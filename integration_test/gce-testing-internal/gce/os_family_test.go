@@ -0,0 +1,56 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import "testing"
+
+func TestOSFamily(t *testing.T) {
+	for _, tc := range []struct {
+		id, idLike string
+		want       OSFamily
+	}{
+		{id: "debian", want: OSFamilyDebian},
+		{id: "ubuntu", idLike: "debian", want: OSFamilyDebian},
+		{id: "rhel", idLike: "fedora", want: OSFamilyRHEL},
+		{id: "centos", idLike: "rhel fedora", want: OSFamilyRHEL},
+		{id: "rocky", idLike: "rhel centos fedora", want: OSFamilyRHEL},
+		{id: "almalinux", idLike: "rhel centos fedora", want: OSFamilyRHEL},
+		{id: "sles", idLike: "suse", want: OSFamilySUSE},
+		{id: "opensuse-leap", idLike: "suse opensuse", want: OSFamilySUSE},
+		{id: "someos", idLike: "", want: ""},
+	} {
+		if got := osFamily(tc.id, tc.idLike); got != tc.want {
+			t.Errorf("osFamily(%q, %q) = %q, want %q", tc.id, tc.idLike, got, tc.want)
+		}
+	}
+}
+
+func TestVMFamilyHelpers(t *testing.T) {
+	vm := &VM{ImageSpec: "rocky-linux-cloud:rocky-linux-9", OS: OS{ID: "someos", Family: OSFamilyDebian}}
+	if !IsDebianBasedVM(vm) {
+		t.Errorf("IsDebianBasedVM() = false, want true when OS.Family is debian")
+	}
+	if IsRHELLikeVM(vm) {
+		t.Errorf("IsRHELLikeVM() = true, want false when OS.Family is debian")
+	}
+
+	vm.OS = OS{}
+	if IsDebianBasedVM(vm) {
+		t.Errorf("IsDebianBasedVM() = true, want false for a Rocky image spec")
+	}
+	if !IsRHELLikeVM(vm) {
+		t.Errorf("IsRHELLikeVM() = false, want true for a Rocky image spec")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+// fakeRepoRunRemotely replaces runRemotely with a fake that fails with
+// the given error, and returns the commands that were run.
+func fakeRepoRunRemotely(t *testing.T, err error) *[]string {
+	t.Helper()
+	var commands []string
+	fakeRunRemotely(t, func(_ context.Context, _ *VM, command string) (CommandOutput, error) {
+		commands = append(commands, command)
+		return CommandOutput{}, err
+	})
+	return &commands
+}
+
+func TestConfigureAptRepo(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	vm := &VM{Name: "vm", Project: "p", ImageSpec: "debian-cloud:debian-12"}
+	repo := PackageRepo{Name: "staging", URL: "https://packages.example.com/apt", Suite: "bookworm main"}
+
+	commands := fakeRepoRunRemotely(t, nil)
+	if err := ConfigureAptRepo(context.Background(), logger, vm, repo, true); err != nil {
+		t.Fatalf("ConfigureAptRepo() failed: %v", err)
+	}
+	if want := "echo 'deb [trusted=yes] https://packages.example.com/apt bookworm main' | sudo tee '/etc/apt/sources.list.d/staging.list'"; len(*commands) != 1 || (*commands)[0] != want {
+		t.Errorf("ConfigureAptRepo() ran %q, want %q", *commands, want)
+	}
+
+	commands = fakeRepoRunRemotely(t, nil)
+	if err := ConfigureAptRepo(context.Background(), logger, vm, PackageRepo{Name: "bullseye-backports", Suite: "bullseye-backports"}, false); err != nil {
+		t.Fatalf("ConfigureAptRepo() failed: %v", err)
+	}
+	if len(*commands) != 1 || !strings.Contains((*commands)[0], "s/deb[^ ]* [^ ]+ bullseye-backports .*//") {
+		t.Errorf("ConfigureAptRepo() ran %q, want it to remove bullseye-backports from sources.list", *commands)
+	}
+
+	commands = fakeRepoRunRemotely(t, nil)
+	if err := ConfigureAptRepo(context.Background(), logger, vm, PackageRepo{Name: "it's", URL: "https://example.com/a'b", Suite: "stable"}, true); err != nil {
+		t.Fatalf("ConfigureAptRepo() failed: %v", err)
+	}
+	if want := `echo 'deb [trusted=yes] https://example.com/a'\''b stable' | sudo tee '/etc/apt/sources.list.d/it'\''s.list'`; len(*commands) != 1 || (*commands)[0] != want {
+		t.Errorf("ConfigureAptRepo() ran %q, want %q", *commands, want)
+	}
+}
+
+func TestConfigureYumRepo(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	vm := &VM{Name: "vm", Project: "p", ImageSpec: "rhel-cloud:rhel-9"}
+
+	commands := fakeRepoRunRemotely(t, nil)
+	repo := PackageRepo{Name: "staging", URL: "https://packages.example.com/yum", GPGKeyURL: "https://packages.example.com/key.gpg"}
+	if err := ConfigureYumRepo(context.Background(), logger, vm, repo, true); err != nil {
+		t.Fatalf("ConfigureYumRepo() failed: %v", err)
+	}
+	for _, want := range []string{"[staging]", "baseurl=https://packages.example.com/yum", "gpgkey=https://packages.example.com/key.gpg", "/etc/yum.repos.d/staging.repo"} {
+		if len(*commands) != 1 || !strings.Contains((*commands)[0], want) {
+			t.Errorf("ConfigureYumRepo() ran %q, want it to contain %q", *commands, want)
+		}
+	}
+
+	fakeRepoRunRemotely(t, errors.New("Error: No matching repo to modify: rhui-*."))
+	if err := ConfigureYumRepo(context.Background(), logger, vm, PackageRepo{Name: "rhui-*"}, false); err != nil {
+		t.Errorf("ConfigureYumRepo() disabling a missing repo failed: %v", err)
+	}
+
+	fakeRepoRunRemotely(t, errors.New("Error: Failed to download metadata"))
+	if err := ConfigureYumRepo(context.Background(), logger, vm, PackageRepo{Name: "rhui-*"}, false); err == nil {
+		t.Error("ConfigureYumRepo() unexpectedly succeeded")
+	}
+}
+
+func TestConfigureZypperRepo(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	vm := &VM{Name: "vm", Project: "p", ImageSpec: "suse-cloud:sles-15"}
+	repo := PackageRepo{Name: "staging", URL: "https://packages.example.com/zypp"}
+
+	fakeRepoRunRemotely(t, errors.New("Repository named 'staging' already exists. Please use another alias."))
+	if err := ConfigureZypperRepo(context.Background(), logger, vm, repo, true); err != nil {
+		t.Errorf("ConfigureZypperRepo() adding an existing repo failed: %v", err)
+	}
+
+	fakeRepoRunRemotely(t, errors.New("Repository 'staging' not found by its alias, number, or URI."))
+	if err := ConfigureZypperRepo(context.Background(), logger, vm, repo, false); err != nil {
+		t.Errorf("ConfigureZypperRepo() removing a missing repo failed: %v", err)
+	}
+
+	fakeRepoRunRemotely(t, errors.New("Permission denied"))
+	if err := ConfigureZypperRepo(context.Background(), logger, vm, repo, true); err == nil {
+		t.Error("ConfigureZypperRepo() unexpectedly succeeded")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+	cfg := PollConfig{
+		Description: "TestPoll()",
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		IsRetriable: func(err error) bool { return err == errTransient },
+	}
+
+	tests := []struct {
+		name         string
+		results      []error // nil means done
+		wantAttempts int
+		wantErr      string
+	}{
+		{name: "done on first attempt", results: []error{nil}, wantAttempts: 1},
+		{name: "retries transient errors", results: []error{errTransient, errTransient, nil}, wantAttempts: 3},
+		{name: "stops on non-retriable error", results: []error{errTransient, errFatal}, wantAttempts: 2, wantErr: "TestPoll() failed: fatal"},
+		{name: "exhausts retries", results: []error{errTransient, errTransient, errTransient}, wantAttempts: 3, wantErr: exhaustedRetriesSuffix},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+			err := Poll(context.Background(), logger, cfg, func() (bool, error) {
+				result := tc.results[attempts]
+				attempts++
+				return result == nil, result
+			})
+			if attempts != tc.wantAttempts {
+				t.Errorf("Poll() made %d attempts, want %d", attempts, tc.wantAttempts)
+			}
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("Poll() failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Poll() error = %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPollNotDoneIsRetried(t *testing.T) {
+	attempts := 0
+	err := Poll(context.Background(), log.New(io.Discard, "", 0), PollConfig{MaxAttempts: 2, Backoff: time.Millisecond}, func() (bool, error) {
+		attempts++
+		return false, nil
+	})
+	if attempts != 2 || !IsExhaustedRetriesMetricError(err) {
+		t.Errorf("Poll() made %d attempts and returned %v, want 2 attempts and an exhausted retries error", attempts, err)
+	}
+}
+
+func TestPollStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts := 0
+	err := Poll(ctx, log.New(io.Discard, "", 0), PollConfig{MaxAttempts: 5, Backoff: time.Hour}, func() (bool, error) {
+		attempts++
+		return false, nil
+	})
+	if attempts != 1 || !errors.Is(ctx.Err(), context.Canceled) || err == nil {
+		t.Errorf("Poll() made %d attempts and returned %v, want 1 attempt and an error", attempts, err)
+	}
+}
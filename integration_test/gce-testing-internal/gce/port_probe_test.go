@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForOpenPort(t *testing.T) {
+	origIsListening, origBackoff := isPortListening, openPortBackoffDuration
+	t.Cleanup(func() {
+		isPortListening, openPortBackoffDuration = origIsListening, origBackoff
+	})
+	openPortBackoffDuration = time.Millisecond
+
+	polls := 0
+	isPortListening = func(_ context.Context, _ *log.Logger, _ *VM, port int) (bool, error) {
+		if port != 20201 {
+			t.Errorf("isPortListening() called for port %d; want 20201", port)
+		}
+		polls++
+		return polls >= 3, nil
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	vm := &VM{Name: "vm", ImageSpec: "debian-cloud:debian-12"}
+	if err := WaitForOpenPort(context.Background(), logger, vm, 20201, time.Minute); err != nil {
+		t.Errorf("WaitForOpenPort() failed: %v", err)
+	}
+	if polls != 3 {
+		t.Errorf("WaitForOpenPort() polled %d times; want 3", polls)
+	}
+
+	isPortListening = func(context.Context, *log.Logger, *VM, int) (bool, error) {
+		return false, nil
+	}
+	err := WaitForOpenPort(context.Background(), logger, vm, 20201, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "nothing is listening on port 20201") {
+		t.Errorf("WaitForOpenPort() error = %v; want a timeout", err)
+	}
+}
+
+func TestParseHTTPGetOutput(t *testing.T) {
+	for _, tc := range []struct {
+		stdout   string
+		wantCode int
+		wantBody string
+		wantErr  bool
+	}{
+		{stdout: "ok\n200", wantCode: 200, wantBody: "ok"},
+		{stdout: "line1\nline2\n\n404", wantCode: 404, wantBody: "line1\nline2\n"},
+		{stdout: "ok\r\n200\r\n", wantCode: 200, wantBody: "ok"},
+		{stdout: "\n204", wantCode: 204, wantBody: ""},
+		{stdout: "500", wantCode: 500, wantBody: ""},
+		{stdout: "no status code", wantErr: true},
+	} {
+		code, body, err := parseHTTPGetOutput(tc.stdout)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("parseHTTPGetOutput(%q) error = %v, wantErr %v", tc.stdout, err, tc.wantErr)
+			continue
+		}
+		if code != tc.wantCode || body != tc.wantBody {
+			t.Errorf("parseHTTPGetOutput(%q) = (%d, %q), want (%d, %q)", tc.stdout, code, body, tc.wantCode, tc.wantBody)
+		}
+	}
+}
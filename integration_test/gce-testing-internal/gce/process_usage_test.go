@@ -0,0 +1,141 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestParseProcessSnapshot(t *testing.T) {
+	stdout := "1234.56 100\n1 1500 4096 systemd\n42 250 1048576 fluent-bit\n43 9 8192 (sd-pam)\n44 0 0 Web Content\n"
+	snapshot, err := parseProcessSnapshot(stdout)
+	if err != nil {
+		t.Fatalf("parseProcessSnapshot() failed: %v", err)
+	}
+	if snapshot.Uptime != 1234.56 || snapshot.TicksPerSecond != 100 {
+		t.Errorf("parseProcessSnapshot() header = (%v, %v), want (1234.56, 100)", snapshot.Uptime, snapshot.TicksPerSecond)
+	}
+	want := []processStat{
+		{PID: 1, Name: "systemd", CPUTicks: 1500, RSSBytes: 4096},
+		{PID: 42, Name: "fluent-bit", CPUTicks: 250, RSSBytes: 1 << 20},
+		{PID: 43, Name: "(sd-pam)", CPUTicks: 9, RSSBytes: 8192},
+		{PID: 44, Name: "Web Content", CPUTicks: 0, RSSBytes: 0},
+	}
+	if len(snapshot.Processes) != len(want) {
+		t.Fatalf("parseProcessSnapshot() processes = %+v, want %+v", snapshot.Processes, want)
+	}
+	for i := range want {
+		if snapshot.Processes[i] != want[i] {
+			t.Errorf("parseProcessSnapshot() process %d = %+v, want %+v", i, snapshot.Processes[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"", "1234.56\n", "1234.56 0\n", "1 100\n42 x 4096 otelopscol\n", "1 100\n42 250\n"} {
+		if _, err := parseProcessSnapshot(bad); err == nil {
+			t.Errorf("parseProcessSnapshot(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestParseProcessSnapshotWindows(t *testing.T) {
+	snapshot, err := parseProcessSnapshot("5000.25 10000000\r\n812 25000000 104857600 otelopscol\r\n")
+	if err != nil {
+		t.Fatalf("parseProcessSnapshot() failed: %v", err)
+	}
+	want := processStat{PID: 812, Name: "otelopscol", CPUTicks: 25000000, RSSBytes: 100 << 20}
+	if len(snapshot.Processes) != 1 || snapshot.Processes[0] != want {
+		t.Errorf("parseProcessSnapshot() processes = %+v, want [%+v]", snapshot.Processes, want)
+	}
+}
+
+func TestProcessSamples(t *testing.T) {
+	prev := processSnapshot{Uptime: 100, TicksPerSecond: 100, Processes: []processStat{
+		{PID: 10, Name: "otelopscol", CPUTicks: 1000},
+		{PID: 11, Name: "fluent-bit", CPUTicks: 500},
+		{PID: 12, Name: "bash", CPUTicks: 0},
+	}}
+	cur := processSnapshot{Uptime: 102, TicksPerSecond: 100, Processes: []processStat{
+		// 100 ticks in 2 seconds is 50% of a core.
+		{PID: 10, Name: "otelopscol", CPUTicks: 1100, RSSBytes: 300},
+		// Restarted since prev.
+		{PID: 13, Name: "fluent-bit", CPUTicks: 20, RSSBytes: 200},
+		{PID: 12, Name: "bash", CPUTicks: 200},
+	}}
+	now := time.Now()
+	got := processSamples(prev, cur, regexp.MustCompile("^(fluent-bit|otelopscol)$"), now)
+	want := []ProcessSample{
+		{Time: now, PID: 10, Name: "otelopscol", CPUPercent: 50, RSSBytes: 300},
+		{Time: now, PID: 13, Name: "fluent-bit", CPUPercent: 10, RSSBytes: 200},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("processSamples() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("processSamples()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSampleProcessUsage(t *testing.T) {
+	origSnapshot := takeProcessSnapshot
+	t.Cleanup(func() { takeProcessSnapshot = origSnapshot })
+	calls := 0
+	takeProcessSnapshot = func(context.Context, *log.Logger, *VM) (processSnapshot, error) {
+		defer func() { calls++ }()
+		p := []processStat{
+			{PID: 10, Name: "otelopscol", CPUTicks: int64(calls) * 10, RSSBytes: 100},
+			{PID: 11, Name: "fluent-bit", CPUTicks: int64(calls) * 20, RSSBytes: 50},
+		}
+		if calls >= 3 {
+			// The collector crashed.
+			p = p[1:]
+		}
+		return processSnapshot{Uptime: float64(calls), TicksPerSecond: 100, Processes: p}, nil
+	}
+	logger := log.New(io.Discard, "", 0)
+	vm := &VM{Name: "vm"}
+
+	samples, err := SampleProcessUsage(context.Background(), logger, vm, "^otelopscol$", 2*time.Millisecond, time.Millisecond)
+	if err != nil {
+		t.Fatalf("SampleProcessUsage() failed: %v", err)
+	}
+	if len(samples) == 0 {
+		t.Fatal("SampleProcessUsage() returned no samples")
+	}
+	for _, s := range samples {
+		if s.Name != "otelopscol" || s.CPUPercent != 10 || s.RSSBytes != 100 {
+			t.Errorf("SampleProcessUsage() sample = %+v, want otelopscol at 10%% CPU and 100 bytes", s)
+		}
+	}
+
+	calls = 0
+	samples, err = SampleProcessUsage(context.Background(), logger, vm, "^otelopscol$", time.Hour, time.Millisecond)
+	if err == nil {
+		t.Error("SampleProcessUsage() succeeded after the process stopped, want an error")
+	}
+	if len(samples) != 2 {
+		t.Errorf("SampleProcessUsage() returned %d samples before the process stopped, want 2", len(samples))
+	}
+
+	if _, err := SampleProcessUsage(context.Background(), logger, vm, "(", time.Second, time.Millisecond); err == nil {
+		t.Error("SampleProcessUsage() with an invalid regex succeeded, want an error")
+	}
+}
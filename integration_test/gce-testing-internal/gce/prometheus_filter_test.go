@@ -0,0 +1,61 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+func TestPrometheusLabelFilter(t *testing.T) {
+	tests := []struct {
+		k, v string
+		want string
+	}{
+		{k: "job", v: "node-exporter", want: `resource.labels.job = "node-exporter"`},
+		{k: "instance", v: "localhost:9100", want: `resource.labels.instance = "localhost:9100"`},
+		{k: "device", v: "sda", want: `metric.labels.device = "sda"`},
+		{k: "path", v: `C:\data "x"`, want: `metric.labels.path = "C:\\data \"x\""`},
+	}
+	for _, tc := range tests {
+		if got := PrometheusLabelFilter(tc.k, tc.v); got != tc.want {
+			t.Errorf("PrometheusLabelFilter(%q, %q) = %s; want %s", tc.k, tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestWaitForPrometheusMetric(t *testing.T) {
+	var gotFilter string
+	fakeListTimeSeries(t, func(req *monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+		gotFilter = req.Filter
+		return []*monitoringpb.TimeSeries{seriesWithPoints(time.Now()), seriesWithPoints(time.Now())}
+	})
+
+	vm := &VM{Name: "vm", Project: "p", ID: 1234}
+	labels := map[string]string{"job": "app", "code": "200"}
+	series, err := WaitForPrometheusMetric(context.Background(), log.New(io.Discard, "", 0), vm, "prometheus.googleapis.com/http_requests_total/counter", time.Hour, labels, 2)
+	if err != nil || len(series) != 2 {
+		t.Fatalf("WaitForPrometheusMetric() = (%v, %v); want two series", series, err)
+	}
+	want := `metric.type = "prometheus.googleapis.com/http_requests_total/counter" AND resource.labels.namespace = "1234/vm" AND metric.labels.code = "200" AND resource.labels.job = "app"`
+	if gotFilter != want {
+		t.Errorf("WaitForPrometheusMetric() used filter\n%s\nwant\n%s", gotFilter, want)
+	}
+}
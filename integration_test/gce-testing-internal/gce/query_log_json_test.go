@@ -0,0 +1,67 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	cloudlogging "cloud.google.com/go/logging"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestQueryLogJSON(t *testing.T) {
+	origQueryLog := queryLog
+	t.Cleanup(func() { queryLog = origQueryLog })
+
+	var payload interface{}
+	queryLog = func(context.Context, *log.Logger, *VM, string, time.Duration, string, int) (*cloudlogging.Entry, error) {
+		return &cloudlogging.Entry{Payload: payload}, nil
+	}
+	vm := &VM{Name: "vm", Project: "p", ID: 1234}
+	logger := log.New(io.Discard, "", 0)
+
+	structPayload, err := structpb.NewStruct(map[string]interface{}{
+		"message": "Started the collector",
+		"level":   "info",
+		"count":   3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload = structPayload
+	var out struct {
+		Message string
+		Level   string
+		Count   int
+	}
+	if err := QueryLogJSON(context.Background(), logger, vm, "ops-agent-health", time.Hour, "", 1, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Message != "Started the collector" || out.Level != "info" || out.Count != 3 {
+		t.Errorf("QueryLogJSON() unmarshaled %+v; want the fields of %v", out, structPayload)
+	}
+
+	payload = "plain text"
+	err = QueryLogJSON(context.Background(), logger, vm, "syslog", time.Hour, "", 1, &out)
+	var textErr *TextPayloadError
+	if !errors.As(err, &textErr) || textErr.Text != "plain text" {
+		t.Errorf("QueryLogJSON() with a text payload returned %v; want a *TextPayloadError", err)
+	}
+}
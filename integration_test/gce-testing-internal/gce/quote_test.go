@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: `''`},
+		{in: "plain", want: `'plain'`},
+		{in: "with spaces", want: `'with spaces'`},
+		{in: "it's", want: `'it'\''s'`},
+		{in: "''", want: `''\'''\'''`},
+		{in: "$HOME and $(whoami)", want: `'$HOME and $(whoami)'`},
+		{in: "line 1\nline 2", want: "'line 1\nline 2'"},
+		{in: `{"key": "it's"}`, want: `'{"key": "it'\''s"}'`},
+		{in: `^a\.b$`, want: `'^a\.b$'`},
+	}
+	for _, tc := range tests {
+		if got := shellQuote(tc.in); got != tc.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestPowershellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: `''`},
+		{in: "plain", want: `'plain'`},
+		{in: "with spaces", want: `'with spaces'`},
+		{in: "it's", want: `'it''s'`},
+		{in: "it’s", want: "'it’’s'"},
+		{in: "$env:PATH and $(whoami)", want: `'$env:PATH and $(whoami)'`},
+		{in: "line 1\r\nline 2", want: "'line 1\r\nline 2'"},
+		{in: "`n", want: "'`n'"},
+		{in: `{"key": "it's"}`, want: `'{"key": "it''s"}'`},
+	}
+	for _, tc := range tests {
+		if got := powershellQuote(tc.in); got != tc.want {
+			t.Errorf("powershellQuote(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestQuoteScriptFlags(t *testing.T) {
+	flags := []string{"--name=it's", "--pattern=^a b$"}
+	if got, want := quoteScriptFlags(flags, shellQuote), `'--name=it'\''s' '--pattern=^a b$'`; got != want {
+		t.Errorf("quoteScriptFlags(shellQuote) = %q, want %q", got, want)
+	}
+	if got, want := quoteScriptFlags(flags, powershellQuote), `'--name=it''s' '--pattern=^a b$'`; got != want {
+		t.Errorf("quoteScriptFlags(powershellQuote) = %q, want %q", got, want)
+	}
+}
+
+func TestEnvVarMapPrefixes(t *testing.T) {
+	env := map[string]string{"VAR": "it's $5\nnext"}
+	if got, want := envVarMapToBashPrefix(env), "VAR='it'\\''s $5\nnext' "; got != want {
+		t.Errorf("envVarMapToBashPrefix() = %q, want %q", got, want)
+	}
+	if got, want := envVarMapToPowershellPrefix(env), "$env:VAR='it''s $5\nnext'\n"; got != want {
+		t.Errorf("envVarMapToPowershellPrefix() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStaleResources(t *testing.T) {
+	cutoff := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		kind      string
+		stdout    string
+		wantNames []string
+	}{
+		{
+			kind: "firewall-rules",
+			stdout: `[
+  {"name": "default-allow-ssh", "creationTimestamp": "2020-01-01T00:00:00.000-08:00"},
+  {"name": "test-20260101-abcde-backend-1234abcd", "creationTimestamp": "2026-01-01T10:00:00.000-08:00"},
+  {"name": "test-20260301-abcde-backend-5678abcd", "creationTimestamp": "2026-03-01T10:00:00.000-08:00"}
+]`,
+			wantNames: []string{"test-20260101-abcde-backend-1234abcd"},
+		},
+		{
+			kind: "instance-templates",
+			stdout: `[
+  {"name": "github-test-20260201-abcde-0123-tmpl", "creationTimestamp": "2026-02-01T10:00:00.000-08:00"},
+  {"name": "prod-template", "creationTimestamp": "2026-02-01T10:00:00.000-08:00"}
+]`,
+			wantNames: []string{"github-test-20260201-abcde-0123-tmpl"},
+		},
+		{
+			kind: "snapshots",
+			stdout: `[
+  {"name": "test-20260228-abcde-snap", "creationTimestamp": "2026-02-28T15:59:00.000-08:00"},
+  {"name": "test-20260228-fghij-snap", "creationTimestamp": "2026-02-28T16:01:00.000-08:00"},
+  {"name": "mytest-20260101-abcde-snap", "creationTimestamp": "2026-01-01T00:00:00.000-08:00"}
+]`,
+			wantNames: []string{"test-20260228-abcde-snap"},
+		},
+		{
+			kind:   "snapshots",
+			stdout: `[]`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.kind, func(t *testing.T) {
+			stale, err := parseStaleResources(tc.kind, tc.stdout, cutoff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, resource := range stale {
+				if resource.Kind != tc.kind {
+					t.Errorf("parseStaleResources() returned %v with kind %q; want %q", resource.Name, resource.Kind, tc.kind)
+				}
+				names = append(names, resource.Name)
+			}
+			if len(names) != len(tc.wantNames) {
+				t.Fatalf("parseStaleResources() = %v; want %v", names, tc.wantNames)
+			}
+			for i := range names {
+				if names[i] != tc.wantNames[i] {
+					t.Errorf("parseStaleResources() = %v; want %v", names, tc.wantNames)
+				}
+			}
+		})
+	}
+}
+
+func TestParseStaleManagedInstanceGroups(t *testing.T) {
+	cutoff := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	stdout := `[
+  {"name": "test-20260201-abcde-0123-mig", "creationTimestamp": "2026-02-01T10:00:00.000-08:00", "zone": "https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-a"},
+  {"name": "test-20260301-abcde-4567-mig", "creationTimestamp": "2026-03-01T10:00:00.000-08:00", "zone": "https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-b"}
+]`
+	stale, err := parseStaleResources("instance-groups managed", stdout, cutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 || stale[0].Name != "test-20260201-abcde-0123-mig" || stale[0].Zone != "us-central1-a" {
+		t.Errorf("parseStaleResources() = %+v; want only test-20260201-abcde-0123-mig in us-central1-a", stale)
+	}
+}
+
+func TestParseStaleResourcesBadTimestamp(t *testing.T) {
+	stdout := `[{"name": "test-20260101-abcde-fw", "creationTimestamp": "yesterday"}]`
+	if _, err := parseStaleResources("firewall-rules", stdout, time.Now()); err == nil {
+		t.Error("parseStaleResources() with a bad timestamp unexpectedly succeeded")
+	}
+}
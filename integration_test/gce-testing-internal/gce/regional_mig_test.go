@@ -0,0 +1,123 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestZoneRegion(t *testing.T) {
+	for zone, want := range map[string]string{
+		"us-central1-a":  "us-central1",
+		"europe-west4-b": "europe-west4",
+	} {
+		if got := zoneRegion(zone); got != want {
+			t.Errorf("zoneRegion(%q) = %q; want %q", zone, got, want)
+		}
+	}
+}
+
+func TestManagedInstanceGroupCreateArgs(t *testing.T) {
+	zonal := &ManagedInstanceGroupVM{VM: &VM{Name: "vm", Project: "p", Zone: "us-central1-a"}}
+	want := []string{
+		"compute", "instance-groups", "managed", "create", "vm-mig",
+		"--project=p",
+		"--zone=us-central1-a",
+		"--size=0",
+		"--template=vm-tmpl",
+		"--format=json",
+	}
+	if got := managedInstanceGroupCreateArgs(zonal, []string{"us-central1-b"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("managedInstanceGroupCreateArgs() for a zonal group = %v; want %v", got, want)
+	}
+
+	regional := &ManagedInstanceGroupVM{VM: &VM{Name: "vm", Project: "p", Zone: "us-central1-a"}, Region: "us-central1"}
+	want = []string{
+		"compute", "instance-groups", "managed", "create", "vm-mig",
+		"--project=p",
+		"--region=us-central1",
+		"--size=0",
+		"--template=vm-tmpl",
+		"--format=json",
+		"--zones=us-central1-a,us-central1-b",
+	}
+	if got := managedInstanceGroupCreateArgs(regional, []string{"us-central1-a", "us-central1-b"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("managedInstanceGroupCreateArgs() for a regional group = %v; want %v", got, want)
+	}
+	// Without zones, Compute Engine chooses them.
+	if got := managedInstanceGroupCreateArgs(regional, nil); !reflect.DeepEqual(got, want[:len(want)-1]) {
+		t.Errorf("managedInstanceGroupCreateArgs() for a regional group without zones = %v; want %v", got, want[:len(want)-1])
+	}
+}
+
+func TestRegionalManagedInstanceGroupCommands(t *testing.T) {
+	var commands [][]string
+	fakeRunGcloud(t, func(args []string) (CommandOutput, error) {
+		commands = append(commands, args)
+		if args[2] == "list" {
+			return CommandOutput{Stdout: `[
+  {"name": "vm-mig-a", "id": "1", "status": "RUNNING", "creationTimestamp": "2024-01-10T08:00:00.000-08:00", "networkInterfaces": [{"networkIP": "10.0.0.1", "accessConfigs": [{"natIP": "203.0.113.1"}]}]},
+  {"name": "vm-mig-b", "id": "2", "status": "RUNNING", "creationTimestamp": "2024-01-10T08:00:00.000-08:00", "networkInterfaces": [{"networkIP": "10.0.0.2", "accessConfigs": [{"natIP": "203.0.113.2"}]}]}
+]`}, nil
+		}
+		return CommandOutput{}, nil
+	})
+	replaceForTest(t, &listMIGInstances, func(context.Context, *log.Logger, *ManagedInstanceGroupVM) ([]ManagedInstance, error) {
+		return []ManagedInstance{
+			{Name: "vm-mig-a", ID: 1, Zone: "us-central1-a", Status: "RUNNING"},
+			{Name: "vm-mig-b", ID: 2, Zone: "us-central1-b", Status: "RUNNING"},
+		}, nil
+	})
+
+	ctx := context.Background()
+	logger := log.New(io.Discard, "", 0)
+	migVM := &ManagedInstanceGroupVM{VM: &VM{Name: "vm", Project: "p", Zone: "us-central1-a"}, Region: "us-central1"}
+	if err := ResizeManagedInstanceGroup(ctx, logger, migVM, 2); err != nil {
+		t.Fatal(err)
+	}
+	vms, err := ListMIGInstances(ctx, logger, migVM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range commands[:2] {
+		if !slices.Contains(args, "--region=us-central1") || strings.Contains(strings.Join(args, " "), "--zone=") {
+			t.Errorf("command %v; want it to use --region=us-central1 instead of --zone", args)
+		}
+	}
+	if !slices.Contains(commands[2], "--zones=us-central1-a,us-central1-b") {
+		t.Errorf("instance list command %v; want it to look in both zones", commands[2])
+	}
+	if len(vms) != 2 || vms[0].Zone != "us-central1-a" || vms[1].Zone != "us-central1-b" {
+		t.Errorf("ListMIGInstances() = %+v; want VMs in us-central1-a and us-central1-b", vms)
+	}
+}
+
+func TestValidateRegionalWithZone(t *testing.T) {
+	options := VMOptions{ImageSpec: "debian-cloud:debian-12", Regional: true}
+	if err := options.Validate(); err != nil {
+		t.Errorf("Validate() for a regional group = %v; want nil", err)
+	}
+	options.Zone = "us-central1-a"
+	if err := options.Validate(); err == nil || !strings.Contains(err.Error(), "Regional and Zone can't be set together") {
+		t.Errorf("Validate() for a regional group with a Zone = %v; want an error", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestAssertAgentResourceBudget(t *testing.T) {
+	const (
+		maxCPUPercent  = 50
+		maxMemoryBytes = 200 << 20
+	)
+	// usage is the collector's usage over one second.
+	type usage struct {
+		cpuPercent  int64
+		memoryBytes int64
+	}
+	tests := []struct {
+		name    string
+		samples []usage
+		// Error returned by the snapshot once samples are used up.
+		snapshotErr error
+		wantErr     bool
+	}{
+		{
+			name: "within budget",
+			samples: []usage{
+				{cpuPercent: 10, memoryBytes: 100 << 20},
+				{cpuPercent: 50, memoryBytes: 200 << 20},
+				{cpuPercent: 20, memoryBytes: 150 << 20},
+			},
+		},
+		{
+			name: "CPU crosses budget",
+			samples: []usage{
+				{cpuPercent: 10, memoryBytes: 100 << 20},
+				{cpuPercent: 75, memoryBytes: 100 << 20},
+				{cpuPercent: 10, memoryBytes: 100 << 20},
+			},
+			wantErr: true,
+		},
+		{
+			name: "memory crosses budget",
+			samples: []usage{
+				{cpuPercent: 10, memoryBytes: 100 << 20},
+				{cpuPercent: 10, memoryBytes: 100 << 20},
+				{cpuPercent: 10, memoryBytes: 300 << 20},
+			},
+			wantErr: true,
+		},
+		{
+			name:        "listing processes fails",
+			samples:     []usage{{cpuPercent: 10, memoryBytes: 100 << 20}},
+			snapshotErr: errors.New("connection reset"),
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			origSnapshot := takeProcessSnapshot
+			t.Cleanup(func() { takeProcessSnapshot = origSnapshot })
+			// The first snapshot is the baseline, and each later one is a
+			// second after the previous one.
+			calls := 0
+			var ticks int64
+			takeProcessSnapshot = func(context.Context, *log.Logger, *VM) (processSnapshot, error) {
+				defer func() { calls++ }()
+				u := tc.samples[len(tc.samples)-1]
+				if calls > 0 && calls <= len(tc.samples) {
+					u = tc.samples[calls-1]
+				} else if calls > len(tc.samples) && tc.snapshotErr != nil {
+					return processSnapshot{}, tc.snapshotErr
+				}
+				if calls > 0 {
+					ticks += u.cpuPercent
+				}
+				return processSnapshot{Uptime: float64(calls), TicksPerSecond: 100, Processes: []processStat{
+					{PID: 10, Name: "otelopscol", CPUTicks: ticks, RSSBytes: u.memoryBytes},
+					{PID: 11, Name: "fluent-bit", CPUTicks: 100 * int64(calls), RSSBytes: 1 << 30},
+				}}, nil
+			}
+
+			vm := &VM{Name: "vm", Project: "p", ID: 1234}
+			err := AssertAgentResourceBudget(context.Background(), log.New(io.Discard, "", 0), vm, maxCPUPercent, maxMemoryBytes, time.Millisecond, 100*time.Millisecond)
+			if tc.wantErr && err == nil {
+				t.Error("AssertAgentResourceBudget() unexpectedly succeeded")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("AssertAgentResourceBudget() failed: %v", err)
+			}
+			if calls <= len(tc.samples) && !tc.wantErr {
+				t.Errorf("AssertAgentResourceBudget() took %d snapshots; want at least %d", calls, len(tc.samples)+1)
+			}
+		})
+	}
+
+	t.Run("collector not running", func(t *testing.T) {
+		origSnapshot := takeProcessSnapshot
+		t.Cleanup(func() { takeProcessSnapshot = origSnapshot })
+		takeProcessSnapshot = func(context.Context, *log.Logger, *VM) (processSnapshot, error) {
+			return processSnapshot{Uptime: 1, TicksPerSecond: 100, Processes: []processStat{{PID: 11, Name: "fluent-bit"}}}, nil
+		}
+		vm := &VM{Name: "vm", Project: "p", ID: 1234}
+		if err := AssertAgentResourceBudget(context.Background(), log.New(io.Discard, "", 0), vm, maxCPUPercent, maxMemoryBytes, time.Millisecond, time.Second); err == nil {
+			t.Error("AssertAgentResourceBudget() unexpectedly succeeded")
+		}
+	})
+}
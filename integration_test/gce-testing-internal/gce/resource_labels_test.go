@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+)
+
+func seriesWithResource(resourceType string, labels map[string]string) *monitoringpb.TimeSeries {
+	series := seriesWithPoints(time.Now())
+	series.Resource = &monitoredres.MonitoredResource{Type: resourceType, Labels: labels}
+	return series
+}
+
+func TestAssertMetricResourceLabels(t *testing.T) {
+	vm := &VM{Name: "vm", Project: "p", Zone: "us-central1-b", ID: 1234}
+	want := map[string]string{"zone": "us-central1-b", "instance_id": "1234"}
+	tests := []struct {
+		name    string
+		series  []*monitoringpb.TimeSeries
+		wantErr string
+	}{
+		{
+			name: "matching",
+			series: []*monitoringpb.TimeSeries{
+				seriesWithResource("gce_instance", map[string]string{"project_id": "p", "zone": "us-central1-b", "instance_id": "1234"}),
+			},
+		},
+		{
+			name: "wrong value",
+			series: []*monitoringpb.TimeSeries{
+				seriesWithResource("gce_instance", map[string]string{"zone": "us-central1-b", "instance_id": "1234"}),
+				seriesWithResource("gce_instance", map[string]string{"zone": "us-east1-b", "instance_id": "1234"}),
+			},
+			wantErr: `has label zone="us-east1-b", want "us-central1-b"`,
+		},
+		{
+			name: "wrong resource type",
+			series: []*monitoringpb.TimeSeries{
+				seriesWithResource("generic_node", map[string]string{"location": "us-central1-b"}),
+			},
+			wantErr: `resource of type "generic_node" has no label "instance_id"`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeListTimeSeries(t, func(*monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+				return tc.series
+			})
+			err := AssertMetricResourceLabels(context.Background(), log.New(io.Discard, "", 0), vm, "agent.googleapis.com/agent/uptime", time.Hour, false, want)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("AssertMetricResourceLabels() = %v; want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("AssertMetricResourceLabels() = %v; want an error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
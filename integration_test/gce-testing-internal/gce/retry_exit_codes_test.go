@@ -0,0 +1,72 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// fakeExitCodes replaces runRemotely with a fake that exits with each of
+// the given exit codes in turn, and returns how many times it was called.
+func fakeExitCodes(t *testing.T, exitCodes ...int) *int {
+	t.Helper()
+	replaceForTest(t, &retryExitCodeBackoffDuration, time.Millisecond)
+	calls := 0
+	fakeRunRemotely(t, func(context.Context, *VM, string) (CommandOutput, error) {
+		code := exitCodes[calls]
+		calls++
+		if code != 0 {
+			return CommandOutput{ExitCode: code}, fmt.Errorf("exit status %d", code)
+		}
+		return CommandOutput{Stdout: "done", ExitCode: 0}, nil
+	})
+	return &calls
+}
+
+func TestRunRemotelyRetryExitCodes(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	vm := &VM{Name: "vm"}
+
+	calls := fakeExitCodes(t, 100, 100, 0)
+	output, err := RunRemotelyRetryExitCodes(context.Background(), logger, vm, "apt-get install -y foo", []int{100}, 5)
+	if err != nil {
+		t.Fatalf("RunRemotelyRetryExitCodes() failed: %v", err)
+	}
+	if output.Stdout != "done" || *calls != 3 {
+		t.Errorf("RunRemotelyRetryExitCodes() = %+v after %d calls; want success after 3 calls", output, *calls)
+	}
+}
+
+func TestRunRemotelyRetryExitCodesFailures(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	vm := &VM{Name: "vm"}
+
+	calls := fakeExitCodes(t, 100, 1, 0)
+	output, err := RunRemotelyRetryExitCodes(context.Background(), logger, vm, "apt-get install -y foo", []int{100}, 5)
+	if err == nil || output.ExitCode != 1 || *calls != 2 {
+		t.Errorf("RunRemotelyRetryExitCodes() = (%+v, %v) after %d calls; want exit code 1 after 2 calls", output, err, *calls)
+	}
+
+	calls = fakeExitCodes(t, 100, 100, 100)
+	output, err = RunRemotelyRetryExitCodes(context.Background(), logger, vm, "apt-get install -y foo", []int{100}, 2)
+	if err == nil || output.ExitCode != 100 || *calls != 2 {
+		t.Errorf("RunRemotelyRetryExitCodes() = (%+v, %v) after %d calls; want exit code 100 after 2 calls", output, err, *calls)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import "testing"
+
+func TestRunAsUserCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		imageSpec string
+		user      string
+		command   string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "simple command",
+			imageSpec: "debian-cloud:debian-12",
+			user:      "agent_user",
+			command:   "id -un",
+			want:      "sudo -u agent_user -H bash -c 'id -un'",
+		},
+		{
+			name:      "command with single quotes",
+			imageSpec: "debian-cloud:debian-12",
+			user:      "agent-user",
+			command:   "echo 'hello' > /tmp/out",
+			want:      `sudo -u agent-user -H bash -c 'echo '\''hello'\'' > /tmp/out'`,
+		},
+		{
+			name:      "shell injection in username",
+			imageSpec: "debian-cloud:debian-12",
+			user:      "root; rm -rf /",
+			command:   "id -un",
+			wantErr:   true,
+		},
+		{
+			name:      "empty username",
+			imageSpec: "debian-cloud:debian-12",
+			command:   "id -un",
+			wantErr:   true,
+		},
+		{
+			name:      "windows",
+			imageSpec: "windows-cloud:windows-2022",
+			user:      "agent_user",
+			command:   "whoami",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := runAsUserCommand(tc.imageSpec, tc.user, tc.command)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("runAsUserCommand() = %q; want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runAsUserCommand() failed: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("runAsUserCommand() = %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunForEachImageParallel(t *testing.T) {
+	origMax := MaxParallelImages
+	t.Cleanup(func() {
+		MaxParallelImages = origMax
+		parallelImageSlotsOnce = sync.Once{}
+	})
+	MaxParallelImages = 2
+	parallelImageSlotsOnce = sync.Once{}
+	t.Setenv("IMAGE_SPECS", "debian-cloud:debian-12,windows-cloud:windows-2016,windows-cloud:windows-2019,windows-cloud:windows-2022,rocky-linux-cloud:rocky-linux-9")
+	t.Setenv("IS_OPS_AGENT_UAP_PLUGIN", "true")
+
+	var mu sync.Mutex
+	var ran []string
+	running, peak := 0, 0
+	t.Run("images", func(t *testing.T) {
+		RunForEachImageParallel(t, func(t *testing.T, imageSpec string) {
+			mu.Lock()
+			ran = append(ran, imageSpec)
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			t.Cleanup(func() {
+				mu.Lock()
+				running--
+				mu.Unlock()
+			})
+			// Give other subtests a chance to overlap with this one.
+			time.Sleep(10 * time.Millisecond)
+		})
+	})
+
+	slices.Sort(ran)
+	want := []string{"debian-cloud:debian-12", "rocky-linux-cloud:rocky-linux-9", "windows-cloud:windows-2022"}
+	if !slices.Equal(ran, want) {
+		t.Errorf("RunForEachImageParallel() ran subtests for %v; want %v", ran, want)
+	}
+	if peak > MaxParallelImages {
+		t.Errorf("RunForEachImageParallel() ran %d subtests at once; want at most %d", peak, MaxParallelImages)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunRemotelyOnMIG(t *testing.T) {
+	ctx := context.Background()
+	logger := log.New(io.Discard, "", 0)
+	useFakeMIG(t)
+	migVM := &ManagedInstanceGroupVM{VM: &VM{Name: "vm", Project: "p", Zone: "z", OS: OS{ID: "debian"}}}
+	if err := ResizeManagedInstanceGroup(ctx, logger, migVM, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	replaceForTest(t, &RunRemotelyOnMIGParallelism, 2)
+	var mu sync.Mutex
+	concurrent, peak := 0, 0
+	fakeRunRemotely(t, func(ctx context.Context, vm *VM, command string) (CommandOutput, error) {
+		mu.Lock()
+		concurrent++
+		peak = max(peak, concurrent)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			concurrent--
+			mu.Unlock()
+		}()
+		// Give other commands a chance to overlap with this one, and to
+		// notice if a failure cancelled them.
+		time.Sleep(10 * time.Millisecond)
+		if err := ctx.Err(); err != nil {
+			return CommandOutput{ExitCode: -1}, err
+		}
+
+		if vm.Name == "vm-mig-2" {
+			return CommandOutput{Stderr: "no such file", ExitCode: 1}, errors.New("exit status 1")
+		}
+		return CommandOutput{Stdout: command + " on " + vm.Name}, nil
+	})
+
+	outputs, err := RunRemotelyOnMIG(ctx, logger, migVM, "uptime")
+	if err == nil || !strings.Contains(err.Error(), "vm-mig-2") {
+		t.Errorf("RunRemotelyOnMIG() error = %v; want an error mentioning vm-mig-2", err)
+	}
+	want := map[string]CommandOutput{
+		"vm-mig-1": {Stdout: "uptime on vm-mig-1"},
+		"vm-mig-2": {Stderr: "no such file", ExitCode: 1},
+		"vm-mig-3": {Stdout: "uptime on vm-mig-3"},
+		"vm-mig-4": {Stdout: "uptime on vm-mig-4"},
+	}
+	if len(outputs) != len(want) {
+		t.Errorf("RunRemotelyOnMIG() = %v; want %v", outputs, want)
+	}
+	for name, wantOutput := range want {
+		if outputs[name] != wantOutput {
+			t.Errorf("RunRemotelyOnMIG()[%v] = %+v; want %+v", name, outputs[name], wantOutput)
+		}
+	}
+	if peak > RunRemotelyOnMIGParallelism {
+		t.Errorf("RunRemotelyOnMIG() ran on %d instances at once; want at most %d", peak, RunRemotelyOnMIGParallelism)
+	}
+
+	// A group with no instances is an error rather than a silent no-op.
+	if err := ResizeManagedInstanceGroup(ctx, logger, migVM, 0); err != nil {
+		t.Fatal(err)
+	}
+	if outputs, err := RunRemotelyOnMIG(ctx, logger, migVM, "uptime"); err == nil {
+		t.Errorf("RunRemotelyOnMIG() on an empty group = %v; want an error", outputs)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"testing"
+)
+
+func TestRemotePathNeedsRoot(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "agent.tar.gz", expected: false},
+		{path: "/tmp/agent.tar.gz", expected: false},
+		{path: "/home/test_user/agent.tar.gz", expected: false},
+		{path: "/tmp", expected: true},
+		{path: "/tmp/../etc/agent.conf", expected: true},
+		{path: "/opt/agent/bin/otelopscol", expected: true},
+		{path: "/home/other_user/agent.tar.gz", expected: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			actual := remotePathNeedsRoot(tc.path)
+			if actual != tc.expected {
+				t.Errorf("remotePathNeedsRoot(%q) = %v; want %v", tc.path, actual, tc.expected)
+			}
+		})
+	}
+}
+
+func TestVMTransfersBucket(t *testing.T) {
+	if got := vmTransfersBucket(&VM{Name: "vm"}); got != transfersBucket {
+		t.Errorf("vmTransfersBucket() = %q; want the default %q", got, transfersBucket)
+	}
+	vm := createVMFromVMOptions(VMOptions{Name: "vm", Project: "p", Zone: "z", TransfersBucket: "other-bucket"})
+	if got := vmTransfersBucket(vm); got != "other-bucket" {
+		t.Errorf("vmTransfersBucket() = %q; want %q", got, "other-bucket")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestServiceAccountOverride(t *testing.T) {
+	t.Setenv("SERVICE_EMAIL", "default@p.iam.gserviceaccount.com")
+	options := VMOptions{
+		ImageSpec:           "debian-cloud:debian-12",
+		Project:             "p",
+		Zone:                "us-central1-b",
+		ServiceAccountEmail: "restricted@p.iam.gserviceaccount.com",
+		Scopes:              []string{"monitoring-write", "logging-write"},
+	}
+	args, err := additionalCreateInstanceArgs(options, createVMFromVMOptions(options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"--service-account=restricted@p.iam.gserviceaccount.com", "--scopes=monitoring-write,logging-write"} {
+		if !slices.Contains(args, want) {
+			t.Errorf("additionalCreateInstanceArgs() = %v; want it to contain %v", args, want)
+		}
+	}
+
+	options.ServiceAccountEmail, options.Scopes = "", nil
+	args, err = additionalCreateInstanceArgs(options, createVMFromVMOptions(options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--service-account=default@p.iam.gserviceaccount.com"; !slices.Contains(args, want) {
+		t.Errorf("additionalCreateInstanceArgs() = %v; want it to contain %v", args, want)
+	}
+}
+
+func TestSandboxPrefixForServiceAccount(t *testing.T) {
+	orig := sandboxPrefix
+	t.Cleanup(func() { sandboxPrefix = orig })
+	sandboxPrefix = "test-20240110-abcde"
+
+	if got := sandboxPrefixForServiceAccount("restricted@p.iam.gserviceaccount.com"); got != sandboxPrefix {
+		t.Errorf("sandboxPrefixForServiceAccount() for an ordinary account = %q; want %q", got, sandboxPrefix)
+	}
+	external := "build-and-test-external@p.iam.gserviceaccount.com"
+	if got, want := sandboxPrefixForServiceAccount(external), "github-"+sandboxPrefix; got != want {
+		t.Errorf("sandboxPrefixForServiceAccount(%q) = %q; want %q", external, got, want)
+	}
+	vm := createVMFromVMOptions(VMOptions{ImageSpec: "debian-cloud:debian-12", Project: "p", Zone: "z", ServiceAccountEmail: external})
+	if !strings.HasPrefix(vm.Name, "github-"+sandboxPrefix+"-") {
+		t.Errorf("createVMFromVMOptions() named the VM %q; want a name starting with github-%v", vm.Name, sandboxPrefix)
+	}
+
+	// A prefix that already has "github-" is left alone.
+	sandboxPrefix = "github-test-20240110-abcde"
+	if got := sandboxPrefixForServiceAccount(external); got != sandboxPrefix {
+		t.Errorf("sandboxPrefixForServiceAccount(%q) = %q; want %q", external, got, sandboxPrefix)
+	}
+}
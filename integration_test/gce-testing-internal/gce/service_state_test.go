@@ -0,0 +1,55 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForServiceState(t *testing.T) {
+	origGetStatus, origBackoff := getServiceStatus, serviceStateBackoffDuration
+	t.Cleanup(func() {
+		getServiceStatus, serviceStateBackoffDuration = origGetStatus, origBackoff
+	})
+	serviceStateBackoffDuration = time.Millisecond
+
+	states := []string{"inactive", "activating", "active"}
+	getServiceStatus = func(_ context.Context, _ *log.Logger, _ *VM, serviceName string) (serviceStatus, error) {
+		if serviceName != "google-cloud-ops-agent" {
+			t.Errorf("getServiceStatus() called for %q; want google-cloud-ops-agent", serviceName)
+		}
+		state := states[0]
+		if len(states) > 1 {
+			states = states[1:]
+		}
+		return serviceStatus{State: state}, nil
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	vm := &VM{Name: "vm", ImageSpec: "debian-cloud:debian-12"}
+	if err := WaitForServiceState(context.Background(), logger, vm, "google-cloud-ops-agent", "active", time.Minute); err != nil {
+		t.Errorf("WaitForServiceState() failed: %v", err)
+	}
+
+	err := WaitForServiceState(context.Background(), logger, vm, "google-cloud-ops-agent", "inactive", 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), `last observed state was "active"`) {
+		t.Errorf("WaitForServiceState() error = %v; want a timeout mentioning the last observed state", err)
+	}
+}
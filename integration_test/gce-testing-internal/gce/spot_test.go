@@ -0,0 +1,75 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGcloudFlagsForProvisioningModel(t *testing.T) {
+	tests := []struct {
+		name       string
+		timeToLive string
+		spot       bool
+		want       []string
+	}{
+		{
+			name: "standard",
+		},
+		{
+			name:       "time to live",
+			timeToLive: "3h",
+			want:       []string{"--max-run-duration=3h", "--instance-termination-action=DELETE", "--provisioning-model=STANDARD"},
+		},
+		{
+			name: "spot",
+			spot: true,
+			want: []string{"--provisioning-model=SPOT", "--instance-termination-action=DELETE"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := gcloudFlagsForProvisioningModel(tc.timeToLive, tc.spot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("gcloudFlagsForProvisioningModel() = %v; want %v", got, tc.want)
+			}
+		})
+	}
+
+	_, err := gcloudFlagsForProvisioningModel("3h", true)
+	if err == nil || !strings.Contains(err.Error(), "Spot and TimeToLive can't be set together") {
+		t.Errorf("gcloudFlagsForProvisioningModel() with Spot and TimeToLive error = %v; want an error saying they can't be set together", err)
+	}
+}
+
+func TestShouldRetryCreateSpotVM(t *testing.T) {
+	spot := VMOptions{ImageSpec: "debian-cloud:debian-12", Spot: true}
+	standard := VMOptions{ImageSpec: "debian-cloud:debian-12"}
+	preempted := errors.New("Instance was preempted before it became ready")
+
+	if !isSpotCapacityUnavailableError(preempted, spot) || !shouldRetryCreateVM(preempted, spot) {
+		t.Errorf("shouldRetryCreateVM(%v) for a Spot VM = false; want true", preempted)
+	}
+	if isSpotCapacityUnavailableError(preempted, standard) || shouldRetryCreateVM(preempted, standard) {
+		t.Errorf("shouldRetryCreateVM(%v) for a standard VM = true; want false", preempted)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := &lineWriter{mu: &sync.Mutex{}, onLine: func(line string) { lines = append(lines, line) }}
+	for _, chunk := range []string{"fir", "st\nsecond\r\n", "\nthi", "rd"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"first", "second", ""}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines before flush = %q, want %q", lines, want)
+	}
+	w.flush()
+	if want := []string{"first", "second", "", "third"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines after flush = %q, want %q", lines, want)
+	}
+}
+
+func TestRunCommandStreaming(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	var lines []string
+	output, err := runCommandStreaming(context.Background(), logger, nil,
+		[]string{"sh", "-c", "echo out1; echo err1 >&2; printf out2"}, nil,
+		func(line string) { lines = append(lines, line) })
+	if err != nil {
+		t.Fatalf("runCommandStreaming() failed: %v", err)
+	}
+	if output.Stdout != "out1\nout2" || output.Stderr != "err1\n" {
+		t.Errorf("runCommandStreaming() = %#v, want stdout %q and stderr %q", output, "out1\nout2", "err1\n")
+	}
+	// The order of lines from stdout relative to stderr depends on scheduling.
+	sort.Strings(lines)
+	if want := []string{"err1", "out1", "out2"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("runCommandStreaming() streamed lines %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// SyntheticLoadLogPath is the file that GenerateSyntheticLoad appends log
+	// lines to. The agent must be configured to tail it for the logs to be
+	// ingested.
+	SyntheticLoadLogPath = "/tmp/synthetic_load.log"
+	// SyntheticLoadMetric is the name of the gauge that GenerateSyntheticLoad
+	// sends over OTLP/HTTP. Each point has a "series" attribute, so that
+	// metricsPerSec points are sent each second to as many distinct series.
+	SyntheticLoadMetric = "synthetic_load.value"
+	// syntheticLoadOTLPEndpoint is where GenerateSyntheticLoad sends metrics.
+	// This is the default address of the agent's OTLP/HTTP receiver.
+	syntheticLoadOTLPEndpoint = "http://localhost:4318"
+)
+
+// syntheticLoadScript generates logs and metrics at a fixed rate for a fixed
+// amount of time. Each second, it appends a batch of log lines to a file and
+// sends a batch of metric points in a single OTLP/HTTP request.
+const syntheticLoadScript = `#!/bin/bash
+# Usage: synthetic_load.sh <logs/sec> <metrics/sec> <duration in seconds> <log file> <OTLP endpoint>
+set -u
+LOGS_PER_SEC=$1
+METRICS_PER_SEC=$2
+DURATION=$3
+LOG_FILE=$4
+OTLP_ENDPOINT=$5
+
+end=$((SECONDS + DURATION))
+seq_num=0
+while [ "$SECONDS" -lt "$end" ]; do
+  start_ns=$(date +%s%N)
+  for ((i = 0; i < LOGS_PER_SEC; i++)); do
+    echo "synthetic_load seq=$seq_num"
+    seq_num=$((seq_num + 1))
+  done >> "$LOG_FILE"
+  if [ "$METRICS_PER_SEC" -gt 0 ]; then
+    now_ns=$(date +%s%N)
+    points=""
+    for ((i = 0; i < METRICS_PER_SEC; i++)); do
+      points+="${points:+,}{\"asInt\":\"$i\",\"timeUnixNano\":\"$now_ns\",\"attributes\":[{\"key\":\"series\",\"value\":{\"intValue\":\"$i\"}}]}"
+    done
+    curl --silent --show-error --max-time 1 -X POST -H 'Content-Type: application/json' \
+      --data "{\"resourceMetrics\":[{\"scopeMetrics\":[{\"metrics\":[{\"name\":\"` + SyntheticLoadMetric + `\",\"gauge\":{\"dataPoints\":[$points]}}]}]}]}" \
+      "$OTLP_ENDPOINT/v1/metrics" > /dev/null || true
+  fi
+  # Sleep for whatever is left of this second.
+  elapsed_ns=$(( $(date +%s%N) - start_ns ))
+  if [ "$elapsed_ns" -lt 1000000000 ]; then
+    sleep "$(printf '0.%09d' $((1000000000 - elapsed_ns)))"
+  fi
+done
+`
+
+// syntheticLoadCommand returns the command that starts the synthetic load
+// generator at scriptPath in the background and prints its PID.
+func syntheticLoadCommand(scriptPath string, logsPerSec, metricsPerSec int, duration time.Duration) (string, error) {
+	if logsPerSec < 0 || metricsPerSec < 0 {
+		return "", fmt.Errorf("rates must not be negative, got logsPerSec=%d, metricsPerSec=%d", logsPerSec, metricsPerSec)
+	}
+	if logsPerSec == 0 && metricsPerSec == 0 {
+		return "", errors.New("at least one of logsPerSec and metricsPerSec must be positive")
+	}
+	seconds := int(duration.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		return "", fmt.Errorf("duration must be at least 1s, got %v", duration)
+	}
+	return fmt.Sprintf("nohup bash %s %d %d %d '%s' '%s' > %s.out 2>&1 < /dev/null & echo $!",
+		scriptPath, logsPerSec, metricsPerSec, seconds, SyntheticLoadLogPath, syntheticLoadOTLPEndpoint, scriptPath), nil
+}
+
+// GenerateSyntheticLoad starts generating logs and metrics on the given VM at
+// the given rates, in the background, for the given duration. Logs are
+// appended to SyntheticLoadLogPath and metrics are sent as SyntheticLoadMetric
+// to the agent's OTLP/HTTP receiver on localhost:4318.
+//
+// Returns a cleanup function that stops the generator if it is still running.
+// Only Linux VMs are supported.
+func GenerateSyntheticLoad(ctx context.Context, logger *log.Logger, vm *VM, logsPerSec, metricsPerSec int, duration time.Duration) (cleanup func() error, err error) {
+	if IsWindows(vm.ImageSpec) {
+		return nil, errors.New("GenerateSyntheticLoad() does not support Windows")
+	}
+	scriptPath := "/tmp/synthetic_load_" + uuid.NewString() + ".sh"
+	cmd, err := syntheticLoadCommand(scriptPath, logsPerSec, metricsPerSec, duration)
+	if err != nil {
+		return nil, fmt.Errorf("GenerateSyntheticLoad(): %v", err)
+	}
+	if _, err := RunRemotelyStdin(ctx, logger, vm, strings.NewReader(syntheticLoadScript), "cat - > "+scriptPath); err != nil {
+		return nil, fmt.Errorf("GenerateSyntheticLoad() could not upload generator: %w", err)
+	}
+	output, err := RunRemotely(ctx, logger, vm, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("GenerateSyntheticLoad() could not start generator: %w", err)
+	}
+	pid := strings.TrimSpace(output.Stdout)
+	logger.Printf("Started synthetic load generator with PID %s", pid)
+	return func() error {
+		// The generator may have already exited on its own.
+		_, err := RunRemotely(ctx, logger, vm, fmt.Sprintf("kill %s 2>/dev/null || true; rm -f %s", pid, scriptPath))
+		return err
+	}, nil
+}
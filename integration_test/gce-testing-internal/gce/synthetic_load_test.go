@@ -0,0 +1,48 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyntheticLoadCommand(t *testing.T) {
+	cmd, err := syntheticLoadCommand("/tmp/load.sh", 100, 25, 90*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "bash /tmp/load.sh 100 25 90 '/tmp/synthetic_load.log' 'http://localhost:4318'"
+	if !strings.Contains(cmd, want) {
+		t.Errorf("syntheticLoadCommand() = %q; want it to contain %q", cmd, want)
+	}
+	if !strings.HasPrefix(cmd, "nohup ") || !strings.HasSuffix(cmd, "& echo $!") {
+		t.Errorf("syntheticLoadCommand() = %q; want it to run in the background and print its PID", cmd)
+	}
+
+	for _, tc := range []struct {
+		logsPerSec, metricsPerSec int
+		duration                  time.Duration
+	}{
+		{logsPerSec: -1, metricsPerSec: 10, duration: time.Minute},
+		{logsPerSec: 0, metricsPerSec: 0, duration: time.Minute},
+		{logsPerSec: 10, metricsPerSec: 10, duration: 100 * time.Millisecond},
+	} {
+		if cmd, err := syntheticLoadCommand("/tmp/load.sh", tc.logsPerSec, tc.metricsPerSec, tc.duration); err == nil {
+			t.Errorf("syntheticLoadCommand(%d, %d, %v) = %q; want error", tc.logsPerSec, tc.metricsPerSec, tc.duration, cmd)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGcloudFlagsForTags(t *testing.T) {
+	if got, err := gcloudFlagsForTags(nil); err != nil || got != nil {
+		t.Errorf("gcloudFlagsForTags(nil) = (%v, %v), want no flags", got, err)
+	}
+
+	got, err := gcloudFlagsForTags([]string{DenyEgressTrafficTag, "http-server"})
+	if want := []string{"--tags=" + DenyEgressTrafficTag + ",http-server"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("gcloudFlagsForTags() = (%v, %v), want %v", got, err, want)
+	}
+
+	if _, err := gcloudFlagsForTags([]string{"a,b"}); err == nil {
+		t.Error("gcloudFlagsForTags() with a comma in a tag unexpectedly succeeded")
+	}
+}
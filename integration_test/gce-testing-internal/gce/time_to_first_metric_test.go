@@ -0,0 +1,63 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+func TestTimeToFirstMetric(t *testing.T) {
+	since := time.Now().Add(-time.Minute)
+	// The metric already has a stale point from before since, and only gets
+	// a fresh one on the third lookup.
+	lookups := 0
+	fakeListTimeSeries(t, func(*monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+		lookups++
+		if lookups < 3 {
+			return []*monitoringpb.TimeSeries{seriesWithPoints(since.Add(-10 * time.Second))}
+		}
+		return []*monitoringpb.TimeSeries{seriesWithPoints(since.Add(30*time.Second), since.Add(-10*time.Second))}
+	})
+
+	vm := &VM{Name: "vm", Project: "p", ID: 1234}
+	latency, err := TimeToFirstMetric(context.Background(), log.New(io.Discard, "", 0), vm, "agent.googleapis.com/agent/uptime", since, time.Hour, false)
+	if err != nil {
+		t.Fatalf("TimeToFirstMetric() failed: %v", err)
+	}
+	if lookups != 3 {
+		t.Errorf("TimeToFirstMetric() looked up the metric %d times; want 3", lookups)
+	}
+	if latency < time.Minute || latency > time.Since(since) {
+		t.Errorf("TimeToFirstMetric() = %v; want between 1m and %v", latency, time.Since(since))
+	}
+}
+
+func TestTimeToFirstMetricNeverFresh(t *testing.T) {
+	since := time.Now()
+	fakeListTimeSeries(t, func(*monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+		return []*monitoringpb.TimeSeries{seriesWithPoints(since.Add(-10 * time.Second))}
+	})
+
+	vm := &VM{Name: "vm", Project: "p", ID: 1234}
+	if _, err := TimeToFirstMetric(context.Background(), log.New(io.Discard, "", 0), vm, "agent.googleapis.com/agent/uptime", since, time.Hour, false); err == nil {
+		t.Error("TimeToFirstMetric() unexpectedly succeeded")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"testing"
+)
+
+func TestSetTimezoneCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		imageSpec string
+		tz        string
+		expected  string
+		wantErr   bool
+	}{
+		{
+			name:      "linux",
+			imageSpec: "debian-cloud:debian-12",
+			tz:        "America/New_York",
+			expected:  "sudo timedatectl set-timezone 'America/New_York'",
+		},
+		{
+			name:      "linux utc",
+			imageSpec: "debian-cloud:debian-12",
+			tz:        "UTC",
+			expected:  "sudo timedatectl set-timezone 'UTC'",
+		},
+		{
+			name:      "windows",
+			imageSpec: "windows-cloud:windows-2022",
+			tz:        "Eastern Standard Time",
+			expected:  "Set-TimeZone -Id 'Eastern Standard Time'",
+		},
+		{
+			name:      "linux unknown timezone",
+			imageSpec: "debian-cloud:debian-12",
+			tz:        "Mars/Olympus_Mons",
+			wantErr:   true,
+		},
+		{
+			name:      "linux empty timezone",
+			imageSpec: "debian-cloud:debian-12",
+			tz:        "",
+			wantErr:   true,
+		},
+		{
+			name:      "linux shell injection",
+			imageSpec: "debian-cloud:debian-12",
+			tz:        "UTC'; rm -rf /; '",
+			wantErr:   true,
+		},
+		{
+			name:      "windows shell injection",
+			imageSpec: "windows-cloud:windows-2022",
+			tz:        `UTC'; Remove-Item C:\; '`,
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := setTimezoneCommand(tc.imageSpec, tc.tz)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("setTimezoneCommand(%q, %q) = %q; want error", tc.imageSpec, tc.tz, actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("setTimezoneCommand(%q, %q) failed: %v", tc.imageSpec, tc.tz, err)
+			}
+			if actual != tc.expected {
+				t.Errorf("setTimezoneCommand(%q, %q) = %q; want %q", tc.imageSpec, tc.tz, actual, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	cloudtrace "cloud.google.com/go/trace/apiv1/tracepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWaitForTraceSpans(t *testing.T) {
+	origGetTrace, origBackoff := getTrace, queryBackoffDuration
+	t.Cleanup(func() {
+		getTrace, queryBackoffDuration = origGetTrace, origBackoff
+	})
+	queryBackoffDuration = time.Millisecond
+
+	tests := []struct {
+		name string
+		// The spans returned by successive calls to getTrace, after an initial
+		// "not found" error.
+		spanCounts    []int
+		minSpans      int
+		wantGetTraces int
+		wantErr       bool
+	}{
+		{
+			name:          "spans present immediately",
+			spanCounts:    []int{3},
+			minSpans:      1,
+			wantGetTraces: 2,
+		},
+		{
+			name:          "spans arrive gradually",
+			spanCounts:    []int{0, 1, 2, 3},
+			minSpans:      3,
+			wantGetTraces: 5,
+		},
+		{
+			name:          "never enough spans",
+			spanCounts:    []int{1},
+			minSpans:      2,
+			wantGetTraces: TraceQueryMaxAttempts,
+			wantErr:       true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			getTraces := 0
+			getTrace = func(_ context.Context, req *cloudtrace.GetTraceRequest) (*cloudtrace.Trace, error) {
+				getTraces++
+				if getTraces == 1 {
+					return nil, status.Error(codes.NotFound, "trace not found")
+				}
+				count := tc.spanCounts[min(getTraces-2, len(tc.spanCounts)-1)]
+				trace := &cloudtrace.Trace{ProjectId: req.ProjectId, TraceId: req.TraceId}
+				for i := range count {
+					trace.Spans = append(trace.Spans, &cloudtrace.TraceSpan{SpanId: uint64(i + 1)})
+				}
+				return trace, nil
+			}
+
+			trace, err := waitForTraceSpans(context.Background(), log.New(io.Discard, "", 0), "p", "abc", tc.minSpans)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("waitForTraceSpans() = %v; want error", trace)
+				}
+			} else if err != nil {
+				t.Errorf("waitForTraceSpans() failed: %v", err)
+			} else if len(trace.GetSpans()) < tc.minSpans {
+				t.Errorf("waitForTraceSpans() returned %d spans; want at least %d", len(trace.GetSpans()), tc.minSpans)
+			}
+			if getTraces != tc.wantGetTraces {
+				t.Errorf("getTrace was called %d times; want %d", getTraces, tc.wantGetTraces)
+			}
+		})
+	}
+}
+
+func TestWaitForTraceSpansCanceled(t *testing.T) {
+	origGetTrace, origBackoff := getTrace, queryBackoffDuration
+	t.Cleanup(func() {
+		getTrace, queryBackoffDuration = origGetTrace, origBackoff
+	})
+	queryBackoffDuration = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	getTraces := 0
+	getTrace = func(context.Context, *cloudtrace.GetTraceRequest) (*cloudtrace.Trace, error) {
+		getTraces++
+		cancel()
+		return nil, status.Error(codes.NotFound, "trace not found")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := waitForTraceSpans(ctx, log.New(io.Discard, "", 0), "p", "abc", 1)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("waitForTraceSpans() succeeded; want error")
+		}
+		var exhausted *ExhaustedRetriesError
+		if errors.As(err, &exhausted) {
+			t.Errorf("waitForTraceSpans() = %v; want a cancellation error", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("waitForTraceSpans() did not return after ctx was canceled")
+	}
+	if getTraces != 1 {
+		t.Errorf("getTrace was called %d times; want 1", getTraces)
+	}
+}
+
+func TestWaitForTraceMinSpans(t *testing.T) {
+	origLookup, origGetTrace, origBackoff := lookupFirstTrace, getTrace, queryBackoffDuration
+	t.Cleanup(func() {
+		lookupFirstTrace, getTrace, queryBackoffDuration = origLookup, origGetTrace, origBackoff
+	})
+	queryBackoffDuration = time.Millisecond
+
+	lookupFirstTrace = func(_ context.Context, vm *VM, _ WaitForTraceOptions) (*cloudtrace.Trace, error) {
+		return &cloudtrace.Trace{ProjectId: vm.Project, TraceId: "abc"}, nil
+	}
+
+	tests := []struct {
+		name          string
+		minSpans      int
+		wantGetTraces int
+		wantSpans     int
+	}{
+		{
+			name:          "no minimum",
+			minSpans:      0,
+			wantGetTraces: 0,
+			wantSpans:     0,
+		},
+		{
+			name:          "minimum reached after retries",
+			minSpans:      3,
+			wantGetTraces: 3,
+			wantSpans:     3,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			getTraces := 0
+			getTrace = func(_ context.Context, req *cloudtrace.GetTraceRequest) (*cloudtrace.Trace, error) {
+				getTraces++
+				// One more span becomes visible with every call.
+				trace := &cloudtrace.Trace{ProjectId: req.ProjectId, TraceId: req.TraceId}
+				for i := range getTraces {
+					trace.Spans = append(trace.Spans, &cloudtrace.TraceSpan{SpanId: uint64(i + 1)})
+				}
+				return trace, nil
+			}
+
+			vm := &VM{Name: "vm", Project: "p", ID: 1234}
+			trace, err := WaitForTrace(context.Background(), log.New(io.Discard, "", 0), vm, WaitForTraceOptions{MinSpans: tc.minSpans})
+			if err != nil {
+				t.Fatalf("WaitForTrace() failed: %v", err)
+			}
+			if trace.TraceId != "abc" {
+				t.Errorf("WaitForTrace() returned trace %q; want %q", trace.TraceId, "abc")
+			}
+			if got := len(trace.GetSpans()); got != tc.wantSpans {
+				t.Errorf("WaitForTrace() returned %d spans; want %d", got, tc.wantSpans)
+			}
+			if getTraces != tc.wantGetTraces {
+				t.Errorf("getTrace was called %d times; want %d", getTraces, tc.wantGetTraces)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestIsTransientSSHError(t *testing.T) {
+	failed := errors.New("exit status 255")
+	tests := []struct {
+		name   string
+		output CommandOutput
+		err    error
+		want   bool
+	}{
+		{
+			name:   "kex reset",
+			output: CommandOutput{Stderr: "kex_exchange_identification: read: Connection reset by peer\r\n", ExitCode: 255},
+			err:    failed,
+			want:   true,
+		},
+		{
+			name:   "connection refused",
+			output: CommandOutput{Stderr: "ssh: connect to host 10.0.0.2 port 22: Connection refused\r\n", ExitCode: 255},
+			err:    failed,
+			want:   true,
+		},
+		{
+			name:   "dropped after the command started",
+			output: CommandOutput{Stderr: "Connection to 10.0.0.2 closed by remote host.\r\n", ExitCode: 255},
+			err:    failed,
+		},
+		{
+			name:   "command exited nonzero",
+			output: CommandOutput{Stderr: "ssh: connect to host is what my test prints\n", ExitCode: 1},
+			err:    errors.New("exit status 1"),
+		},
+		{
+			name:   "success",
+			output: CommandOutput{Stderr: "kex_exchange_identification: this was a warning"},
+		},
+	}
+	for _, tc := range tests {
+		if got := isTransientSSHError(tc.output, tc.err); got != tc.want {
+			t.Errorf("%s: isTransientSSHError() = %v; want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRetryTransientSSH(t *testing.T) {
+	orig := transientSSHBackoffDuration
+	t.Cleanup(func() { transientSSHBackoffDuration = orig })
+	transientSSHBackoffDuration = time.Millisecond
+
+	ctx := context.Background()
+	logger := log.New(io.Discard, "", 0)
+	transient := CommandOutput{Stderr: "kex_exchange_identification: read: Connection reset by peer", ExitCode: 255}
+	failed := errors.New("exit status 255")
+
+	tests := []struct {
+		name      string
+		results   []CommandOutput
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "succeeds after a transient error",
+			results:   []CommandOutput{transient, {Stdout: "ok"}},
+			wantCalls: 2,
+		},
+		{
+			name:      "gives up after max attempts",
+			results:   []CommandOutput{transient, transient, transient, {Stdout: "ok"}},
+			wantCalls: transientSSHMaxAttempts,
+			wantErr:   true,
+		},
+		{
+			name:      "doesn't retry a command that ran",
+			results:   []CommandOutput{{ExitCode: 1}, {Stdout: "ok"}},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			_, err := retryTransientSSH(ctx, logger, func() (CommandOutput, error) {
+				result := tc.results[calls]
+				calls++
+				if result.ExitCode != 0 {
+					return result, failed
+				}
+				return result, nil
+			})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("retryTransientSSH() error = %v; want error: %v", err, tc.wantErr)
+			}
+			if calls != tc.wantCalls {
+				t.Errorf("retryTransientSSH() ran the command %d times; want %d", calls, tc.wantCalls)
+			}
+		})
+	}
+}
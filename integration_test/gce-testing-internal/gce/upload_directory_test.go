@@ -0,0 +1,140 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeFixtureDir creates a directory with a script, a nested file and
+// optionally a symlink, and returns its path.
+func makeFixtureDir(t *testing.T, symlink bool) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "conf"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "conf", "config.yaml"), []byte("logging: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if symlink {
+		if err := os.Symlink("conf/config.yaml", filepath.Join(dir, "config.yaml")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestWriteTarGz(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTarGz(&buf, makeFixtureDir(t, true)); err != nil {
+		t.Fatalf("writeTarGz() failed: %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	got := make(map[string]*tar.Header)
+	contents := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[header.Name] = header
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[header.Name] = string(data)
+	}
+	if len(got) != 4 {
+		t.Errorf("writeTarGz() wrote entries %v, want 4", got)
+	}
+	if h := got["run.sh"]; h == nil || h.Mode&0777 != 0755 || contents["run.sh"] != "#!/bin/sh\n" {
+		t.Errorf("writeTarGz() run.sh = %+v, want an executable script", h)
+	}
+	if h := got["conf/"]; h == nil || h.Typeflag != tar.TypeDir {
+		t.Errorf("writeTarGz() conf/ = %+v, want a directory", h)
+	}
+	if contents["conf/config.yaml"] != "logging: {}\n" {
+		t.Errorf("writeTarGz() conf/config.yaml contents = %q, want %q", contents["conf/config.yaml"], "logging: {}\n")
+	}
+	if h := got["config.yaml"]; h == nil || h.Typeflag != tar.TypeSymlink || h.Linkname != "conf/config.yaml" {
+		t.Errorf("writeTarGz() config.yaml = %+v, want a symlink to conf/config.yaml", h)
+	}
+}
+
+func TestWriteZip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeZip(&buf, makeFixtureDir(t, false)); err != nil {
+		t.Fatalf("writeZip() failed: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[f.Name] = string(data)
+	}
+	want := map[string]string{"run.sh": "#!/bin/sh\n", "conf/": "", "conf/config.yaml": "logging: {}\n"}
+	if len(contents) != len(want) {
+		t.Errorf("writeZip() wrote %v, want %v", contents, want)
+	}
+	for name, data := range want {
+		if got, ok := contents[name]; !ok || got != data {
+			t.Errorf("writeZip() %s = %q (present=%v), want %q", name, got, ok, data)
+		}
+	}
+
+	if err := writeZip(io.Discard, makeFixtureDir(t, true)); err == nil {
+		t.Error("writeZip() with a symlink succeeded, want an error")
+	}
+}
+
+func TestExtractArchiveCommand(t *testing.T) {
+	if got, want := extractArchiveCommand("debian-cloud:debian-12", "/tmp/a.tar.gz", "/opt/my fixtures"),
+		"sudo mkdir -p '/opt/my fixtures' && sudo tar --no-same-owner -xzf '/tmp/a.tar.gz' -C '/opt/my fixtures' && sudo rm -f '/tmp/a.tar.gz'"; got != want {
+		t.Errorf("extractArchiveCommand() on Linux = %q, want %q", got, want)
+	}
+	if got, want := extractArchiveCommand("windows-cloud:windows-2022", `C:\tmp\a.zip`, `C:\fixtures`),
+		"$ErrorActionPreference = 'Stop'\nExpand-Archive -Path 'C:\\tmp\\a.zip' -DestinationPath 'C:\\fixtures' -Force\nRemove-Item -Path 'C:\\tmp\\a.zip'"; got != want {
+		t.Errorf("extractArchiveCommand() on Windows = %q, want %q", got, want)
+	}
+}
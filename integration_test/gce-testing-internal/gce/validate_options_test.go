@@ -0,0 +1,125 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVMOptionsValidate(t *testing.T) {
+	const linux = "debian-cloud:debian-12"
+	const windows = "windows-cloud:windows-2022"
+	tests := []struct {
+		name    string
+		options VMOptions
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			options: VMOptions{ImageSpec: linux, TimeToLive: "1d2h", Metadata: map[string]string{"foo": "bar"}, Labels: map[string]string{"foo": "bar"}, Tags: []string{"a", "b"}, StartupScript: "true"},
+		},
+		{
+			name:    "valid windows",
+			options: VMOptions{ImageSpec: windows, Metadata: map[string]string{"windows-startup-script-ps1": "x"}, SysprepScript: "echo hi"},
+		},
+		{
+			name:    "windows-only metadata on linux",
+			options: VMOptions{ImageSpec: linux, Metadata: map[string]string{"enable-windows-ssh": "FALSE", "sysprep-specialize-script-cmd": "x"}},
+		},
+		{
+			name:    "linux-only metadata on windows",
+			options: VMOptions{ImageSpec: windows, Metadata: map[string]string{"startup-script": "x"}},
+		},
+		{
+			name:    "unparseable image spec",
+			options: VMOptions{ImageSpec: "debian-12"},
+			wantErr: "invalid imageSpec",
+		},
+		{
+			name:    "bad time to live",
+			options: VMOptions{ImageSpec: linux, TimeToLive: "3 hours"},
+			wantErr: "invalid VMOptions.TimeToLive",
+		},
+		{
+			name:    "spot with time to live",
+			options: VMOptions{ImageSpec: linux, TimeToLive: "3h", Spot: true},
+			wantErr: "Spot and TimeToLive can't be set together",
+		},
+		{
+			name:    "reserved metadata",
+			options: VMOptions{ImageSpec: linux, Metadata: map[string]string{"ssh-keys": "x"}},
+			wantErr: `the 'ssh-keys' metadata key is reserved`,
+		},
+		{
+			name:    "reserved linux metadata",
+			options: VMOptions{ImageSpec: linux, Metadata: map[string]string{"startup-script": "x"}},
+			wantErr: `the 'startup-script' metadata key is reserved`,
+		},
+		{
+			name:    "reserved windows metadata",
+			options: VMOptions{ImageSpec: windows, Metadata: map[string]string{"enable-windows-ssh": "FALSE"}},
+			wantErr: `the 'enable-windows-ssh' metadata key is reserved`,
+		},
+		{
+			name:    "reserved label",
+			options: VMOptions{ImageSpec: linux, Labels: map[string]string{"kokoro_build_id": "1"}},
+			wantErr: `the "kokoro_build_id" key is reserved`,
+		},
+		{
+			name:    "startup script on windows",
+			options: VMOptions{ImageSpec: windows, StartupScript: "true"},
+			wantErr: "StartupScript is not supported on Windows",
+		},
+		{
+			name:    "sysprep script on linux",
+			options: VMOptions{ImageSpec: linux, SysprepScript: "echo hi"},
+			wantErr: "SysprepScript is only supported on Windows",
+		},
+		{
+			name:    "valid service account",
+			options: VMOptions{ImageSpec: linux, ServiceAccountEmail: "restricted@p.iam.gserviceaccount.com", Scopes: []string{"cloud-platform"}},
+		},
+		{
+			name:    "bad service account",
+			options: VMOptions{ImageSpec: linux, ServiceAccountEmail: "restricted"},
+			wantErr: "invalid VMOptions.ServiceAccountEmail",
+		},
+		{
+			name:    "scope with comma",
+			options: VMOptions{ImageSpec: linux, Scopes: []string{"a,b"}},
+			wantErr: "invalid VMOptions.Scopes",
+		},
+		{
+			name:    "tag with comma",
+			options: VMOptions{ImageSpec: linux, Tags: []string{"a,b"}},
+			wantErr: "invalid VMOptions.Tags",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v; want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Validate() = %v; want an error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
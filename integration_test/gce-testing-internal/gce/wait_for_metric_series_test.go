@@ -0,0 +1,74 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+)
+
+func TestWaitForMetricSeriesWithOpts(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		wantErr     bool
+		wantCalls   int
+	}{
+		{
+			name:        "found before running out of attempts",
+			maxAttempts: 5,
+			wantCalls:   3,
+		},
+		{
+			name:        "fails fast",
+			maxAttempts: 2,
+			wantErr:     true,
+			wantCalls:   2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			fakeListTimeSeries(t, func(*monitoringpb.ListTimeSeriesRequest) []*monitoringpb.TimeSeries {
+				calls++
+				if calls < 3 {
+					return nil
+				}
+				return []*monitoringpb.TimeSeries{seriesWithPoints(time.Now())}
+			})
+
+			vm := &VM{Name: "vm", Project: "p", ID: 1234}
+			opts := WaitForMetricSeriesOpts{MaxAttempts: tc.maxAttempts, Backoff: time.Millisecond}
+			series, err := WaitForMetricSeriesWithOpts(context.Background(), log.New(io.Discard, "", 0), vm, "agent.googleapis.com/agent/uptime", time.Hour, nil, false, opts)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), exhaustedRetriesSuffix) {
+					t.Errorf("WaitForMetricSeriesWithOpts() = %v; want an error about running out of retries", err)
+				}
+			} else if err != nil || len(series) != 1 {
+				t.Errorf("WaitForMetricSeriesWithOpts() = (%v, %v); want one series", series, err)
+			}
+			if calls != tc.wantCalls {
+				t.Errorf("WaitForMetricSeriesWithOpts() looked up the metric %d times; want %d", calls, tc.wantCalls)
+			}
+		})
+	}
+}
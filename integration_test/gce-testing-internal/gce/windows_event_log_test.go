@@ -0,0 +1,80 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"context"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWindowsEventLog(t *testing.T) {
+	stdout := `[{"ProviderName":"Service Control Manager","Id":7036,"LevelDisplayName":"Information","TimeCreated":"2024-01-10T16:00:00.0000000Z","Message":"The Google Cloud Ops Agent service entered the running state."},` +
+		`{"ProviderName":"google-cloud-ops-agent","Id":1,"LevelDisplayName":"Error","TimeCreated":"2024-01-10T16:00:01.5000000Z","Message":"oops"}]` + "\r\n"
+	got, err := parseWindowsEventLog(stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EventLogEntry{
+		{
+			ProviderName: "Service Control Manager",
+			ID:           7036,
+			Level:        "Information",
+			TimeCreated:  time.Date(2024, 1, 10, 16, 0, 0, 0, time.UTC),
+			Message:      "The Google Cloud Ops Agent service entered the running state.",
+		},
+		{
+			ProviderName: "google-cloud-ops-agent",
+			ID:           1,
+			Level:        "Error",
+			TimeCreated:  time.Date(2024, 1, 10, 16, 0, 1, 500_000_000, time.UTC),
+			Message:      "oops",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWindowsEventLog() = %+v; want %+v", got, want)
+	}
+
+	if got, err := parseWindowsEventLog("[]"); err != nil || len(got) != 0 {
+		t.Errorf("parseWindowsEventLog(\"[]\") = %v, %v; want no entries", got, err)
+	}
+	if _, err := parseWindowsEventLog("Get-WinEvent : oops"); err == nil {
+		t.Error("parseWindowsEventLog() with non-JSON output succeeded; want error")
+	}
+}
+
+func TestWindowsEventLogCommand(t *testing.T) {
+	cmd := windowsEventLogCommand("Microsoft-Windows-PowerShell/Operational", 90*time.Second+time.Millisecond)
+	for _, want := range []string{"LogName='Microsoft-Windows-PowerShell/Operational'", "AddSeconds(-91)", "NoMatchingEventsFound", "ConvertTo-Json"} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("windowsEventLogCommand() = %s; want it to contain %q", cmd, want)
+		}
+	}
+	if cmd := windowsEventLogCommand("it's", time.Minute); !strings.Contains(cmd, "LogName='it''s'") {
+		t.Errorf("windowsEventLogCommand() = %s; want the log name quoted", cmd)
+	}
+}
+
+func TestGetWindowsEventLogNotWindows(t *testing.T) {
+	vm := &VM{Name: "vm", ImageSpec: "debian-cloud:debian-12"}
+	_, err := GetWindowsEventLog(context.Background(), log.New(io.Discard, "", 0), vm, "System", time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "is not running Windows") {
+		t.Errorf("GetWindowsEventLog() on a Linux VM = %v; want an error saying it is not running Windows", err)
+	}
+}
@@ -17,6 +17,7 @@ package gce
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -43,6 +44,44 @@ func (wrr *weightedRoundRobin) Next() string {
 	return wrr.sw.Next().(string)
 }
 
+// NextExcluding() selects the next zone to spawn a VM in, skipping any of the
+// given zones. The relative weights of the remaining zones are preserved.
+// If every zone is excluded, it behaves like Next().
+// It is thread safe.
+func (wrr *weightedRoundRobin) NextExcluding(zones ...string) string {
+	wrr.mutex.Lock()
+	defer wrr.mutex.Unlock()
+
+	// A full cycle of the smooth weighted round robin algorithm returns each
+	// zone as many times as its weight, so if no acceptable zone comes up in
+	// that many picks, there is none.
+	totalWeight := 0
+	for _, weight := range wrr.sw.All() {
+		totalWeight += weight
+	}
+	for i := 0; i < totalWeight; i++ {
+		zone := wrr.sw.Next().(string)
+		if !slices.Contains(zones, zone) {
+			return zone
+		}
+	}
+	return wrr.sw.Next().(string)
+}
+
+// Zones() returns all of the zones that can be picked, in sorted order.
+// It is thread safe.
+func (wrr *weightedRoundRobin) Zones() []string {
+	wrr.mutex.Lock()
+	defer wrr.mutex.Unlock()
+
+	var zones []string
+	for zone := range wrr.sw.All() {
+		zones = append(zones, zone.(string))
+	}
+	slices.Sort(zones)
+	return zones
+}
+
 // newZonePicker looks at `zones` and extracts the zones and weights into a a
 // weighted round robin zone picker.  `zones` should be a comma-separated list
 // of zone specs, where each zone spec is either in the format
@@ -0,0 +1,97 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gce
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestZones(t *testing.T) {
+	picker, err := newZonePicker("zone-c=3,zone-a=1,zone-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := picker.Zones(), []string{"zone-a", "zone-b", "zone-c"}; !slices.Equal(got, want) {
+		t.Errorf("Zones() = %v; want %v", got, want)
+	}
+}
+
+func TestNextExcluding(t *testing.T) {
+	picker, err := newZonePicker("zone-a=1,zone-b=2,zone-c=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Over a whole number of cycles, zone-b and zone-c should be picked in
+	// proportion to their weights.
+	counts := make(map[string]int)
+	for i := 0; i < 60; i++ {
+		counts[picker.NextExcluding("zone-a")]++
+	}
+	if counts["zone-a"] != 0 {
+		t.Errorf("NextExcluding(zone-a) picked zone-a %d times", counts["zone-a"])
+	}
+	if counts["zone-b"] == 0 || counts["zone-c"] == 0 {
+		t.Fatalf("NextExcluding(zone-a) picks = %v; want both zone-b and zone-c", counts)
+	}
+	if ratio := float64(counts["zone-c"]) / float64(counts["zone-b"]); ratio < 1.3 || ratio > 1.7 {
+		t.Errorf("NextExcluding(zone-a) picks = %v; want zone-c picked about 1.5x as often as zone-b", counts)
+	}
+
+	// Excluding every zone falls back to picking any zone.
+	if zone := picker.NextExcluding("zone-a", "zone-b", "zone-c"); zone == "" {
+		t.Error("NextExcluding() with every zone excluded returned an empty zone")
+	}
+}
+
+func TestIsZoneResourceExhaustedError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "currently unavailable stockout",
+			err:      errors.New("A t2a-standard-4 VM instance is currently unavailable in the us-central1-a zone"),
+			expected: true,
+		},
+		{
+			name:     "zone resource pool exhausted",
+			err:      errors.New("code: ZONE_RESOURCE_POOL_EXHAUSTED_WITH_DETAILS"),
+			expected: true,
+		},
+		{
+			name:     "not enough resources",
+			err:      errors.New("The zone 'projects/p/zones/us-central1-a' does not have enough resources available to fulfill the request."),
+			expected: true,
+		},
+		{
+			name:     "quota error",
+			err:      errors.New("Quota 'CPUS' exceeded"),
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := isZoneResourceExhaustedError(tc.err)
+			if actual != tc.expected {
+				t.Errorf("isZoneResourceExhaustedError(%v) = %v; want %v", tc.err, actual, tc.expected)
+			}
+		})
+	}
+}
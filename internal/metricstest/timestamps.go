@@ -0,0 +1,70 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metricstest holds assertions on scraped metrics that are shared by
+// the receivers' tests.
+package metricstest
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// AssertMonotonicTimestamps checks that no data point timestamp in each of
+// the given scrapes is older than any data point timestamp of the previous
+// scrape.
+func AssertMonotonicTimestamps(t *testing.T, scrapes ...pmetric.Metrics) {
+	t.Helper()
+	var previousMax pcommon.Timestamp
+	for i, scrape := range scrapes {
+		timestamps := dataPointTimestamps(scrape)
+		require.NotEmpty(t, timestamps, "scrape %d has no data points", i)
+		for _, ts := range timestamps {
+			require.GreaterOrEqual(t, ts, previousMax, "scrape %d has a data point at %v, before the previous scrape's %v", i, ts, previousMax)
+		}
+		previousMax = slices.Max(timestamps)
+	}
+}
+
+// dataPointTimestamps returns the timestamps of all data points in the given
+// metrics.
+func dataPointTimestamps(metrics pmetric.Metrics) []pcommon.Timestamp {
+	var timestamps []pcommon.Timestamp
+	rms := metrics.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			ms := sms.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				var dps pmetric.NumberDataPointSlice
+				switch m := ms.At(k); m.Type() {
+				case pmetric.MetricTypeGauge:
+					dps = m.Gauge().DataPoints()
+				case pmetric.MetricTypeSum:
+					dps = m.Sum().DataPoints()
+				default:
+					continue
+				}
+				for l := 0; l < dps.Len(); l++ {
+					timestamps = append(timestamps, dps.At(l).Timestamp())
+				}
+			}
+		}
+	}
+	return timestamps
+}
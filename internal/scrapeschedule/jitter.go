@@ -0,0 +1,140 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scrapeschedule holds the scrape timing options shared by the GPU
+// receivers.
+package scrapeschedule
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Jitter returns a random duration in [0, bound), or 0 if bound is not
+// positive. It is safe for concurrent use.
+func Jitter(bound time.Duration) time.Duration {
+	if bound <= 0 {
+		return 0
+	}
+	return rand.N(bound)
+}
+
+// Ticker ticks every interval, with each tick delayed by a random jitter of
+// up to a bound, which keeps collectors that scrape at the same interval from
+// staying in sync. It is meant for scraperhelper.WithTickerChannel, so that
+// the jitter is spent between scrapes rather than inside them, where it would
+// count against the scrape timeout.
+type Ticker struct {
+	// C delivers the ticks. Like time.Ticker, a tick is dropped if the
+	// previous one has not been received yet.
+	C <-chan time.Time
+
+	c        chan time.Time
+	interval time.Duration
+	bound    time.Duration
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	// done is closed by Stop, and exited is closed when the ticking
+	// goroutine has returned.
+	done   chan struct{}
+	exited chan struct{}
+}
+
+// NewTicker returns a Ticker that ticks every interval, each tick delayed by
+// a random jitter in [0, bound). It does not tick until Start is called. The
+// bound must be shorter than interval, which ValidateJitter checks.
+func NewTicker(interval, bound time.Duration) *Ticker {
+	c := make(chan time.Time, 1)
+	return &Ticker{
+		C:        c,
+		c:        c,
+		interval: interval,
+		bound:    bound,
+		done:     make(chan struct{}),
+		exited:   make(chan struct{}),
+	}
+}
+
+// Start starts the ticks. The first one comes one interval, plus jitter,
+// after Start is called. Calling Start again, or after Stop, has no effect.
+func (t *Ticker) Start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started || t.stopped {
+		return
+	}
+	t.started = true
+	go t.run()
+}
+
+// Stop stops the ticks. No tick is sent after Stop returns, though one sent
+// before may still be waiting in C. It is safe to call Stop more than once,
+// and whether or not Start was called.
+func (t *Ticker) Stop() {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+	t.stopped = true
+	close(t.done)
+	started := t.started
+	t.mu.Unlock()
+	if started {
+		<-t.exited
+	}
+}
+
+func (t *Ticker) run() {
+	defer close(t.exited)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		}
+		timer := time.NewTimer(Jitter(t.bound))
+		select {
+		case <-t.done:
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			select {
+			case t.c <- now:
+			default:
+			}
+		}
+	}
+}
+
+// ValidateJitter checks the initial_delay_jitter and interval_jitter options
+// against the receiver's collection_interval.
+func ValidateJitter(initialDelayJitter, intervalJitter, collectionInterval time.Duration) error {
+	if initialDelayJitter < 0 {
+		return fmt.Errorf("invalid initial_delay_jitter %v: must not be negative", initialDelayJitter)
+	}
+	if intervalJitter < 0 {
+		return fmt.Errorf("invalid interval_jitter %v: must not be negative", intervalJitter)
+	}
+	if intervalJitter > 0 && intervalJitter >= collectionInterval {
+		return fmt.Errorf("invalid interval_jitter %v: must be shorter than collection_interval %v", intervalJitter, collectionInterval)
+	}
+	return nil
+}
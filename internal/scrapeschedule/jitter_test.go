@@ -0,0 +1,90 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrapeschedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJitterStaysWithinBound(t *testing.T) {
+	bound := 3 * time.Second
+	for i := 0; i < 1000; i++ {
+		d := Jitter(bound)
+		require.GreaterOrEqual(t, d, time.Duration(0))
+		require.Less(t, d, bound)
+	}
+	assert.Equal(t, time.Duration(0), Jitter(0))
+	assert.Equal(t, time.Duration(0), Jitter(-time.Second))
+}
+
+func TestTicker(t *testing.T) {
+	interval := 20 * time.Millisecond
+	ticker := NewTicker(interval, interval/2)
+	defer ticker.Stop()
+
+	select {
+	case <-ticker.C:
+		t.Fatal("Ticker ticked before Start")
+	case <-time.After(2 * interval):
+	}
+
+	ticker.Start()
+	ticker.Start()
+	last := time.Now()
+	for i := 0; i < 5; i++ {
+		select {
+		case tick := <-ticker.C:
+			require.True(t, tick.After(last), "tick %d at %v, not after %v", i, tick, last)
+			last = tick
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no tick %d within 10s", i)
+		}
+	}
+}
+
+func TestTickerStop(t *testing.T) {
+	ticker := NewTicker(time.Millisecond, 0)
+	// Stopping a ticker that was never started must not block or panic.
+	NewTicker(time.Millisecond, 0).Stop()
+
+	ticker.Start()
+	<-ticker.C
+	ticker.Stop()
+	ticker.Stop()
+	// Drain a tick that may have been sent before Stop.
+	select {
+	case <-ticker.C:
+	default:
+	}
+	select {
+	case <-ticker.C:
+		t.Fatal("Ticker ticked after Stop")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestValidateJitter(t *testing.T) {
+	interval := 10 * time.Second
+	require.NoError(t, ValidateJitter(0, 0, interval))
+	require.NoError(t, ValidateJitter(time.Minute, interval/2, interval))
+
+	require.ErrorContains(t, ValidateJitter(0, interval, interval), "must be shorter than collection_interval")
+	require.ErrorContains(t, ValidateJitter(0, -time.Second, interval), "invalid interval_jitter")
+	require.ErrorContains(t, ValidateJitter(-time.Second, 0, interval), "invalid initial_delay_jitter")
+}